@@ -0,0 +1,86 @@
+// Command replay re-announces stored users on NATS so a downstream service
+// can rebuild its projection:
+//
+//	replay -config config/local.yml -checkpoint billing-rebuild -rate 500
+//
+// Runs with a -checkpoint name can be stopped and started again; they
+// continue after the last saved batch.
+package main
+
+import (
+	"context"
+	"flag"
+	"github.com/sorawaslocked/ap2final_base/pkg/logger"
+	"github.com/sorawaslocked/ap2final_user_service/internal/app"
+	"github.com/sorawaslocked/ap2final_user_service/internal/config"
+	"github.com/sorawaslocked/ap2final_user_service/internal/model"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+func main() {
+	checkpoint := flag.String("checkpoint", "", "name of the run to save and resume; empty starts over every time")
+	event := flag.String("event", model.ReplayEventSnapshot, "event to publish: snapshot or register")
+	createdAfter := flag.String("created-after", "", "only users created after this RFC 3339 time")
+	role := flag.String("role", "", "only users with this role")
+	batchSize := flag.Int64("batch-size", 0, "users read per batch")
+	rate := flag.Int("rate", 0, "events published per second")
+	dryRun := flag.Bool("dry-run", false, "count the users without publishing")
+
+	// MustLoad parses the flags.
+	cfg := config.MustLoad()
+
+	log := logger.SetupLogger(cfg.Env)
+
+	opts := model.ReplayOptions{
+		Checkpoint:    *checkpoint,
+		Event:         *event,
+		Role:          *role,
+		BatchSize:     *batchSize,
+		RatePerSecond: *rate,
+		DryRun:        *dryRun,
+	}
+
+	if *createdAfter != "" {
+		t, err := time.Parse(time.RFC3339, *createdAfter)
+		if err != nil {
+			log.Error("parsing created-after", logger.Err(err))
+			os.Exit(2)
+		}
+
+		opts.CreatedAfter = t
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	replay, err := app.NewReplay(ctx, cfg, log)
+	if err != nil {
+		log.Error("failed to initialize replay", logger.Err(err))
+		os.Exit(1)
+	}
+
+	progress, err := replay.Run(ctx, opts, func(progress model.ReplayProgress) error {
+		log.Info(
+			"replayed batch",
+			slog.Int64("replayed", progress.Replayed),
+			slog.String("lastUserID", progress.LastUserID),
+		)
+
+		return nil
+	})
+	if err != nil {
+		log.Error(
+			"replay stopped",
+			logger.Err(err),
+			slog.Int64("replayed", progress.Replayed),
+			slog.String("lastUserID", progress.LastUserID),
+		)
+		os.Exit(1)
+	}
+
+	log.Info("replay finished", slog.Int64("replayed", progress.Replayed), slog.Bool("dryRun", progress.DryRun))
+}
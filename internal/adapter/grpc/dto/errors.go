@@ -3,8 +3,10 @@ package dto
 import (
 	"errors"
 	"github.com/sorawaslocked/ap2final_user_service/internal/model"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"strconv"
 )
 
 var (
@@ -14,6 +16,16 @@ var (
 )
 
 func FromError(err error) error {
+	var validationErr *model.ValidationError
+	if errors.As(err, &validationErr) {
+		return fromValidationError(validationErr)
+	}
+
+	var conflictErr *model.VersionConflictError
+	if errors.As(err, &conflictErr) {
+		return fromVersionConflictError(conflictErr)
+	}
+
 	switch {
 	case errors.Is(err, ErrMissingPasswordArgument):
 		return status.Error(codes.InvalidArgument, err.Error())
@@ -21,13 +33,84 @@ func FromError(err error) error {
 		return status.Error(codes.InvalidArgument, err.Error())
 	case errors.Is(err, model.ErrNotFound):
 		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, model.ErrAlreadyExists):
+		return status.Error(codes.AlreadyExists, err.Error())
 	case errors.Is(err, ErrUnauthenticated):
 		return status.Error(codes.Unauthenticated, err.Error())
 	case errors.Is(err, model.ErrPasswordsDoNotMatch):
 		return status.Error(codes.InvalidArgument, err.Error())
 	case errors.Is(err, model.ErrRefreshTokenExpired):
 		return status.Error(codes.DeadlineExceeded, err.Error())
+	case errors.Is(err, model.ErrInvalidSearchQuery):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, model.ErrAccountSuspended):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, model.ErrNotSuspended):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, model.ErrInvalidSuspension):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, model.ErrPhoneNotSet), errors.Is(err, model.ErrAlreadyVerified):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, model.ErrInvalidVerificationCode):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, model.ErrVerificationExpired):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	case errors.Is(err, model.ErrTooManyAttempts):
+		return status.Error(codes.ResourceExhausted, err.Error())
+	case errors.Is(err, model.ErrEmailChangeRequiresVerification):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, model.ErrUnsupportedImage), errors.Is(err, model.ErrImageTooLarge):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, model.ErrRegistrationClosed):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, model.ErrInvitationExpired):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	case errors.Is(err, model.ErrAlreadyMember), errors.Is(err, model.ErrLastOwner):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, model.ErrNotMember):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, model.ErrUnauthorized):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, model.ErrInvalidToken), errors.Is(err, model.ErrEmptyClaims):
+		return status.Error(codes.Unauthenticated, err.Error())
 	default:
 		return status.Error(codes.Internal, "something went wrong")
 	}
 }
+
+func fromValidationError(err *model.ValidationError) error {
+	badRequest := &errdetails.BadRequest{
+		FieldViolations: make([]*errdetails.BadRequest_FieldViolation, len(err.Violations)),
+	}
+
+	for i, violation := range err.Violations {
+		badRequest.FieldViolations[i] = &errdetails.BadRequest_FieldViolation{
+			Field:       violation.Field,
+			Description: violation.Description,
+		}
+	}
+
+	st, detailsErr := status.New(codes.InvalidArgument, err.Error()).WithDetails(badRequest)
+	if detailsErr != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	return st.Err()
+}
+
+func fromVersionConflictError(err *model.VersionConflictError) error {
+	errorInfo := &errdetails.ErrorInfo{
+		Reason: "VERSION_CONFLICT",
+		Domain: "user_svc",
+		Metadata: map[string]string{
+			"currentVersion": strconv.FormatInt(err.CurrentVersion, 10),
+		},
+	}
+
+	st, detailsErr := status.New(codes.Aborted, err.Error()).WithDetails(errorInfo)
+	if detailsErr != nil {
+		return status.Error(codes.Aborted, err.Error())
+	}
+
+	return st.Err()
+}
@@ -0,0 +1,121 @@
+package dto
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/sorawaslocked/ap2final_user_service/internal/model"
+	"time"
+)
+
+// ExportSchemaVersion is bumped whenever the layout of the export document changes.
+const ExportSchemaVersion = "2"
+
+type exportDocument struct {
+	SchemaVersion string          `json:"schemaVersion"`
+	GeneratedAt   time.Time       `json:"generatedAt"`
+	Profile       exportProfile   `json:"profile"`
+	Sessions      []exportSession `json:"sessions"`
+}
+
+type exportProfile struct {
+	ID          string     `json:"id"`
+	FirstName   string     `json:"firstName"`
+	LastName    string     `json:"lastName"`
+	Email       string     `json:"email"`
+	PhoneNumber string     `json:"phoneNumber"`
+	Role        string     `json:"role"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	UpdatedAt   time.Time  `json:"updatedAt"`
+	DeletedAt   *time.Time `json:"deletedAt,omitempty"`
+	IsDeleted   bool       `json:"isDeleted"`
+	IsActive    bool       `json:"isActive"`
+
+	Locale      string            `json:"locale,omitempty"`
+	Preferences exportPreferences `json:"preferences"`
+	Attributes  map[string]any    `json:"attributes,omitempty"`
+}
+
+type exportPreferences struct {
+	Timezone       string `json:"timezone,omitempty"`
+	Theme          string `json:"theme,omitempty"`
+	MarketingOptIn bool   `json:"marketingOptIn"`
+}
+
+// exportSession leaves out the refresh token itself, which is a credential.
+type exportSession struct {
+	CreatedAt time.Time `json:"createdAt"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// FromUserDataExportToFile renders the export as a JSON document, optionally
+// zipped, and returns its content together with a file name and content type.
+func FromUserDataExportToFile(export model.UserDataExport, compress bool) ([]byte, string, string, error) {
+	doc := exportDocument{
+		SchemaVersion: ExportSchemaVersion,
+		GeneratedAt:   export.GeneratedAt,
+		Profile: exportProfile{
+			ID:          export.User.ID,
+			FirstName:   export.User.FirstName,
+			LastName:    export.User.LastName,
+			Email:       export.User.Email,
+			PhoneNumber: export.User.PhoneNumber,
+			Role:        export.User.Role,
+			CreatedAt:   export.User.CreatedAt,
+			UpdatedAt:   export.User.UpdatedAt,
+			IsDeleted:   export.User.IsDeleted,
+			IsActive:    export.User.IsActive,
+
+			Locale: export.User.Locale,
+			Preferences: exportPreferences{
+				Timezone:       export.User.Preferences.Timezone,
+				Theme:          export.User.Preferences.Theme,
+				MarketingOptIn: export.User.Preferences.MarketingOptIn,
+			},
+			Attributes: export.User.Attributes,
+		},
+		Sessions: make([]exportSession, len(export.Sessions)),
+	}
+
+	if !export.User.DeletedAt.IsZero() {
+		doc.Profile.DeletedAt = &export.User.DeletedAt
+	}
+
+	for i, session := range export.Sessions {
+		doc.Sessions[i] = exportSession{
+			CreatedAt: session.CreatedAt,
+			ExpiresAt: session.ExpiresAt,
+		}
+	}
+
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	fileName := fmt.Sprintf("user-%s-export.json", export.User.ID)
+
+	if !compress {
+		return data, fileName, "application/json", nil
+	}
+
+	var buf bytes.Buffer
+
+	zw := zip.NewWriter(&buf)
+
+	w, err := zw.Create(fileName)
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	if _, err = w.Write(data); err != nil {
+		return nil, "", "", err
+	}
+
+	if err = zw.Close(); err != nil {
+		return nil, "", "", err
+	}
+
+	return buf.Bytes(), fileName + ".zip", "application/zip", nil
+}
@@ -0,0 +1,27 @@
+package dto
+
+import (
+	svc "github.com/sorawaslocked/ap2final_protos_gen/service/user"
+	"github.com/sorawaslocked/ap2final_user_service/internal/model"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func FromGroupToPb(group model.Group) *svc.Group {
+	return &svc.Group{
+		ID:             group.ID,
+		Name:           group.Name,
+		OrganizationID: group.TenantID,
+		CreatedAt:      timestamppb.New(group.CreatedAt),
+		UpdatedAt:      timestamppb.New(group.UpdatedAt),
+	}
+}
+
+func FromGroupsToPb(groups []model.Group) []*svc.Group {
+	pbGroups := make([]*svc.Group, len(groups))
+
+	for i, group := range groups {
+		pbGroups[i] = FromGroupToPb(group)
+	}
+
+	return pbGroups
+}
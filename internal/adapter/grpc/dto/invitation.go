@@ -0,0 +1,61 @@
+package dto
+
+import (
+	svc "github.com/sorawaslocked/ap2final_protos_gen/service/user"
+	"github.com/sorawaslocked/ap2final_user_service/internal/model"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func ToInvitationFromCreateRequest(req *svc.CreateInvitationRequest) model.Invitation {
+	invitation := model.Invitation{
+		Email: req.Email,
+		Role:  req.Role,
+	}
+
+	if req.ExpiresAt != nil {
+		invitation.ExpiresAt = req.ExpiresAt.AsTime()
+	}
+
+	return invitation
+}
+
+func ToInvitationListingFromRequest(req *svc.ListInvitationsRequest) model.InvitationListing {
+	return model.InvitationListing{
+		Status: req.Status,
+		Limit:  req.Limit,
+		Offset: req.Offset,
+	}
+}
+
+func ToUserFromAcceptInvitationRequest(req *svc.AcceptInvitationRequest) model.User {
+	return model.User{
+		FirstName:   req.FirstName,
+		LastName:    req.LastName,
+		PhoneNumber: req.PhoneNumber,
+		Locale:      req.Locale,
+	}
+}
+
+func FromInvitationToPb(invitation model.Invitation) *svc.Invitation {
+	return &svc.Invitation{
+		ID:             invitation.ID,
+		Email:          invitation.Email,
+		Role:           invitation.Role,
+		OrganizationID: invitation.TenantID,
+		OrgRole:        invitation.OrgRole,
+		Status:         invitation.Status,
+		InvitedBy:      invitation.InvitedBy,
+		ExpiresAt:      timestamppb.New(invitation.ExpiresAt),
+		CreatedAt:      timestamppb.New(invitation.CreatedAt),
+	}
+}
+
+func FromInvitationsToPb(invitations []model.Invitation) []*svc.Invitation {
+	pbInvitations := make([]*svc.Invitation, len(invitations))
+
+	for i, invitation := range invitations {
+		pbInvitations[i] = FromInvitationToPb(invitation)
+	}
+
+	return pbInvitations
+}
@@ -0,0 +1,70 @@
+package dto
+
+import (
+	"fmt"
+	svc "github.com/sorawaslocked/ap2final_protos_gen/service/user"
+	"github.com/sorawaslocked/ap2final_user_service/internal/model"
+)
+
+// toUserUpdateFromMask builds an update from the paths in req.UpdateMask only.
+// A listed optional field without a value, or with an empty value, is cleared.
+// Required fields cannot be cleared and unknown paths are rejected.
+func toUserUpdateFromMask(req *svc.UpdateRequest) (model.UserUpdateData, error) {
+	update := model.UserUpdateData{}
+	verr := &model.ValidationError{}
+	seen := make(map[string]bool)
+
+	for _, path := range req.UpdateMask.GetPaths() {
+		if seen[path] {
+			continue
+		}
+		seen[path] = true
+
+		switch path {
+		case "first_name":
+			update.FirstName = setOrClear(req.FirstName, model.UserFieldFirstName, &update)
+		case "last_name":
+			update.LastName = setOrClear(req.LastName, model.UserFieldLastName, &update)
+		case "phone_number":
+			update.PhoneNumber = setOrClear(req.PhoneNumber, model.UserFieldPhoneNumber, &update)
+		case "email":
+			if req.Email == nil || *req.Email == "" {
+				verr.Add(path, "email cannot be cleared")
+			}
+			update.Email = req.Email
+		case "role":
+			if req.Role == nil || *req.Role == "" {
+				verr.Add(path, "role cannot be cleared")
+			}
+			update.Role = req.Role
+		case "is_active":
+			if req.IsActive == nil {
+				verr.Add(path, "is_active must be set when listed in the mask")
+			}
+			update.IsActive = req.IsActive
+		case "is_deleted":
+			if req.IsDeleted == nil {
+				verr.Add(path, "is_deleted must be set when listed in the mask")
+			}
+			update.IsDeleted = req.IsDeleted
+		default:
+			verr.Add("update_mask", fmt.Sprintf("unknown field path %q", path))
+		}
+	}
+
+	if err := verr.Err(); err != nil {
+		return model.UserUpdateData{}, err
+	}
+
+	return update, nil
+}
+
+func setOrClear(value *string, field string, update *model.UserUpdateData) *string {
+	if value == nil || *value == "" {
+		update.Clear = append(update.Clear, field)
+
+		return nil
+	}
+
+	return value
+}
@@ -0,0 +1,132 @@
+package dto
+
+import (
+	"errors"
+	svc "github.com/sorawaslocked/ap2final_protos_gen/service/user"
+	"github.com/sorawaslocked/ap2final_user_service/internal/model"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	"reflect"
+	"testing"
+)
+
+func TestToUserUpdateFromMask(t *testing.T) {
+	name := "John"
+	empty := ""
+	email := "john@example.com"
+	role := "admin"
+	active := true
+
+	tests := []struct {
+		name       string
+		req        *svc.UpdateRequest
+		want       model.UserUpdateData
+		wantFields []string
+	}{
+		{
+			name: "empty mask",
+			req:  &svc.UpdateRequest{FirstName: &name},
+			want: model.UserUpdateData{},
+		},
+		{
+			name: "sets listed field only",
+			req: &svc.UpdateRequest{
+				FirstName:  &name,
+				Email:      &email,
+				UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"first_name"}},
+			},
+			want: model.UserUpdateData{FirstName: &name},
+		},
+		{
+			name: "clears listed field without value",
+			req: &svc.UpdateRequest{
+				UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"last_name"}},
+			},
+			want: model.UserUpdateData{Clear: []string{model.UserFieldLastName}},
+		},
+		{
+			name: "clears listed field with empty value",
+			req: &svc.UpdateRequest{
+				PhoneNumber: &empty,
+				UpdateMask:  &fieldmaskpb.FieldMask{Paths: []string{"phone_number"}},
+			},
+			want: model.UserUpdateData{Clear: []string{model.UserFieldPhoneNumber}},
+		},
+		{
+			name: "duplicate path",
+			req: &svc.UpdateRequest{
+				UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"first_name", "first_name"}},
+			},
+			want: model.UserUpdateData{Clear: []string{model.UserFieldFirstName}},
+		},
+		{
+			name: "required and flag fields",
+			req: &svc.UpdateRequest{
+				Email:      &email,
+				Role:       &role,
+				IsActive:   &active,
+				UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"email", "role", "is_active"}},
+			},
+			want: model.UserUpdateData{Email: &email, Role: &role, IsActive: &active},
+		},
+		{
+			name: "email cannot be cleared",
+			req: &svc.UpdateRequest{
+				Email:      &empty,
+				UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"email"}},
+			},
+			wantFields: []string{"email"},
+		},
+		{
+			name: "role cannot be cleared",
+			req: &svc.UpdateRequest{
+				UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"role"}},
+			},
+			wantFields: []string{"role"},
+		},
+		{
+			name: "flags must be set",
+			req: &svc.UpdateRequest{
+				UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"is_active", "is_deleted"}},
+			},
+			wantFields: []string{"is_active", "is_deleted"},
+		},
+		{
+			name: "unknown path",
+			req: &svc.UpdateRequest{
+				UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"password_hash"}},
+			},
+			wantFields: []string{"update_mask"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := toUserUpdateFromMask(tt.req)
+
+			if tt.wantFields != nil {
+				var verr *model.ValidationError
+				if !errors.As(err, &verr) {
+					t.Fatalf("toUserUpdateFromMask() error = %v, want a validation error", err)
+				}
+
+				fields := make([]string, len(verr.Violations))
+				for i, violation := range verr.Violations {
+					fields[i] = violation.Field
+				}
+
+				if !reflect.DeepEqual(fields, tt.wantFields) {
+					t.Errorf("violations = %q, want %q", fields, tt.wantFields)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("toUserUpdateFromMask() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("toUserUpdateFromMask() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package dto
+
+import (
+	svc "github.com/sorawaslocked/ap2final_protos_gen/service/user"
+	"github.com/sorawaslocked/ap2final_user_service/internal/model"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func ToOrganizationFromCreateRequest(req *svc.CreateOrganizationRequest) model.Organization {
+	return model.Organization{
+		Name: req.Name,
+		Slug: req.Slug,
+	}
+}
+
+func ToOrganizationUpdateFromRequest(req *svc.UpdateOrganizationRequest) model.OrganizationUpdateData {
+	return model.OrganizationUpdateData{
+		Name: req.Name,
+	}
+}
+
+func FromOrganizationToPb(org model.Organization) *svc.Organization {
+	return &svc.Organization{
+		ID:        org.ID,
+		Name:      org.Name,
+		Slug:      org.Slug,
+		CreatedAt: timestamppb.New(org.CreatedAt),
+		UpdatedAt: timestamppb.New(org.UpdatedAt),
+	}
+}
@@ -0,0 +1,67 @@
+package dto
+
+import (
+	svc "github.com/sorawaslocked/ap2final_protos_gen/service/user"
+	"github.com/sorawaslocked/ap2final_user_service/internal/model"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func ToPreferencesUpdateFromRequest(req *svc.UpdatePreferencesRequest) model.UserPreferencesUpdate {
+	return model.UserPreferencesUpdate{
+		Locale:         req.Locale,
+		Timezone:       req.Timezone,
+		Theme:          req.Theme,
+		MarketingOptIn: req.MarketingOptIn,
+	}
+}
+
+func FromUserToPreferencesPb(user model.User) *svc.Preferences {
+	return &svc.Preferences{
+		Locale:         user.Locale,
+		Timezone:       user.Preferences.Timezone,
+		Theme:          user.Preferences.Theme,
+		MarketingOptIn: user.Preferences.MarketingOptIn,
+	}
+}
+
+// ToAttributes unwraps protobuf values. Numbers become float64, which is what
+// the attribute registry expects.
+func ToAttributes(values map[string]*structpb.Value) map[string]any {
+	if len(values) == 0 {
+		return nil
+	}
+
+	attributes := make(map[string]any, len(values))
+	for key, value := range values {
+		attributes[key] = value.AsInterface()
+	}
+
+	return attributes
+}
+
+// FromAttributesToPb wraps attribute values for the response. Values of a type
+// the registry does not allow are left out.
+func FromAttributesToPb(attributes map[string]any) map[string]*structpb.Value {
+	values := make(map[string]*structpb.Value, len(attributes))
+
+	for key, attribute := range attributes {
+		value, err := structpb.NewValue(attribute)
+		if err != nil {
+			continue
+		}
+
+		values[key] = value
+	}
+
+	return values
+}
+
+func ToUserListingFromRequest(req *svc.ListUsersRequest) model.UserListing {
+	return model.UserListing{
+		Role:       req.Role,
+		IsActive:   req.IsActive,
+		Attributes: ToAttributes(req.Attributes),
+		Limit:      req.Limit,
+		Offset:     req.Offset,
+	}
+}
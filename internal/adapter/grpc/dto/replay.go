@@ -0,0 +1,33 @@
+package dto
+
+import (
+	svc "github.com/sorawaslocked/ap2final_protos_gen/service/user"
+	"github.com/sorawaslocked/ap2final_user_service/internal/model"
+)
+
+func ToReplayOptionsFromRequest(req *svc.ReplayUsersRequest) model.ReplayOptions {
+	opts := model.ReplayOptions{
+		Checkpoint:    req.Checkpoint,
+		Event:         req.Event,
+		Role:          req.Role,
+		BatchSize:     req.BatchSize,
+		RatePerSecond: int(req.RatePerSecond),
+		DryRun:        req.DryRun,
+	}
+
+	if req.CreatedAfter != nil {
+		opts.CreatedAfter = req.CreatedAfter.AsTime()
+	}
+
+	return opts
+}
+
+func FromReplayProgressToPb(progress model.ReplayProgress) *svc.ReplayProgress {
+	return &svc.ReplayProgress{
+		Checkpoint: progress.Checkpoint,
+		LastUserID: progress.LastUserID,
+		Replayed:   progress.Replayed,
+		Done:       progress.Done,
+		DryRun:     progress.DryRun,
+	}
+}
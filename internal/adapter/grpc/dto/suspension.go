@@ -0,0 +1,57 @@
+package dto
+
+import (
+	svc "github.com/sorawaslocked/ap2final_protos_gen/service/user"
+	"github.com/sorawaslocked/ap2final_user_service/internal/model"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"time"
+)
+
+func ToSuspensionFromSuspendRequest(req *svc.SuspendUserRequest) model.Suspension {
+	suspension := model.Suspension{
+		ReasonCode: req.ReasonCode,
+		Note:       req.Note,
+	}
+
+	if req.Until != nil {
+		suspension.Until = req.Until.AsTime()
+	}
+
+	return suspension
+}
+
+func FromSuspensionToPb(suspension model.Suspension) *svc.Suspension {
+	return &svc.Suspension{
+		ReasonCode:  suspension.ReasonCode,
+		Note:        suspension.Note,
+		SuspendedBy: suspension.SuspendedBy,
+		SuspendedAt: timestamppb.New(suspension.SuspendedAt),
+		Until:       optionalTimestamp(suspension.Until),
+		LiftedBy:    suspension.LiftedBy,
+		LiftedAt:    optionalTimestamp(suspension.LiftedAt),
+	}
+}
+
+func FromUserToGetSuspensionsResponse(user model.User) *svc.GetSuspensionsResponse {
+	res := &svc.GetSuspensionsResponse{
+		History: make([]*svc.Suspension, len(user.SuspensionHistory)),
+	}
+
+	if user.Suspension != nil {
+		res.Active = FromSuspensionToPb(*user.Suspension)
+	}
+
+	for i, suspension := range user.SuspensionHistory {
+		res.History[i] = FromSuspensionToPb(suspension)
+	}
+
+	return res
+}
+
+func optionalTimestamp(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+
+	return timestamppb.New(t)
+}
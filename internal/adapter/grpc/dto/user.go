@@ -9,15 +9,21 @@ import (
 
 func ToUserFromRegisterRequest(req *svc.RegisterRequest) model.User {
 	return model.User{
-		Email:    req.Email,
-		Password: req.Password,
+		FirstName:   req.FirstName,
+		LastName:    req.LastName,
+		Email:       req.Email,
+		PhoneNumber: req.PhoneNumber,
+		Locale:      req.Locale,
+		Password:    req.Password,
 	}
 }
 
 func ToUserFromLoginRequest(req *svc.LoginRequest) model.User {
 	return model.User{
-		Email:    req.Email,
-		Password: req.Password,
+		Email:       req.Email,
+		PhoneNumber: req.PhoneNumber,
+		Password:    req.Password,
+		TenantID:    req.OrganizationID,
 	}
 }
 
@@ -39,31 +45,82 @@ func ToUserUpdateFromUpdateRequest(req *svc.UpdateRequest) (
 		return "", model.UserUpdateData{}, model.UserCredentialUpdateData{}, ErrMissingPasswordArgument
 	}
 
-	update := model.UserUpdateData{
-		FirstName:   req.FirstName,
-		LastName:    req.LastName,
-		Email:       req.Email,
-		PhoneNumber: req.PhoneNumber,
-		Role:        req.Role,
-		IsDeleted:   req.IsDeleted,
-		IsActive:    req.IsActive,
+	// Without a mask every non-nil field is set, as before field masks.
+	if req.UpdateMask == nil {
+		update := model.UserUpdateData{
+			FirstName:   req.FirstName,
+			LastName:    req.LastName,
+			Email:       req.Email,
+			PhoneNumber: req.PhoneNumber,
+			Role:        req.Role,
+			IsDeleted:   req.IsDeleted,
+			IsActive:    req.IsActive,
+
+			ExpectedVersion: req.ExpectedVersion,
+		}
+
+		return req.ID, update, credentialsUpdate, nil
+	}
+
+	update, err := toUserUpdateFromMask(req)
+	if err != nil {
+		return "", model.UserUpdateData{}, model.UserCredentialUpdateData{}, err
 	}
+	update.ExpectedVersion = req.ExpectedVersion
 
 	return req.ID, update, credentialsUpdate, nil
 }
 
 func FromUserToPb(user model.User) *base.User {
 	return &base.User{
-		ID:           user.ID,
-		FirstName:    user.FirstName,
-		LastName:     user.LastName,
-		Email:        user.Email,
-		PhoneNumber:  user.PhoneNumber,
-		PasswordHash: user.PasswordHash,
-		Role:         user.Role,
-		CreatedAt:    timestamppb.New(user.CreatedAt),
-		UpdatedAt:    timestamppb.New(user.UpdatedAt),
-		IsDeleted:    user.IsDeleted,
-		IsActive:     user.IsActive,
+		ID:            user.ID,
+		FirstName:     user.FirstName,
+		LastName:      user.LastName,
+		Email:         user.Email,
+		PhoneNumber:   user.PhoneNumber,
+		PasswordHash:  user.PasswordHash,
+		Role:          user.Role,
+		CreatedAt:     timestamppb.New(user.CreatedAt),
+		UpdatedAt:     timestamppb.New(user.UpdatedAt),
+		Version:       user.Version,
+		IsDeleted:     user.IsDeleted,
+		IsActive:      user.IsActive,
+		PhoneVerified: user.PhoneVerified,
+		AvatarURL:     user.AvatarURL,
+		AvatarVersion: user.AvatarVersion,
+		TenantID:      user.TenantID,
+		OrgRole:       user.OrgRole,
 	}
 }
+
+func ToUserSearchFromRequest(req *svc.SearchUsersRequest) model.UserSearch {
+	return model.UserSearch{
+		Query:  req.Query,
+		Limit:  req.Limit,
+		Offset: req.Offset,
+	}
+}
+
+func FromUserSearchResultsToPb(results []model.UserSearchResult) []*svc.UserSearchResult {
+	pbResults := make([]*svc.UserSearchResult, len(results))
+
+	for i, result := range results {
+		pbResults[i] = &svc.UserSearchResult{
+			User:       FromUserToPb(result.User),
+			Score:      result.Score,
+			Highlights: result.Highlights,
+		}
+	}
+
+	return pbResults
+}
+
+func FromUsersToPb(users []model.User) []*base.User {
+	pbUsers := make([]*base.User, len(users))
+
+	for i, user := range users {
+		pbUsers[i] = FromUserToPb(user)
+	}
+
+	return pbUsers
+}
@@ -0,0 +1,116 @@
+package dto
+
+import (
+	svc "github.com/sorawaslocked/ap2final_protos_gen/service/user"
+	"github.com/sorawaslocked/ap2final_user_service/internal/model"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func ToWebhookFromCreateRequest(req *svc.CreateWebhookRequest) model.Webhook {
+	return model.Webhook{
+		URL:         req.URL,
+		EventTypes:  req.EventTypes,
+		Description: req.Description,
+	}
+}
+
+func ToWebhookListingFromRequest(req *svc.ListWebhooksRequest) model.WebhookListing {
+	return model.WebhookListing{
+		Limit:  req.Limit,
+		Offset: req.Offset,
+	}
+}
+
+// ToWebhookUpdateFromRequest keeps unset fields unchanged. EventTypes is
+// wrapped so an empty list can subscribe the webhook to all events.
+func ToWebhookUpdateFromRequest(req *svc.UpdateWebhookRequest) model.WebhookUpdateData {
+	update := model.WebhookUpdateData{
+		URL:         req.URL,
+		Description: req.Description,
+		Enabled:     req.Enabled,
+	}
+
+	if req.EventTypes != nil {
+		eventTypes := req.EventTypes.Types
+		if eventTypes == nil {
+			eventTypes = []string{}
+		}
+
+		update.EventTypes = &eventTypes
+	}
+
+	return update
+}
+
+func ToWebhookDeliveryListingFromRequest(req *svc.ListWebhookDeliveriesRequest) model.WebhookDeliveryListing {
+	return model.WebhookDeliveryListing{
+		WebhookID: req.WebhookID,
+		Status:    req.Status,
+		Limit:     req.Limit,
+		Offset:    req.Offset,
+	}
+}
+
+// FromWebhookToPb leaves out the secret, which only CreateWebhookResponse carries.
+func FromWebhookToPb(webhook model.Webhook) *svc.Webhook {
+	pbWebhook := &svc.Webhook{
+		ID:                  webhook.ID,
+		URL:                 webhook.URL,
+		EventTypes:          webhook.EventTypes,
+		Description:         webhook.Description,
+		Enabled:             webhook.Enabled,
+		ConsecutiveFailures: int32(webhook.ConsecutiveFailures),
+		CreatedAt:           timestamppb.New(webhook.CreatedAt),
+		UpdatedAt:           timestamppb.New(webhook.UpdatedAt),
+	}
+
+	if !webhook.DisabledAt.IsZero() {
+		pbWebhook.DisabledAt = timestamppb.New(webhook.DisabledAt)
+	}
+
+	return pbWebhook
+}
+
+func FromWebhooksToPb(webhooks []model.Webhook) []*svc.Webhook {
+	pbWebhooks := make([]*svc.Webhook, len(webhooks))
+
+	for i, webhook := range webhooks {
+		pbWebhooks[i] = FromWebhookToPb(webhook)
+	}
+
+	return pbWebhooks
+}
+
+func FromWebhookDeliveryToPb(delivery model.WebhookDelivery) *svc.WebhookDelivery {
+	attempts := make([]*svc.WebhookAttempt, len(delivery.Log))
+
+	for i, attempt := range delivery.Log {
+		attempts[i] = &svc.WebhookAttempt{
+			AttemptedAt: timestamppb.New(attempt.AttemptedAt),
+			StatusCode:  int32(attempt.StatusCode),
+			Error:       attempt.Error,
+			DurationMs:  attempt.Duration.Milliseconds(),
+		}
+	}
+
+	return &svc.WebhookDelivery{
+		ID:            delivery.ID,
+		WebhookID:     delivery.WebhookID,
+		EventID:       delivery.EventID,
+		EventType:     delivery.EventType,
+		Status:        delivery.Status,
+		NextAttemptAt: timestamppb.New(delivery.NextAttemptAt),
+		CreatedAt:     timestamppb.New(delivery.CreatedAt),
+		Attempts:      attempts,
+	}
+}
+
+func FromWebhookDeliveriesToPb(deliveries []model.WebhookDelivery) []*svc.WebhookDelivery {
+	pbDeliveries := make([]*svc.WebhookDelivery, len(deliveries))
+
+	for i, delivery := range deliveries {
+		pbDeliveries[i] = FromWebhookDeliveryToPb(delivery)
+	}
+
+	return pbDeliveries
+}
@@ -17,12 +17,44 @@ func logError(log *slog.Logger, op string, err error) {
 		warn(log, op, err)
 	case errors.Is(err, model.ErrNotFound):
 		warn(log, op, err)
+	case errors.Is(err, model.ErrAlreadyExists):
+		warn(log, op, err)
+	case errors.Is(err, model.ErrVersionConflict):
+		warn(log, op, err)
 	case errors.Is(err, dto.ErrUnauthenticated):
 		warn(log, op, err)
 	case errors.Is(err, model.ErrPasswordsDoNotMatch):
 		warn(log, op, err)
 	case errors.Is(err, model.ErrRefreshTokenExpired):
 		warn(log, op, err)
+	case errors.Is(err, model.ErrInvalidSearchQuery):
+		warn(log, op, err)
+	case errors.Is(err, model.ErrAccountSuspended):
+		warn(log, op, err)
+	case errors.Is(err, model.ErrNotSuspended):
+		warn(log, op, err)
+	case errors.Is(err, model.ErrInvalidSuspension):
+		warn(log, op, err)
+	case errors.Is(err, model.ErrValidation):
+		warn(log, op, err)
+	case errors.Is(err, model.ErrPhoneNotSet), errors.Is(err, model.ErrAlreadyVerified):
+		warn(log, op, err)
+	case errors.Is(err, model.ErrInvalidVerificationCode), errors.Is(err, model.ErrVerificationExpired):
+		warn(log, op, err)
+	case errors.Is(err, model.ErrTooManyAttempts):
+		warn(log, op, err)
+	case errors.Is(err, model.ErrEmailChangeRequiresVerification):
+		warn(log, op, err)
+	case errors.Is(err, model.ErrUnsupportedImage), errors.Is(err, model.ErrImageTooLarge):
+		warn(log, op, err)
+	case errors.Is(err, model.ErrRegistrationClosed), errors.Is(err, model.ErrInvitationExpired):
+		warn(log, op, err)
+	case errors.Is(err, model.ErrAlreadyMember), errors.Is(err, model.ErrNotMember), errors.Is(err, model.ErrLastOwner):
+		warn(log, op, err)
+	case errors.Is(err, model.ErrInvalidToken):
+		warn(log, op, err)
+	case errors.Is(err, model.ErrUnauthorized):
+		warn(log, op, err)
 	default:
 		log.Error(
 			fmt.Sprintf("user %s", op),
@@ -3,6 +3,7 @@ package grpc
 import (
 	"context"
 	"github.com/sorawaslocked/ap2final_user_service/internal/model"
+	"io"
 )
 
 type UserUseCase interface {
@@ -18,4 +19,107 @@ type UserUseCase interface {
 		update model.UserUpdateData,
 	) (model.User, error)
 	DeleteByID(ctx context.Context, token model.Token, id string) (model.User, error)
+	RestoreUser(ctx context.Context, token model.Token, id string) (model.User, error)
+	AnonymizeUser(ctx context.Context, token model.Token, id string) (model.User, error)
+	ExportMyData(ctx context.Context, token model.Token) (model.UserDataExport, error)
+	ExportUserData(ctx context.Context, token model.Token, id string) (model.UserDataExport, error)
+	SuspendUser(ctx context.Context, token model.Token, id string, suspension model.Suspension) (model.User, error)
+	UnsuspendUser(ctx context.Context, token model.Token, id string) (model.User, error)
+	GetSuspensions(ctx context.Context, token model.Token, id string) (model.User, error)
+	RequestPhoneVerification(ctx context.Context, token model.Token) error
+	VerifyPhone(ctx context.Context, token model.Token, code string) (model.User, error)
+	RequestEmailChange(ctx context.Context, token model.Token, newEmail, currentPassword string) error
+	ConfirmEmailChange(ctx context.Context, confirmToken string) (model.User, error)
+	RevertEmailChange(ctx context.Context, revertToken string) (model.User, error)
+	SearchUsers(ctx context.Context, token model.Token, search model.UserSearch) ([]model.UserSearchResult, error)
+	ListUsers(ctx context.Context, token model.Token, listing model.UserListing) ([]model.User, error)
+	GetPreferences(ctx context.Context, token model.Token, id string) (model.User, error)
+	UpdatePreferences(
+		ctx context.Context,
+		token model.Token,
+		id string,
+		preferences model.UserPreferencesUpdate,
+	) (model.User, error)
+	UploadAvatar(ctx context.Context, token model.Token, contentType string, r io.Reader) (model.User, error)
+	GetAvatar(ctx context.Context, token model.Token, id, size string) (model.AvatarImage, error)
+	GetAttributes(ctx context.Context, token model.Token, id string) (map[string]any, error)
+	SetAttributes(
+		ctx context.Context,
+		token model.Token,
+		id string,
+		set map[string]any,
+		remove []string,
+	) (map[string]any, error)
+}
+
+type OrganizationUseCase interface {
+	CreateOrganization(
+		ctx context.Context,
+		token model.Token,
+		org model.Organization,
+		ownerID string,
+	) (model.Organization, error)
+	GetOrganization(ctx context.Context, token model.Token, id string) (model.Organization, error)
+	UpdateOrganization(
+		ctx context.Context,
+		token model.Token,
+		id string,
+		update model.OrganizationUpdateData,
+	) (model.Organization, error)
+	ListOrganizationMembers(ctx context.Context, token model.Token, id string, limit, offset int64) ([]model.User, error)
+	AddOrganizationMember(ctx context.Context, token model.Token, orgID, userID, role string) (model.User, error)
+	UpdateOrganizationMember(ctx context.Context, token model.Token, orgID, userID, role string) (model.User, error)
+	RemoveOrganizationMember(ctx context.Context, token model.Token, orgID, userID string) (model.User, error)
+}
+
+type GroupUseCase interface {
+	CreateGroup(ctx context.Context, token model.Token, name string) (model.Group, error)
+	RenameGroup(ctx context.Context, token model.Token, id, name string) (model.Group, error)
+	DeleteGroup(ctx context.Context, token model.Token, id string) (model.Group, error)
+	AddGroupMembers(ctx context.Context, token model.Token, id string, userIDs []string) ([]string, error)
+	RemoveGroupMembers(ctx context.Context, token model.Token, id string, userIDs []string) ([]string, error)
+	ListGroupsForUser(ctx context.Context, token model.Token, userID string) ([]model.Group, error)
+}
+
+type InvitationUseCase interface {
+	CreateInvitation(ctx context.Context, token model.Token, invitation model.Invitation) (model.Invitation, error)
+	ListInvitations(ctx context.Context, token model.Token, listing model.InvitationListing) ([]model.Invitation, error)
+	RevokeInvitation(ctx context.Context, token model.Token, id string) (model.Invitation, error)
+	AcceptInvitation(ctx context.Context, invitationToken, password string, profile model.User) (model.User, error)
+}
+
+type WebhookUseCase interface {
+	CreateWebhook(ctx context.Context, token model.Token, webhook model.Webhook) (model.Webhook, error)
+	ListWebhooks(ctx context.Context, token model.Token, listing model.WebhookListing) ([]model.Webhook, error)
+	UpdateWebhook(
+		ctx context.Context,
+		token model.Token,
+		id string,
+		update model.WebhookUpdateData,
+	) (model.Webhook, error)
+	DeleteWebhook(ctx context.Context, token model.Token, id string) (model.Webhook, error)
+	ListWebhookDeliveries(
+		ctx context.Context,
+		token model.Token,
+		listing model.WebhookDeliveryListing,
+	) ([]model.WebhookDelivery, error)
+}
+
+type ReplayUseCase interface {
+	ReplayUsers(
+		ctx context.Context,
+		token model.Token,
+		opts model.ReplayOptions,
+		progress func(model.ReplayProgress) error,
+	) (model.ReplayProgress, error)
+}
+
+// UseCases are the use cases behind the user service.
+type UseCases struct {
+	User         UserUseCase
+	Organization OrganizationUseCase
+	Group        GroupUseCase
+	Invitation   InvitationUseCase
+	Webhook      WebhookUseCase
+	Replay       ReplayUseCase
 }
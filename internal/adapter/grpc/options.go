@@ -28,7 +28,37 @@ func (s *Server) interceptors() grpc.ServerOption {
 	chain := grpc.ChainUnaryInterceptor(
 		recovery.UnaryServerInterceptor(recoveryOpts...),
 		logging.UnaryServerInterceptor(grpccfg.LoggingInterceptor(s.log), loggingOpts...),
-		grpccfg.AuthInterceptor(*s.jwtProvider, []string{"Register", "Login", "RefreshToken"}),
+		grpccfg.AuthInterceptor(*s.jwtProvider, []string{
+			"Register",
+			"Login",
+			"RefreshToken",
+			"ConfirmEmailChange",
+			"RevertEmailChange",
+			"AcceptInvitation",
+		}),
+	)
+
+	return chain
+}
+
+func (s *Server) streamInterceptors() grpc.ServerOption {
+	loggingOpts := []logging.Option{
+		logging.WithLogOnEvents(
+			logging.StartCall, logging.FinishCall,
+		),
+	}
+
+	recoveryOpts := []recovery.Option{
+		recovery.WithRecoveryHandler(func(p interface{}) (err error) {
+			s.log.Error("Recovered from panic", slog.Any("panic", p))
+
+			return status.Errorf(codes.Internal, "internal error")
+		}),
+	}
+
+	chain := grpc.ChainStreamInterceptor(
+		recovery.StreamServerInterceptor(recoveryOpts...),
+		logging.StreamServerInterceptor(grpccfg.LoggingInterceptor(s.log), loggingOpts...),
 	)
 
 	return chain
@@ -16,21 +16,21 @@ type Server struct {
 	cfg         grpccfg.Config
 	addr        string
 	log         *slog.Logger
-	userUseCase UserUseCase
+	useCases    UseCases
 	jwtProvider *security.JWTProvider
 }
 
 func New(
 	cfg grpccfg.Config,
 	log *slog.Logger,
-	userUseCase UserUseCase,
+	useCases UseCases,
 	jwtProvider *security.JWTProvider,
 ) *Server {
 	server := &Server{
 		cfg:         cfg,
 		addr:        fmt.Sprintf(":%d", cfg.Port),
 		log:         log,
-		userUseCase: userUseCase,
+		useCases:    useCases,
 		jwtProvider: jwtProvider,
 	}
 
@@ -54,9 +54,9 @@ func (s *Server) Stop() {
 }
 
 func (s *Server) register() {
-	s.s = grpc.NewServer(s.interceptors())
+	s.s = grpc.NewServer(s.interceptors(), s.streamInterceptors())
 
-	svc.RegisterUserServiceServer(s.s, NewUserServer(s.userUseCase, s.log))
+	svc.RegisterUserServiceServer(s.s, NewUserServer(s.useCases, s.log))
 
 	reflection.Register(s.s)
 }
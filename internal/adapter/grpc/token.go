@@ -0,0 +1,30 @@
+package grpc
+
+import (
+	"context"
+	"github.com/sorawaslocked/ap2final_base/pkg/security"
+	"google.golang.org/grpc/metadata"
+	"strings"
+)
+
+// streamTokenFromCtx returns the access token for streaming calls, which are
+// not covered by the unary auth interceptor and so read the header directly.
+func streamTokenFromCtx(ctx context.Context) (string, bool) {
+	if token, ok := security.TokenFromCtx(ctx); ok {
+		return token, true
+	}
+
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return "", false
+	}
+
+	for _, value := range md.Get("authorization") {
+		token, found := strings.CutPrefix(value, "Bearer ")
+		if found && token != "" {
+			return token, true
+		}
+	}
+
+	return "", false
+}
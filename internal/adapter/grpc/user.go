@@ -2,24 +2,38 @@ package grpc
 
 import (
 	"context"
+	"errors"
 	"github.com/sorawaslocked/ap2final_base/pkg/security"
 	"github.com/sorawaslocked/ap2final_protos_gen/base"
 	svc "github.com/sorawaslocked/ap2final_protos_gen/service/user"
 	"github.com/sorawaslocked/ap2final_user_service/internal/adapter/grpc/dto"
 	"github.com/sorawaslocked/ap2final_user_service/internal/model"
+	"io"
 	"log/slog"
 )
 
+const streamChunkSize = 64 * 1024
+
 type UserServer struct {
-	uc  UserUseCase
-	log *slog.Logger
+	uc          UserUseCase
+	orgs        OrganizationUseCase
+	groups      GroupUseCase
+	invitations InvitationUseCase
+	webhooks    WebhookUseCase
+	replay      ReplayUseCase
+	log         *slog.Logger
 	svc.UnimplementedUserServiceServer
 }
 
-func NewUserServer(uc UserUseCase, log *slog.Logger) *UserServer {
+func NewUserServer(useCases UseCases, log *slog.Logger) *UserServer {
 	return &UserServer{
-		uc:  uc,
-		log: log,
+		uc:          useCases.User,
+		orgs:        useCases.Organization,
+		groups:      useCases.Group,
+		invitations: useCases.Invitation,
+		webhooks:    useCases.Webhook,
+		replay:      useCases.Replay,
+		log:         log,
 	}
 }
 
@@ -47,7 +61,7 @@ func (s *UserServer) Login(ctx context.Context, req *svc.LoginRequest) (*svc.Log
 
 	log := s.log.With(slog.String("op", op))
 
-	if req.Email == "" || req.Password == "" {
+	if (req.Email == "" && req.PhoneNumber == "") || req.Password == "" {
 		err := dto.ErrMissingLoginCredentials
 		logError(log, "login", err)
 
@@ -176,3 +190,1278 @@ func (s *UserServer) Delete(ctx context.Context, req *svc.DeleteRequest) (*svc.D
 		User: dto.FromUserToPb(deletedUser),
 	}, nil
 }
+
+func (s *UserServer) RestoreUser(ctx context.Context, req *svc.RestoreUserRequest) (*svc.RestoreUserResponse, error) {
+	const op = "grpc.UserServer.RestoreUser"
+
+	log := s.log.With(slog.String("op", op))
+
+	token, ok := security.TokenFromCtx(ctx)
+	if !ok {
+		err := dto.ErrUnauthenticated
+		logError(log, "restore", err)
+
+		return nil, dto.FromError(err)
+	}
+
+	restoredUser, err := s.uc.RestoreUser(ctx, model.Token{AccessToken: token}, req.ID)
+	if err != nil {
+		logError(log, "restore", err)
+
+		return nil, dto.FromError(err)
+	}
+
+	return &svc.RestoreUserResponse{
+		User: dto.FromUserToPb(restoredUser),
+	}, nil
+}
+
+func (s *UserServer) AnonymizeUser(ctx context.Context, req *svc.AnonymizeUserRequest) (*svc.AnonymizeUserResponse, error) {
+	const op = "grpc.UserServer.AnonymizeUser"
+
+	log := s.log.With(slog.String("op", op))
+
+	token, ok := security.TokenFromCtx(ctx)
+	if !ok {
+		err := dto.ErrUnauthenticated
+		logError(log, "anonymize", err)
+
+		return nil, dto.FromError(err)
+	}
+
+	anonymizedUser, err := s.uc.AnonymizeUser(ctx, model.Token{AccessToken: token}, req.ID)
+	if err != nil {
+		logError(log, "anonymize", err)
+
+		return nil, dto.FromError(err)
+	}
+
+	return &svc.AnonymizeUserResponse{
+		User: dto.FromUserToPb(anonymizedUser),
+	}, nil
+}
+
+func (s *UserServer) SuspendUser(ctx context.Context, req *svc.SuspendUserRequest) (*svc.SuspendUserResponse, error) {
+	const op = "grpc.UserServer.SuspendUser"
+
+	log := s.log.With(slog.String("op", op))
+
+	token, ok := security.TokenFromCtx(ctx)
+	if !ok {
+		err := dto.ErrUnauthenticated
+		logError(log, "suspend", err)
+
+		return nil, dto.FromError(err)
+	}
+
+	suspendedUser, err := s.uc.SuspendUser(
+		ctx,
+		model.Token{AccessToken: token},
+		req.ID,
+		dto.ToSuspensionFromSuspendRequest(req),
+	)
+	if err != nil {
+		logError(log, "suspend", err)
+
+		return nil, dto.FromError(err)
+	}
+
+	return &svc.SuspendUserResponse{
+		User: dto.FromUserToPb(suspendedUser),
+	}, nil
+}
+
+func (s *UserServer) UnsuspendUser(ctx context.Context, req *svc.UnsuspendUserRequest) (*svc.UnsuspendUserResponse, error) {
+	const op = "grpc.UserServer.UnsuspendUser"
+
+	log := s.log.With(slog.String("op", op))
+
+	token, ok := security.TokenFromCtx(ctx)
+	if !ok {
+		err := dto.ErrUnauthenticated
+		logError(log, "unsuspend", err)
+
+		return nil, dto.FromError(err)
+	}
+
+	unsuspendedUser, err := s.uc.UnsuspendUser(ctx, model.Token{AccessToken: token}, req.ID)
+	if err != nil {
+		logError(log, "unsuspend", err)
+
+		return nil, dto.FromError(err)
+	}
+
+	return &svc.UnsuspendUserResponse{
+		User: dto.FromUserToPb(unsuspendedUser),
+	}, nil
+}
+
+func (s *UserServer) GetSuspensions(ctx context.Context, req *svc.GetSuspensionsRequest) (*svc.GetSuspensionsResponse, error) {
+	const op = "grpc.UserServer.GetSuspensions"
+
+	log := s.log.With(slog.String("op", op))
+
+	token, ok := security.TokenFromCtx(ctx)
+	if !ok {
+		err := dto.ErrUnauthenticated
+		logError(log, "get suspensions", err)
+
+		return nil, dto.FromError(err)
+	}
+
+	user, err := s.uc.GetSuspensions(ctx, model.Token{AccessToken: token}, req.ID)
+	if err != nil {
+		logError(log, "get suspensions", err)
+
+		return nil, dto.FromError(err)
+	}
+
+	return dto.FromUserToGetSuspensionsResponse(user), nil
+}
+
+func (s *UserServer) RequestPhoneVerification(
+	ctx context.Context,
+	req *svc.RequestPhoneVerificationRequest,
+) (*svc.RequestPhoneVerificationResponse, error) {
+	const op = "grpc.UserServer.RequestPhoneVerification"
+
+	log := s.log.With(slog.String("op", op))
+
+	token, ok := security.TokenFromCtx(ctx)
+	if !ok {
+		err := dto.ErrUnauthenticated
+		logError(log, "request phone verification", err)
+
+		return nil, dto.FromError(err)
+	}
+
+	err := s.uc.RequestPhoneVerification(ctx, model.Token{AccessToken: token})
+	if err != nil {
+		logError(log, "request phone verification", err)
+
+		return nil, dto.FromError(err)
+	}
+
+	return &svc.RequestPhoneVerificationResponse{}, nil
+}
+
+func (s *UserServer) VerifyPhone(ctx context.Context, req *svc.VerifyPhoneRequest) (*svc.VerifyPhoneResponse, error) {
+	const op = "grpc.UserServer.VerifyPhone"
+
+	log := s.log.With(slog.String("op", op))
+
+	token, ok := security.TokenFromCtx(ctx)
+	if !ok {
+		err := dto.ErrUnauthenticated
+		logError(log, "verify phone", err)
+
+		return nil, dto.FromError(err)
+	}
+
+	verifiedUser, err := s.uc.VerifyPhone(ctx, model.Token{AccessToken: token}, req.Code)
+	if err != nil {
+		logError(log, "verify phone", err)
+
+		return nil, dto.FromError(err)
+	}
+
+	return &svc.VerifyPhoneResponse{
+		User: dto.FromUserToPb(verifiedUser),
+	}, nil
+}
+
+func (s *UserServer) RequestEmailChange(
+	ctx context.Context,
+	req *svc.RequestEmailChangeRequest,
+) (*svc.RequestEmailChangeResponse, error) {
+	const op = "grpc.UserServer.RequestEmailChange"
+
+	log := s.log.With(slog.String("op", op))
+
+	token, ok := security.TokenFromCtx(ctx)
+	if !ok {
+		err := dto.ErrUnauthenticated
+		logError(log, "request email change", err)
+
+		return nil, dto.FromError(err)
+	}
+
+	err := s.uc.RequestEmailChange(ctx, model.Token{AccessToken: token}, req.NewEmail, req.CurrentPassword)
+	if err != nil {
+		logError(log, "request email change", err)
+
+		return nil, dto.FromError(err)
+	}
+
+	return &svc.RequestEmailChangeResponse{}, nil
+}
+
+func (s *UserServer) ConfirmEmailChange(
+	ctx context.Context,
+	req *svc.ConfirmEmailChangeRequest,
+) (*svc.ConfirmEmailChangeResponse, error) {
+	const op = "grpc.UserServer.ConfirmEmailChange"
+
+	log := s.log.With(slog.String("op", op))
+
+	updatedUser, err := s.uc.ConfirmEmailChange(ctx, req.Token)
+	if err != nil {
+		logError(log, "confirm email change", err)
+
+		return nil, dto.FromError(err)
+	}
+
+	return &svc.ConfirmEmailChangeResponse{
+		User: dto.FromUserToPb(updatedUser),
+	}, nil
+}
+
+func (s *UserServer) RevertEmailChange(
+	ctx context.Context,
+	req *svc.RevertEmailChangeRequest,
+) (*svc.RevertEmailChangeResponse, error) {
+	const op = "grpc.UserServer.RevertEmailChange"
+
+	log := s.log.With(slog.String("op", op))
+
+	revertedUser, err := s.uc.RevertEmailChange(ctx, req.Token)
+	if err != nil {
+		logError(log, "revert email change", err)
+
+		return nil, dto.FromError(err)
+	}
+
+	return &svc.RevertEmailChangeResponse{
+		User: dto.FromUserToPb(revertedUser),
+	}, nil
+}
+
+func (s *UserServer) CreateInvitation(
+	ctx context.Context,
+	req *svc.CreateInvitationRequest,
+) (*svc.CreateInvitationResponse, error) {
+	const op = "grpc.UserServer.CreateInvitation"
+
+	log := s.log.With(slog.String("op", op))
+
+	token, ok := security.TokenFromCtx(ctx)
+	if !ok {
+		err := dto.ErrUnauthenticated
+		logError(log, "create invitation", err)
+
+		return nil, dto.FromError(err)
+	}
+
+	invitation, err := s.invitations.CreateInvitation(ctx, model.Token{AccessToken: token}, dto.ToInvitationFromCreateRequest(req))
+	if err != nil {
+		logError(log, "create invitation", err)
+
+		return nil, dto.FromError(err)
+	}
+
+	return &svc.CreateInvitationResponse{
+		Invitation: dto.FromInvitationToPb(invitation),
+	}, nil
+}
+
+func (s *UserServer) ListInvitations(
+	ctx context.Context,
+	req *svc.ListInvitationsRequest,
+) (*svc.ListInvitationsResponse, error) {
+	const op = "grpc.UserServer.ListInvitations"
+
+	log := s.log.With(slog.String("op", op))
+
+	token, ok := security.TokenFromCtx(ctx)
+	if !ok {
+		err := dto.ErrUnauthenticated
+		logError(log, "list invitations", err)
+
+		return nil, dto.FromError(err)
+	}
+
+	invitations, err := s.invitations.ListInvitations(ctx, model.Token{AccessToken: token}, dto.ToInvitationListingFromRequest(req))
+	if err != nil {
+		logError(log, "list invitations", err)
+
+		return nil, dto.FromError(err)
+	}
+
+	return &svc.ListInvitationsResponse{
+		Invitations: dto.FromInvitationsToPb(invitations),
+	}, nil
+}
+
+func (s *UserServer) RevokeInvitation(
+	ctx context.Context,
+	req *svc.RevokeInvitationRequest,
+) (*svc.RevokeInvitationResponse, error) {
+	const op = "grpc.UserServer.RevokeInvitation"
+
+	log := s.log.With(slog.String("op", op))
+
+	token, ok := security.TokenFromCtx(ctx)
+	if !ok {
+		err := dto.ErrUnauthenticated
+		logError(log, "revoke invitation", err)
+
+		return nil, dto.FromError(err)
+	}
+
+	invitation, err := s.invitations.RevokeInvitation(ctx, model.Token{AccessToken: token}, req.ID)
+	if err != nil {
+		logError(log, "revoke invitation", err)
+
+		return nil, dto.FromError(err)
+	}
+
+	return &svc.RevokeInvitationResponse{
+		Invitation: dto.FromInvitationToPb(invitation),
+	}, nil
+}
+
+func (s *UserServer) AcceptInvitation(
+	ctx context.Context,
+	req *svc.AcceptInvitationRequest,
+) (*svc.AcceptInvitationResponse, error) {
+	const op = "grpc.UserServer.AcceptInvitation"
+
+	log := s.log.With(slog.String("op", op))
+
+	createdUser, err := s.invitations.AcceptInvitation(ctx, req.Token, req.Password, dto.ToUserFromAcceptInvitationRequest(req))
+	if err != nil {
+		logError(log, "accept invitation", err)
+
+		return nil, dto.FromError(err)
+	}
+
+	return &svc.AcceptInvitationResponse{
+		User: dto.FromUserToPb(createdUser),
+	}, nil
+}
+
+func (s *UserServer) SearchUsers(ctx context.Context, req *svc.SearchUsersRequest) (*svc.SearchUsersResponse, error) {
+	const op = "grpc.UserServer.SearchUsers"
+
+	log := s.log.With(slog.String("op", op))
+
+	token, ok := security.TokenFromCtx(ctx)
+	if !ok {
+		err := dto.ErrUnauthenticated
+		logError(log, "search", err)
+
+		return nil, dto.FromError(err)
+	}
+
+	results, err := s.uc.SearchUsers(ctx, model.Token{AccessToken: token}, dto.ToUserSearchFromRequest(req))
+	if err != nil {
+		logError(log, "search", err)
+
+		return nil, dto.FromError(err)
+	}
+
+	return &svc.SearchUsersResponse{
+		Results: dto.FromUserSearchResultsToPb(results),
+	}, nil
+}
+
+func (s *UserServer) ListUsers(ctx context.Context, req *svc.ListUsersRequest) (*svc.ListUsersResponse, error) {
+	const op = "grpc.UserServer.ListUsers"
+
+	log := s.log.With(slog.String("op", op))
+
+	token, ok := security.TokenFromCtx(ctx)
+	if !ok {
+		err := dto.ErrUnauthenticated
+		logError(log, "list users", err)
+
+		return nil, dto.FromError(err)
+	}
+
+	users, err := s.uc.ListUsers(ctx, model.Token{AccessToken: token}, dto.ToUserListingFromRequest(req))
+	if err != nil {
+		logError(log, "list users", err)
+
+		return nil, dto.FromError(err)
+	}
+
+	return &svc.ListUsersResponse{
+		Users: dto.FromUsersToPb(users),
+	}, nil
+}
+
+func (s *UserServer) GetPreferences(ctx context.Context, req *svc.GetPreferencesRequest) (*svc.GetPreferencesResponse, error) {
+	const op = "grpc.UserServer.GetPreferences"
+
+	log := s.log.With(slog.String("op", op))
+
+	token, ok := security.TokenFromCtx(ctx)
+	if !ok {
+		err := dto.ErrUnauthenticated
+		logError(log, "get preferences", err)
+
+		return nil, dto.FromError(err)
+	}
+
+	user, err := s.uc.GetPreferences(ctx, model.Token{AccessToken: token}, req.ID)
+	if err != nil {
+		logError(log, "get preferences", err)
+
+		return nil, dto.FromError(err)
+	}
+
+	return &svc.GetPreferencesResponse{
+		Preferences: dto.FromUserToPreferencesPb(user),
+	}, nil
+}
+
+func (s *UserServer) UpdatePreferences(
+	ctx context.Context,
+	req *svc.UpdatePreferencesRequest,
+) (*svc.UpdatePreferencesResponse, error) {
+	const op = "grpc.UserServer.UpdatePreferences"
+
+	log := s.log.With(slog.String("op", op))
+
+	token, ok := security.TokenFromCtx(ctx)
+	if !ok {
+		err := dto.ErrUnauthenticated
+		logError(log, "update preferences", err)
+
+		return nil, dto.FromError(err)
+	}
+
+	user, err := s.uc.UpdatePreferences(
+		ctx,
+		model.Token{AccessToken: token},
+		req.ID,
+		dto.ToPreferencesUpdateFromRequest(req),
+	)
+	if err != nil {
+		logError(log, "update preferences", err)
+
+		return nil, dto.FromError(err)
+	}
+
+	return &svc.UpdatePreferencesResponse{
+		Preferences: dto.FromUserToPreferencesPb(user),
+	}, nil
+}
+
+func (s *UserServer) GetAttributes(ctx context.Context, req *svc.GetAttributesRequest) (*svc.GetAttributesResponse, error) {
+	const op = "grpc.UserServer.GetAttributes"
+
+	log := s.log.With(slog.String("op", op))
+
+	token, ok := security.TokenFromCtx(ctx)
+	if !ok {
+		err := dto.ErrUnauthenticated
+		logError(log, "get attributes", err)
+
+		return nil, dto.FromError(err)
+	}
+
+	attributes, err := s.uc.GetAttributes(ctx, model.Token{AccessToken: token}, req.ID)
+	if err != nil {
+		logError(log, "get attributes", err)
+
+		return nil, dto.FromError(err)
+	}
+
+	return &svc.GetAttributesResponse{
+		Attributes: dto.FromAttributesToPb(attributes),
+	}, nil
+}
+
+func (s *UserServer) SetAttributes(ctx context.Context, req *svc.SetAttributesRequest) (*svc.SetAttributesResponse, error) {
+	const op = "grpc.UserServer.SetAttributes"
+
+	log := s.log.With(slog.String("op", op))
+
+	token, ok := security.TokenFromCtx(ctx)
+	if !ok {
+		err := dto.ErrUnauthenticated
+		logError(log, "set attributes", err)
+
+		return nil, dto.FromError(err)
+	}
+
+	attributes, err := s.uc.SetAttributes(
+		ctx,
+		model.Token{AccessToken: token},
+		req.ID,
+		dto.ToAttributes(req.Set),
+		req.Remove,
+	)
+	if err != nil {
+		logError(log, "set attributes", err)
+
+		return nil, dto.FromError(err)
+	}
+
+	return &svc.SetAttributesResponse{
+		Attributes: dto.FromAttributesToPb(attributes),
+	}, nil
+}
+
+func (s *UserServer) CreateGroup(ctx context.Context, req *svc.CreateGroupRequest) (*svc.CreateGroupResponse, error) {
+	const op = "grpc.UserServer.CreateGroup"
+
+	log := s.log.With(slog.String("op", op))
+
+	token, ok := security.TokenFromCtx(ctx)
+	if !ok {
+		err := dto.ErrUnauthenticated
+		logError(log, "create group", err)
+
+		return nil, dto.FromError(err)
+	}
+
+	group, err := s.groups.CreateGroup(ctx, model.Token{AccessToken: token}, req.Name)
+	if err != nil {
+		logError(log, "create group", err)
+
+		return nil, dto.FromError(err)
+	}
+
+	return &svc.CreateGroupResponse{
+		Group: dto.FromGroupToPb(group),
+	}, nil
+}
+
+func (s *UserServer) RenameGroup(ctx context.Context, req *svc.RenameGroupRequest) (*svc.RenameGroupResponse, error) {
+	const op = "grpc.UserServer.RenameGroup"
+
+	log := s.log.With(slog.String("op", op))
+
+	token, ok := security.TokenFromCtx(ctx)
+	if !ok {
+		err := dto.ErrUnauthenticated
+		logError(log, "rename group", err)
+
+		return nil, dto.FromError(err)
+	}
+
+	group, err := s.groups.RenameGroup(ctx, model.Token{AccessToken: token}, req.ID, req.Name)
+	if err != nil {
+		logError(log, "rename group", err)
+
+		return nil, dto.FromError(err)
+	}
+
+	return &svc.RenameGroupResponse{
+		Group: dto.FromGroupToPb(group),
+	}, nil
+}
+
+func (s *UserServer) DeleteGroup(ctx context.Context, req *svc.DeleteGroupRequest) (*svc.DeleteGroupResponse, error) {
+	const op = "grpc.UserServer.DeleteGroup"
+
+	log := s.log.With(slog.String("op", op))
+
+	token, ok := security.TokenFromCtx(ctx)
+	if !ok {
+		err := dto.ErrUnauthenticated
+		logError(log, "delete group", err)
+
+		return nil, dto.FromError(err)
+	}
+
+	group, err := s.groups.DeleteGroup(ctx, model.Token{AccessToken: token}, req.ID)
+	if err != nil {
+		logError(log, "delete group", err)
+
+		return nil, dto.FromError(err)
+	}
+
+	return &svc.DeleteGroupResponse{
+		Group: dto.FromGroupToPb(group),
+	}, nil
+}
+
+func (s *UserServer) AddGroupMembers(
+	ctx context.Context,
+	req *svc.AddGroupMembersRequest,
+) (*svc.AddGroupMembersResponse, error) {
+	const op = "grpc.UserServer.AddGroupMembers"
+
+	log := s.log.With(slog.String("op", op))
+
+	token, ok := security.TokenFromCtx(ctx)
+	if !ok {
+		err := dto.ErrUnauthenticated
+		logError(log, "add group members", err)
+
+		return nil, dto.FromError(err)
+	}
+
+	added, err := s.groups.AddGroupMembers(ctx, model.Token{AccessToken: token}, req.ID, req.UserIDs)
+	if err != nil {
+		logError(log, "add group members", err)
+
+		return nil, dto.FromError(err)
+	}
+
+	return &svc.AddGroupMembersResponse{
+		AddedUserIDs: added,
+	}, nil
+}
+
+func (s *UserServer) RemoveGroupMembers(
+	ctx context.Context,
+	req *svc.RemoveGroupMembersRequest,
+) (*svc.RemoveGroupMembersResponse, error) {
+	const op = "grpc.UserServer.RemoveGroupMembers"
+
+	log := s.log.With(slog.String("op", op))
+
+	token, ok := security.TokenFromCtx(ctx)
+	if !ok {
+		err := dto.ErrUnauthenticated
+		logError(log, "remove group members", err)
+
+		return nil, dto.FromError(err)
+	}
+
+	removed, err := s.groups.RemoveGroupMembers(ctx, model.Token{AccessToken: token}, req.ID, req.UserIDs)
+	if err != nil {
+		logError(log, "remove group members", err)
+
+		return nil, dto.FromError(err)
+	}
+
+	return &svc.RemoveGroupMembersResponse{
+		RemovedUserIDs: removed,
+	}, nil
+}
+
+func (s *UserServer) ListGroupsForUser(
+	ctx context.Context,
+	req *svc.ListGroupsForUserRequest,
+) (*svc.ListGroupsForUserResponse, error) {
+	const op = "grpc.UserServer.ListGroupsForUser"
+
+	log := s.log.With(slog.String("op", op))
+
+	token, ok := security.TokenFromCtx(ctx)
+	if !ok {
+		err := dto.ErrUnauthenticated
+		logError(log, "list groups for user", err)
+
+		return nil, dto.FromError(err)
+	}
+
+	groups, err := s.groups.ListGroupsForUser(ctx, model.Token{AccessToken: token}, req.UserID)
+	if err != nil {
+		logError(log, "list groups for user", err)
+
+		return nil, dto.FromError(err)
+	}
+
+	return &svc.ListGroupsForUserResponse{
+		Groups: dto.FromGroupsToPb(groups),
+	}, nil
+}
+
+func (s *UserServer) CreateOrganization(
+	ctx context.Context,
+	req *svc.CreateOrganizationRequest,
+) (*svc.CreateOrganizationResponse, error) {
+	const op = "grpc.UserServer.CreateOrganization"
+
+	log := s.log.With(slog.String("op", op))
+
+	token, ok := security.TokenFromCtx(ctx)
+	if !ok {
+		err := dto.ErrUnauthenticated
+		logError(log, "create organization", err)
+
+		return nil, dto.FromError(err)
+	}
+
+	org, err := s.orgs.CreateOrganization(
+		ctx,
+		model.Token{AccessToken: token},
+		dto.ToOrganizationFromCreateRequest(req),
+		req.OwnerID,
+	)
+	if err != nil {
+		logError(log, "create organization", err)
+
+		return nil, dto.FromError(err)
+	}
+
+	return &svc.CreateOrganizationResponse{
+		Organization: dto.FromOrganizationToPb(org),
+	}, nil
+}
+
+func (s *UserServer) GetOrganization(
+	ctx context.Context,
+	req *svc.GetOrganizationRequest,
+) (*svc.GetOrganizationResponse, error) {
+	const op = "grpc.UserServer.GetOrganization"
+
+	log := s.log.With(slog.String("op", op))
+
+	token, ok := security.TokenFromCtx(ctx)
+	if !ok {
+		err := dto.ErrUnauthenticated
+		logError(log, "get organization", err)
+
+		return nil, dto.FromError(err)
+	}
+
+	org, err := s.orgs.GetOrganization(ctx, model.Token{AccessToken: token}, req.ID)
+	if err != nil {
+		logError(log, "get organization", err)
+
+		return nil, dto.FromError(err)
+	}
+
+	return &svc.GetOrganizationResponse{
+		Organization: dto.FromOrganizationToPb(org),
+	}, nil
+}
+
+func (s *UserServer) UpdateOrganization(
+	ctx context.Context,
+	req *svc.UpdateOrganizationRequest,
+) (*svc.UpdateOrganizationResponse, error) {
+	const op = "grpc.UserServer.UpdateOrganization"
+
+	log := s.log.With(slog.String("op", op))
+
+	token, ok := security.TokenFromCtx(ctx)
+	if !ok {
+		err := dto.ErrUnauthenticated
+		logError(log, "update organization", err)
+
+		return nil, dto.FromError(err)
+	}
+
+	org, err := s.orgs.UpdateOrganization(
+		ctx,
+		model.Token{AccessToken: token},
+		req.ID,
+		dto.ToOrganizationUpdateFromRequest(req),
+	)
+	if err != nil {
+		logError(log, "update organization", err)
+
+		return nil, dto.FromError(err)
+	}
+
+	return &svc.UpdateOrganizationResponse{
+		Organization: dto.FromOrganizationToPb(org),
+	}, nil
+}
+
+func (s *UserServer) ListOrganizationMembers(
+	ctx context.Context,
+	req *svc.ListOrganizationMembersRequest,
+) (*svc.ListOrganizationMembersResponse, error) {
+	const op = "grpc.UserServer.ListOrganizationMembers"
+
+	log := s.log.With(slog.String("op", op))
+
+	token, ok := security.TokenFromCtx(ctx)
+	if !ok {
+		err := dto.ErrUnauthenticated
+		logError(log, "list organization members", err)
+
+		return nil, dto.FromError(err)
+	}
+
+	users, err := s.orgs.ListOrganizationMembers(ctx, model.Token{AccessToken: token}, req.ID, req.Limit, req.Offset)
+	if err != nil {
+		logError(log, "list organization members", err)
+
+		return nil, dto.FromError(err)
+	}
+
+	return &svc.ListOrganizationMembersResponse{
+		Users: dto.FromUsersToPb(users),
+	}, nil
+}
+
+func (s *UserServer) AddOrganizationMember(
+	ctx context.Context,
+	req *svc.AddOrganizationMemberRequest,
+) (*svc.AddOrganizationMemberResponse, error) {
+	const op = "grpc.UserServer.AddOrganizationMember"
+
+	log := s.log.With(slog.String("op", op))
+
+	token, ok := security.TokenFromCtx(ctx)
+	if !ok {
+		err := dto.ErrUnauthenticated
+		logError(log, "add organization member", err)
+
+		return nil, dto.FromError(err)
+	}
+
+	user, err := s.orgs.AddOrganizationMember(ctx, model.Token{AccessToken: token}, req.OrganizationID, req.UserID, req.Role)
+	if err != nil {
+		logError(log, "add organization member", err)
+
+		return nil, dto.FromError(err)
+	}
+
+	return &svc.AddOrganizationMemberResponse{
+		User: dto.FromUserToPb(user),
+	}, nil
+}
+
+func (s *UserServer) UpdateOrganizationMember(
+	ctx context.Context,
+	req *svc.UpdateOrganizationMemberRequest,
+) (*svc.UpdateOrganizationMemberResponse, error) {
+	const op = "grpc.UserServer.UpdateOrganizationMember"
+
+	log := s.log.With(slog.String("op", op))
+
+	token, ok := security.TokenFromCtx(ctx)
+	if !ok {
+		err := dto.ErrUnauthenticated
+		logError(log, "update organization member", err)
+
+		return nil, dto.FromError(err)
+	}
+
+	user, err := s.orgs.UpdateOrganizationMember(
+		ctx,
+		model.Token{AccessToken: token},
+		req.OrganizationID,
+		req.UserID,
+		req.Role,
+	)
+	if err != nil {
+		logError(log, "update organization member", err)
+
+		return nil, dto.FromError(err)
+	}
+
+	return &svc.UpdateOrganizationMemberResponse{
+		User: dto.FromUserToPb(user),
+	}, nil
+}
+
+func (s *UserServer) RemoveOrganizationMember(
+	ctx context.Context,
+	req *svc.RemoveOrganizationMemberRequest,
+) (*svc.RemoveOrganizationMemberResponse, error) {
+	const op = "grpc.UserServer.RemoveOrganizationMember"
+
+	log := s.log.With(slog.String("op", op))
+
+	token, ok := security.TokenFromCtx(ctx)
+	if !ok {
+		err := dto.ErrUnauthenticated
+		logError(log, "remove organization member", err)
+
+		return nil, dto.FromError(err)
+	}
+
+	user, err := s.orgs.RemoveOrganizationMember(ctx, model.Token{AccessToken: token}, req.OrganizationID, req.UserID)
+	if err != nil {
+		logError(log, "remove organization member", err)
+
+		return nil, dto.FromError(err)
+	}
+
+	return &svc.RemoveOrganizationMemberResponse{
+		User: dto.FromUserToPb(user),
+	}, nil
+}
+
+func (s *UserServer) CreateWebhook(
+	ctx context.Context,
+	req *svc.CreateWebhookRequest,
+) (*svc.CreateWebhookResponse, error) {
+	const op = "grpc.UserServer.CreateWebhook"
+
+	log := s.log.With(slog.String("op", op))
+
+	token, ok := security.TokenFromCtx(ctx)
+	if !ok {
+		err := dto.ErrUnauthenticated
+		logError(log, "create webhook", err)
+
+		return nil, dto.FromError(err)
+	}
+
+	webhook, err := s.webhooks.CreateWebhook(ctx, model.Token{AccessToken: token}, dto.ToWebhookFromCreateRequest(req))
+	if err != nil {
+		logError(log, "create webhook", err)
+
+		return nil, dto.FromError(err)
+	}
+
+	return &svc.CreateWebhookResponse{
+		Webhook: dto.FromWebhookToPb(webhook),
+		Secret:  webhook.Secret,
+	}, nil
+}
+
+func (s *UserServer) ListWebhooks(
+	ctx context.Context,
+	req *svc.ListWebhooksRequest,
+) (*svc.ListWebhooksResponse, error) {
+	const op = "grpc.UserServer.ListWebhooks"
+
+	log := s.log.With(slog.String("op", op))
+
+	token, ok := security.TokenFromCtx(ctx)
+	if !ok {
+		err := dto.ErrUnauthenticated
+		logError(log, "list webhooks", err)
+
+		return nil, dto.FromError(err)
+	}
+
+	webhooks, err := s.webhooks.ListWebhooks(ctx, model.Token{AccessToken: token}, dto.ToWebhookListingFromRequest(req))
+	if err != nil {
+		logError(log, "list webhooks", err)
+
+		return nil, dto.FromError(err)
+	}
+
+	return &svc.ListWebhooksResponse{
+		Webhooks: dto.FromWebhooksToPb(webhooks),
+	}, nil
+}
+
+func (s *UserServer) UpdateWebhook(
+	ctx context.Context,
+	req *svc.UpdateWebhookRequest,
+) (*svc.UpdateWebhookResponse, error) {
+	const op = "grpc.UserServer.UpdateWebhook"
+
+	log := s.log.With(slog.String("op", op))
+
+	token, ok := security.TokenFromCtx(ctx)
+	if !ok {
+		err := dto.ErrUnauthenticated
+		logError(log, "update webhook", err)
+
+		return nil, dto.FromError(err)
+	}
+
+	webhook, err := s.webhooks.UpdateWebhook(
+		ctx,
+		model.Token{AccessToken: token},
+		req.ID,
+		dto.ToWebhookUpdateFromRequest(req),
+	)
+	if err != nil {
+		logError(log, "update webhook", err)
+
+		return nil, dto.FromError(err)
+	}
+
+	return &svc.UpdateWebhookResponse{
+		Webhook: dto.FromWebhookToPb(webhook),
+	}, nil
+}
+
+func (s *UserServer) DeleteWebhook(
+	ctx context.Context,
+	req *svc.DeleteWebhookRequest,
+) (*svc.DeleteWebhookResponse, error) {
+	const op = "grpc.UserServer.DeleteWebhook"
+
+	log := s.log.With(slog.String("op", op))
+
+	token, ok := security.TokenFromCtx(ctx)
+	if !ok {
+		err := dto.ErrUnauthenticated
+		logError(log, "delete webhook", err)
+
+		return nil, dto.FromError(err)
+	}
+
+	webhook, err := s.webhooks.DeleteWebhook(ctx, model.Token{AccessToken: token}, req.ID)
+	if err != nil {
+		logError(log, "delete webhook", err)
+
+		return nil, dto.FromError(err)
+	}
+
+	return &svc.DeleteWebhookResponse{
+		Webhook: dto.FromWebhookToPb(webhook),
+	}, nil
+}
+
+func (s *UserServer) ListWebhookDeliveries(
+	ctx context.Context,
+	req *svc.ListWebhookDeliveriesRequest,
+) (*svc.ListWebhookDeliveriesResponse, error) {
+	const op = "grpc.UserServer.ListWebhookDeliveries"
+
+	log := s.log.With(slog.String("op", op))
+
+	token, ok := security.TokenFromCtx(ctx)
+	if !ok {
+		err := dto.ErrUnauthenticated
+		logError(log, "list webhook deliveries", err)
+
+		return nil, dto.FromError(err)
+	}
+
+	deliveries, err := s.webhooks.ListWebhookDeliveries(
+		ctx,
+		model.Token{AccessToken: token},
+		dto.ToWebhookDeliveryListingFromRequest(req),
+	)
+	if err != nil {
+		logError(log, "list webhook deliveries", err)
+
+		return nil, dto.FromError(err)
+	}
+
+	return &svc.ListWebhookDeliveriesResponse{
+		Deliveries: dto.FromWebhookDeliveriesToPb(deliveries),
+	}, nil
+}
+
+// UploadAvatar reads the image from the client stream. The first message
+// carries the content type; every message may carry a chunk of the image.
+func (s *UserServer) UploadAvatar(stream svc.UserService_UploadAvatarServer) error {
+	const op = "grpc.UserServer.UploadAvatar"
+
+	log := s.log.With(slog.String("op", op))
+
+	token, ok := streamTokenFromCtx(stream.Context())
+	if !ok {
+		err := dto.ErrUnauthenticated
+		logError(log, "upload avatar", err)
+
+		return dto.FromError(err)
+	}
+
+	// An empty stream is passed on as an empty upload and rejected as such.
+	first, err := stream.Recv()
+	if errors.Is(err, io.EOF) {
+		first = &svc.UploadAvatarRequest{}
+	} else if err != nil {
+		logError(log, "upload avatar", err)
+
+		return dto.FromError(err)
+	}
+
+	user, err := s.uc.UploadAvatar(
+		stream.Context(),
+		model.Token{AccessToken: token},
+		first.ContentType,
+		&avatarUploadReader{stream: stream, buf: first.Chunk},
+	)
+	if err != nil {
+		logError(log, "upload avatar", err)
+
+		return dto.FromError(err)
+	}
+
+	return stream.SendAndClose(&svc.UploadAvatarResponse{
+		User: dto.FromUserToPb(user),
+	})
+}
+
+func (s *UserServer) GetAvatar(req *svc.GetAvatarRequest, stream svc.UserService_GetAvatarServer) error {
+	const op = "grpc.UserServer.GetAvatar"
+
+	log := s.log.With(slog.String("op", op))
+
+	token, ok := streamTokenFromCtx(stream.Context())
+	if !ok {
+		err := dto.ErrUnauthenticated
+		logError(log, "get avatar", err)
+
+		return dto.FromError(err)
+	}
+
+	image, err := s.uc.GetAvatar(stream.Context(), model.Token{AccessToken: token}, req.ID, req.Size)
+	if err != nil {
+		logError(log, "get avatar", err)
+
+		return dto.FromError(err)
+	}
+
+	for offset := 0; offset < len(image.Data); offset += streamChunkSize {
+		chunk := &svc.AvatarChunk{
+			Data: image.Data[offset:min(offset+streamChunkSize, len(image.Data))],
+		}
+
+		if offset == 0 {
+			chunk.ContentType = image.ContentType
+			chunk.Version = image.Version
+		}
+
+		if err = stream.Send(chunk); err != nil {
+			logError(log, "get avatar", err)
+
+			return dto.FromError(err)
+		}
+	}
+
+	return nil
+}
+
+// avatarUploadReader turns the upload stream into an io.Reader. It returns
+// io.EOF once the client closes its side of the stream.
+type avatarUploadReader struct {
+	stream svc.UserService_UploadAvatarServer
+	buf    []byte
+}
+
+func (r *avatarUploadReader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		req, err := r.stream.Recv()
+		if err != nil {
+			return 0, err
+		}
+
+		r.buf = req.Chunk
+	}
+
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+
+	return n, nil
+}
+
+func (s *UserServer) ExportMyData(req *svc.ExportMyDataRequest, stream svc.UserService_ExportMyDataServer) error {
+	const op = "grpc.UserServer.ExportMyData"
+
+	log := s.log.With(slog.String("op", op))
+
+	token, ok := streamTokenFromCtx(stream.Context())
+	if !ok {
+		err := dto.ErrUnauthenticated
+		logError(log, "export my data", err)
+
+		return dto.FromError(err)
+	}
+
+	export, err := s.uc.ExportMyData(stream.Context(), model.Token{AccessToken: token})
+	if err != nil {
+		logError(log, "export my data", err)
+
+		return dto.FromError(err)
+	}
+
+	err = sendExport(stream, export, req.Zip)
+	if err != nil {
+		logError(log, "export my data", err)
+
+		return dto.FromError(err)
+	}
+
+	return nil
+}
+
+func (s *UserServer) ExportUserData(req *svc.ExportUserDataRequest, stream svc.UserService_ExportUserDataServer) error {
+	const op = "grpc.UserServer.ExportUserData"
+
+	log := s.log.With(slog.String("op", op))
+
+	token, ok := streamTokenFromCtx(stream.Context())
+	if !ok {
+		err := dto.ErrUnauthenticated
+		logError(log, "export user data", err)
+
+		return dto.FromError(err)
+	}
+
+	export, err := s.uc.ExportUserData(stream.Context(), model.Token{AccessToken: token}, req.ID)
+	if err != nil {
+		logError(log, "export user data", err)
+
+		return dto.FromError(err)
+	}
+
+	err = sendExport(stream, export, req.Zip)
+	if err != nil {
+		logError(log, "export user data", err)
+
+		return dto.FromError(err)
+	}
+
+	return nil
+}
+
+type exportChunkSender interface {
+	Send(*svc.ExportDataChunk) error
+}
+
+// sendExport streams the rendered export in fixed-size chunks. The file name
+// and content type are only set on the first chunk.
+func sendExport(stream exportChunkSender, export model.UserDataExport, compress bool) error {
+	data, fileName, contentType, err := dto.FromUserDataExportToFile(export, compress)
+	if err != nil {
+		return err
+	}
+
+	for offset := 0; offset < len(data); offset += streamChunkSize {
+		chunk := &svc.ExportDataChunk{
+			Data: data[offset:min(offset+streamChunkSize, len(data))],
+		}
+
+		if offset == 0 {
+			chunk.FileName = fileName
+			chunk.ContentType = contentType
+		}
+
+		if err = stream.Send(chunk); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ReplayUsers streams the progress of the replay, one message per batch. A
+// client that disconnects stops the replay; a named checkpoint lets it
+// continue later.
+func (s *UserServer) ReplayUsers(req *svc.ReplayUsersRequest, stream svc.UserService_ReplayUsersServer) error {
+	const op = "grpc.UserServer.ReplayUsers"
+
+	log := s.log.With(slog.String("op", op))
+
+	token, ok := streamTokenFromCtx(stream.Context())
+	if !ok {
+		err := dto.ErrUnauthenticated
+		logError(log, "replay users", err)
+
+		return dto.FromError(err)
+	}
+
+	sent := false
+
+	progress, err := s.replay.ReplayUsers(
+		stream.Context(),
+		model.Token{AccessToken: token},
+		dto.ToReplayOptionsFromRequest(req),
+		func(progress model.ReplayProgress) error {
+			sent = true
+
+			return stream.Send(dto.FromReplayProgressToPb(progress))
+		},
+	)
+	if err != nil {
+		logError(log, "replay users", err)
+
+		return dto.FromError(err)
+	}
+
+	// A checkpoint that was already completed replays no batch.
+	if !sent {
+		if err = stream.Send(dto.FromReplayProgressToPb(progress)); err != nil {
+			logError(log, "replay users", err)
+
+			return dto.FromError(err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,29 @@
+package mongo
+
+import (
+	"context"
+	"github.com/sorawaslocked/ap2final_user_service/internal/adapter/mongo/dao"
+	"github.com/sorawaslocked/ap2final_user_service/internal/model"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const collectionAuditLog = "audit_log"
+
+type Audit struct {
+	col *mongo.Collection
+}
+
+func NewAudit(conn *mongo.Database) *Audit {
+	return &Audit{
+		col: conn.Collection(collectionAuditLog),
+	}
+}
+
+func (db *Audit) InsertOne(ctx context.Context, entry model.AuditEntry) error {
+	_, err := db.col.InsertOne(ctx, dao.FromAuditEntry(entry))
+	if err != nil {
+		return mongoError("InsertOne", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,135 @@
+package mongo
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/sorawaslocked/ap2final_user_service/internal/adapter/mongo/dao"
+	"github.com/sorawaslocked/ap2final_user_service/internal/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"io"
+)
+
+const avatarBucket = "avatars"
+
+// Avatar keeps avatar renditions in GridFS. Each rendition is a file whose
+// metadata names the user, size and avatar version.
+type Avatar struct {
+	bucket *gridfs.Bucket
+}
+
+func NewAvatar(conn *mongo.Database) (*Avatar, error) {
+	bucket, err := gridfs.NewBucket(conn, options.GridFSBucket().SetName(avatarBucket))
+	if err != nil {
+		return nil, mongoError("NewBucket", err)
+	}
+
+	return &Avatar{
+		bucket: bucket,
+	}, nil
+}
+
+func (db *Avatar) EnsureIndexes(ctx context.Context) error {
+	_, err := db.bucket.GetFilesCollection().Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "metadata.userID", Value: 1},
+			{Key: "metadata.version", Value: 1},
+			{Key: "metadata.size", Value: 1},
+		},
+	})
+	if err != nil {
+		return mongoError("CreateIndexes", err)
+	}
+
+	return nil
+}
+
+// Save uploads the renditions. The GridFS upload API takes no context, so ctx
+// is unused and only keeps the signature in line with the other repositories.
+func (db *Avatar) Save(_ context.Context, avatars []model.AvatarImage) error {
+	for _, avatar := range avatars {
+		_, err := db.bucket.UploadFromStream(
+			fmt.Sprintf("%s/%s", avatar.UserID, avatar.Size),
+			bytes.NewReader(avatar.Data),
+			options.GridFSUpload().SetMetadata(dao.FromAvatarImageToMetadata(avatar)),
+		)
+		if err != nil {
+			return mongoError("UploadFromStream", err)
+		}
+	}
+
+	return nil
+}
+
+func (db *Avatar) FindOne(ctx context.Context, userID, size string, version int64) (model.AvatarImage, error) {
+	cur, err := db.bucket.FindContext(
+		ctx,
+		bson.M{"metadata.userID": userID, "metadata.version": version, "metadata.size": size},
+		options.GridFSFind().SetSort(bson.D{{Key: "uploadDate", Value: -1}}).SetLimit(1),
+	)
+	if err != nil {
+		return model.AvatarImage{}, mongoError("Find", err)
+	}
+
+	var files []dao.AvatarFile
+	if err = cur.All(ctx, &files); err != nil {
+		return model.AvatarImage{}, mongoError("Cursor.All", err)
+	}
+
+	if len(files) == 0 {
+		return model.AvatarImage{}, model.ErrNotFound
+	}
+
+	stream, err := db.bucket.OpenDownloadStream(files[0].ID)
+	if err != nil {
+		return model.AvatarImage{}, mongoError("OpenDownloadStream", err)
+	}
+	defer stream.Close()
+
+	data, err := io.ReadAll(stream)
+	if err != nil {
+		return model.AvatarImage{}, mongoError("DownloadStream.Read", err)
+	}
+
+	return dao.ToAvatarImage(files[0], data), nil
+}
+
+// DeleteStale removes the user's renditions of every version except keepVersion.
+func (db *Avatar) DeleteStale(ctx context.Context, userID string, keepVersion int64) error {
+	return db.deleteFiles(ctx, bson.M{"metadata.userID": userID, "metadata.version": bson.M{"$ne": keepVersion}})
+}
+
+// DeleteVersion removes the renditions of one version, such as those of an
+// upload that lost to a concurrent one.
+func (db *Avatar) DeleteVersion(ctx context.Context, userID string, version int64) error {
+	return db.deleteFiles(ctx, bson.M{"metadata.userID": userID, "metadata.version": version})
+}
+
+func (db *Avatar) DeleteByUserID(ctx context.Context, userID string) error {
+	return db.deleteFiles(ctx, bson.M{"metadata.userID": userID})
+}
+
+func (db *Avatar) deleteFiles(ctx context.Context, filter bson.M) error {
+	cur, err := db.bucket.FindContext(ctx, filter)
+	if err != nil {
+		return mongoError("Find", err)
+	}
+
+	var files []dao.AvatarFile
+	if err = cur.All(ctx, &files); err != nil {
+		return mongoError("Cursor.All", err)
+	}
+
+	for _, file := range files {
+		err = db.bucket.DeleteContext(ctx, file.ID)
+		if err != nil && !errors.Is(err, gridfs.ErrFileNotFound) {
+			return mongoError("Delete", err)
+		}
+	}
+
+	return nil
+}
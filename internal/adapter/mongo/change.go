@@ -0,0 +1,178 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/sorawaslocked/ap2final_user_service/internal/adapter/mongo/dao"
+	"github.com/sorawaslocked/ap2final_user_service/internal/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+const (
+	collectionResumeTokens = "resume_tokens"
+	userChangesTokenID     = "users"
+)
+
+// Server error codes of change streams that cannot resume.
+const (
+	codeChangeStreamFatal       = 280
+	codeChangeStreamHistoryLost = 286
+)
+
+// UserChanges tails the users collection with a change stream and stores
+// the token to resume it from. Change streams need a replica set.
+type UserChanges struct {
+	users  *mongo.Collection
+	tokens *mongo.Collection
+}
+
+func NewUserChanges(conn *mongo.Database) *UserChanges {
+	return &UserChanges{
+		users:  conn.Collection(userCollection),
+		tokens: conn.Collection(collectionResumeTokens),
+	}
+}
+
+// Watch passes every change after resumeToken to handle, in order, until ctx
+// ends, handle fails or the stream breaks. Without a resume token it starts
+// with the next change.
+func (db *UserChanges) Watch(
+	ctx context.Context,
+	resumeToken []byte,
+	handle func(ctx context.Context, change model.UserChange) error,
+) error {
+	opts := options.ChangeStream().SetFullDocument(options.UpdateLookup)
+	if resumeToken != nil {
+		opts.SetResumeAfter(bson.Raw(resumeToken))
+	}
+
+	stream, err := db.users.Watch(ctx, mongo.Pipeline{}, opts)
+	if err != nil {
+		return changeStreamError("Watch", err)
+	}
+	// ctx is usually done by the time the stream is closed.
+	defer stream.Close(context.Background())
+
+	for stream.Next(ctx) {
+		var event dao.UserChangeEvent
+
+		if err = stream.Decode(&event); err != nil {
+			return mongoError("ChangeStream.Decode", err)
+		}
+
+		// A dropped or renamed collection ends the stream for good.
+		if event.OperationType == "invalidate" {
+			return fmt.Errorf("%w: stream invalidated", model.ErrChangeHistoryLost)
+		}
+
+		token := append([]byte(nil), stream.ResumeToken()...)
+
+		if err = handle(ctx, dao.ToUserChange(event, token)); err != nil {
+			return err
+		}
+	}
+
+	return changeStreamError("ChangeStream.Next", stream.Err())
+}
+
+func (db *UserChanges) LoadResumeToken(ctx context.Context) ([]byte, error) {
+	var token dao.ResumeToken
+
+	err := db.tokens.FindOne(ctx, bson.M{"_id": userChangesTokenID}).Decode(&token)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, model.ErrNotFound
+		}
+
+		return nil, mongoError("FindOne", err)
+	}
+
+	if len(token.Token) == 0 {
+		return nil, model.ErrNotFound
+	}
+
+	return token.Token, nil
+}
+
+// SaveResumeToken stores the token to resume from while owner holds the
+// lease. A nil token removes it, so the next stream starts with the next
+// change. It returns model.ErrLeaseHeld if another replica took the lease.
+func (db *UserChanges) SaveResumeToken(ctx context.Context, owner string, token []byte) error {
+	update := bson.M{"$unset": bson.M{"token": ""}}
+	if token != nil {
+		update = bson.M{"$set": bson.M{"token": bson.Raw(token), "updatedAt": time.Now().UTC()}}
+	}
+
+	res, err := db.tokens.UpdateOne(ctx, bson.M{"_id": userChangesTokenID, "owner": owner}, update)
+	if err != nil {
+		return mongoError("UpdateOne", err)
+	}
+
+	if res.MatchedCount == 0 {
+		return model.ErrLeaseHeld
+	}
+
+	return nil
+}
+
+// AcquireLease makes owner the only replica to tail the stream until the
+// lease ends. Calling it again while holding the lease extends it.
+func (db *UserChanges) AcquireLease(ctx context.Context, owner string, now time.Time, ttl time.Duration) error {
+	filter := bson.M{
+		"_id": userChangesTokenID,
+		"$or": bson.A{
+			bson.M{"owner": owner},
+			bson.M{"owner": bson.M{"$exists": false}},
+			bson.M{"leaseExpiresAt": bson.M{"$lte": now}},
+		},
+	}
+	update := bson.M{"$set": bson.M{"owner": owner, "leaseExpiresAt": now.Add(ttl)}}
+
+	// When the lease is held the filter misses and the upsert collides
+	// with the existing document.
+	_, err := db.tokens.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return model.ErrLeaseHeld
+		}
+
+		return mongoError("UpdateOne", err)
+	}
+
+	return nil
+}
+
+// ReleaseLease ends owner's lease so another replica can take over without
+// waiting for it to run out.
+func (db *UserChanges) ReleaseLease(ctx context.Context, owner string) error {
+	_, err := db.tokens.UpdateOne(
+		ctx,
+		bson.M{"_id": userChangesTokenID, "owner": owner},
+		bson.M{"$unset": bson.M{"owner": "", "leaseExpiresAt": ""}},
+	)
+	if err != nil {
+		return mongoError("UpdateOne", err)
+	}
+
+	return nil
+}
+
+// changeStreamError marks errors after which the stream cannot resume from
+// its token.
+func changeStreamError(op string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var serverErr mongo.ServerError
+	if errors.As(err, &serverErr) &&
+		(serverErr.HasErrorCode(codeChangeStreamHistoryLost) || serverErr.HasErrorCode(codeChangeStreamFatal)) {
+		return fmt.Errorf("%w: %w", model.ErrChangeHistoryLost, mongoError(op, err))
+	}
+
+	return mongoError(op, err)
+}
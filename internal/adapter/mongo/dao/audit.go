@@ -0,0 +1,24 @@
+package dao
+
+import (
+	"github.com/sorawaslocked/ap2final_user_service/internal/model"
+	"time"
+)
+
+type AuditEntry struct {
+	ActorID   string            `bson:"actorID"`
+	Action    string            `bson:"action"`
+	TargetID  string            `bson:"targetID"`
+	Details   map[string]string `bson:"details,omitempty"`
+	CreatedAt time.Time         `bson:"createdAt"`
+}
+
+func FromAuditEntry(entry model.AuditEntry) AuditEntry {
+	return AuditEntry{
+		ActorID:   entry.ActorID,
+		Action:    entry.Action,
+		TargetID:  entry.TargetID,
+		Details:   entry.Details,
+		CreatedAt: entry.CreatedAt,
+	}
+}
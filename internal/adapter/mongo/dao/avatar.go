@@ -0,0 +1,38 @@
+package dao
+
+import (
+	"github.com/sorawaslocked/ap2final_user_service/internal/model"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type AvatarMetadata struct {
+	UserID      string `bson:"userID"`
+	Size        string `bson:"size"`
+	Version     int64  `bson:"version"`
+	ContentType string `bson:"contentType"`
+}
+
+// AvatarFile is the part of a GridFS files document the repository reads.
+type AvatarFile struct {
+	ID       primitive.ObjectID `bson:"_id"`
+	Metadata AvatarMetadata     `bson:"metadata"`
+}
+
+func FromAvatarImageToMetadata(avatar model.AvatarImage) AvatarMetadata {
+	return AvatarMetadata{
+		UserID:      avatar.UserID,
+		Size:        avatar.Size,
+		Version:     avatar.Version,
+		ContentType: avatar.ContentType,
+	}
+}
+
+func ToAvatarImage(file AvatarFile, data []byte) model.AvatarImage {
+	return model.AvatarImage{
+		UserID:      file.Metadata.UserID,
+		Size:        file.Metadata.Size,
+		Version:     file.Metadata.Version,
+		ContentType: file.Metadata.ContentType,
+		Data:        data,
+	}
+}
@@ -0,0 +1,109 @@
+package dao
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/sorawaslocked/ap2final_user_service/internal/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"slices"
+	"strings"
+	"time"
+)
+
+// UserChangeEvent is a change stream event of the users collection.
+type UserChangeEvent struct {
+	ID struct {
+		Data string `bson:"_data"`
+	} `bson:"_id"`
+	OperationType string `bson:"operationType"`
+	DocumentKey   struct {
+		ID primitive.ObjectID `bson:"_id"`
+	} `bson:"documentKey"`
+	FullDocument      *User               `bson:"fullDocument"`
+	UpdateDescription *UpdateDescription  `bson:"updateDescription"`
+	ClusterTime       primitive.Timestamp `bson:"clusterTime"`
+}
+
+type UpdateDescription struct {
+	UpdatedFields bson.M   `bson:"updatedFields"`
+	RemovedFields []string `bson:"removedFields"`
+}
+
+// ResumeToken also holds the lease of the replica that tails the stream.
+type ResumeToken struct {
+	ID             string    `bson:"_id"`
+	Token          bson.Raw  `bson:"token,omitempty"`
+	Owner          string    `bson:"owner,omitempty"`
+	LeaseExpiresAt time.Time `bson:"leaseExpiresAt"`
+	UpdatedAt      time.Time `bson:"updatedAt"`
+}
+
+// changeFieldNames renames fields in change events. Bookkeeping fields map
+// to "" and are left out; the password hash is only named, never sent.
+var changeFieldNames = map[string]string{
+	"passwordHash": "password",
+	"emailLower":   "",
+	"updatedAt":    "",
+	"version":      "",
+}
+
+func ToUserChange(event UserChangeEvent, resumeToken []byte) model.UserChange {
+	change := model.UserChange{
+		ID:          changeID(event),
+		ResumeToken: resumeToken,
+		Operation:   event.OperationType,
+		User:        model.User{ID: event.DocumentKey.ID.Hex()},
+		ChangedAt:   time.Unix(int64(event.ClusterTime.T), 0).UTC(),
+	}
+
+	if event.FullDocument != nil {
+		change.User = ToUser(*event.FullDocument)
+	}
+
+	if event.UpdateDescription != nil {
+		paths := make([]string, 0, len(event.UpdateDescription.UpdatedFields)+len(event.UpdateDescription.RemovedFields))
+		for path := range event.UpdateDescription.UpdatedFields {
+			paths = append(paths, path)
+		}
+		paths = append(paths, event.UpdateDescription.RemovedFields...)
+
+		change.ChangedFields = changedFieldNames(paths)
+	}
+
+	return change
+}
+
+// changeID hashes the event's resume token, which is unique to the event
+// and the same each time it is read.
+func changeID(event UserChangeEvent) string {
+	if event.ID.Data == "" {
+		return fmt.Sprintf("%s:%d.%d", event.DocumentKey.ID.Hex(), event.ClusterTime.T, event.ClusterTime.I)
+	}
+
+	sum := sha256.Sum256([]byte(event.ID.Data))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// changedFieldNames reduces dotted paths to their top-level field.
+func changedFieldNames(paths []string) []string {
+	var fields []string
+
+	for _, path := range paths {
+		field, _, _ := strings.Cut(path, ".")
+
+		if name, ok := changeFieldNames[field]; ok {
+			field = name
+		}
+
+		if field != "" && !slices.Contains(fields, field) {
+			fields = append(fields, field)
+		}
+	}
+
+	slices.Sort(fields)
+
+	return fields
+}
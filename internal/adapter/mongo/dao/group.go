@@ -0,0 +1,86 @@
+package dao
+
+import (
+	"github.com/sorawaslocked/ap2final_user_service/internal/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+type Group struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	Name      string             `bson:"name"`
+	TenantID  string             `bson:"tenantID,omitempty"`
+	CreatedAt time.Time          `bson:"createdAt"`
+	UpdatedAt time.Time          `bson:"updatedAt"`
+}
+
+// GroupMembership links one user to one group.
+type GroupMembership struct {
+	GroupID string    `bson:"groupID"`
+	UserID  string    `bson:"userID"`
+	AddedAt time.Time `bson:"addedAt"`
+}
+
+func FromGroup(group model.Group) (Group, error) {
+	objID, err := primitive.ObjectIDFromHex(group.ID)
+	if err != nil && group.ID != "" {
+		return Group{}, ErrInvalidID
+	}
+
+	return Group{
+		ID:        objID,
+		Name:      group.Name,
+		TenantID:  group.TenantID,
+		CreatedAt: group.CreatedAt,
+		UpdatedAt: group.UpdatedAt,
+	}, nil
+}
+
+func ToGroup(group Group) model.Group {
+	return model.Group{
+		ID:        group.ID.Hex(),
+		Name:      group.Name,
+		TenantID:  group.TenantID,
+		CreatedAt: group.CreatedAt,
+		UpdatedAt: group.UpdatedAt,
+	}
+}
+
+func FromGroupFilter(filter model.GroupFilter) (bson.M, error) {
+	query := bson.M{}
+
+	if filter.ID != nil {
+		objID, err := primitive.ObjectIDFromHex(*filter.ID)
+		if err != nil {
+			return query, ErrInvalidID
+		}
+
+		query["_id"] = objID
+	}
+
+	if filter.IDs != nil {
+		objIDs, err := toObjectIDs(filter.IDs)
+		if err != nil {
+			return query, err
+		}
+
+		query["_id"] = bson.M{"$in": objIDs}
+	}
+
+	scopeToTenant(query, filter.TenantID)
+
+	return query, nil
+}
+
+func FromGroupUpdateData(update model.GroupUpdateData) bson.M {
+	query := bson.M{}
+
+	if update.Name != nil {
+		query["name"] = *update.Name
+	}
+
+	query["updatedAt"] = update.UpdatedAt
+
+	return bson.M{"$set": query}
+}
@@ -0,0 +1,107 @@
+package dao
+
+import (
+	"github.com/sorawaslocked/ap2final_user_service/internal/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+type Invitation struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty"`
+	Email      string             `bson:"email"`
+	Role       string             `bson:"role"`
+	TenantID   string             `bson:"tenantID,omitempty"`
+	OrgRole    string             `bson:"orgRole,omitempty"`
+	TokenHash  string             `bson:"tokenHash"`
+	Status     string             `bson:"status"`
+	InvitedBy  string             `bson:"invitedBy"`
+	AcceptedBy string             `bson:"acceptedBy,omitempty"`
+	ExpiresAt  time.Time          `bson:"expiresAt"`
+	CreatedAt  time.Time          `bson:"createdAt"`
+	UpdatedAt  time.Time          `bson:"updatedAt"`
+}
+
+func FromInvitation(invitation model.Invitation) (Invitation, error) {
+	objID, err := primitive.ObjectIDFromHex(invitation.ID)
+	if err != nil && invitation.ID != "" {
+		return Invitation{}, ErrInvalidID
+	}
+
+	return Invitation{
+		ID:         objID,
+		Email:      invitation.Email,
+		Role:       invitation.Role,
+		TenantID:   invitation.TenantID,
+		OrgRole:    invitation.OrgRole,
+		TokenHash:  invitation.TokenHash,
+		Status:     invitation.Status,
+		InvitedBy:  invitation.InvitedBy,
+		AcceptedBy: invitation.AcceptedBy,
+		ExpiresAt:  invitation.ExpiresAt,
+		CreatedAt:  invitation.CreatedAt,
+		UpdatedAt:  invitation.UpdatedAt,
+	}, nil
+}
+
+func ToInvitation(invitation Invitation) model.Invitation {
+	return model.Invitation{
+		ID:         invitation.ID.Hex(),
+		Email:      invitation.Email,
+		Role:       invitation.Role,
+		TenantID:   invitation.TenantID,
+		OrgRole:    invitation.OrgRole,
+		TokenHash:  invitation.TokenHash,
+		Status:     invitation.Status,
+		InvitedBy:  invitation.InvitedBy,
+		AcceptedBy: invitation.AcceptedBy,
+		ExpiresAt:  invitation.ExpiresAt,
+		CreatedAt:  invitation.CreatedAt,
+		UpdatedAt:  invitation.UpdatedAt,
+	}
+}
+
+func FromInvitationFilter(filter model.InvitationFilter) (bson.M, error) {
+	query := bson.M{}
+
+	if filter.ID != nil {
+		objID, err := primitive.ObjectIDFromHex(*filter.ID)
+		if err != nil {
+			return query, ErrInvalidID
+		}
+
+		query["_id"] = objID
+	}
+
+	if filter.Email != nil {
+		query["email"] = *filter.Email
+	}
+
+	scopeToTenant(query, filter.TenantID)
+
+	if filter.TokenHash != nil {
+		query["tokenHash"] = *filter.TokenHash
+	}
+
+	if filter.Status != nil {
+		query["status"] = *filter.Status
+	}
+
+	return query, nil
+}
+
+func FromInvitationUpdateData(update model.InvitationUpdateData) bson.M {
+	query := bson.M{}
+
+	if update.Status != nil {
+		query["status"] = *update.Status
+	}
+
+	if update.AcceptedBy != nil {
+		query["acceptedBy"] = *update.AcceptedBy
+	}
+
+	query["updatedAt"] = update.UpdatedAt
+
+	return bson.M{"$set": query}
+}
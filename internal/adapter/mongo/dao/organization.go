@@ -0,0 +1,72 @@
+package dao
+
+import (
+	"github.com/sorawaslocked/ap2final_user_service/internal/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+type Organization struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	Name      string             `bson:"name"`
+	Slug      string             `bson:"slug"`
+	CreatedAt time.Time          `bson:"createdAt"`
+	UpdatedAt time.Time          `bson:"updatedAt"`
+}
+
+func FromOrganization(org model.Organization) (Organization, error) {
+	objID, err := primitive.ObjectIDFromHex(org.ID)
+	if err != nil && org.ID != "" {
+		return Organization{}, ErrInvalidID
+	}
+
+	return Organization{
+		ID:        objID,
+		Name:      org.Name,
+		Slug:      org.Slug,
+		CreatedAt: org.CreatedAt,
+		UpdatedAt: org.UpdatedAt,
+	}, nil
+}
+
+func ToOrganization(org Organization) model.Organization {
+	return model.Organization{
+		ID:        org.ID.Hex(),
+		Name:      org.Name,
+		Slug:      org.Slug,
+		CreatedAt: org.CreatedAt,
+		UpdatedAt: org.UpdatedAt,
+	}
+}
+
+func FromOrganizationFilter(filter model.OrganizationFilter) (bson.M, error) {
+	query := bson.M{}
+
+	if filter.ID != nil {
+		objID, err := primitive.ObjectIDFromHex(*filter.ID)
+		if err != nil {
+			return query, ErrInvalidID
+		}
+
+		query["_id"] = objID
+	}
+
+	if filter.Slug != nil {
+		query["slug"] = *filter.Slug
+	}
+
+	return query, nil
+}
+
+func FromOrganizationUpdateData(update model.OrganizationUpdateData) bson.M {
+	query := bson.M{}
+
+	if update.Name != nil {
+		query["name"] = *update.Name
+	}
+
+	query["updatedAt"] = update.UpdatedAt
+
+	return bson.M{"$set": query}
+}
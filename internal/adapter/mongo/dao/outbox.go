@@ -0,0 +1,61 @@
+package dao
+
+import (
+	"github.com/sorawaslocked/ap2final_user_service/internal/model"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+type OutboxMessage struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty"`
+	Subject       string             `bson:"subject"`
+	AggregateID   string             `bson:"aggregateID,omitempty"`
+	Headers       map[string]string  `bson:"headers,omitempty"`
+	Payload       []byte             `bson:"payload"`
+	Sensitive     bool               `bson:"sensitive,omitempty"`
+	Status        string             `bson:"status"`
+	Attempts      int                `bson:"attempts"`
+	LastError     string             `bson:"lastError,omitempty"`
+	NextAttemptAt time.Time          `bson:"nextAttemptAt"`
+	CreatedAt     time.Time          `bson:"createdAt"`
+	SentAt        time.Time          `bson:"sentAt,omitempty"`
+}
+
+func FromOutboxMessage(message model.OutboxMessage) (OutboxMessage, error) {
+	objID, err := primitive.ObjectIDFromHex(message.ID)
+	if err != nil && message.ID != "" {
+		return OutboxMessage{}, ErrInvalidID
+	}
+
+	return OutboxMessage{
+		ID:            objID,
+		Subject:       message.Subject,
+		AggregateID:   message.AggregateID,
+		Headers:       message.Headers,
+		Payload:       message.Payload,
+		Sensitive:     message.Sensitive,
+		Status:        message.Status,
+		Attempts:      message.Attempts,
+		LastError:     message.LastError,
+		NextAttemptAt: message.NextAttemptAt,
+		CreatedAt:     message.CreatedAt,
+		SentAt:        message.SentAt,
+	}, nil
+}
+
+func ToOutboxMessage(message OutboxMessage) model.OutboxMessage {
+	return model.OutboxMessage{
+		ID:            message.ID.Hex(),
+		Subject:       message.Subject,
+		AggregateID:   message.AggregateID,
+		Headers:       message.Headers,
+		Payload:       message.Payload,
+		Sensitive:     message.Sensitive,
+		Status:        message.Status,
+		Attempts:      message.Attempts,
+		LastError:     message.LastError,
+		NextAttemptAt: message.NextAttemptAt,
+		CreatedAt:     message.CreatedAt,
+		SentAt:        message.SentAt,
+	}
+}
@@ -0,0 +1,25 @@
+package dao
+
+import "github.com/sorawaslocked/ap2final_user_service/internal/model"
+
+type Preferences struct {
+	Timezone       string `bson:"timezone,omitempty"`
+	Theme          string `bson:"theme,omitempty"`
+	MarketingOptIn bool   `bson:"marketingOptIn"`
+}
+
+func FromPreferences(preferences model.UserPreferences) Preferences {
+	return Preferences{
+		Timezone:       preferences.Timezone,
+		Theme:          preferences.Theme,
+		MarketingOptIn: preferences.MarketingOptIn,
+	}
+}
+
+func ToPreferences(preferences Preferences) model.UserPreferences {
+	return model.UserPreferences{
+		Timezone:       preferences.Timezone,
+		Theme:          preferences.Theme,
+		MarketingOptIn: preferences.MarketingOptIn,
+	}
+}
@@ -0,0 +1,46 @@
+package dao
+
+import (
+	"github.com/sorawaslocked/ap2final_user_service/internal/model"
+	"time"
+)
+
+type ReplayCheckpoint struct {
+	ID           string    `bson:"_id"`
+	Event        string    `bson:"event"`
+	CreatedAfter time.Time `bson:"createdAfter,omitempty"`
+	Role         string    `bson:"role,omitempty"`
+	LastUserID   string    `bson:"lastUserID,omitempty"`
+	Replayed     int64     `bson:"replayed"`
+	StartedAt    time.Time `bson:"startedAt"`
+	UpdatedAt    time.Time `bson:"updatedAt"`
+	CompletedAt  time.Time `bson:"completedAt,omitempty"`
+}
+
+func FromReplayCheckpoint(checkpoint model.ReplayCheckpoint) ReplayCheckpoint {
+	return ReplayCheckpoint{
+		ID:           checkpoint.ID,
+		Event:        checkpoint.Event,
+		CreatedAfter: checkpoint.CreatedAfter,
+		Role:         checkpoint.Role,
+		LastUserID:   checkpoint.LastUserID,
+		Replayed:     checkpoint.Replayed,
+		StartedAt:    checkpoint.StartedAt,
+		UpdatedAt:    checkpoint.UpdatedAt,
+		CompletedAt:  checkpoint.CompletedAt,
+	}
+}
+
+func ToReplayCheckpoint(checkpoint ReplayCheckpoint) model.ReplayCheckpoint {
+	return model.ReplayCheckpoint{
+		ID:           checkpoint.ID,
+		Event:        checkpoint.Event,
+		CreatedAfter: checkpoint.CreatedAfter,
+		Role:         checkpoint.Role,
+		LastUserID:   checkpoint.LastUserID,
+		Replayed:     checkpoint.Replayed,
+		StartedAt:    checkpoint.StartedAt,
+		UpdatedAt:    checkpoint.UpdatedAt,
+		CompletedAt:  checkpoint.CompletedAt,
+	}
+}
@@ -0,0 +1,126 @@
+package dao
+
+import (
+	"github.com/sorawaslocked/ap2final_user_service/internal/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"regexp"
+	"strings"
+)
+
+const (
+	highlightOpen  = "<em>"
+	highlightClose = "</em>"
+)
+
+// UserSearchHit is a user document decoded together with its text search score.
+type UserSearchHit struct {
+	User  `bson:",inline"`
+	Score float64 `bson:"score"`
+}
+
+// SearchTerms splits the query into plain words, dropping the characters that
+// carry meaning in the $text search syntax (phrases and negation).
+func SearchTerms(query string) []string {
+	fields := strings.Fields(strings.NewReplacer(`"`, " ", `\`, " ").Replace(query))
+
+	terms := make([]string, 0, len(fields))
+	for _, field := range fields {
+		field = strings.TrimLeft(field, "-")
+		if field != "" {
+			terms = append(terms, field)
+		}
+	}
+
+	return terms
+}
+
+func FromUserSearchToTextQuery(terms []string, tenantID *string) bson.M {
+	query := bson.M{
+		"$text":     bson.M{"$search": strings.Join(terms, " ")},
+		"isDeleted": bson.M{"$ne": true},
+	}
+	scopeToTenant(query, tenantID)
+
+	return query
+}
+
+// FromUserSearchToPrefixQuery matches the beginning of the email or phone number.
+// The input is quoted so regex metacharacters are matched literally. Emails
+// are matched on their lowercase copy with a case-sensitive anchored regex,
+// which can use its index.
+func FromUserSearchToPrefixQuery(query string, tenantID *string) bson.M {
+	prefix := "^" + regexp.QuoteMeta(query)
+
+	res := bson.M{
+		"$or": bson.A{
+			bson.M{"emailLower": bson.M{"$regex": "^" + regexp.QuoteMeta(strings.ToLower(query))}},
+			bson.M{"phoneNumber": bson.M{"$regex": prefix}},
+		},
+		"isDeleted": bson.M{"$ne": true},
+	}
+	scopeToTenant(res, tenantID)
+
+	return res
+}
+
+// FromUserSearchToPrefixPipeline ranks the prefix matches in the database
+// and keeps the best limit of them. Prefix matches rank above partial text
+// matches, exact emails first: 10 for the exact email, 5 for an email prefix
+// and 4 for a phone prefix. Ties go to the older user so pages are stable.
+func FromUserSearchToPrefixPipeline(query string, tenantID *string, limit int64) bson.A {
+	emailQuery := strings.ToLower(query)
+
+	hasPrefix := func(field, prefix string) bson.M {
+		return bson.M{"$eq": bson.A{bson.M{"$indexOfCP": bson.A{bson.M{"$ifNull": bson.A{field, ""}}, prefix}}, 0}}
+	}
+
+	score := bson.M{"$switch": bson.M{
+		"branches": bson.A{
+			bson.M{"case": bson.M{"$eq": bson.A{"$emailLower", emailQuery}}, "then": 10},
+			bson.M{"case": hasPrefix("$emailLower", emailQuery), "then": 5},
+			bson.M{"case": hasPrefix("$phoneNumber", query), "then": 4},
+		},
+		"default": 0,
+	}}
+
+	return bson.A{
+		bson.M{"$match": FromUserSearchToPrefixQuery(query, tenantID)},
+		bson.M{"$addFields": bson.M{"score": score}},
+		bson.M{"$sort": bson.D{{Key: "score", Value: -1}, {Key: "_id", Value: 1}}},
+		bson.M{"$limit": limit},
+	}
+}
+
+// Highlight wraps every case-insensitive occurrence of the terms in the user's
+// searchable fields, returning only the fields that matched.
+func Highlight(user model.User, terms []string) map[string]string {
+	if len(terms) == 0 {
+		return nil
+	}
+
+	quoted := make([]string, len(terms))
+	for i, term := range terms {
+		quoted[i] = regexp.QuoteMeta(term)
+	}
+	re := regexp.MustCompile("(?i)" + strings.Join(quoted, "|"))
+
+	fields := map[string]string{
+		"firstName":   user.FirstName,
+		"lastName":    user.LastName,
+		"email":       user.Email,
+		"phoneNumber": user.PhoneNumber,
+	}
+
+	highlights := make(map[string]string)
+	for name, value := range fields {
+		if value == "" || !re.MatchString(value) {
+			continue
+		}
+
+		highlights[name] = re.ReplaceAllStringFunc(value, func(match string) string {
+			return highlightOpen + match + highlightClose
+		})
+	}
+
+	return highlights
+}
@@ -0,0 +1,75 @@
+package dao
+
+import (
+	"go.mongodb.org/mongo-driver/bson"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestSearchTerms(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{"single word", "john", []string{"john"}},
+		{"extra spaces", "  john   doe ", []string{"john", "doe"}},
+		{"phrase quotes", `"john doe"`, []string{"john", "doe"}},
+		{"negation", "john -doe", []string{"john", "doe"}},
+		{"repeated negation", "--doe", []string{"doe"}},
+		{"inner hyphen kept", "jean-luc", []string{"jean-luc"}},
+		{"backslash", `jo\hn`, []string{"jo", "hn"}},
+		{"only operators", `- " \`, []string{}},
+		{"empty", "", []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SearchTerms(tt.query); !slices.Equal(got, tt.want) {
+				t.Errorf("SearchTerms(%q) = %q, want %q", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFromUserSearchToPrefixPipeline(t *testing.T) {
+	tenantID := "org-1"
+
+	pipeline := FromUserSearchToPrefixPipeline("ÉLODIE@", &tenantID, 30)
+	if len(pipeline) != 4 {
+		t.Fatalf("pipeline has %d stages, want 4", len(pipeline))
+	}
+
+	match := pipeline[0].(bson.M)["$match"].(bson.M)
+	if match["tenantID"] != tenantID {
+		t.Errorf("$match tenantID = %v, want %q", match["tenantID"], tenantID)
+	}
+
+	branches := pipeline[1].(bson.M)["$addFields"].(bson.M)["score"].(bson.M)["$switch"].(bson.M)["branches"].(bson.A)
+
+	wantScores := []int{10, 5, 4}
+	if len(branches) != len(wantScores) {
+		t.Fatalf("score has %d branches, want %d", len(branches), len(wantScores))
+	}
+	for i, want := range wantScores {
+		if got := branches[i].(bson.M)["then"]; got != want {
+			t.Errorf("branch %d scores %v, want %d", i, got, want)
+		}
+	}
+
+	exact := branches[0].(bson.M)["case"].(bson.M)["$eq"].(bson.A)
+	if exact[1] != "élodie@" {
+		t.Errorf("exact email compared with %q, want the Unicode lowercase query", exact[1])
+	}
+
+	sort := pipeline[2].(bson.M)["$sort"].(bson.D)
+	wantSort := bson.D{{Key: "score", Value: -1}, {Key: "_id", Value: 1}}
+	if !reflect.DeepEqual(sort, wantSort) {
+		t.Errorf("$sort = %v, want %v", sort, wantSort)
+	}
+
+	if limit := pipeline[3].(bson.M)["$limit"]; limit != int64(30) {
+		t.Errorf("$limit = %v, want 30", limit)
+	}
+}
@@ -0,0 +1,55 @@
+package dao
+
+import (
+	"github.com/sorawaslocked/ap2final_user_service/internal/model"
+	"time"
+)
+
+type Suspension struct {
+	ReasonCode  string    `bson:"reasonCode"`
+	Note        string    `bson:"note,omitempty"`
+	SuspendedBy string    `bson:"suspendedBy"`
+	SuspendedAt time.Time `bson:"suspendedAt"`
+	Until       time.Time `bson:"until,omitempty"`
+
+	LiftedBy string    `bson:"liftedBy,omitempty"`
+	LiftedAt time.Time `bson:"liftedAt,omitempty"`
+}
+
+func FromSuspension(suspension model.Suspension) Suspension {
+	return Suspension{
+		ReasonCode:  suspension.ReasonCode,
+		Note:        suspension.Note,
+		SuspendedBy: suspension.SuspendedBy,
+		SuspendedAt: suspension.SuspendedAt,
+		Until:       suspension.Until,
+		LiftedBy:    suspension.LiftedBy,
+		LiftedAt:    suspension.LiftedAt,
+	}
+}
+
+func ToSuspension(suspension Suspension) model.Suspension {
+	return model.Suspension{
+		ReasonCode:  suspension.ReasonCode,
+		Note:        suspension.Note,
+		SuspendedBy: suspension.SuspendedBy,
+		SuspendedAt: suspension.SuspendedAt,
+		Until:       suspension.Until,
+		LiftedBy:    suspension.LiftedBy,
+		LiftedAt:    suspension.LiftedAt,
+	}
+}
+
+func toSuspensionHistory(history []Suspension) []model.Suspension {
+	if len(history) == 0 {
+		return nil
+	}
+
+	suspensions := make([]model.Suspension, len(history))
+
+	for i, suspension := range history {
+		suspensions[i] = ToSuspension(suspension)
+	}
+
+	return suspensions
+}
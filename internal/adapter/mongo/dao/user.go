@@ -4,6 +4,8 @@ import (
 	"github.com/sorawaslocked/ap2final_user_service/internal/model"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"maps"
+	"strings"
 	"time"
 )
 
@@ -12,14 +14,34 @@ type User struct {
 	FirstName    string             `bson:"firstName"`
 	LastName     string             `bson:"lastName"`
 	Email        string             `bson:"email"`
+	EmailLower   string             `bson:"emailLower,omitempty"`
+	PendingEmail string             `bson:"pendingEmail,omitempty"`
 	PhoneNumber  string             `bson:"phoneNumber"`
+	Locale       string             `bson:"locale,omitempty"`
 	PasswordHash string             `bson:"passwordHash"`
 	Role         string             `bson:"role"`
 	CreatedAt    time.Time          `bson:"createdAt"`
 	UpdatedAt    time.Time          `bson:"updatedAt"`
+	DeletedAt    time.Time          `bson:"deletedAt,omitempty"`
+	TenantID     string             `bson:"tenantID,omitempty"`
+	OrgRole      string             `bson:"orgRole,omitempty"`
+	Version      int64              `bson:"version"`
 
-	IsDeleted bool `bson:"isDeleted"`
-	IsActive  bool `bson:"isActive"`
+	ErasureStatus string    `bson:"erasureStatus,omitempty"`
+	ErasedAt      time.Time `bson:"erasedAt,omitempty"`
+
+	AvatarVersion int64  `bson:"avatarVersion,omitempty"`
+	AvatarURL     string `bson:"avatarURL,omitempty"`
+
+	Preferences Preferences    `bson:"preferences"`
+	Attributes  map[string]any `bson:"attributes,omitempty"`
+
+	Suspension        *Suspension  `bson:"suspension,omitempty"`
+	SuspensionHistory []Suspension `bson:"suspensionHistory,omitempty"`
+
+	IsDeleted     bool `bson:"isDeleted"`
+	IsActive      bool `bson:"isActive"`
+	PhoneVerified bool `bson:"phoneVerified"`
 }
 
 func FromUser(user model.User) (User, error) {
@@ -33,40 +55,115 @@ func FromUser(user model.User) (User, error) {
 		FirstName:    user.FirstName,
 		LastName:     user.LastName,
 		Email:        user.Email,
+		EmailLower:   strings.ToLower(user.Email),
+		PendingEmail: user.PendingEmail,
+		PhoneNumber:  user.PhoneNumber,
+		Locale:       user.Locale,
 		PasswordHash: user.PasswordHash,
 		Role:         user.Role,
 		CreatedAt:    user.CreatedAt,
 		UpdatedAt:    user.UpdatedAt,
-		IsDeleted:    user.IsDeleted,
-		IsActive:     user.IsActive,
+		DeletedAt:    user.DeletedAt,
+		TenantID:     user.TenantID,
+		OrgRole:      user.OrgRole,
+		Version:      user.Version,
+
+		ErasureStatus: user.ErasureStatus,
+		ErasedAt:      user.ErasedAt,
+
+		AvatarVersion: user.AvatarVersion,
+		AvatarURL:     user.AvatarURL,
+
+		Preferences: FromPreferences(user.Preferences),
+		Attributes:  user.Attributes,
+
+		IsDeleted:     user.IsDeleted,
+		IsActive:      user.IsActive,
+		PhoneVerified: user.PhoneVerified,
 	}, nil
 }
 
 func ToUser(user User) model.User {
+	var suspension *model.Suspension
+	if user.Suspension != nil {
+		s := ToSuspension(*user.Suspension)
+		suspension = &s
+	}
+
 	return model.User{
 		ID:           user.ID.Hex(),
 		FirstName:    user.FirstName,
 		LastName:     user.LastName,
 		Email:        user.Email,
+		PendingEmail: user.PendingEmail,
+		PhoneNumber:  user.PhoneNumber,
+		Locale:       user.Locale,
 		PasswordHash: user.PasswordHash,
 		Role:         user.Role,
 		CreatedAt:    user.CreatedAt,
 		UpdatedAt:    user.UpdatedAt,
-		IsDeleted:    user.IsDeleted,
-		IsActive:     user.IsActive,
+		DeletedAt:    user.DeletedAt,
+		TenantID:     user.TenantID,
+		OrgRole:      user.OrgRole,
+		Version:      user.Version,
+
+		ErasureStatus: user.ErasureStatus,
+		ErasedAt:      user.ErasedAt,
+
+		AvatarVersion: user.AvatarVersion,
+		AvatarURL:     user.AvatarURL,
+
+		Preferences: ToPreferences(user.Preferences),
+		Attributes:  user.Attributes,
+
+		Suspension:        suspension,
+		SuspensionHistory: toSuspensionHistory(user.SuspensionHistory),
+
+		IsDeleted:     user.IsDeleted,
+		IsActive:      user.IsActive,
+		PhoneVerified: user.PhoneVerified,
 	}
 }
 
+// FromUserFilter builds a query from the filter. Soft-deleted users are
+// excluded unless IsDeleted or IncludeDeleted is set.
 func FromUserFilter(filter model.UserFilter) (bson.M, error) {
 	query := bson.M{}
 
+	// ID, IDs and IDAfter may be combined, so each adds its own condition on _id.
+	var idConds []bson.M
+
 	if filter.ID != nil {
 		objID, err := primitive.ObjectIDFromHex(*filter.ID)
 		if err != nil {
 			return query, ErrInvalidID
 		}
 
-		query["_id"] = objID
+		idConds = append(idConds, bson.M{"_id": objID})
+	}
+
+	if filter.IDs != nil {
+		objIDs, err := toObjectIDs(filter.IDs)
+		if err != nil {
+			return query, err
+		}
+
+		idConds = append(idConds, bson.M{"_id": bson.M{"$in": objIDs}})
+	}
+
+	if filter.IDAfter != nil {
+		objID, err := primitive.ObjectIDFromHex(*filter.IDAfter)
+		if err != nil {
+			return query, ErrInvalidID
+		}
+
+		idConds = append(idConds, bson.M{"_id": bson.M{"$gt": objID}})
+	}
+
+	if len(idConds) == 1 {
+		maps.Copy(query, idConds[0])
+	} else if len(idConds) > 1 {
+		query["$and"] = idConds
 	}
 
 	if filter.FirstName != nil {
@@ -93,17 +190,89 @@ func FromUserFilter(filter model.UserFilter) (bson.M, error) {
 		query["role"] = *filter.Role
 	}
 
+	scopeToTenant(query, filter.TenantID)
+
+	if filter.OrgRole != nil {
+		query["orgRole"] = *filter.OrgRole
+	}
+
+	if filter.Version != nil {
+		if *filter.Version == 0 {
+			// Documents written before versioning have no version field.
+			query["version"] = bson.M{"$in": bson.A{0, nil}}
+		} else {
+			query["version"] = *filter.Version
+		}
+	}
+
+	if filter.CreatedAfter != nil {
+		query["createdAt"] = bson.M{"$gt": *filter.CreatedAfter}
+	}
+
+	if filter.DeletedBefore != nil {
+		query["deletedAt"] = bson.M{"$lt": *filter.DeletedBefore}
+	}
+
+	if filter.SuspendedUntilBefore != nil {
+		query["suspension.until"] = bson.M{"$lt": *filter.SuspendedUntilBefore}
+	}
+
+	for key, value := range filter.Attributes {
+		query["attributes."+key] = value
+	}
+
 	if filter.IsDeleted != nil {
 		query["isDeleted"] = *filter.IsDeleted
+	} else if !filter.IncludeDeleted {
+		query["isDeleted"] = bson.M{"$ne": true}
 	}
 
 	if filter.IsActive != nil {
 		query["isActive"] = *filter.IsActive
 	}
 
+	if filter.PhoneVerified != nil {
+		query["phoneVerified"] = *filter.PhoneVerified
+	}
+
 	return query, nil
 }
 
+// scopeToTenant restricts the query to one organisation. An empty tenant id
+// matches users outside any organisation.
+func scopeToTenant(query bson.M, tenantID *string) {
+	if tenantID == nil {
+		return
+	}
+
+	if *tenantID == "" {
+		query["tenantID"] = bson.M{"$in": bson.A{"", nil}}
+	} else {
+		query["tenantID"] = *tenantID
+	}
+}
+
+func toObjectIDs(ids []string) ([]primitive.ObjectID, error) {
+	objIDs := make([]primitive.ObjectID, len(ids))
+
+	for i, id := range ids {
+		objID, err := primitive.ObjectIDFromHex(id)
+		if err != nil {
+			return nil, ErrInvalidID
+		}
+
+		objIDs[i] = objID
+	}
+
+	return objIDs, nil
+}
+
+var clearableUserFields = map[string]string{
+	model.UserFieldFirstName:   "firstName",
+	model.UserFieldLastName:    "lastName",
+	model.UserFieldPhoneNumber: "phoneNumber",
+}
+
 func FromUserUpdateData(update model.UserUpdateData) bson.M {
 	query := bson.M{}
 
@@ -117,6 +286,11 @@ func FromUserUpdateData(update model.UserUpdateData) bson.M {
 
 	if update.Email != nil {
 		query["email"] = *update.Email
+		query["emailLower"] = strings.ToLower(*update.Email)
+	}
+
+	if update.PendingEmail != nil {
+		query["pendingEmail"] = *update.PendingEmail
 	}
 
 	if update.PhoneNumber != nil {
@@ -139,7 +313,119 @@ func FromUserUpdateData(update model.UserUpdateData) bson.M {
 		query["isActive"] = *update.IsActive
 	}
 
+	if update.PhoneVerified != nil {
+		query["phoneVerified"] = *update.PhoneVerified
+	}
+
+	if update.ErasureStatus != nil {
+		query["erasureStatus"] = *update.ErasureStatus
+	}
+
+	if update.ErasedAt != nil {
+		query["erasedAt"] = *update.ErasedAt
+	}
+
+	if update.Locale != nil {
+		query["locale"] = *update.Locale
+	}
+
+	if update.Timezone != nil {
+		query["preferences.timezone"] = *update.Timezone
+	}
+
+	if update.Theme != nil {
+		query["preferences.theme"] = *update.Theme
+	}
+
+	if update.MarketingOptIn != nil {
+		query["preferences.marketingOptIn"] = *update.MarketingOptIn
+	}
+
+	if update.AvatarVersion != nil {
+		query["avatarVersion"] = *update.AvatarVersion
+	}
+
+	if update.AvatarURL != nil {
+		query["avatarURL"] = *update.AvatarURL
+	}
+
+	// Attribute keys come from the registry, so they are safe to use in paths.
+	for key, value := range update.SetAttributes {
+		query["attributes."+key] = value
+	}
+
 	query["updatedAt"] = update.UpdatedAt
 
-	return bson.M{"$set": query}
+	unset := bson.M{}
+	push := bson.M{}
+
+	for _, field := range update.Clear {
+		if key, ok := clearableUserFields[field]; ok {
+			unset[key] = ""
+		}
+	}
+
+	if update.TenantID != nil {
+		if *update.TenantID == "" {
+			unset["tenantID"] = ""
+		} else {
+			query["tenantID"] = *update.TenantID
+		}
+	}
+
+	if update.OrgRole != nil {
+		if *update.OrgRole == "" {
+			unset["orgRole"] = ""
+		} else {
+			query["orgRole"] = *update.OrgRole
+		}
+	}
+
+	for _, key := range update.UnsetAttributes {
+		unset["attributes."+key] = ""
+	}
+
+	if update.Suspension != nil {
+		query["suspension"] = FromSuspension(*update.Suspension)
+	}
+
+	if update.SuspensionHistory != nil {
+		history := make([]Suspension, len(update.SuspensionHistory))
+		for i, suspension := range update.SuspensionHistory {
+			history[i] = FromSuspension(suspension)
+		}
+
+		query["suspensionHistory"] = history
+	}
+
+	if update.ArchivedSuspension != nil {
+		push["suspensionHistory"] = FromSuspension(*update.ArchivedSuspension)
+
+		if update.Suspension == nil {
+			unset["suspension"] = ""
+		}
+	}
+
+	if update.DeletedAt != nil {
+		if update.DeletedAt.IsZero() {
+			unset["deletedAt"] = ""
+		} else {
+			query["deletedAt"] = *update.DeletedAt
+		}
+	}
+
+	res := bson.M{
+		"$set": query,
+		"$inc": bson.M{"version": 1},
+	}
+
+	if len(unset) > 0 {
+		res["$unset"] = unset
+	}
+
+	if len(push) > 0 {
+		res["$push"] = push
+	}
+
+	return res
 }
@@ -0,0 +1,82 @@
+package dao
+
+import (
+	"errors"
+	"github.com/sorawaslocked/ap2final_user_service/internal/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"reflect"
+	"testing"
+)
+
+func TestFromUserFilterIDs(t *testing.T) {
+	first := primitive.NewObjectID()
+	second := primitive.NewObjectID()
+	firstHex, secondHex := first.Hex(), second.Hex()
+
+	tests := []struct {
+		name   string
+		filter model.UserFilter
+		want   bson.M
+	}{
+		{
+			name:   "id",
+			filter: model.UserFilter{ID: &firstHex},
+			want:   bson.M{"_id": first},
+		},
+		{
+			name:   "ids",
+			filter: model.UserFilter{IDs: []string{firstHex, secondHex}},
+			want:   bson.M{"_id": bson.M{"$in": []primitive.ObjectID{first, second}}},
+		},
+		{
+			name:   "id after",
+			filter: model.UserFilter{IDAfter: &firstHex},
+			want:   bson.M{"_id": bson.M{"$gt": first}},
+		},
+		{
+			name:   "ids and id after",
+			filter: model.UserFilter{IDs: []string{firstHex, secondHex}, IDAfter: &firstHex},
+			want: bson.M{"$and": []bson.M{
+				{"_id": bson.M{"$in": []primitive.ObjectID{first, second}}},
+				{"_id": bson.M{"$gt": first}},
+			}},
+		},
+		{
+			name:   "id and ids",
+			filter: model.UserFilter{ID: &secondHex, IDs: []string{firstHex}},
+			want: bson.M{"$and": []bson.M{
+				{"_id": second},
+				{"_id": bson.M{"$in": []primitive.ObjectID{first}}},
+			}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.filter.IncludeDeleted = true
+
+			got, err := FromUserFilter(tt.filter)
+			if err != nil {
+				t.Fatalf("FromUserFilter() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FromUserFilter() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFromUserFilterInvalidID(t *testing.T) {
+	invalid := "not-an-id"
+
+	for _, filter := range []model.UserFilter{
+		{ID: &invalid},
+		{IDs: []string{invalid}},
+		{IDAfter: &invalid},
+	} {
+		if _, err := FromUserFilter(filter); !errors.Is(err, ErrInvalidID) {
+			t.Errorf("FromUserFilter(%+v) error = %v, want %v", filter, err, ErrInvalidID)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package dao
+
+import (
+	"github.com/sorawaslocked/ap2final_user_service/internal/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"time"
+)
+
+type Verification struct {
+	UserID    string    `bson:"userID"`
+	Purpose   string    `bson:"purpose"`
+	Key       string    `bson:"key,omitempty"`
+	CodeHash  string    `bson:"codeHash"`
+	Payload   string    `bson:"payload,omitempty"`
+	Attempts  int       `bson:"attempts"`
+	ExpiresAt time.Time `bson:"expiresAt"`
+	CreatedAt time.Time `bson:"createdAt"`
+}
+
+func FromVerification(verification model.Verification) Verification {
+	return Verification{
+		UserID:    verification.UserID,
+		Purpose:   verification.Purpose,
+		Key:       verification.Key,
+		CodeHash:  verification.CodeHash,
+		Payload:   verification.Payload,
+		Attempts:  verification.Attempts,
+		ExpiresAt: verification.ExpiresAt,
+		CreatedAt: verification.CreatedAt,
+	}
+}
+
+func ToVerification(verification Verification) model.Verification {
+	return model.Verification{
+		UserID:    verification.UserID,
+		Purpose:   verification.Purpose,
+		Key:       verification.Key,
+		CodeHash:  verification.CodeHash,
+		Payload:   verification.Payload,
+		Attempts:  verification.Attempts,
+		ExpiresAt: verification.ExpiresAt,
+		CreatedAt: verification.CreatedAt,
+	}
+}
+
+func FromVerificationFilter(filter model.VerificationFilter) bson.M {
+	query := bson.M{}
+
+	if filter.UserID != nil {
+		query["userID"] = *filter.UserID
+	}
+
+	if filter.Purpose != nil {
+		query["purpose"] = *filter.Purpose
+	}
+
+	if filter.CodeHash != nil {
+		query["codeHash"] = *filter.CodeHash
+	}
+
+	return query
+}
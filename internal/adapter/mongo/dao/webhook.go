@@ -0,0 +1,236 @@
+package dao
+
+import (
+	"github.com/sorawaslocked/ap2final_user_service/internal/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+type Webhook struct {
+	ID     primitive.ObjectID `bson:"_id,omitempty"`
+	URL    string             `bson:"url"`
+	Secret string             `bson:"secret"`
+	// EventTypes is always an array so that an empty one can be matched.
+	EventTypes          []string  `bson:"eventTypes"`
+	Description         string    `bson:"description,omitempty"`
+	Enabled             bool      `bson:"enabled"`
+	ConsecutiveFailures int       `bson:"consecutiveFailures"`
+	DisabledAt          time.Time `bson:"disabledAt,omitempty"`
+	CreatedBy           string    `bson:"createdBy"`
+	CreatedAt           time.Time `bson:"createdAt"`
+	UpdatedAt           time.Time `bson:"updatedAt"`
+}
+
+type WebhookDelivery struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty"`
+	WebhookID     string             `bson:"webhookID"`
+	EventID       string             `bson:"eventID"`
+	EventType     string             `bson:"eventType"`
+	UserID        string             `bson:"userID,omitempty"`
+	Payload       []byte             `bson:"payload"`
+	Status        string             `bson:"status"`
+	Attempts      int                `bson:"attempts"`
+	NextAttemptAt time.Time          `bson:"nextAttemptAt"`
+	CreatedAt     time.Time          `bson:"createdAt"`
+	Log           []WebhookAttempt   `bson:"log,omitempty"`
+}
+
+type WebhookAttempt struct {
+	AttemptedAt time.Time     `bson:"attemptedAt"`
+	StatusCode  int           `bson:"statusCode,omitempty"`
+	Error       string        `bson:"error,omitempty"`
+	Duration    time.Duration `bson:"duration"`
+}
+
+func FromWebhook(webhook model.Webhook) (Webhook, error) {
+	objID, err := primitive.ObjectIDFromHex(webhook.ID)
+	if err != nil && webhook.ID != "" {
+		return Webhook{}, ErrInvalidID
+	}
+
+	eventTypes := webhook.EventTypes
+	if eventTypes == nil {
+		eventTypes = []string{}
+	}
+
+	return Webhook{
+		ID:                  objID,
+		URL:                 webhook.URL,
+		Secret:              webhook.Secret,
+		EventTypes:          eventTypes,
+		Description:         webhook.Description,
+		Enabled:             webhook.Enabled,
+		ConsecutiveFailures: webhook.ConsecutiveFailures,
+		DisabledAt:          webhook.DisabledAt,
+		CreatedBy:           webhook.CreatedBy,
+		CreatedAt:           webhook.CreatedAt,
+		UpdatedAt:           webhook.UpdatedAt,
+	}, nil
+}
+
+func ToWebhook(webhook Webhook) model.Webhook {
+	return model.Webhook{
+		ID:                  webhook.ID.Hex(),
+		URL:                 webhook.URL,
+		Secret:              webhook.Secret,
+		EventTypes:          webhook.EventTypes,
+		Description:         webhook.Description,
+		Enabled:             webhook.Enabled,
+		ConsecutiveFailures: webhook.ConsecutiveFailures,
+		DisabledAt:          webhook.DisabledAt,
+		CreatedBy:           webhook.CreatedBy,
+		CreatedAt:           webhook.CreatedAt,
+		UpdatedAt:           webhook.UpdatedAt,
+	}
+}
+
+func FromWebhookFilter(filter model.WebhookFilter) (bson.M, error) {
+	query := bson.M{}
+
+	if filter.ID != nil {
+		objID, err := primitive.ObjectIDFromHex(*filter.ID)
+		if err != nil {
+			return query, ErrInvalidID
+		}
+
+		query["_id"] = objID
+	}
+
+	if filter.Enabled != nil {
+		query["enabled"] = *filter.Enabled
+	}
+
+	if filter.EventType != nil {
+		query["$or"] = bson.A{
+			bson.M{"eventTypes": *filter.EventType},
+			bson.M{"eventTypes": bson.M{"$size": 0}},
+		}
+	}
+
+	return query, nil
+}
+
+func FromWebhookUpdateData(update model.WebhookUpdateData) bson.M {
+	set := bson.M{}
+	unset := bson.M{}
+
+	if update.URL != nil {
+		set["url"] = *update.URL
+	}
+
+	if update.EventTypes != nil {
+		eventTypes := *update.EventTypes
+		if eventTypes == nil {
+			eventTypes = []string{}
+		}
+
+		set["eventTypes"] = eventTypes
+	}
+
+	if update.Description != nil {
+		set["description"] = *update.Description
+	}
+
+	if update.Enabled != nil {
+		set["enabled"] = *update.Enabled
+	}
+
+	if update.ConsecutiveFailures != nil {
+		set["consecutiveFailures"] = *update.ConsecutiveFailures
+	}
+
+	if update.DisabledAt != nil {
+		if update.DisabledAt.IsZero() {
+			unset["disabledAt"] = ""
+		} else {
+			set["disabledAt"] = *update.DisabledAt
+		}
+	}
+
+	set["updatedAt"] = update.UpdatedAt
+
+	query := bson.M{"$set": set}
+	if len(unset) > 0 {
+		query["$unset"] = unset
+	}
+
+	return query
+}
+
+func FromWebhookDelivery(delivery model.WebhookDelivery) (WebhookDelivery, error) {
+	objID, err := primitive.ObjectIDFromHex(delivery.ID)
+	if err != nil && delivery.ID != "" {
+		return WebhookDelivery{}, ErrInvalidID
+	}
+
+	log := make([]WebhookAttempt, len(delivery.Log))
+
+	for i, attempt := range delivery.Log {
+		log[i] = FromWebhookAttempt(attempt)
+	}
+
+	return WebhookDelivery{
+		ID:            objID,
+		WebhookID:     delivery.WebhookID,
+		EventID:       delivery.EventID,
+		EventType:     delivery.EventType,
+		UserID:        delivery.UserID,
+		Payload:       delivery.Payload,
+		Status:        delivery.Status,
+		Attempts:      delivery.Attempts,
+		NextAttemptAt: delivery.NextAttemptAt,
+		CreatedAt:     delivery.CreatedAt,
+		Log:           log,
+	}, nil
+}
+
+func ToWebhookDelivery(delivery WebhookDelivery) model.WebhookDelivery {
+	log := make([]model.WebhookAttempt, len(delivery.Log))
+
+	for i, attempt := range delivery.Log {
+		log[i] = model.WebhookAttempt{
+			AttemptedAt: attempt.AttemptedAt,
+			StatusCode:  attempt.StatusCode,
+			Error:       attempt.Error,
+			Duration:    attempt.Duration,
+		}
+	}
+
+	return model.WebhookDelivery{
+		ID:            delivery.ID.Hex(),
+		WebhookID:     delivery.WebhookID,
+		EventID:       delivery.EventID,
+		EventType:     delivery.EventType,
+		UserID:        delivery.UserID,
+		Payload:       delivery.Payload,
+		Status:        delivery.Status,
+		Attempts:      delivery.Attempts,
+		NextAttemptAt: delivery.NextAttemptAt,
+		CreatedAt:     delivery.CreatedAt,
+		Log:           log,
+	}
+}
+
+func FromWebhookAttempt(attempt model.WebhookAttempt) WebhookAttempt {
+	return WebhookAttempt{
+		AttemptedAt: attempt.AttemptedAt,
+		StatusCode:  attempt.StatusCode,
+		Error:       attempt.Error,
+		Duration:    attempt.Duration,
+	}
+}
+
+func FromWebhookDeliveryFilter(filter model.WebhookDeliveryFilter) bson.M {
+	query := bson.M{}
+
+	if filter.WebhookID != nil {
+		query["webhookID"] = *filter.WebhookID
+	}
+
+	if filter.Status != nil {
+		query["status"] = *filter.Status
+	}
+
+	return query
+}
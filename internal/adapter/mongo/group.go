@@ -0,0 +1,330 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"github.com/sorawaslocked/ap2final_user_service/internal/adapter/mongo/dao"
+	"github.com/sorawaslocked/ap2final_user_service/internal/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+const (
+	collectionGroups           = "groups"
+	collectionGroupMemberships = "group_memberships"
+)
+
+// Group stores groups and, in a separate collection, one document per
+// membership so large groups do not grow a single document.
+type Group struct {
+	col         *mongo.Collection
+	memberships *mongo.Collection
+}
+
+func NewGroup(conn *mongo.Database) *Group {
+	return &Group{
+		col:         conn.Collection(collectionGroups),
+		memberships: conn.Collection(collectionGroupMemberships),
+	}
+}
+
+// EnsureIndexes keeps group names unique within an organisation and a user
+// in a group at most once.
+func (db *Group) EnsureIndexes(ctx context.Context) error {
+	_, err := db.col.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "tenantID", Value: 1}, {Key: "name", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return mongoError("CreateIndexes", err)
+	}
+
+	_, err = db.memberships.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "groupID", Value: 1}, {Key: "userID", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "userID", Value: 1}, {Key: "addedAt", Value: 1}},
+		},
+	})
+	if err != nil {
+		return mongoError("CreateIndexes", err)
+	}
+
+	return nil
+}
+
+func (db *Group) InsertOne(ctx context.Context, group model.Group) (model.Group, error) {
+	groupDao, err := dao.FromGroup(group)
+	if err != nil {
+		return model.Group{}, err
+	}
+
+	res, err := db.col.InsertOne(ctx, groupDao)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return model.Group{}, model.ErrAlreadyExists
+		}
+
+		return model.Group{}, mongoError("InsertOne", err)
+	}
+
+	id := res.InsertedID.(primitive.ObjectID).Hex()
+
+	return db.FindOne(ctx, model.GroupFilter{ID: &id})
+}
+
+func (db *Group) FindOne(ctx context.Context, filter model.GroupFilter) (model.Group, error) {
+	var groupDao dao.Group
+
+	query, err := dao.FromGroupFilter(filter)
+	if err != nil {
+		return model.Group{}, err
+	}
+
+	err = db.col.FindOne(ctx, query).Decode(&groupDao)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return model.Group{}, model.ErrNotFound
+		}
+
+		return model.Group{}, mongoError("FindOne", err)
+	}
+
+	return dao.ToGroup(groupDao), nil
+}
+
+func (db *Group) Find(ctx context.Context, filter model.GroupFilter) ([]model.Group, error) {
+	var groupDaos []dao.Group
+
+	query, err := dao.FromGroupFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	cur, err := db.col.Find(ctx, query, options.Find().SetSort(bson.D{{Key: "name", Value: 1}}))
+	if err != nil {
+		return nil, mongoError("Find", err)
+	}
+
+	if err = cur.All(ctx, &groupDaos); err != nil {
+		return nil, mongoError("Cursor.All", err)
+	}
+
+	groups := make([]model.Group, len(groupDaos))
+
+	for i, groupDao := range groupDaos {
+		groups[i] = dao.ToGroup(groupDao)
+	}
+
+	return groups, nil
+}
+
+func (db *Group) UpdateOne(ctx context.Context, filter model.GroupFilter, update model.GroupUpdateData) (model.Group, error) {
+	var groupDao dao.Group
+
+	query, err := dao.FromGroupFilter(filter)
+	if err != nil {
+		return model.Group{}, err
+	}
+
+	err = db.col.FindOneAndUpdate(
+		ctx,
+		query,
+		dao.FromGroupUpdateData(update),
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&groupDao)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return model.Group{}, model.ErrNotFound
+		}
+		if mongo.IsDuplicateKeyError(err) {
+			return model.Group{}, model.ErrAlreadyExists
+		}
+
+		return model.Group{}, mongoError("FindOneAndUpdate", err)
+	}
+
+	return dao.ToGroup(groupDao), nil
+}
+
+// DeleteOne removes the group together with its memberships.
+func (db *Group) DeleteOne(ctx context.Context, filter model.GroupFilter) (model.Group, error) {
+	var groupDao dao.Group
+
+	query, err := dao.FromGroupFilter(filter)
+	if err != nil {
+		return model.Group{}, err
+	}
+
+	err = db.col.FindOneAndDelete(ctx, query).Decode(&groupDao)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return model.Group{}, model.ErrNotFound
+		}
+
+		return model.Group{}, mongoError("FindOneAndDelete", err)
+	}
+
+	group := dao.ToGroup(groupDao)
+
+	_, err = db.memberships.DeleteMany(ctx, bson.M{"groupID": group.ID})
+	if err != nil {
+		return model.Group{}, mongoError("DeleteMany", err)
+	}
+
+	return group, nil
+}
+
+// AddMembers adds the users to the group and returns those who were not
+// members before. Existing memberships are matched by upsert rather than
+// reported as duplicate key errors, which would abort a transaction.
+func (db *Group) AddMembers(ctx context.Context, groupID string, userIDs []string) ([]string, error) {
+	if len(userIDs) == 0 {
+		return nil, nil
+	}
+
+	now := time.Now().UTC()
+	models := make([]mongo.WriteModel, len(userIDs))
+
+	for i, userID := range userIDs {
+		models[i] = mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"groupID": groupID, "userID": userID}).
+			SetUpdate(bson.M{"$setOnInsert": bson.M{"addedAt": now}}).
+			SetUpsert(true)
+	}
+
+	res, err := db.memberships.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+	if err != nil {
+		return nil, mongoError("BulkWrite", err)
+	}
+
+	added := make([]string, 0, len(res.UpsertedIDs))
+
+	for i, userID := range userIDs {
+		if _, ok := res.UpsertedIDs[int64(i)]; ok {
+			added = append(added, userID)
+		}
+	}
+
+	return added, nil
+}
+
+// RemoveMembers removes the users from the group and returns those who were
+// members.
+func (db *Group) RemoveMembers(ctx context.Context, groupID string, userIDs []string) ([]string, error) {
+	if len(userIDs) == 0 {
+		return nil, nil
+	}
+
+	query := bson.M{"groupID": groupID, "userID": bson.M{"$in": userIDs}}
+
+	removed, err := db.memberUserIDs(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = db.memberships.DeleteMany(ctx, query)
+	if err != nil {
+		return nil, mongoError("DeleteMany", err)
+	}
+
+	return removed, nil
+}
+
+// GroupIDsForUser returns the ids of the user's groups in tenantID, oldest
+// membership first. Memberships left over from another organisation are
+// skipped. A limit of 0 returns all of them.
+func (db *Group) GroupIDsForUser(ctx context.Context, userID, tenantID string, limit int64) ([]string, error) {
+	opts := options.Find().
+		SetSort(bson.D{{Key: "addedAt", Value: 1}}).
+		SetProjection(bson.M{"groupID": 1})
+
+	cur, err := db.memberships.Find(ctx, bson.M{"userID": userID}, opts)
+	if err != nil {
+		return nil, mongoError("Find", err)
+	}
+
+	var memberships []dao.GroupMembership
+	if err = cur.All(ctx, &memberships); err != nil {
+		return nil, mongoError("Cursor.All", err)
+	}
+
+	if len(memberships) == 0 {
+		return []string{}, nil
+	}
+
+	groupIDs := make([]string, len(memberships))
+
+	for i, membership := range memberships {
+		groupIDs[i] = membership.GroupID
+	}
+
+	query, err := dao.FromGroupFilter(model.GroupFilter{IDs: groupIDs, TenantID: &tenantID})
+	if err != nil {
+		return nil, err
+	}
+
+	cur, err = db.col.Find(ctx, query, options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return nil, mongoError("Find", err)
+	}
+
+	var groups []dao.Group
+	if err = cur.All(ctx, &groups); err != nil {
+		return nil, mongoError("Cursor.All", err)
+	}
+
+	inTenant := make(map[string]bool, len(groups))
+
+	for _, group := range groups {
+		inTenant[group.ID.Hex()] = true
+	}
+
+	tenantGroupIDs := make([]string, 0, len(groups))
+
+	for _, groupID := range groupIDs {
+		if limit > 0 && int64(len(tenantGroupIDs)) == limit {
+			break
+		}
+
+		if inTenant[groupID] {
+			tenantGroupIDs = append(tenantGroupIDs, groupID)
+		}
+	}
+
+	return tenantGroupIDs, nil
+}
+
+func (db *Group) DeleteMembershipsByUserID(ctx context.Context, userID string) error {
+	_, err := db.memberships.DeleteMany(ctx, bson.M{"userID": userID})
+	if err != nil {
+		return mongoError("DeleteMany", err)
+	}
+
+	return nil
+}
+
+func (db *Group) memberUserIDs(ctx context.Context, query bson.M) ([]string, error) {
+	cur, err := db.memberships.Find(ctx, query, options.Find().SetProjection(bson.M{"userID": 1}))
+	if err != nil {
+		return nil, mongoError("Find", err)
+	}
+
+	var memberships []dao.GroupMembership
+	if err = cur.All(ctx, &memberships); err != nil {
+		return nil, mongoError("Cursor.All", err)
+	}
+
+	userIDs := make([]string, len(memberships))
+
+	for i, membership := range memberships {
+		userIDs[i] = membership.UserID
+	}
+
+	return userIDs, nil
+}
@@ -0,0 +1,169 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"github.com/sorawaslocked/ap2final_user_service/internal/adapter/mongo/dao"
+	"github.com/sorawaslocked/ap2final_user_service/internal/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const collectionInvitations = "invitations"
+
+type Invitation struct {
+	col *mongo.Collection
+}
+
+func NewInvitation(conn *mongo.Database) *Invitation {
+	return &Invitation{
+		col: conn.Collection(collectionInvitations),
+	}
+}
+
+// EnsureIndexes looks invitations up by token and lists them per organisation.
+// Accepted and revoked invitations are kept as a record.
+func (db *Invitation) EnsureIndexes(ctx context.Context) error {
+	_, err := db.col.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "tokenHash", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "tenantID", Value: 1}, {Key: "status", Value: 1}, {Key: "_id", Value: 1}},
+		},
+	})
+	if err != nil {
+		return mongoError("CreateIndexes", err)
+	}
+
+	return nil
+}
+
+func (db *Invitation) InsertOne(ctx context.Context, invitation model.Invitation) (model.Invitation, error) {
+	invitationDao, err := dao.FromInvitation(invitation)
+	if err != nil {
+		return model.Invitation{}, err
+	}
+
+	res, err := db.col.InsertOne(ctx, invitationDao)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return model.Invitation{}, model.ErrAlreadyExists
+		}
+
+		return model.Invitation{}, mongoError("InsertOne", err)
+	}
+
+	id := res.InsertedID.(primitive.ObjectID).Hex()
+
+	return db.FindOne(ctx, model.InvitationFilter{ID: &id})
+}
+
+func (db *Invitation) FindOne(ctx context.Context, filter model.InvitationFilter) (model.Invitation, error) {
+	var invitationDao dao.Invitation
+
+	query, err := dao.FromInvitationFilter(filter)
+	if err != nil {
+		return model.Invitation{}, err
+	}
+
+	err = db.col.FindOne(ctx, query).Decode(&invitationDao)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return model.Invitation{}, model.ErrNotFound
+		}
+
+		return model.Invitation{}, mongoError("FindOne", err)
+	}
+
+	return dao.ToInvitation(invitationDao), nil
+}
+
+// List returns a page of matching invitations, newest first.
+func (db *Invitation) List(
+	ctx context.Context,
+	filter model.InvitationFilter,
+	limit, offset int64,
+) ([]model.Invitation, error) {
+	var invitationDaos []dao.Invitation
+
+	query, err := dao.FromInvitationFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "_id", Value: -1}}).
+		SetSkip(offset).
+		SetLimit(limit)
+
+	cur, err := db.col.Find(ctx, query, opts)
+	if err != nil {
+		return nil, mongoError("Find", err)
+	}
+
+	if err = cur.All(ctx, &invitationDaos); err != nil {
+		return nil, mongoError("Cursor.All", err)
+	}
+
+	invitations := make([]model.Invitation, len(invitationDaos))
+
+	for i, invitationDao := range invitationDaos {
+		invitations[i] = dao.ToInvitation(invitationDao)
+	}
+
+	return invitations, nil
+}
+
+func (db *Invitation) UpdateOne(
+	ctx context.Context,
+	filter model.InvitationFilter,
+	update model.InvitationUpdateData,
+) (model.Invitation, error) {
+	var invitationDao dao.Invitation
+
+	query, err := dao.FromInvitationFilter(filter)
+	if err != nil {
+		return model.Invitation{}, err
+	}
+
+	err = db.col.FindOneAndUpdate(
+		ctx,
+		query,
+		dao.FromInvitationUpdateData(update),
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&invitationDao)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return model.Invitation{}, model.ErrNotFound
+		}
+
+		return model.Invitation{}, mongoError("FindOneAndUpdate", err)
+	}
+
+	return dao.ToInvitation(invitationDao), nil
+}
+
+// DeleteMany removes every matching invitation. Emails match regardless of
+// case, as they do for users.
+func (db *Invitation) DeleteMany(ctx context.Context, filter model.InvitationFilter) error {
+	query, err := dao.FromInvitationFilter(filter)
+	if err != nil {
+		return err
+	}
+
+	opts := options.Delete()
+	if filter.Email != nil {
+		opts.SetCollation(userCollation)
+	}
+
+	_, err = db.col.DeleteMany(ctx, query, opts)
+	if err != nil {
+		return mongoError("DeleteMany", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,105 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"github.com/sorawaslocked/ap2final_user_service/internal/adapter/mongo/dao"
+	"github.com/sorawaslocked/ap2final_user_service/internal/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const collectionOrganizations = "organizations"
+
+type Organization struct {
+	col *mongo.Collection
+}
+
+func NewOrganization(conn *mongo.Database) *Organization {
+	return &Organization{
+		col: conn.Collection(collectionOrganizations),
+	}
+}
+
+func (db *Organization) EnsureIndexes(ctx context.Context) error {
+	_, err := db.col.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "slug", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return mongoError("CreateIndexes", err)
+	}
+
+	return nil
+}
+
+func (db *Organization) InsertOne(ctx context.Context, org model.Organization) (model.Organization, error) {
+	orgDao, err := dao.FromOrganization(org)
+	if err != nil {
+		return model.Organization{}, err
+	}
+
+	res, err := db.col.InsertOne(ctx, orgDao)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return model.Organization{}, model.ErrAlreadyExists
+		}
+
+		return model.Organization{}, mongoError("InsertOne", err)
+	}
+
+	id := res.InsertedID.(primitive.ObjectID).Hex()
+
+	return db.FindOne(ctx, model.OrganizationFilter{ID: &id})
+}
+
+func (db *Organization) FindOne(ctx context.Context, filter model.OrganizationFilter) (model.Organization, error) {
+	var orgDao dao.Organization
+
+	query, err := dao.FromOrganizationFilter(filter)
+	if err != nil {
+		return model.Organization{}, err
+	}
+
+	err = db.col.FindOne(ctx, query).Decode(&orgDao)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return model.Organization{}, model.ErrNotFound
+		}
+
+		return model.Organization{}, mongoError("FindOne", err)
+	}
+
+	return dao.ToOrganization(orgDao), nil
+}
+
+func (db *Organization) UpdateOne(
+	ctx context.Context,
+	filter model.OrganizationFilter,
+	update model.OrganizationUpdateData,
+) (model.Organization, error) {
+	var orgDao dao.Organization
+
+	query, err := dao.FromOrganizationFilter(filter)
+	if err != nil {
+		return model.Organization{}, err
+	}
+
+	err = db.col.FindOneAndUpdate(
+		ctx,
+		query,
+		dao.FromOrganizationUpdateData(update),
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&orgDao)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return model.Organization{}, model.ErrNotFound
+		}
+
+		return model.Organization{}, mongoError("FindOneAndUpdate", err)
+	}
+
+	return dao.ToOrganization(orgDao), nil
+}
@@ -0,0 +1,225 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"github.com/sorawaslocked/ap2final_user_service/internal/adapter/mongo/dao"
+	"github.com/sorawaslocked/ap2final_user_service/internal/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+const collectionOutbox = "outbox"
+
+// Outbox stores events until the relay has published them. Publish joins the
+// transaction carried by ctx, if any.
+type Outbox struct {
+	col       *mongo.Collection
+	retention time.Duration
+}
+
+func NewOutbox(conn *mongo.Database, retention time.Duration) *Outbox {
+	return &Outbox{
+		col:       conn.Collection(collectionOutbox),
+		retention: retention,
+	}
+}
+
+// EnsureIndexes finds the oldest pending messages and the messages of an
+// aggregate quickly, and lets Mongo drop sent messages after the retention
+// period. Pending and dead messages have no sentAt and never expire.
+func (db *Outbox) EnsureIndexes(ctx context.Context) error {
+	_, err := db.col.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "status", Value: 1}, {Key: "createdAt", Value: 1}, {Key: "_id", Value: 1}},
+		},
+		{
+			Keys: bson.D{{Key: "aggregateID", Value: 1}},
+		},
+		{
+			Keys:    bson.D{{Key: "sentAt", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(int32(db.retention.Seconds())),
+		},
+	})
+	if err != nil {
+		return mongoError("CreateIndexes", err)
+	}
+
+	return nil
+}
+
+// Publish queues an encoded event for the relay. Only the subject, aggregate,
+// headers, payload and sensitivity of message are used.
+func (db *Outbox) Publish(ctx context.Context, message model.OutboxMessage) error {
+	now := time.Now().UTC()
+
+	messageDao, err := dao.FromOutboxMessage(model.OutboxMessage{
+		Subject:       message.Subject,
+		AggregateID:   message.AggregateID,
+		Headers:       message.Headers,
+		Payload:       message.Payload,
+		Sensitive:     message.Sensitive,
+		Status:        model.OutboxStatusPending,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = db.col.InsertOne(ctx, messageDao)
+	if err != nil {
+		return mongoError("InsertOne", err)
+	}
+
+	return nil
+}
+
+// Claim takes the oldest due message whose aggregate has no older pending
+// message and hides it from other relays for the lease. Messages of one
+// aggregate are claimed strictly in the order they were queued: while the
+// oldest one waits for a retry or is leased, nothing after it is claimed, so
+// consumers never see a later event of that aggregate first. Other aggregates
+// are not held up. A message whose relay dies is picked up again once the
+// lease ends.
+func (db *Outbox) Claim(ctx context.Context, now time.Time, lease time.Duration) (model.OutboxMessage, error) {
+	queueOrder := bson.D{{Key: "createdAt", Value: 1}, {Key: "_id", Value: 1}}
+
+	cur, err := db.col.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"status": model.OutboxStatusPending}}},
+		{{Key: "$sort", Value: queueOrder}},
+		{{Key: "$group", Value: bson.M{"_id": "$aggregateID", "head": bson.M{"$first": "$$ROOT"}}}},
+		{{Key: "$replaceWith", Value: "$head"}},
+		{{Key: "$match", Value: bson.M{"nextAttemptAt": bson.M{"$lte": now}}}},
+		{{Key: "$sort", Value: queueOrder}},
+		{{Key: "$limit", Value: 1}},
+	})
+	if err != nil {
+		return model.OutboxMessage{}, mongoError("Aggregate", err)
+	}
+
+	var heads []dao.OutboxMessage
+	if err = cur.All(ctx, &heads); err != nil {
+		return model.OutboxMessage{}, mongoError("Cursor.All", err)
+	}
+
+	if len(heads) == 0 {
+		return model.OutboxMessage{}, model.ErrNotFound
+	}
+
+	head := heads[0]
+
+	var messageDao dao.OutboxMessage
+
+	// Matching the attempt time read above lets only one relay claim it.
+	err = db.col.FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": head.ID, "status": model.OutboxStatusPending, "nextAttemptAt": head.NextAttemptAt},
+		bson.M{"$set": bson.M{"nextAttemptAt": now.Add(lease)}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&messageDao)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return model.OutboxMessage{}, model.ErrNotFound
+		}
+
+		return model.OutboxMessage{}, mongoError("FindOneAndUpdate", err)
+	}
+
+	return dao.ToOutboxMessage(messageDao), nil
+}
+
+func (db *Outbox) MarkSent(ctx context.Context, id string, sentAt time.Time) error {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return dao.ErrInvalidID
+	}
+
+	_, err = db.col.UpdateOne(
+		ctx,
+		bson.M{"_id": objID},
+		bson.M{
+			"$set":   bson.M{"status": model.OutboxStatusSent, "sentAt": sentAt},
+			"$unset": bson.M{"lastError": ""},
+		},
+	)
+	if err != nil {
+		return mongoError("UpdateOne", err)
+	}
+
+	return nil
+}
+
+// Delete removes a sent message that must not be kept, such as one carrying
+// a secret.
+func (db *Outbox) Delete(ctx context.Context, id string) error {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return dao.ErrInvalidID
+	}
+
+	_, err = db.col.DeleteOne(ctx, bson.M{"_id": objID})
+	if err != nil {
+		return mongoError("DeleteOne", err)
+	}
+
+	return nil
+}
+
+// DeleteByAggregateID drops every message about the aggregate, whatever its
+// status.
+func (db *Outbox) DeleteByAggregateID(ctx context.Context, aggregateID string) error {
+	_, err := db.col.DeleteMany(ctx, bson.M{"aggregateID": aggregateID})
+	if err != nil {
+		return mongoError("DeleteMany", err)
+	}
+
+	return nil
+}
+
+// MarkDead records the last failed attempt and gives up on the message.
+func (db *Outbox) MarkDead(ctx context.Context, id string, reason string) error {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return dao.ErrInvalidID
+	}
+
+	_, err = db.col.UpdateOne(
+		ctx,
+		bson.M{"_id": objID},
+		bson.M{
+			"$set": bson.M{"status": model.OutboxStatusDead, "lastError": reason},
+			"$inc": bson.M{"attempts": 1},
+		},
+	)
+	if err != nil {
+		return mongoError("UpdateOne", err)
+	}
+
+	return nil
+}
+
+// MarkFailed records a failed attempt and when to try again.
+func (db *Outbox) MarkFailed(ctx context.Context, id string, nextAttemptAt time.Time, reason string) error {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return dao.ErrInvalidID
+	}
+
+	_, err = db.col.UpdateOne(
+		ctx,
+		bson.M{"_id": objID},
+		bson.M{
+			"$set": bson.M{"nextAttemptAt": nextAttemptAt, "lastError": reason},
+			"$inc": bson.M{"attempts": 1},
+		},
+	)
+	if err != nil {
+		return mongoError("UpdateOne", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,54 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"github.com/sorawaslocked/ap2final_user_service/internal/adapter/mongo/dao"
+	"github.com/sorawaslocked/ap2final_user_service/internal/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const collectionReplayCheckpoints = "replay_checkpoints"
+
+// ReplayCheckpoint stores the position of named replays, keyed by name.
+type ReplayCheckpoint struct {
+	col *mongo.Collection
+}
+
+func NewReplayCheckpoint(conn *mongo.Database) *ReplayCheckpoint {
+	return &ReplayCheckpoint{
+		col: conn.Collection(collectionReplayCheckpoints),
+	}
+}
+
+func (db *ReplayCheckpoint) FindOne(ctx context.Context, id string) (model.ReplayCheckpoint, error) {
+	var checkpoint dao.ReplayCheckpoint
+
+	err := db.col.FindOne(ctx, bson.M{"_id": id}).Decode(&checkpoint)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return model.ReplayCheckpoint{}, model.ErrNotFound
+		}
+
+		return model.ReplayCheckpoint{}, mongoError("FindOne", err)
+	}
+
+	return dao.ToReplayCheckpoint(checkpoint), nil
+}
+
+// Save stores the checkpoint, replacing the saved position of the same run.
+func (db *ReplayCheckpoint) Save(ctx context.Context, checkpoint model.ReplayCheckpoint) error {
+	_, err := db.col.ReplaceOne(
+		ctx,
+		bson.M{"_id": checkpoint.ID},
+		dao.FromReplayCheckpoint(checkpoint),
+		options.Replace().SetUpsert(true),
+	)
+	if err != nil {
+		return mongoError("ReplaceOne", err)
+	}
+
+	return nil
+}
@@ -42,6 +42,27 @@ func (db *Session) FindOneByToken(ctx context.Context, token string) (model.Sess
 	return dao.ToSession(session), nil
 }
 
+func (db *Session) FindByUserID(ctx context.Context, userID string) ([]model.Session, error) {
+	var sessionDaos []dao.Session
+
+	cur, err := db.col.Find(ctx, bson.M{"userID": userID})
+	if err != nil {
+		return []model.Session{}, err
+	}
+
+	if err = cur.All(ctx, &sessionDaos); err != nil {
+		return []model.Session{}, err
+	}
+
+	sessions := make([]model.Session, len(sessionDaos))
+
+	for i, sessionDao := range sessionDaos {
+		sessions[i] = dao.ToSession(sessionDao)
+	}
+
+	return sessions, nil
+}
+
 func (db *Session) DeleteByToken(ctx context.Context, token string) error {
 	res, err := db.col.DeleteOne(ctx, bson.M{"refreshToken": token})
 
@@ -51,3 +72,9 @@ func (db *Session) DeleteByToken(ctx context.Context, token string) error {
 
 	return err
 }
+
+func (db *Session) DeleteByUserID(ctx context.Context, userID string) error {
+	_, err := db.col.DeleteMany(ctx, bson.M{"userID": userID})
+
+	return err
+}
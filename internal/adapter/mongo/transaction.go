@@ -0,0 +1,40 @@
+package mongo
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Transactor runs functions in a Mongo transaction. Repositories join it by
+// using the context passed to the function. Transactions need a replica set.
+type Transactor struct {
+	client *mongo.Client
+}
+
+func NewTransactor(conn *mongo.Database) *Transactor {
+	return &Transactor{
+		client: conn.Client(),
+	}
+}
+
+// WithTransaction commits when fn succeeds and aborts otherwise. fn may run
+// more than once on transient errors, so it must not have side effects
+// outside the database. Called inside another transaction, fn joins it and
+// commits or aborts with it.
+func (t *Transactor) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if mongo.SessionFromContext(ctx) != nil {
+		return fn(ctx)
+	}
+
+	session, err := t.client.StartSession()
+	if err != nil {
+		return mongoError("StartSession", err)
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sessCtx mongo.SessionContext) (any, error) {
+		return nil, fn(sessCtx)
+	})
+
+	return err
+}
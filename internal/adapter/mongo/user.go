@@ -3,22 +3,213 @@ package mongo
 import (
 	"context"
 	"errors"
+	"fmt"
 	"github.com/sorawaslocked/ap2final_user_service/internal/adapter/mongo/dao"
 	"github.com/sorawaslocked/ap2final_user_service/internal/model"
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"sort"
+	"strings"
 )
 
-const userCollection = "users"
+const (
+	userCollection       = "users"
+	userSearchIndex      = "user_search"
+	userEmailIndex       = "email_unique"
+	userEmailLowerIndex  = "email_lower"
+	userTenantEmailIndex = "tenant_email_unique"
+	userPhoneIndex       = "phone_unique"
+	userAttributesIndex  = "attributes_wildcard"
+	userTenantIndex      = "tenant_members"
+	userCollationLocale  = "en"
+)
+
+// Scopes of email uniqueness.
+const (
+	EmailUniqueGlobal = "global"
+	EmailUniqueTenant = "tenant"
+)
+
+// Mongo error codes for dropping an index or collection that does not exist.
+const (
+	codeNamespaceNotFound = 26
+	codeIndexNotFound     = 27
+)
+
+// userCollation compares strings case-insensitively. Queries on email or phone
+// number must use it to hit the unique indexes and match their semantics.
+var userCollation = &options.Collation{Locale: userCollationLocale, Strength: 2}
+
+func collationFor(filter model.UserFilter) *options.Collation {
+	if filter.Email != nil || filter.PhoneNumber != nil {
+		return userCollation
+	}
+
+	return nil
+}
 
 type User struct {
 	col *mongo.Collection
+	// emailUniqueness is EmailUniqueGlobal or EmailUniqueTenant.
+	emailUniqueness string
 }
 
-func NewUser(conn *mongo.Database) *User {
+func NewUser(conn *mongo.Database, emailUniqueness string) *User {
 	return &User{
-		col: conn.Collection(userCollection),
+		col:             conn.Collection(userCollection),
+		emailUniqueness: emailUniqueness,
+	}
+}
+
+// EnsureIndexes creates the user indexes. Switching the email uniqueness scope
+// drops the unique index of the other scope.
+func (db *User) EnsureIndexes(ctx context.Context) error {
+	emailIndex, staleEmailIndex, err := db.emailIndex()
+	if err != nil {
+		return err
+	}
+
+	if err = dropIndex(ctx, db.col, staleEmailIndex); err != nil {
+		return err
+	}
+
+	_, err = db.col.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys: bson.D{
+				{Key: "firstName", Value: "text"},
+				{Key: "lastName", Value: "text"},
+				{Key: "email", Value: "text"},
+				{Key: "phoneNumber", Value: "text"},
+			},
+			Options: options.Index().
+				SetName(userSearchIndex).
+				SetWeights(bson.D{
+					{Key: "email", Value: 5},
+					{Key: "firstName", Value: 3},
+					{Key: "lastName", Value: 3},
+					{Key: "phoneNumber", Value: 2},
+				}),
+		},
+		emailIndex,
+		{
+			Keys: bson.D{{Key: "phoneNumber", Value: 1}},
+			Options: options.Index().
+				SetName(userPhoneIndex).
+				SetUnique(true).
+				SetCollation(userCollation).
+				SetPartialFilterExpression(bson.M{
+					"phoneNumber": bson.M{"$type": "string", "$gt": ""},
+				}),
+		},
+		{
+			Keys:    bson.D{{Key: "emailLower", Value: 1}},
+			Options: options.Index().SetName(userEmailLowerIndex),
+		},
+		{
+			Keys:    bson.D{{Key: "attributes.$**", Value: 1}},
+			Options: options.Index().SetName(userAttributesIndex),
+		},
+		{
+			Keys:    bson.D{{Key: "tenantID", Value: 1}, {Key: "orgRole", Value: 1}},
+			Options: options.Index().SetName(userTenantIndex),
+		},
+	})
+	if err != nil {
+		return mongoError("CreateIndexes", err)
+	}
+
+	return db.backfillEmailLower(ctx)
+}
+
+// backfillEmailLower fills in the lowercase email of users stored before it
+// existed. Mongo's $toLower only folds ASCII letters, so the lowercase copy is
+// computed here the same way as for new users. Non-ASCII emails are checked
+// again to repair copies written by an earlier $toLower backfill.
+func (db *User) backfillEmailLower(ctx context.Context) error {
+	cur, err := db.col.Find(
+		ctx,
+		bson.M{
+			"email": bson.M{"$type": "string", "$gt": ""},
+			"$or": bson.A{
+				bson.M{"emailLower": bson.M{"$exists": false}},
+				bson.M{"email": bson.M{"$regex": `[^\x00-\x7F]`}},
+			},
+		},
+		options.Find().SetProjection(bson.M{"email": 1, "emailLower": 1}),
+	)
+	if err != nil {
+		return mongoError("Find", err)
+	}
+	defer cur.Close(ctx)
+
+	for cur.Next(ctx) {
+		var userDao dao.User
+		if err = cur.Decode(&userDao); err != nil {
+			return mongoError("Cursor.Decode", err)
+		}
+
+		emailLower := strings.ToLower(userDao.Email)
+		if userDao.EmailLower == emailLower {
+			continue
+		}
+
+		_, err = db.col.UpdateOne(
+			ctx,
+			bson.M{"_id": userDao.ID, "email": userDao.Email},
+			bson.M{"$set": bson.M{"emailLower": emailLower}},
+		)
+		if err != nil {
+			return mongoError("UpdateOne", err)
+		}
+	}
+
+	if err = cur.Err(); err != nil {
+		return mongoError("Cursor.Next", err)
+	}
+
+	return nil
+}
+
+func (db *User) emailIndex() (mongo.IndexModel, string, error) {
+	switch db.emailUniqueness {
+	case EmailUniqueGlobal:
+		return mongo.IndexModel{
+			Keys: bson.D{{Key: "email", Value: 1}},
+			Options: options.Index().
+				SetName(userEmailIndex).
+				SetUnique(true).
+				SetCollation(userCollation),
+		}, userTenantEmailIndex, nil
+	case EmailUniqueTenant:
+		// Users outside any organisation have no tenantID and share one scope.
+		return mongo.IndexModel{
+			Keys: bson.D{{Key: "tenantID", Value: 1}, {Key: "email", Value: 1}},
+			Options: options.Index().
+				SetName(userTenantEmailIndex).
+				SetUnique(true).
+				SetCollation(userCollation),
+		}, userEmailIndex, nil
+	default:
+		return mongo.IndexModel{}, "", fmt.Errorf("unknown email uniqueness %q", db.emailUniqueness)
+	}
+}
+
+// dropIndex drops the named index, if the collection and index exist.
+func dropIndex(ctx context.Context, col *mongo.Collection, name string) error {
+	_, err := col.Indexes().DropOne(ctx, name)
+
+	var cmdErr mongo.CommandError
+	if errors.As(err, &cmdErr) && (cmdErr.Code == codeIndexNotFound || cmdErr.Code == codeNamespaceNotFound) {
+		return nil
+	}
+
+	if err != nil {
+		return mongoError("DropIndex", err)
 	}
+
+	return nil
 }
 
 func (db *User) InsertOne(ctx context.Context, user model.User) (model.User, error) {
@@ -27,8 +218,14 @@ func (db *User) InsertOne(ctx context.Context, user model.User) (model.User, err
 		return model.User{}, err
 	}
 
+	userDao.Version = 1
+
 	res, err := db.col.InsertOne(ctx, userDao)
 	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return model.User{}, model.ErrAlreadyExists
+		}
+
 		return model.User{}, mongoError("InsertOne", err)
 	}
 
@@ -45,7 +242,7 @@ func (db *User) FindOne(ctx context.Context, filter model.UserFilter) (model.Use
 		return model.User{}, err
 	}
 
-	err = db.col.FindOne(ctx, query).Decode(&userDao)
+	err = db.col.FindOne(ctx, query, options.FindOne().SetCollation(collationFor(filter))).Decode(&userDao)
 	if err != nil {
 		if errors.Is(err, mongo.ErrNoDocuments) {
 			return model.User{}, model.ErrNotFound
@@ -65,7 +262,7 @@ func (db *User) Find(ctx context.Context, filter model.UserFilter) ([]model.User
 		return []model.User{}, err
 	}
 
-	cur, err := db.col.Find(ctx, query)
+	cur, err := db.col.Find(ctx, query, options.Find().SetCollation(collationFor(filter)))
 	if err != nil {
 		if errors.Is(err, mongo.ErrNoDocuments) {
 			return []model.User{}, model.ErrNotFound
@@ -87,26 +284,87 @@ func (db *User) Find(ctx context.Context, filter model.UserFilter) ([]model.User
 	return users, nil
 }
 
+// List returns a page of users matching the filter, oldest first.
+func (db *User) List(ctx context.Context, filter model.UserFilter, limit, offset int64) ([]model.User, error) {
+	var userDaos []dao.User
+
+	query, err := dao.FromUserFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := options.Find().
+		SetCollation(collationFor(filter)).
+		SetSort(bson.D{{Key: "_id", Value: 1}}).
+		SetSkip(offset).
+		SetLimit(limit)
+
+	cur, err := db.col.Find(ctx, query, opts)
+	if err != nil {
+		return nil, mongoError("Find", err)
+	}
+
+	if err = cur.All(ctx, &userDaos); err != nil {
+		return nil, mongoError("Cursor.All", err)
+	}
+
+	users := make([]model.User, len(userDaos))
+
+	for i, userDao := range userDaos {
+		users[i] = dao.ToUser(userDao)
+	}
+
+	return users, nil
+}
+
 func (db *User) UpdateOne(ctx context.Context, filter model.UserFilter, update model.UserUpdateData) (model.User, error) {
 	query, err := dao.FromUserFilter(filter)
 	if err != nil {
 		return model.User{}, err
 	}
 
-	res, err := db.col.UpdateOne(
+	var userDao dao.User
+
+	// The filter may no longer match after the update (e.g. restoring a deleted
+	// user), so the updated document is returned by the update itself.
+	err = db.col.FindOneAndUpdate(
 		ctx,
 		query,
 		dao.FromUserUpdateData(update),
-	)
+		options.FindOneAndUpdate().
+			SetReturnDocument(options.After).
+			SetCollation(collationFor(filter)),
+	).Decode(&userDao)
 	if err != nil {
-		return model.User{}, mongoError("UpdateOne", err)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return model.User{}, db.versionConflict(ctx, filter)
+		}
+
+		if mongo.IsDuplicateKeyError(err) {
+			return model.User{}, model.ErrAlreadyExists
+		}
+
+		return model.User{}, mongoError("FindOneAndUpdate", err)
 	}
 
-	if res.MatchedCount == 0 {
-		return model.User{}, model.ErrNotFound
+	return dao.ToUser(userDao), nil
+}
+
+// versionConflict explains why a versioned update matched nothing: either the
+// user does not exist or it has moved on to another version.
+func (db *User) versionConflict(ctx context.Context, filter model.UserFilter) error {
+	if filter.Version == nil {
+		return model.ErrNotFound
 	}
 
-	return db.FindOne(ctx, filter)
+	filter.Version = nil
+
+	current, err := db.FindOne(ctx, filter)
+	if err != nil {
+		return err
+	}
+
+	return &model.VersionConflictError{CurrentVersion: current.Version}
 }
 
 func (db *User) DeleteOne(ctx context.Context, filter model.UserFilter) (model.User, error) {
@@ -117,19 +375,95 @@ func (db *User) DeleteOne(ctx context.Context, filter model.UserFilter) (model.U
 		return model.User{}, err
 	}
 
-	err = db.col.FindOne(ctx, query).Decode(&userDao)
+	err = db.col.FindOneAndDelete(
+		ctx,
+		query,
+		options.FindOneAndDelete().SetCollation(collationFor(filter)),
+	).Decode(&userDao)
 	if err != nil {
 		if errors.Is(err, mongo.ErrNoDocuments) {
 			return model.User{}, model.ErrNotFound
 		}
 
-		return model.User{}, mongoError("FindOne", err)
+		return model.User{}, mongoError("FindOneAndDelete", err)
 	}
 
-	_, err = db.col.DeleteOne(ctx, query)
+	return dao.ToUser(userDao), nil
+}
+
+// Search merges ranked full-text matches with case-insensitive prefix matches on
+// email and phone number. Both queries rank their matches in the database and
+// fetch the best ones needed to cover the requested page, which is then cut
+// from the merged result.
+func (db *User) Search(ctx context.Context, search model.UserSearch) ([]model.UserSearchResult, error) {
+	terms := dao.SearchTerms(search.Query)
+	window := search.Offset + search.Limit
+
+	results := make(map[string]*model.UserSearchResult)
+
+	if len(terms) > 0 {
+		opts := options.Find().
+			SetProjection(bson.M{"score": bson.M{"$meta": "textScore"}}).
+			SetSort(bson.M{"score": bson.M{"$meta": "textScore"}}).
+			SetLimit(window)
+
+		cur, err := db.col.Find(ctx, dao.FromUserSearchToTextQuery(terms, search.TenantID), opts)
+		if err != nil {
+			return nil, mongoError("Find", err)
+		}
+
+		var hits []dao.UserSearchHit
+		if err = cur.All(ctx, &hits); err != nil {
+			return nil, mongoError("Cursor.All", err)
+		}
+
+		for _, hit := range hits {
+			user := dao.ToUser(hit.User)
+			results[user.ID] = &model.UserSearchResult{User: user, Score: hit.Score}
+		}
+	}
+
+	cur, err := db.col.Aggregate(ctx, dao.FromUserSearchToPrefixPipeline(search.Query, search.TenantID, window))
 	if err != nil {
-		return model.User{}, mongoError("DeleteOne", err)
+		return nil, mongoError("Aggregate", err)
 	}
 
-	return dao.ToUser(userDao), nil
+	var prefixHits []dao.UserSearchHit
+	if err = cur.All(ctx, &prefixHits); err != nil {
+		return nil, mongoError("Cursor.All", err)
+	}
+
+	for _, hit := range prefixHits {
+		user := dao.ToUser(hit.User)
+
+		result, ok := results[user.ID]
+		if !ok {
+			result = &model.UserSearchResult{User: user}
+			results[user.ID] = result
+		}
+
+		result.Score += hit.Score
+	}
+
+	ranked := make([]model.UserSearchResult, 0, len(results))
+	for _, result := range results {
+		result.Highlights = dao.Highlight(result.User, terms)
+		ranked = append(ranked, *result)
+	}
+
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].Score != ranked[j].Score {
+			return ranked[i].Score > ranked[j].Score
+		}
+
+		return ranked[i].User.ID < ranked[j].User.ID
+	})
+
+	if search.Offset >= int64(len(ranked)) {
+		return []model.UserSearchResult{}, nil
+	}
+
+	end := min(window, int64(len(ranked)))
+
+	return ranked[search.Offset:end], nil
 }
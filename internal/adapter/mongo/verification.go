@@ -0,0 +1,142 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"github.com/sorawaslocked/ap2final_user_service/internal/adapter/mongo/dao"
+	"github.com/sorawaslocked/ap2final_user_service/internal/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	collectionVerifications  = "verifications"
+	verificationPendingIndex = "pending_unique"
+	// verificationStaleIndex was unique per user and purpose, without the key.
+	verificationStaleIndex = "userID_1_purpose_1"
+)
+
+type Verification struct {
+	col *mongo.Collection
+}
+
+func NewVerification(conn *mongo.Database) *Verification {
+	return &Verification{
+		col: conn.Collection(collectionVerifications),
+	}
+}
+
+// EnsureIndexes lets Mongo drop expired verifications and keeps a single
+// pending verification per user, purpose and key.
+func (db *Verification) EnsureIndexes(ctx context.Context) error {
+	if err := dropIndex(ctx, db.col, verificationStaleIndex); err != nil {
+		return err
+	}
+
+	_, err := db.col.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+		{
+			Keys:    bson.D{{Key: "userID", Value: 1}, {Key: "purpose", Value: 1}, {Key: "key", Value: 1}},
+			Options: options.Index().SetName(verificationPendingIndex).SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "codeHash", Value: 1}},
+		},
+	})
+	if err != nil {
+		return mongoError("CreateIndexes", err)
+	}
+
+	return nil
+}
+
+// Save stores the verification, replacing any pending one for the same user,
+// purpose and key.
+func (db *Verification) Save(ctx context.Context, verification model.Verification) error {
+	filter := bson.M{"userID": verification.UserID, "purpose": verification.Purpose, "key": verification.Key}
+	if verification.Key == "" {
+		filter["key"] = bson.M{"$exists": false}
+	}
+
+	_, err := db.col.ReplaceOne(
+		ctx,
+		filter,
+		dao.FromVerification(verification),
+		options.Replace().SetUpsert(true),
+	)
+	if err != nil {
+		return mongoError("ReplaceOne", err)
+	}
+
+	return nil
+}
+
+func (db *Verification) FindOne(ctx context.Context, filter model.VerificationFilter) (model.Verification, error) {
+	var verification dao.Verification
+
+	err := db.col.FindOne(ctx, dao.FromVerificationFilter(filter)).Decode(&verification)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return model.Verification{}, model.ErrNotFound
+		}
+
+		return model.Verification{}, mongoError("FindOne", err)
+	}
+
+	return dao.ToVerification(verification), nil
+}
+
+// ClaimAttempt counts an attempt at the verification and returns it as it was
+// before. The count is checked and raised in one step, so concurrent guesses
+// cannot exceed maxAttempts; once they are used up it returns
+// model.ErrTooManyAttempts.
+func (db *Verification) ClaimAttempt(
+	ctx context.Context,
+	userID, purpose string,
+	maxAttempts int,
+) (model.Verification, error) {
+	var verification dao.Verification
+
+	err := db.col.FindOneAndUpdate(
+		ctx,
+		bson.M{"userID": userID, "purpose": purpose, "attempts": bson.M{"$lt": maxAttempts}},
+		bson.M{"$inc": bson.M{"attempts": 1}},
+		options.FindOneAndUpdate().SetReturnDocument(options.Before),
+	).Decode(&verification)
+	if err == nil {
+		return dao.ToVerification(verification), nil
+	}
+	if !errors.Is(err, mongo.ErrNoDocuments) {
+		return model.Verification{}, mongoError("FindOneAndUpdate", err)
+	}
+
+	_, err = db.FindOne(ctx, model.VerificationFilter{UserID: &userID, Purpose: &purpose})
+	if err != nil {
+		return model.Verification{}, err
+	}
+
+	return model.Verification{}, model.ErrTooManyAttempts
+}
+
+// DeleteMany removes every verification matching the filter.
+func (db *Verification) DeleteMany(ctx context.Context, filter model.VerificationFilter) error {
+	_, err := db.col.DeleteMany(ctx, dao.FromVerificationFilter(filter))
+	if err != nil {
+		return mongoError("DeleteMany", err)
+	}
+
+	return nil
+}
+
+func (db *Verification) DeleteOne(ctx context.Context, userID, purpose string) error {
+	_, err := db.col.DeleteOne(ctx, bson.M{"userID": userID, "purpose": purpose})
+	if err != nil {
+		return mongoError("DeleteOne", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,391 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"github.com/sorawaslocked/ap2final_user_service/internal/adapter/mongo/dao"
+	"github.com/sorawaslocked/ap2final_user_service/internal/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+const (
+	collectionWebhooks          = "webhooks"
+	collectionWebhookDeliveries = "webhook_deliveries"
+)
+
+// Webhook stores webhooks and the deliveries queued for them. Enqueue joins
+// the transaction carried by ctx, if any.
+type Webhook struct {
+	col        *mongo.Collection
+	deliveries *mongo.Collection
+	retention  time.Duration
+}
+
+func NewWebhook(conn *mongo.Database, retention time.Duration) *Webhook {
+	return &Webhook{
+		col:        conn.Collection(collectionWebhooks),
+		deliveries: conn.Collection(collectionWebhookDeliveries),
+		retention:  retention,
+	}
+}
+
+// EnsureIndexes finds due deliveries quickly, lists them per webhook, finds
+// those about a user and lets Mongo drop deliveries after the retention
+// period.
+func (db *Webhook) EnsureIndexes(ctx context.Context) error {
+	_, err := db.col.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "enabled", Value: 1}, {Key: "eventTypes", Value: 1}},
+	})
+	if err != nil {
+		return mongoError("CreateIndexes", err)
+	}
+
+	_, err = db.deliveries.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "status", Value: 1}, {Key: "nextAttemptAt", Value: 1}},
+		},
+		{
+			Keys: bson.D{{Key: "webhookID", Value: 1}, {Key: "_id", Value: -1}},
+		},
+		{
+			Keys: bson.D{{Key: "userID", Value: 1}},
+		},
+		{
+			Keys:    bson.D{{Key: "createdAt", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(int32(db.retention.Seconds())),
+		},
+	})
+	if err != nil {
+		return mongoError("CreateIndexes", err)
+	}
+
+	return nil
+}
+
+func (db *Webhook) InsertOne(ctx context.Context, webhook model.Webhook) (model.Webhook, error) {
+	webhookDao, err := dao.FromWebhook(webhook)
+	if err != nil {
+		return model.Webhook{}, err
+	}
+
+	res, err := db.col.InsertOne(ctx, webhookDao)
+	if err != nil {
+		return model.Webhook{}, mongoError("InsertOne", err)
+	}
+
+	id := res.InsertedID.(primitive.ObjectID).Hex()
+
+	return db.FindOne(ctx, model.WebhookFilter{ID: &id})
+}
+
+func (db *Webhook) FindOne(ctx context.Context, filter model.WebhookFilter) (model.Webhook, error) {
+	var webhookDao dao.Webhook
+
+	query, err := dao.FromWebhookFilter(filter)
+	if err != nil {
+		return model.Webhook{}, err
+	}
+
+	err = db.col.FindOne(ctx, query).Decode(&webhookDao)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return model.Webhook{}, model.ErrNotFound
+		}
+
+		return model.Webhook{}, mongoError("FindOne", err)
+	}
+
+	return dao.ToWebhook(webhookDao), nil
+}
+
+// List returns a page of matching webhooks, newest first.
+func (db *Webhook) List(ctx context.Context, filter model.WebhookFilter, limit, offset int64) ([]model.Webhook, error) {
+	var webhookDaos []dao.Webhook
+
+	query, err := dao.FromWebhookFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "_id", Value: -1}}).
+		SetSkip(offset).
+		SetLimit(limit)
+
+	cur, err := db.col.Find(ctx, query, opts)
+	if err != nil {
+		return nil, mongoError("Find", err)
+	}
+
+	if err = cur.All(ctx, &webhookDaos); err != nil {
+		return nil, mongoError("Cursor.All", err)
+	}
+
+	webhooks := make([]model.Webhook, len(webhookDaos))
+
+	for i, webhookDao := range webhookDaos {
+		webhooks[i] = dao.ToWebhook(webhookDao)
+	}
+
+	return webhooks, nil
+}
+
+func (db *Webhook) UpdateOne(
+	ctx context.Context,
+	filter model.WebhookFilter,
+	update model.WebhookUpdateData,
+) (model.Webhook, error) {
+	var webhookDao dao.Webhook
+
+	query, err := dao.FromWebhookFilter(filter)
+	if err != nil {
+		return model.Webhook{}, err
+	}
+
+	err = db.col.FindOneAndUpdate(
+		ctx,
+		query,
+		dao.FromWebhookUpdateData(update),
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&webhookDao)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return model.Webhook{}, model.ErrNotFound
+		}
+
+		return model.Webhook{}, mongoError("FindOneAndUpdate", err)
+	}
+
+	return dao.ToWebhook(webhookDao), nil
+}
+
+// DeleteOne removes the webhook together with its deliveries.
+func (db *Webhook) DeleteOne(ctx context.Context, filter model.WebhookFilter) (model.Webhook, error) {
+	var webhookDao dao.Webhook
+
+	query, err := dao.FromWebhookFilter(filter)
+	if err != nil {
+		return model.Webhook{}, err
+	}
+
+	err = db.col.FindOneAndDelete(ctx, query).Decode(&webhookDao)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return model.Webhook{}, model.ErrNotFound
+		}
+
+		return model.Webhook{}, mongoError("FindOneAndDelete", err)
+	}
+
+	webhook := dao.ToWebhook(webhookDao)
+
+	_, err = db.deliveries.DeleteMany(ctx, bson.M{"webhookID": webhook.ID})
+	if err != nil {
+		return model.Webhook{}, mongoError("DeleteMany", err)
+	}
+
+	return webhook, nil
+}
+
+// RecordSuccess resets the failure count after a delivered attempt.
+func (db *Webhook) RecordSuccess(ctx context.Context, id string) error {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return dao.ErrInvalidID
+	}
+
+	_, err = db.col.UpdateOne(ctx, bson.M{"_id": objID}, bson.M{"$set": bson.M{"consecutiveFailures": 0}})
+	if err != nil {
+		return mongoError("UpdateOne", err)
+	}
+
+	return nil
+}
+
+// RecordFailure counts a failed attempt and disables the webhook once
+// disableAfter attempts in a row have failed. It reports whether this call
+// disabled the webhook.
+func (db *Webhook) RecordFailure(ctx context.Context, id string, disableAfter int, now time.Time) (bool, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return false, dao.ErrInvalidID
+	}
+
+	var webhookDao dao.Webhook
+
+	err = db.col.FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": objID},
+		bson.M{"$inc": bson.M{"consecutiveFailures": 1}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&webhookDao)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return false, model.ErrNotFound
+		}
+
+		return false, mongoError("FindOneAndUpdate", err)
+	}
+
+	if !webhookDao.Enabled || webhookDao.ConsecutiveFailures < disableAfter {
+		return false, nil
+	}
+
+	res, err := db.col.UpdateOne(
+		ctx,
+		bson.M{"_id": objID, "enabled": true},
+		bson.M{"$set": bson.M{"enabled": false, "disabledAt": now, "updatedAt": now}},
+	)
+	if err != nil {
+		return false, mongoError("UpdateOne", err)
+	}
+
+	return res.ModifiedCount > 0, nil
+}
+
+// Enqueue queues the event about the user for every enabled webhook
+// subscribed to it.
+func (db *Webhook) Enqueue(ctx context.Context, eventType, eventID, userID string, payload []byte) error {
+	enabled := true
+
+	webhooks, err := db.List(ctx, model.WebhookFilter{Enabled: &enabled, EventType: &eventType}, 0, 0)
+	if err != nil {
+		return err
+	}
+
+	if len(webhooks) == 0 {
+		return nil
+	}
+
+	now := time.Now().UTC()
+	docs := make([]any, len(webhooks))
+
+	for i, webhook := range webhooks {
+		deliveryDao, err := dao.FromWebhookDelivery(model.WebhookDelivery{
+			WebhookID:     webhook.ID,
+			EventID:       eventID,
+			EventType:     eventType,
+			UserID:        userID,
+			Payload:       payload,
+			Status:        model.WebhookDeliveryPending,
+			NextAttemptAt: now,
+			CreatedAt:     now,
+		})
+		if err != nil {
+			return err
+		}
+
+		docs[i] = deliveryDao
+	}
+
+	_, err = db.deliveries.InsertMany(ctx, docs)
+	if err != nil {
+		return mongoError("InsertMany", err)
+	}
+
+	return nil
+}
+
+// DeleteDeliveriesByUserID drops every delivery of an event about the user,
+// delivered or not.
+func (db *Webhook) DeleteDeliveriesByUserID(ctx context.Context, userID string) error {
+	_, err := db.deliveries.DeleteMany(ctx, bson.M{"userID": userID})
+	if err != nil {
+		return mongoError("DeleteMany", err)
+	}
+
+	return nil
+}
+
+// ClaimDelivery takes the oldest due delivery and hides it from other
+// dispatchers for the lease.
+func (db *Webhook) ClaimDelivery(
+	ctx context.Context,
+	now time.Time,
+	lease time.Duration,
+) (model.WebhookDelivery, error) {
+	var deliveryDao dao.WebhookDelivery
+
+	err := db.deliveries.FindOneAndUpdate(
+		ctx,
+		bson.M{"status": model.WebhookDeliveryPending, "nextAttemptAt": bson.M{"$lte": now}},
+		bson.M{"$set": bson.M{"nextAttemptAt": now.Add(lease)}},
+		options.FindOneAndUpdate().
+			SetSort(bson.D{{Key: "nextAttemptAt", Value: 1}}).
+			SetReturnDocument(options.After),
+	).Decode(&deliveryDao)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return model.WebhookDelivery{}, model.ErrNotFound
+		}
+
+		return model.WebhookDelivery{}, mongoError("FindOneAndUpdate", err)
+	}
+
+	return dao.ToWebhookDelivery(deliveryDao), nil
+}
+
+// RecordAttempt appends the attempt to the delivery log and moves the
+// delivery to status, to be tried again at nextAttemptAt if still pending.
+func (db *Webhook) RecordAttempt(
+	ctx context.Context,
+	id string,
+	attempt model.WebhookAttempt,
+	status string,
+	nextAttemptAt time.Time,
+) error {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return dao.ErrInvalidID
+	}
+
+	_, err = db.deliveries.UpdateOne(
+		ctx,
+		bson.M{"_id": objID},
+		bson.M{
+			"$set":  bson.M{"status": status, "nextAttemptAt": nextAttemptAt},
+			"$inc":  bson.M{"attempts": 1},
+			"$push": bson.M{"log": dao.FromWebhookAttempt(attempt)},
+		},
+	)
+	if err != nil {
+		return mongoError("UpdateOne", err)
+	}
+
+	return nil
+}
+
+// ListDeliveries returns a page of matching deliveries, newest first.
+func (db *Webhook) ListDeliveries(
+	ctx context.Context,
+	filter model.WebhookDeliveryFilter,
+	limit, offset int64,
+) ([]model.WebhookDelivery, error) {
+	var deliveryDaos []dao.WebhookDelivery
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "_id", Value: -1}}).
+		SetSkip(offset).
+		SetLimit(limit)
+
+	cur, err := db.deliveries.Find(ctx, dao.FromWebhookDeliveryFilter(filter), opts)
+	if err != nil {
+		return nil, mongoError("Find", err)
+	}
+
+	if err = cur.All(ctx, &deliveryDaos); err != nil {
+		return nil, mongoError("Cursor.All", err)
+	}
+
+	deliveries := make([]model.WebhookDelivery, len(deliveryDaos))
+
+	for i, deliveryDao := range deliveryDaos {
+		deliveries[i] = dao.ToWebhookDelivery(deliveryDao)
+	}
+
+	return deliveries, nil
+}
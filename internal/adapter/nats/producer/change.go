@@ -0,0 +1,76 @@
+package producer
+
+import (
+	"context"
+	"github.com/sorawaslocked/ap2final_user_service/internal/adapter/nats/producer/dto"
+	"github.com/sorawaslocked/ap2final_user_service/internal/model"
+	"google.golang.org/protobuf/proto"
+	"slices"
+)
+
+type ChangeSubjects struct {
+	Register string
+	Updated  string
+	Deleted  string
+	Purged   string
+}
+
+// ChangeProducer announces writes read from the users collection with the
+// service's user events. It publishes straight to NATS and skips the outbox:
+// the change is already stored, so there is nothing for an outbox to commit
+// with. Its events are therefore not retried, ordered or dead-lettered by the
+// outbox relay; the change stream resumes from the last change it saved.
+type ChangeProducer struct {
+	publisher MessagePublisher
+	subjects  ChangeSubjects
+	envelope  envelope
+}
+
+func NewChangeProducer(
+	publisher MessagePublisher,
+	subjects ChangeSubjects,
+	envelopeCfg EnvelopeConfig,
+) *ChangeProducer {
+	return &ChangeProducer{
+		publisher: publisher,
+		subjects:  subjects,
+		envelope:  envelope{cfg: envelopeCfg},
+	}
+}
+
+// PushChange publishes the event for a change. Inserts are registrations,
+// setting isDeleted is a deletion and removing the document is a purge.
+// Updates that only touch bookkeeping fields publish nothing.
+func (p *ChangeProducer) PushChange(ctx context.Context, change model.UserChange) error {
+	switch change.Operation {
+	case model.UserChangeInsert:
+		return p.publish(ctx, change.ID, p.subjects.Register, dto.FromUserToRegisterEvent(change.User))
+	case model.UserChangeUpdate, model.UserChangeReplace:
+		if change.User.IsDeleted && slices.Contains(change.ChangedFields, "isDeleted") {
+			return p.publish(ctx, change.ID, p.subjects.Deleted, dto.FromUserToDeletedEvent(change.User))
+		}
+
+		if change.Operation == model.UserChangeUpdate && len(change.ChangedFields) == 0 {
+			return nil
+		}
+
+		return p.publish(ctx, change.ID, p.subjects.Updated, dto.FromUserToUpdatedEvent(change.User, change.ChangedFields))
+	case model.UserChangeDelete:
+		return p.publish(ctx, change.ID, p.subjects.Purged, dto.FromUserToPurgedEvent(change.User))
+	default:
+		return nil
+	}
+}
+
+// publish uses the change id as the event id. JetStream only drops a change
+// published again within the stream's duplicate window (2m by default); one
+// published again later, say after resuming from an old token, is stored
+// twice. Consumers must dedup on the event id, which is the change id.
+func (p *ChangeProducer) publish(ctx context.Context, changeID, subject string, event proto.Message) error {
+	headers, data, err := p.envelope.wrapWithID(event, changeID)
+	if err != nil {
+		return err
+	}
+
+	return publishNow(ctx, p.publisher, subject, headers, data)
+}
@@ -0,0 +1,19 @@
+package dto
+
+import (
+	"github.com/sorawaslocked/ap2final_protos_gen/events"
+	"github.com/sorawaslocked/ap2final_user_service/internal/model"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func FromCommandResultToEvent(result model.CommandResult) *events.CommandResultEvent {
+	return &events.CommandResultEvent{
+		CommandID:   result.CommandID,
+		Command:     result.Command,
+		Source:      result.Source,
+		UserID:      result.UserID,
+		Code:        result.Code,
+		Error:       result.Error,
+		ProcessedAt: timestamppb.New(result.ProcessedAt),
+	}
+}
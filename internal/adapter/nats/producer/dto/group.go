@@ -0,0 +1,29 @@
+package dto
+
+import (
+	"github.com/sorawaslocked/ap2final_protos_gen/events"
+	"github.com/sorawaslocked/ap2final_user_service/internal/model"
+)
+
+func FromGroupToMembersAddedEvent(group model.Group, userIDs []string) *events.GroupMembersAddedEvent {
+	return &events.GroupMembersAddedEvent{
+		GroupID:        group.ID,
+		OrganizationID: group.TenantID,
+		UserIDs:        userIDs,
+	}
+}
+
+func FromGroupToMembersRemovedEvent(group model.Group, userIDs []string) *events.GroupMembersRemovedEvent {
+	return &events.GroupMembersRemovedEvent{
+		GroupID:        group.ID,
+		OrganizationID: group.TenantID,
+		UserIDs:        userIDs,
+	}
+}
+
+func FromGroupToDeletedEvent(group model.Group) *events.GroupDeletedEvent {
+	return &events.GroupDeletedEvent{
+		GroupID:        group.ID,
+		OrganizationID: group.TenantID,
+	}
+}
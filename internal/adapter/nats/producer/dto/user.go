@@ -3,6 +3,7 @@ package dto
 import (
 	"github.com/sorawaslocked/ap2final_protos_gen/events"
 	"github.com/sorawaslocked/ap2final_user_service/internal/model"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 func FromUserToRegisterEvent(user model.User) *events.UserRegisterEvent {
@@ -11,3 +12,121 @@ func FromUserToRegisterEvent(user model.User) *events.UserRegisterEvent {
 		Email:  user.Email,
 	}
 }
+
+func FromUserToUpdatedEvent(user model.User, changedFields []string) *events.UserUpdatedEvent {
+	return &events.UserUpdatedEvent{
+		UserID:        user.ID,
+		ChangedFields: changedFields,
+		Version:       user.Version,
+		UpdatedAt:     timestamppb.New(user.UpdatedAt),
+	}
+}
+
+func FromUserToDeletedEvent(user model.User) *events.UserDeletedEvent {
+	return &events.UserDeletedEvent{
+		UserID:    user.ID,
+		DeletedAt: timestamppb.New(user.DeletedAt),
+	}
+}
+
+func FromUserToRoleChangedEvent(user model.User, oldRole string) *events.UserRoleChangedEvent {
+	return &events.UserRoleChangedEvent{
+		UserID:  user.ID,
+		OldRole: oldRole,
+		NewRole: user.Role,
+	}
+}
+
+func FromUserToPasswordChangedEvent(user model.User) *events.UserPasswordChangedEvent {
+	return &events.UserPasswordChangedEvent{
+		UserID:    user.ID,
+		ChangedAt: timestamppb.New(user.UpdatedAt),
+	}
+}
+
+func FromSessionToLoggedInEvent(user model.User, session model.Session) *events.UserLoggedInEvent {
+	return &events.UserLoggedInEvent{
+		UserID:         user.ID,
+		OrganizationID: user.TenantID,
+		LoggedInAt:     timestamppb.New(session.CreatedAt),
+	}
+}
+
+func ToSessionRevokedEvent(userID, reason string) *events.SessionRevokedEvent {
+	return &events.SessionRevokedEvent{
+		UserID:    userID,
+		Reason:    reason,
+		RevokedAt: timestamppb.Now(),
+	}
+}
+
+// FromUserToSnapshotEvent carries the profile without the password hash or
+// custom attributes, which may be readable by admins only.
+func FromUserToSnapshotEvent(user model.User) *events.UserSnapshotEvent {
+	return &events.UserSnapshotEvent{
+		UserID:         user.ID,
+		Email:          user.Email,
+		FirstName:      user.FirstName,
+		LastName:       user.LastName,
+		PhoneNumber:    user.PhoneNumber,
+		Locale:         user.Locale,
+		Role:           user.Role,
+		OrganizationID: user.TenantID,
+		OrgRole:        user.OrgRole,
+		Version:        user.Version,
+		IsActive:       user.IsActive,
+		PhoneVerified:  user.PhoneVerified,
+		Suspended:      user.Suspension != nil,
+		CreatedAt:      timestamppb.New(user.CreatedAt),
+		UpdatedAt:      timestamppb.New(user.UpdatedAt),
+	}
+}
+
+func FromUserToPurgedEvent(user model.User) *events.UserPurgedEvent {
+	return &events.UserPurgedEvent{
+		UserID: user.ID,
+	}
+}
+
+func FromUserToErasedEvent(user model.User) *events.UserErasedEvent {
+	return &events.UserErasedEvent{
+		UserID: user.ID,
+	}
+}
+
+func FromUserToPhoneVerificationEvent(user model.User, code string) *events.PhoneVerificationCodeEvent {
+	return &events.PhoneVerificationCodeEvent{
+		UserID:      user.ID,
+		PhoneNumber: user.PhoneNumber,
+		Code:        code,
+	}
+}
+
+func FromUserToEmailChangeRequestedEvent(user model.User, newEmail, token string) *events.EmailChangeRequestedEvent {
+	return &events.EmailChangeRequestedEvent{
+		UserID:   user.ID,
+		NewEmail: newEmail,
+		Token:    token,
+	}
+}
+
+func FromUserToEmailChangedEvent(user model.User, newEmail, revertToken string) *events.EmailChangedEvent {
+	return &events.EmailChangedEvent{
+		UserID:      user.ID,
+		OldEmail:    user.Email,
+		NewEmail:    newEmail,
+		RevertToken: revertToken,
+	}
+}
+
+func FromInvitationToSentEvent(invitation model.Invitation, token string) *events.InvitationSentEvent {
+	return &events.InvitationSentEvent{
+		InvitationID:   invitation.ID,
+		Email:          invitation.Email,
+		Role:           invitation.Role,
+		OrganizationID: invitation.TenantID,
+		OrgRole:        invitation.OrgRole,
+		Token:          token,
+		ExpiresAt:      timestamppb.New(invitation.ExpiresAt),
+	}
+}
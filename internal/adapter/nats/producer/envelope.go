@@ -0,0 +1,150 @@
+package producer
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"github.com/sorawaslocked/ap2final_protos_gen/events"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"time"
+)
+
+// Events are sent in CloudEvents binary mode: the attributes travel as NATS
+// headers and the message body is the event itself.
+const (
+	HeaderSpecVersion = "ce-specversion"
+	HeaderID          = "ce-id"
+	HeaderSource      = "ce-source"
+	HeaderType        = "ce-type"
+	HeaderTime        = "ce-time"
+	HeaderDataSchema  = "ce-dataschema"
+	HeaderContentType = "content-type"
+	// HeaderReplay marks events re-announced by a replay rather than caused
+	// by a change. Consumers should apply them as upserts.
+	HeaderReplay = "ce-replay"
+
+	specVersion = "1.0"
+)
+
+const (
+	ContentTypeProtobuf = "protobuf"
+	ContentTypeJSON     = "json"
+)
+
+// schemaVersions holds the current schema version of events. Bump an entry
+// when a change would break existing consumers; unlisted events are at 1.
+var schemaVersions = map[protoreflect.FullName]int{
+	proto.MessageName(&events.UserRegisterEvent{}): 1,
+}
+
+type EnvelopeConfig struct {
+	// Source identifies this service in ce-source.
+	Source string
+	// SchemaBase prefixes ce-dataschema, which ends in the event type and
+	// its schema version.
+	SchemaBase string
+	// ContentType is ContentTypeProtobuf or ContentTypeJSON.
+	ContentType string
+}
+
+type envelope struct {
+	cfg EnvelopeConfig
+}
+
+// wrap encodes the event and returns it with its CloudEvents headers.
+func (e envelope) wrap(event proto.Message) (map[string]string, []byte, error) {
+	id, err := newEventID()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return e.wrapWithID(event, id)
+}
+
+// wrapWithID is wrap for events that already have an id.
+func (e envelope) wrapWithID(event proto.Message, id string) (map[string]string, []byte, error) {
+	data, contentType, err := e.encode(event)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	eventType := proto.MessageName(event)
+
+	headers := map[string]string{
+		HeaderSpecVersion: specVersion,
+		HeaderID:          id,
+		HeaderSource:      e.cfg.Source,
+		HeaderType:        string(eventType),
+		HeaderTime:        time.Now().UTC().Format(time.RFC3339Nano),
+		HeaderDataSchema:  fmt.Sprintf("%s/%s/v%d", e.cfg.SchemaBase, eventType, schemaVersion(eventType)),
+		HeaderContentType: contentType,
+	}
+
+	return headers, data, nil
+}
+
+// structured returns the event as a structured CloudEvents JSON document
+// with the given type. The data is always JSON.
+func (e envelope) structured(headers map[string]string, eventType string, event proto.Message) ([]byte, error) {
+	data, err := protojson.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(structuredEvent{
+		SpecVersion:     headers[HeaderSpecVersion],
+		ID:              headers[HeaderID],
+		Source:          headers[HeaderSource],
+		Type:            eventType,
+		Time:            headers[HeaderTime],
+		DataSchema:      headers[HeaderDataSchema],
+		DataContentType: "application/json",
+		Data:            data,
+	})
+}
+
+type structuredEvent struct {
+	SpecVersion     string          `json:"specversion"`
+	ID              string          `json:"id"`
+	Source          string          `json:"source"`
+	Type            string          `json:"type"`
+	Time            string          `json:"time"`
+	DataSchema      string          `json:"dataschema"`
+	DataContentType string          `json:"datacontenttype"`
+	Data            json.RawMessage `json:"data"`
+}
+
+func (e envelope) encode(event proto.Message) ([]byte, string, error) {
+	if e.cfg.ContentType == ContentTypeJSON {
+		data, err := protojson.Marshal(event)
+
+		return data, "application/json", err
+	}
+
+	data, err := proto.Marshal(event)
+
+	return data, "application/protobuf", err
+}
+
+func schemaVersion(eventType protoreflect.FullName) int {
+	if version, ok := schemaVersions[eventType]; ok {
+		return version
+	}
+
+	return 1
+}
+
+// newEventID returns a random UUID.
+func newEventID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
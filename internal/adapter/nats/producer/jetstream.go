@@ -0,0 +1,140 @@
+package producer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	natsgo "github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/sorawaslocked/ap2final_base/pkg/nats"
+	"github.com/sorawaslocked/ap2final_user_service/internal/model"
+	"strings"
+	"time"
+)
+
+type JetStreamConfig struct {
+	Stream   string
+	Subjects []string
+	// DuplicateWindow is how long the stream remembers message ids.
+	DuplicateWindow time.Duration
+	// MaxAge is how long the stream keeps a message. It bounds how long
+	// events about an erased user stay readable.
+	MaxAge time.Duration
+	// AckTimeout bounds a single publish attempt.
+	AckTimeout    time.Duration
+	RetryAttempts int
+	RetryWait     time.Duration
+}
+
+// JetStreamPublisher publishes to a JetStream stream and waits for the ack.
+// Messages carry Nats-Msg-Id, so a retried publish is stored once. A subject
+// no stream stores fails with model.ErrNoStream, which retrying cannot fix.
+type JetStreamPublisher struct {
+	js  jetstream.JetStream
+	cfg JetStreamConfig
+}
+
+// NewJetStreamPublisher creates the stream, or updates it to match cfg.
+func NewJetStreamPublisher(ctx context.Context, natsClient *nats.Client, cfg JetStreamConfig) (*JetStreamPublisher, error) {
+	js, err := jetstream.New(natsClient.Conn)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = js.CreateOrUpdateStream(ctx, jetstream.StreamConfig{
+		Name:       cfg.Stream,
+		Subjects:   cfg.Subjects,
+		Storage:    jetstream.FileStorage,
+		Duplicates: cfg.DuplicateWindow,
+		MaxAge:     cfg.MaxAge,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &JetStreamPublisher{
+		js:  js,
+		cfg: cfg,
+	}, nil
+}
+
+func (p *JetStreamPublisher) Publish(ctx context.Context, message model.OutboxMessage) error {
+	if !p.stores(message.Subject) {
+		return fmt.Errorf("%w: %s", model.ErrNoStream, message.Subject)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, PushTimeout)
+	defer cancel()
+
+	msg := toNatsMsg(message)
+
+	var err error
+
+	for attempt := range p.cfg.RetryAttempts + 1 {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return errors.Join(err, ctx.Err())
+			case <-time.After(p.cfg.RetryWait):
+			}
+		}
+
+		err = p.publish(ctx, msg)
+		if err == nil || !isTransient(err) {
+			return err
+		}
+	}
+
+	return err
+}
+
+func (p *JetStreamPublisher) publish(ctx context.Context, msg *natsgo.Msg) error {
+	ctx, cancel := context.WithTimeout(ctx, p.cfg.AckTimeout)
+	defer cancel()
+
+	_, err := p.js.PublishMsg(ctx, msg)
+
+	// Nothing answered, so no stream listens on the subject.
+	if errors.Is(err, natsgo.ErrNoResponders) || errors.Is(err, jetstream.ErrNoStreamResponse) {
+		return fmt.Errorf("%w: %s: %w", model.ErrNoStream, msg.Subject, err)
+	}
+
+	return err
+}
+
+func (p *JetStreamPublisher) stores(subject string) bool {
+	for _, filter := range p.cfg.Subjects {
+		if subjectMatches(filter, subject) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isTransient reports errors worth retrying: the ack did not arrive in time.
+func isTransient(err error) bool {
+	return errors.Is(err, natsgo.ErrTimeout) ||
+		errors.Is(err, context.DeadlineExceeded)
+}
+
+// subjectMatches reports whether subject falls under filter, where "*"
+// matches one token and a trailing ">" matches one or more.
+func subjectMatches(filter, subject string) bool {
+	filterTokens := strings.Split(filter, ".")
+	subjectTokens := strings.Split(subject, ".")
+
+	for i, token := range filterTokens {
+		if token == ">" {
+			return i == len(filterTokens)-1 && len(subjectTokens) > i
+		}
+		if i >= len(subjectTokens) {
+			return false
+		}
+		if token != "*" && token != subjectTokens[i] {
+			return false
+		}
+	}
+
+	return len(filterTokens) == len(subjectTokens)
+}
@@ -0,0 +1,30 @@
+package producer
+
+import "testing"
+
+func TestSubjectMatches(t *testing.T) {
+	tests := []struct {
+		filter  string
+		subject string
+		want    bool
+	}{
+		{"user.created", "user.created", true},
+		{"user.created", "user.deleted", false},
+		{"user.created", "user.created.v2", false},
+		{"user.*", "user.created", true},
+		{"user.*", "user", false},
+		{"user.*", "user.email.changed", false},
+		{"user.*.changed", "user.email.changed", true},
+		{"user.>", "user.email.changed", true},
+		{"user.>", "user.created", true},
+		{"user.>", "user", false},
+		{">", "group.deleted", true},
+		{"group.>", "user.created", false},
+	}
+
+	for _, tt := range tests {
+		if got := subjectMatches(tt.filter, tt.subject); got != tt.want {
+			t.Errorf("subjectMatches(%q, %q) = %v, want %v", tt.filter, tt.subject, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package producer
+
+import (
+	"context"
+	natsgo "github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/sorawaslocked/ap2final_base/pkg/nats"
+	"github.com/sorawaslocked/ap2final_user_service/internal/model"
+	"time"
+)
+
+const PushTimeout = time.Second * 30
+
+const (
+	PublisherCore      = "core"
+	PublisherJetStream = "jetstream"
+)
+
+// MessagePublisher sends a message to NATS right away, as NatsPublisher and
+// JetStreamPublisher do.
+type MessagePublisher interface {
+	Publish(ctx context.Context, message model.OutboxMessage) error
+}
+
+// publishNow sends an event without the outbox. The event id doubles as the
+// message id.
+func publishNow(
+	ctx context.Context,
+	publisher MessagePublisher,
+	subject string,
+	headers map[string]string,
+	data []byte,
+) error {
+	return publisher.Publish(ctx, model.OutboxMessage{
+		ID:      headers[HeaderID],
+		Subject: subject,
+		Headers: headers,
+		Payload: data,
+	})
+}
+
+// NatsPublisher publishes straight to core NATS. Nothing is stored if no
+// subscriber is listening, so it is meant for tests and local runs.
+type NatsPublisher struct {
+	natsClient *nats.Client
+}
+
+func NewNatsPublisher(natsClient *nats.Client) *NatsPublisher {
+	return &NatsPublisher{
+		natsClient: natsClient,
+	}
+}
+
+// Publish returns once the server has the message, so a nil error means the
+// event left this process.
+func (p *NatsPublisher) Publish(ctx context.Context, message model.OutboxMessage) error {
+	ctx, cancel := context.WithTimeout(ctx, PushTimeout)
+	defer cancel()
+
+	msg := toNatsMsg(message)
+
+	err := p.natsClient.Conn.PublishMsg(msg)
+	if err != nil {
+		return err
+	}
+
+	return p.natsClient.Conn.FlushWithContext(ctx)
+}
+
+// toNatsMsg keeps the outbox id as Nats-Msg-Id so JetStream can deduplicate.
+func toNatsMsg(message model.OutboxMessage) *natsgo.Msg {
+	msg := natsgo.NewMsg(message.Subject)
+	msg.Data = message.Payload
+
+	for key, value := range message.Headers {
+		msg.Header.Set(key, value)
+	}
+	msg.Header.Set(jetstream.MsgIDHeader, message.ID)
+
+	return msg
+}
@@ -0,0 +1,55 @@
+package producer
+
+import (
+	"context"
+	"github.com/sorawaslocked/ap2final_user_service/internal/adapter/nats/producer/dto"
+	"github.com/sorawaslocked/ap2final_user_service/internal/model"
+	"google.golang.org/protobuf/proto"
+)
+
+type ReplaySubjects struct {
+	Register string
+	Snapshot string
+}
+
+// ReplayProducer re-announces stored users. It publishes straight to NATS and
+// skips the outbox and webhooks: replayed events describe no change, and a
+// replay of every user would only fill the outbox. A failed publish is not
+// retried by the outbox relay; the replay stops and resumes from its
+// checkpoint.
+type ReplayProducer struct {
+	publisher MessagePublisher
+	subjects  ReplaySubjects
+	envelope  envelope
+}
+
+func NewReplayProducer(
+	publisher MessagePublisher,
+	subjects ReplaySubjects,
+	envelopeCfg EnvelopeConfig,
+) *ReplayProducer {
+	return &ReplayProducer{
+		publisher: publisher,
+		subjects:  subjects,
+		envelope:  envelope{cfg: envelopeCfg},
+	}
+}
+
+func (p *ReplayProducer) PushRegister(ctx context.Context, user model.User) error {
+	return p.publish(ctx, p.subjects.Register, dto.FromUserToRegisterEvent(user))
+}
+
+func (p *ReplayProducer) PushSnapshot(ctx context.Context, user model.User) error {
+	return p.publish(ctx, p.subjects.Snapshot, dto.FromUserToSnapshotEvent(user))
+}
+
+func (p *ReplayProducer) publish(ctx context.Context, subject string, event proto.Message) error {
+	headers, data, err := p.envelope.wrap(event)
+	if err != nil {
+		return err
+	}
+
+	headers[HeaderReplay] = "true"
+
+	return publishNow(ctx, p.publisher, subject, headers, data)
+}
@@ -0,0 +1,75 @@
+package producer
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+)
+
+// SecretKeySize is the size of the AES-256 key that seals secrets in events.
+const SecretKeySize = 32
+
+var ErrInvalidSecretKey = errors.New("event secret key must be 32 bytes")
+
+// Sealer encrypts the one-time secrets carried by mail and SMS events, such
+// as verification codes and invitation tokens. Events are kept in the outbox
+// and in the stream, so secrets are only stored sealed. The mailer and SMS
+// sender share the key and open them.
+//
+// A sealed secret is the standard base64 encoding of the 12-byte GCM nonce
+// followed by the AES-256-GCM ciphertext and tag.
+type Sealer struct {
+	aead cipher.AEAD
+}
+
+func NewSealer(key []byte) (*Sealer, error) {
+	if len(key) != SecretKeySize {
+		return nil, ErrInvalidSecretKey
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Sealer{aead: aead}, nil
+}
+
+func (s *Sealer) Seal(secret string) (string, error) {
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := s.aead.Seal(nonce, nonce, []byte(secret), nil)
+
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Open reverses Seal. The service itself never needs it; it documents the
+// format for consumers and is used in tests.
+func (s *Sealer) Open(sealed string) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil {
+		return "", err
+	}
+
+	nonceSize := s.aead.NonceSize()
+	if len(data) < nonceSize {
+		return "", errors.New("sealed secret is too short")
+	}
+
+	secret, err := s.aead.Open(nil, data[:nonceSize], data[nonceSize:], nil)
+	if err != nil {
+		return "", err
+	}
+
+	return string(secret), nil
+}
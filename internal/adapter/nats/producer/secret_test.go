@@ -0,0 +1,51 @@
+package producer
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestSealer(t *testing.T) {
+	sealer, err := NewSealer(bytes.Repeat([]byte{1}, SecretKeySize))
+	if err != nil {
+		t.Fatalf("NewSealer() error = %v", err)
+	}
+
+	first, err := sealer.Seal("123456")
+	if err != nil {
+		t.Fatalf("Seal() error = %v", err)
+	}
+
+	second, err := sealer.Seal("123456")
+	if err != nil {
+		t.Fatalf("Seal() error = %v", err)
+	}
+
+	if first == second {
+		t.Error("Seal() returned the same ciphertext twice")
+	}
+
+	for _, sealed := range []string{first, second} {
+		if got, err := sealer.Open(sealed); err != nil || got != "123456" {
+			t.Errorf("Open(%q) = %q, %v, want %q", sealed, got, err, "123456")
+		}
+	}
+
+	other, err := NewSealer(bytes.Repeat([]byte{2}, SecretKeySize))
+	if err != nil {
+		t.Fatalf("NewSealer() error = %v", err)
+	}
+
+	if _, err = other.Open(first); err == nil {
+		t.Error("Open() with another key succeeded")
+	}
+}
+
+func TestNewSealerKeySize(t *testing.T) {
+	for _, size := range []int{0, 16, 24, 31, 33} {
+		if _, err := NewSealer(make([]byte, size)); !errors.Is(err, ErrInvalidSecretKey) {
+			t.Errorf("NewSealer(%d bytes) error = %v, want %v", size, err, ErrInvalidSecretKey)
+		}
+	}
+}
@@ -2,41 +2,296 @@ package producer
 
 import (
 	"context"
-	"github.com/sorawaslocked/ap2final_base/pkg/nats"
 	"github.com/sorawaslocked/ap2final_user_service/internal/adapter/nats/producer/dto"
 	"github.com/sorawaslocked/ap2final_user_service/internal/model"
 	"google.golang.org/protobuf/proto"
-	"time"
 )
 
-const PushTimeout = time.Second * 30
+type Subjects struct {
+	Register            string
+	Updated             string
+	Deleted             string
+	RoleChanged         string
+	PasswordChanged     string
+	LoggedIn            string
+	SessionRevoked      string
+	Purged              string
+	Erased              string
+	PhoneVerification   string
+	EmailChange         string
+	EmailChanged        string
+	Invitation          string
+	GroupMembersAdded   string
+	GroupMembersRemoved string
+	GroupDeleted        string
+	CommandResult       string
+}
+
+// Publisher delivers an encoded event with its headers. In the service it is
+// the outbox that the relay drains into NATS.
+type Publisher interface {
+	Publish(ctx context.Context, message model.OutboxMessage) error
+	DeleteByAggregateID(ctx context.Context, aggregateID string) error
+}
+
+// WebhookQueue queues an event about a user for the webhooks subscribed to
+// it.
+type WebhookQueue interface {
+	Enqueue(ctx context.Context, eventType, eventID, userID string, payload []byte) error
+	DeleteDeliveriesByUserID(ctx context.Context, userID string) error
+}
 
 type UserProducer struct {
-	natsClient *nats.Client
-	subject    string
+	publisher Publisher
+	webhooks  WebhookQueue
+	sealer    *Sealer
+	subjects  Subjects
+	envelope  envelope
 }
 
-func NewUserProducer(natsClient *nats.Client, subject string) *UserProducer {
+// NewUserProducer returns the producer of user events. sealer encrypts the
+// secrets of mail and SMS events.
+func NewUserProducer(
+	publisher Publisher,
+	webhooks WebhookQueue,
+	sealer *Sealer,
+	subjects Subjects,
+	envelopeCfg EnvelopeConfig,
+) *UserProducer {
 	return &UserProducer{
-		natsClient: natsClient,
-		subject:    subject,
+		publisher: publisher,
+		webhooks:  webhooks,
+		sealer:    sealer,
+		subjects:  subjects,
+		envelope:  envelope{cfg: envelopeCfg},
 	}
 }
 
 func (p *UserProducer) Push(ctx context.Context, user model.User) error {
-	ctx, cancel := context.WithTimeout(ctx, PushTimeout)
-	defer cancel()
+	return p.publishWithWebhook(
+		ctx,
+		p.subjects.Register,
+		model.WebhookEventUserRegistered,
+		user.ID,
+		dto.FromUserToRegisterEvent(user),
+	)
+}
+
+func (p *UserProducer) PushUpdated(ctx context.Context, user model.User, changedFields []string) error {
+	return p.publishWithWebhook(
+		ctx,
+		p.subjects.Updated,
+		model.WebhookEventUserUpdated,
+		user.ID,
+		dto.FromUserToUpdatedEvent(user, changedFields),
+	)
+}
+
+func (p *UserProducer) PushDeleted(ctx context.Context, user model.User) error {
+	return p.publishWithWebhook(
+		ctx,
+		p.subjects.Deleted,
+		model.WebhookEventUserDeleted,
+		user.ID,
+		dto.FromUserToDeletedEvent(user),
+	)
+}
+
+func (p *UserProducer) PushRoleChanged(ctx context.Context, user model.User, oldRole string) error {
+	return p.publishWithWebhook(
+		ctx,
+		p.subjects.RoleChanged,
+		model.WebhookEventUserRoleChanged,
+		user.ID,
+		dto.FromUserToRoleChangedEvent(user, oldRole),
+	)
+}
+
+func (p *UserProducer) PushPasswordChanged(ctx context.Context, user model.User) error {
+	return p.publish(ctx, p.subjects.PasswordChanged, user.ID, dto.FromUserToPasswordChangedEvent(user))
+}
+
+func (p *UserProducer) PushLoggedIn(ctx context.Context, user model.User, session model.Session) error {
+	return p.publish(ctx, p.subjects.LoggedIn, user.ID, dto.FromSessionToLoggedInEvent(user, session))
+}
+
+// PushSessionRevoked tells consumers that all sessions of the user ended.
+func (p *UserProducer) PushSessionRevoked(ctx context.Context, userID, reason string) error {
+	return p.publish(ctx, p.subjects.SessionRevoked, userID, dto.ToSessionRevokedEvent(userID, reason))
+}
+
+func (p *UserProducer) PushPurged(ctx context.Context, user model.User) error {
+	return p.publishWithWebhook(
+		ctx,
+		p.subjects.Purged,
+		model.WebhookEventUserPurged,
+		user.ID,
+		dto.FromUserToPurgedEvent(user),
+	)
+}
+
+func (p *UserProducer) PushErased(ctx context.Context, user model.User) error {
+	return p.publishWithWebhook(
+		ctx,
+		p.subjects.Erased,
+		model.WebhookEventUserErased,
+		user.ID,
+		dto.FromUserToErasedEvent(user),
+	)
+}
+
+// EraseUserEvents drops the events about the user that are still stored:
+// queued or sent outbox messages and webhook deliveries. Events already
+// published stay in the stream until its MaxAge removes them.
+func (p *UserProducer) EraseUserEvents(ctx context.Context, userID string) error {
+	if err := p.publisher.DeleteByAggregateID(ctx, userID); err != nil {
+		return err
+	}
+
+	return p.webhooks.DeleteDeliveriesByUserID(ctx, userID)
+}
+
+// PushPhoneVerification hands a verification code to the SMS sender. The
+// code is sealed; see Sealer.
+func (p *UserProducer) PushPhoneVerification(ctx context.Context, user model.User, code string) error {
+	sealed, err := p.sealer.Seal(code)
+	if err != nil {
+		return err
+	}
+
+	return p.publishSensitive(
+		ctx,
+		p.subjects.PhoneVerification,
+		user.ID,
+		dto.FromUserToPhoneVerificationEvent(user, sealed),
+	)
+}
+
+// PushEmailChangeRequested asks the mailer to send the sealed confirmation
+// token to the new address.
+func (p *UserProducer) PushEmailChangeRequested(ctx context.Context, user model.User, newEmail, token string) error {
+	sealed, err := p.sealer.Seal(token)
+	if err != nil {
+		return err
+	}
 
-	pbUser := dto.FromUserToRegisterEvent(user)
-	data, err := proto.Marshal(pbUser)
+	return p.publishSensitive(
+		ctx,
+		p.subjects.EmailChange,
+		user.ID,
+		dto.FromUserToEmailChangeRequestedEvent(user, newEmail, sealed),
+	)
+}
+
+// PushEmailChanged asks the mailer to notify the old address, including the
+// sealed revert token.
+func (p *UserProducer) PushEmailChanged(ctx context.Context, user model.User, newEmail, revertToken string) error {
+	sealed, err := p.sealer.Seal(revertToken)
 	if err != nil {
 		return err
 	}
 
-	err = p.natsClient.Conn.Publish(p.subject, data)
+	return p.publishSensitive(
+		ctx,
+		p.subjects.EmailChanged,
+		user.ID,
+		dto.FromUserToEmailChangedEvent(user, newEmail, sealed),
+	)
+}
+
+// PushInvitationSent asks the mailer to send the sealed invitation token to
+// the invitee.
+func (p *UserProducer) PushInvitationSent(ctx context.Context, invitation model.Invitation, token string) error {
+	sealed, err := p.sealer.Seal(token)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	return p.publishSensitive(
+		ctx,
+		p.subjects.Invitation,
+		invitation.ID,
+		dto.FromInvitationToSentEvent(invitation, sealed),
+	)
+}
+
+func (p *UserProducer) PushGroupMembersAdded(ctx context.Context, group model.Group, userIDs []string) error {
+	return p.publish(ctx, p.subjects.GroupMembersAdded, group.ID, dto.FromGroupToMembersAddedEvent(group, userIDs))
+}
+
+func (p *UserProducer) PushGroupMembersRemoved(ctx context.Context, group model.Group, userIDs []string) error {
+	return p.publish(
+		ctx,
+		p.subjects.GroupMembersRemoved,
+		group.ID,
+		dto.FromGroupToMembersRemovedEvent(group, userIDs),
+	)
+}
+
+// PushGroupDeleted tells consumers to drop all memberships of the group.
+func (p *UserProducer) PushGroupDeleted(ctx context.Context, group model.Group) error {
+	return p.publish(ctx, p.subjects.GroupDeleted, group.ID, dto.FromGroupToDeletedEvent(group))
+}
+
+// PushCommandResult answers a command received from another service.
+func (p *UserProducer) PushCommandResult(ctx context.Context, result model.CommandResult) error {
+	return p.publish(ctx, p.subjects.CommandResult, result.UserID, dto.FromCommandResultToEvent(result))
+}
+
+func (p *UserProducer) publish(ctx context.Context, subject, aggregateID string, event proto.Message) error {
+	_, err := p.publishEvent(ctx, subject, aggregateID, event, false)
+
+	return err
+}
+
+// publishSensitive publishes an event that carries a secret, so the outbox
+// deletes it once sent.
+func (p *UserProducer) publishSensitive(ctx context.Context, subject, aggregateID string, event proto.Message) error {
+	_, err := p.publishEvent(ctx, subject, aggregateID, event, true)
+
+	return err
+}
+
+// publishWithWebhook also queues the event for the webhooks subscribed to
+// webhookEvent, as a structured CloudEvents JSON document. Webhook events are
+// all about users, so aggregateID is a user id.
+func (p *UserProducer) publishWithWebhook(
+	ctx context.Context,
+	subject, webhookEvent, aggregateID string,
+	event proto.Message,
+) error {
+	headers, err := p.publishEvent(ctx, subject, aggregateID, event, false)
+	if err != nil {
+		return err
+	}
+
+	payload, err := p.envelope.structured(headers, webhookEvent, event)
+	if err != nil {
+		return err
+	}
+
+	return p.webhooks.Enqueue(ctx, webhookEvent, headers[HeaderID], aggregateID, payload)
+}
+
+// publishEvent returns the CloudEvents headers the event was sent with.
+// aggregateID is the user, group or invitation the event is about; the relay
+// keeps the events of one aggregate in order.
+func (p *UserProducer) publishEvent(
+	ctx context.Context,
+	subject, aggregateID string,
+	event proto.Message,
+	sensitive bool,
+) (map[string]string, error) {
+	headers, data, err := p.envelope.wrap(event)
+	if err != nil {
+		return nil, err
+	}
+
+	return headers, p.publisher.Publish(ctx, model.OutboxMessage{
+		Subject:     subject,
+		AggregateID: aggregateID,
+		Headers:     headers,
+		Payload:     data,
+		Sensitive:   sensitive,
+	})
 }
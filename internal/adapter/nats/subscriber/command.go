@@ -0,0 +1,391 @@
+package subscriber
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	natsgo "github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/sorawaslocked/ap2final_base/pkg/logger"
+	"github.com/sorawaslocked/ap2final_base/pkg/nats"
+	"github.com/sorawaslocked/ap2final_protos_gen/command"
+	"github.com/sorawaslocked/ap2final_user_service/internal/adapter/nats/subscriber/dto"
+	"github.com/sorawaslocked/ap2final_user_service/internal/model"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/proto"
+	"log/slog"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// Commands are signed by the sending service with its shared key:
+// HeaderSignature is the hex HMAC-SHA256 of the subject, a newline and the
+// payload. Nats-Msg-Id must be the command id, so the stream drops a command
+// sent twice.
+const (
+	HeaderSource    = "Command-Source"
+	HeaderSignature = "Command-Signature"
+
+	headerDeadLetterSubject  = "Dead-Letter-Subject"
+	headerDeadLetterReason   = "Dead-Letter-Reason"
+	headerDeadLetterAttempts = "Dead-Letter-Attempts"
+)
+
+type CommandConfig struct {
+	Stream            string
+	Consumer          string
+	DeactivateSubject string
+	SetRoleSubject    string
+	// DeadLetterSubject receives commands that cannot be processed. It has to
+	// be covered by the stream, but not by the command subjects.
+	DeadLetterSubject string
+	// Keys holds the signing key of each service allowed to send commands.
+	// They come from the environment, never from the config file.
+	Keys map[string]string
+	// Allowed lists the commands each service may send.
+	Allowed map[string][]string
+	// MaxAge rejects commands issued earlier than this. It is also the
+	// stream's duplicate window, so every command young enough to run is
+	// run once.
+	MaxAge time.Duration
+	// MaxAttempts is how often a failing command is tried before it goes to
+	// the dead-letter subject.
+	MaxAttempts int
+	RetryDelay  time.Duration
+	AckWait     time.Duration
+}
+
+// UserCommands executes commands other services send over JetStream and
+// answers each with a result event. A command that ran is acknowledged even
+// if its result could not be queued, so it never runs twice; the lost result
+// is logged.
+type UserCommands struct {
+	log        *slog.Logger
+	natsClient *nats.Client
+	uc         CommandUseCase
+	results    CommandResultPublisher
+	tokens     TokenIssuer
+	cfg        CommandConfig
+	js         jetstream.JetStream
+	consumeCtx jetstream.ConsumeContext
+}
+
+func NewUserCommands(
+	log *slog.Logger,
+	natsClient *nats.Client,
+	uc CommandUseCase,
+	results CommandResultPublisher,
+	tokens TokenIssuer,
+	cfg CommandConfig,
+) *UserCommands {
+	return &UserCommands{
+		log:        log,
+		natsClient: natsClient,
+		uc:         uc,
+		results:    results,
+		tokens:     tokens,
+		cfg:        cfg,
+	}
+}
+
+func (c *UserCommands) MustStart(ctx context.Context) {
+	if err := c.start(ctx); err != nil {
+		panic(err)
+	}
+}
+
+// start creates the command stream and a durable consumer shared by all
+// replicas.
+func (c *UserCommands) start(ctx context.Context) error {
+	js, err := jetstream.New(c.natsClient.Conn)
+	if err != nil {
+		return err
+	}
+
+	stream, err := js.CreateOrUpdateStream(ctx, jetstream.StreamConfig{
+		Name:       c.cfg.Stream,
+		Subjects:   []string{c.cfg.DeactivateSubject, c.cfg.SetRoleSubject, c.cfg.DeadLetterSubject},
+		Storage:    jetstream.FileStorage,
+		Duplicates: c.cfg.MaxAge,
+	})
+	if err != nil {
+		return err
+	}
+
+	consumer, err := stream.CreateOrUpdateConsumer(ctx, jetstream.ConsumerConfig{
+		Durable:        c.cfg.Consumer,
+		FilterSubjects: []string{c.cfg.DeactivateSubject, c.cfg.SetRoleSubject},
+		AckPolicy:      jetstream.AckExplicitPolicy,
+		AckWait:        c.cfg.AckWait,
+	})
+	if err != nil {
+		return err
+	}
+
+	c.js = js
+	c.consumeCtx, err = consumer.Consume(c.handle)
+
+	return err
+}
+
+// Stop lets the command being handled finish.
+func (c *UserCommands) Stop() {
+	c.log.Info("stopping user command consumer")
+
+	if c.consumeCtx != nil {
+		c.consumeCtx.Drain()
+	}
+}
+
+// parsedCommand is a decoded command ready to run as its source service.
+type parsedCommand struct {
+	name     string
+	id       string
+	userID   string
+	issuedAt time.Time
+	run      func(ctx context.Context, token model.Token) error
+}
+
+func (c *UserCommands) handle(msg jetstream.Msg) {
+	const op = "nats.UserCommands.handle"
+
+	log := c.log.With(slog.String("op", op), slog.String("subject", msg.Subject()))
+
+	attempts := 1
+	if meta, err := msg.Metadata(); err == nil {
+		attempts = int(meta.NumDelivered)
+	}
+
+	source, err := c.verify(msg.Headers(), msg.Subject(), msg.Data())
+	if err != nil {
+		log.Warn("verifying command", logger.Err(err), slog.String("source", source))
+		c.deadLetter(log, msg, attempts, err)
+
+		return
+	}
+
+	cmd, err := c.parse(msg.Subject(), msg.Data())
+	if err != nil {
+		log.Warn("decoding command", logger.Err(err), slog.String("source", source))
+		c.deadLetter(log, msg, attempts, err)
+
+		return
+	}
+
+	if msg.Headers().Get(jetstream.MsgIDHeader) != cmd.id {
+		err = fmt.Errorf("%w: message id is not the command id", dto.ErrMalformedRequest)
+		log.Warn("decoding command", logger.Err(err), slog.String("source", source))
+		c.deadLetter(log, msg, attempts, err)
+
+		return
+	}
+
+	log = log.With(
+		slog.String("source", source),
+		slog.String("commandID", cmd.id),
+		slog.String("userID", cmd.userID),
+	)
+
+	err = c.execute(cmd, source)
+	if c.isTransient(err) {
+		log.Error("executing command", logger.Err(err), slog.Int("attempts", attempts))
+
+		if attempts < c.cfg.MaxAttempts {
+			c.nak(log, msg)
+
+			return
+		}
+
+		if pushErr := c.pushResult(cmd, source, err); pushErr != nil {
+			log.Error("publishing command result", logger.Err(pushErr))
+		}
+
+		c.deadLetter(log, msg, attempts, err)
+
+		return
+	}
+	if err != nil {
+		log.Warn("executing command", logger.Err(err))
+	}
+
+	if pushErr := c.pushResult(cmd, source, err); pushErr != nil {
+		log.Error("publishing command result", logger.Err(pushErr))
+	}
+
+	if err = msg.Ack(); err != nil {
+		log.Warn("acknowledging command", logger.Err(err))
+	}
+}
+
+// isTransient reports failures that may pass on a later attempt. Anything
+// the caller got wrong is answered right away.
+func (c *UserCommands) isTransient(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	code, _ := dto.FromError(err)
+
+	return code == codes.Internal
+}
+
+// verify checks the signature and returns the service that sent the command.
+func (c *UserCommands) verify(headers natsgo.Header, subject string, data []byte) (string, error) {
+	source := headers.Get(HeaderSource)
+
+	key, ok := c.cfg.Keys[source]
+	if !ok || key == "" {
+		return source, dto.ErrBadSignature
+	}
+
+	signature, err := hex.DecodeString(headers.Get(HeaderSignature))
+	if err != nil {
+		return source, dto.ErrBadSignature
+	}
+
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(subject + "\n"))
+	mac.Write(data)
+
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return source, dto.ErrBadSignature
+	}
+
+	return source, nil
+}
+
+func (c *UserCommands) parse(subject string, data []byte) (parsedCommand, error) {
+	switch subject {
+	case c.cfg.DeactivateSubject:
+		var cmd command.DeactivateUserCommand
+		if err := proto.Unmarshal(data, &cmd); err != nil {
+			return parsedCommand{}, errors.Join(dto.ErrMalformedRequest, err)
+		}
+
+		suspension := dto.ToSuspensionFromDeactivateCommand(&cmd)
+
+		return parsedCommand{
+			name:     model.CommandDeactivate,
+			id:       cmd.ID,
+			userID:   cmd.UserID,
+			issuedAt: cmd.IssuedAt.AsTime(),
+			run: func(ctx context.Context, token model.Token) error {
+				_, err := c.uc.SuspendUser(ctx, token, cmd.UserID, suspension)
+
+				return err
+			},
+		}, nil
+	case c.cfg.SetRoleSubject:
+		var cmd command.SetRoleCommand
+		if err := proto.Unmarshal(data, &cmd); err != nil {
+			return parsedCommand{}, errors.Join(dto.ErrMalformedRequest, err)
+		}
+
+		return parsedCommand{
+			name:     model.CommandSetRole,
+			id:       cmd.ID,
+			userID:   cmd.UserID,
+			issuedAt: cmd.IssuedAt.AsTime(),
+			run: func(ctx context.Context, token model.Token) error {
+				update, err := dto.ToUserUpdateFromSetRoleCommand(&cmd)
+				if err != nil {
+					return err
+				}
+
+				_, err = c.uc.UpdateByID(ctx, token, cmd.UserID, model.UserCredentialUpdateData{}, update)
+
+				return err
+			},
+		}, nil
+	default:
+		return parsedCommand{}, fmt.Errorf("%w: unknown subject %s", dto.ErrMalformedRequest, subject)
+	}
+}
+
+// execute runs the command as the source service, so audit entries and
+// suspensions name the service that asked for them.
+func (c *UserCommands) execute(cmd parsedCommand, source string) error {
+	if !slices.Contains(c.cfg.Allowed[source], cmd.name) {
+		return dto.ErrCommandNotAllowed
+	}
+
+	if time.Since(cmd.issuedAt) > c.cfg.MaxAge {
+		return dto.ErrCommandExpired
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.cfg.AckWait)
+	defer cancel()
+
+	accessToken, err := c.tokens.GenerateAccessToken(source, principalRole)
+	if err != nil {
+		return err
+	}
+
+	return cmd.run(ctx, model.Token{AccessToken: accessToken})
+}
+
+func (c *UserCommands) pushResult(cmd parsedCommand, source string, err error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), c.cfg.AckWait)
+	defer cancel()
+
+	result := model.CommandResult{
+		CommandID:   cmd.id,
+		Command:     cmd.name,
+		Source:      source,
+		UserID:      cmd.userID,
+		ProcessedAt: time.Now().UTC(),
+	}
+
+	if err != nil {
+		code, message := dto.FromError(err)
+		result.Code = uint32(code)
+		result.Error = message
+	}
+
+	return c.results.PushCommandResult(ctx, result)
+}
+
+// deadLetter moves the command to the dead-letter subject with the reason it
+// failed. If that fails the command stays in the stream and is retried.
+func (c *UserCommands) deadLetter(log *slog.Logger, msg jetstream.Msg, attempts int, reason error) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.cfg.AckWait)
+	defer cancel()
+
+	dead := natsgo.NewMsg(c.cfg.DeadLetterSubject)
+	dead.Data = msg.Data()
+
+	for key, values := range msg.Headers() {
+		for _, value := range values {
+			dead.Header.Add(key, value)
+		}
+	}
+	// The command's message id would make the stream drop the copy as a
+	// duplicate.
+	dead.Header.Del(jetstream.MsgIDHeader)
+	dead.Header.Set(headerDeadLetterSubject, msg.Subject())
+	dead.Header.Set(headerDeadLetterReason, reason.Error())
+	dead.Header.Set(headerDeadLetterAttempts, strconv.Itoa(attempts))
+
+	if _, err := c.js.PublishMsg(ctx, dead); err != nil {
+		log.Error("publishing to dead-letter subject", logger.Err(err))
+		c.nak(log, msg)
+
+		return
+	}
+
+	log.Warn("command moved to dead-letter subject", slog.String("reason", reason.Error()))
+
+	if err := msg.Term(); err != nil {
+		log.Warn("terminating command", logger.Err(err))
+	}
+}
+
+func (c *UserCommands) nak(log *slog.Logger, msg jetstream.Msg) {
+	if err := msg.NakWithDelay(c.cfg.RetryDelay); err != nil {
+		log.Warn("rejecting command", logger.Err(err))
+	}
+}
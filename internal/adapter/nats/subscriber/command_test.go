@@ -0,0 +1,125 @@
+package subscriber
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	natsgo "github.com/nats-io/nats.go"
+	"github.com/sorawaslocked/ap2final_user_service/internal/adapter/nats/subscriber/dto"
+	"github.com/sorawaslocked/ap2final_user_service/internal/model"
+	"testing"
+	"time"
+)
+
+const testSubject = "user_svc.cmd.deactivate"
+
+func sign(key, subject string, data []byte) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(subject + "\n"))
+	mac.Write(data)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestUserCommandsVerify(t *testing.T) {
+	c := &UserCommands{cfg: CommandConfig{Keys: map[string]string{"billing_svc": "billing-key", "empty_svc": ""}}}
+	data := []byte("payload")
+
+	tests := []struct {
+		name      string
+		source    string
+		signature string
+		subject   string
+		wantErr   error
+	}{
+		{"valid", "billing_svc", sign("billing-key", testSubject, data), testSubject, nil},
+		{"unknown source", "crm_svc", sign("billing-key", testSubject, data), testSubject, dto.ErrBadSignature},
+		{"source without key", "empty_svc", sign("", testSubject, data), testSubject, dto.ErrBadSignature},
+		{"wrong key", "billing_svc", sign("other-key", testSubject, data), testSubject, dto.ErrBadSignature},
+		{
+			"signed for another subject",
+			"billing_svc",
+			sign("billing-key", "user_svc.cmd.set_role", data),
+			testSubject,
+			dto.ErrBadSignature,
+		},
+		{"not hex", "billing_svc", "not-a-signature", testSubject, dto.ErrBadSignature},
+		{"missing", "billing_svc", "", testSubject, dto.ErrBadSignature},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			headers := natsgo.Header{}
+			headers.Set(HeaderSource, tt.source)
+			headers.Set(HeaderSignature, tt.signature)
+
+			source, err := c.verify(headers, tt.subject, data)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("verify() error = %v, want %v", err, tt.wantErr)
+			}
+			if source != tt.source {
+				t.Errorf("verify() source = %q, want %q", source, tt.source)
+			}
+		})
+	}
+}
+
+type fakeTokenIssuer struct{}
+
+func (fakeTokenIssuer) GenerateAccessToken(id, role string) (string, error) {
+	return id + ":" + role, nil
+}
+
+func TestUserCommandsExecute(t *testing.T) {
+	c := &UserCommands{
+		tokens: fakeTokenIssuer{},
+		cfg: CommandConfig{
+			Allowed: map[string][]string{"billing_svc": {model.CommandDeactivate}},
+			MaxAge:  5 * time.Minute,
+			AckWait: time.Second,
+		},
+	}
+
+	tests := []struct {
+		name     string
+		source   string
+		command  string
+		issuedAt time.Time
+		wantErr  error
+		wantRun  bool
+	}{
+		{"allowed", "billing_svc", model.CommandDeactivate, time.Now().Add(-time.Minute), nil, true},
+		{"command not allowed", "billing_svc", model.CommandSetRole, time.Now(), dto.ErrCommandNotAllowed, false},
+		{"source not allowed", "crm_svc", model.CommandDeactivate, time.Now(), dto.ErrCommandNotAllowed, false},
+		{"expired", "billing_svc", model.CommandDeactivate, time.Now().Add(-6 * time.Minute), dto.ErrCommandExpired, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var token model.Token
+
+			cmd := parsedCommand{
+				name:     tt.command,
+				issuedAt: tt.issuedAt,
+				run: func(_ context.Context, runToken model.Token) error {
+					token = runToken
+
+					return nil
+				},
+			}
+
+			if err := c.execute(cmd, tt.source); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("execute() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if ran := token.AccessToken != ""; ran != tt.wantRun {
+				t.Fatalf("command ran = %v, want %v", ran, tt.wantRun)
+			}
+			if tt.wantRun && token.AccessToken != tt.source+":"+principalRole {
+				t.Errorf("command ran with token %q, want one for %s", token.AccessToken, tt.source)
+			}
+		})
+	}
+}
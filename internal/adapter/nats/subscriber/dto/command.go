@@ -0,0 +1,45 @@
+package dto
+
+import (
+	"errors"
+	"github.com/sorawaslocked/ap2final_protos_gen/command"
+	"github.com/sorawaslocked/ap2final_user_service/internal/model"
+)
+
+var (
+	// ErrBadSignature is returned for commands from unknown sources or with
+	// a signature that does not match.
+	ErrBadSignature = errors.New("bad command signature")
+	// ErrCommandExpired is returned for commands issued too long ago, which
+	// also keeps old commands from being replayed.
+	ErrCommandExpired = errors.New("command expired")
+	// ErrCommandNotAllowed is returned for commands the source service may
+	// not send.
+	ErrCommandNotAllowed = errors.New("command not allowed for source")
+)
+
+func ToSuspensionFromDeactivateCommand(cmd *command.DeactivateUserCommand) model.Suspension {
+	suspension := model.Suspension{
+		ReasonCode: cmd.ReasonCode,
+		Note:       cmd.Note,
+	}
+
+	if cmd.Until != nil {
+		suspension.Until = cmd.Until.AsTime()
+	}
+
+	return suspension
+}
+
+// ToUserUpdateFromSetRoleCommand only accepts platform roles; organisation
+// roles are managed through the organisation RPCs.
+func ToUserUpdateFromSetRoleCommand(cmd *command.SetRoleCommand) (model.UserUpdateData, error) {
+	if cmd.Role != "user" && cmd.Role != "admin" {
+		verr := &model.ValidationError{}
+		verr.Add("role", "role must be user or admin")
+
+		return model.UserUpdateData{}, verr
+	}
+
+	return model.UserUpdateData{Role: &cmd.Role}, nil
+}
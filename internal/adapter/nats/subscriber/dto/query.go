@@ -0,0 +1,73 @@
+package dto
+
+import (
+	"errors"
+	"github.com/sorawaslocked/ap2final_protos_gen/base"
+	"github.com/sorawaslocked/ap2final_protos_gen/query"
+	"github.com/sorawaslocked/ap2final_user_service/internal/model"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// ErrMalformedRequest is returned for request bodies that do not decode.
+var ErrMalformedRequest = errors.New("malformed request")
+
+// FromUserToResponse leaves out the password hash; lookups never need it.
+func FromUserToResponse(user model.User) *query.UserResponse {
+	return &query.UserResponse{
+		User: &base.User{
+			ID:            user.ID,
+			FirstName:     user.FirstName,
+			LastName:      user.LastName,
+			Email:         user.Email,
+			PhoneNumber:   user.PhoneNumber,
+			Role:          user.Role,
+			CreatedAt:     timestamppb.New(user.CreatedAt),
+			UpdatedAt:     timestamppb.New(user.UpdatedAt),
+			Version:       user.Version,
+			IsDeleted:     user.IsDeleted,
+			IsActive:      user.IsActive,
+			PhoneVerified: user.PhoneVerified,
+			AvatarURL:     user.AvatarURL,
+			AvatarVersion: user.AvatarVersion,
+			TenantID:      user.TenantID,
+			OrgRole:       user.OrgRole,
+		},
+	}
+}
+
+// FromErrorToResponse carries the error as a gRPC status code, so callers
+// handle it the same way on both transports.
+func FromErrorToResponse(err error) *query.UserResponse {
+	code, message := FromError(err)
+
+	return &query.UserResponse{
+		Error: &query.Error{
+			Code:    uint32(code),
+			Message: message,
+		},
+	}
+}
+
+// FromError maps an error to the gRPC status code reported to callers.
+func FromError(err error) (codes.Code, string) {
+	var validationErr *model.ValidationError
+	if errors.As(err, &validationErr) {
+		return codes.InvalidArgument, err.Error()
+	}
+
+	switch {
+	case errors.Is(err, ErrMalformedRequest), errors.Is(err, model.ErrInvalidSuspension):
+		return codes.InvalidArgument, err.Error()
+	case errors.Is(err, ErrCommandExpired):
+		return codes.DeadlineExceeded, err.Error()
+	case errors.Is(err, model.ErrNotFound):
+		return codes.NotFound, err.Error()
+	case errors.Is(err, model.ErrUnauthorized), errors.Is(err, ErrCommandNotAllowed):
+		return codes.PermissionDenied, err.Error()
+	case errors.Is(err, model.ErrInvalidToken), errors.Is(err, model.ErrEmptyClaims):
+		return codes.Unauthenticated, err.Error()
+	default:
+		return codes.Internal, "something went wrong"
+	}
+}
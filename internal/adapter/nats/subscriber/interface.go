@@ -0,0 +1,31 @@
+package subscriber
+
+import (
+	"context"
+	"github.com/sorawaslocked/ap2final_user_service/internal/model"
+)
+
+type UserUseCase interface {
+	GetByID(ctx context.Context, token model.Token, id string) (model.User, error)
+	GetByEmail(ctx context.Context, token model.Token, email, tenantID string) (model.User, error)
+}
+
+// TokenIssuer signs the access tokens of the service principal.
+type TokenIssuer interface {
+	GenerateAccessToken(id, role string) (string, error)
+}
+
+type CommandUseCase interface {
+	SuspendUser(ctx context.Context, token model.Token, id string, suspension model.Suspension) (model.User, error)
+	UpdateByID(
+		ctx context.Context,
+		token model.Token,
+		id string,
+		credentialsUpdate model.UserCredentialUpdateData,
+		update model.UserUpdateData,
+	) (model.User, error)
+}
+
+type CommandResultPublisher interface {
+	PushCommandResult(ctx context.Context, result model.CommandResult) error
+}
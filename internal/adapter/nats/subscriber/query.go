@@ -0,0 +1,167 @@
+package subscriber
+
+import (
+	"context"
+	natsgo "github.com/nats-io/nats.go"
+	"github.com/sorawaslocked/ap2final_base/pkg/logger"
+	"github.com/sorawaslocked/ap2final_base/pkg/nats"
+	"github.com/sorawaslocked/ap2final_protos_gen/query"
+	"github.com/sorawaslocked/ap2final_user_service/internal/adapter/nats/subscriber/dto"
+	"github.com/sorawaslocked/ap2final_user_service/internal/model"
+	"google.golang.org/protobuf/proto"
+	"log/slog"
+	"time"
+)
+
+// principalRole lets the service principal look up users of any tenant.
+const principalRole = "admin"
+
+type QueryConfig struct {
+	GetByIDSubject    string
+	GetByEmailSubject string
+	// QueueGroup is shared by all replicas so each request is served once.
+	QueueGroup string
+	// Principal is the user id the lookups run as.
+	Principal string
+	Timeout   time.Duration
+}
+
+// UserQuery answers user lookups sent as NATS requests.
+type UserQuery struct {
+	log        *slog.Logger
+	natsClient *nats.Client
+	uc         UserUseCase
+	tokens     TokenIssuer
+	cfg        QueryConfig
+	subs       []*natsgo.Subscription
+}
+
+func NewUserQuery(
+	log *slog.Logger,
+	natsClient *nats.Client,
+	uc UserUseCase,
+	tokens TokenIssuer,
+	cfg QueryConfig,
+) *UserQuery {
+	return &UserQuery{
+		log:        log,
+		natsClient: natsClient,
+		uc:         uc,
+		tokens:     tokens,
+		cfg:        cfg,
+	}
+}
+
+func (q *UserQuery) MustStart() {
+	if err := q.start(); err != nil {
+		panic(err)
+	}
+}
+
+func (q *UserQuery) start() error {
+	handlers := map[string]natsgo.MsgHandler{
+		q.cfg.GetByIDSubject:    q.getByID,
+		q.cfg.GetByEmailSubject: q.getByEmail,
+	}
+
+	for subject, handler := range handlers {
+		sub, err := q.natsClient.Conn.QueueSubscribe(subject, q.cfg.QueueGroup, handler)
+		if err != nil {
+			q.Stop()
+
+			return err
+		}
+
+		q.subs = append(q.subs, sub)
+	}
+
+	return nil
+}
+
+// Stop lets requests already received finish before unsubscribing.
+func (q *UserQuery) Stop() {
+	q.log.Info("stopping user query subscriber")
+
+	for _, sub := range q.subs {
+		if err := sub.Drain(); err != nil {
+			q.log.Warn("draining subscription", logger.Err(err), slog.String("subject", sub.Subject))
+		}
+	}
+
+	q.subs = nil
+}
+
+func (q *UserQuery) getByID(msg *natsgo.Msg) {
+	const op = "nats.UserQuery.getByID"
+
+	log := q.log.With(slog.String("op", op))
+
+	var req query.GetByIDRequest
+	if err := proto.Unmarshal(msg.Data, &req); err != nil {
+		q.reply(log, msg, dto.FromErrorToResponse(dto.ErrMalformedRequest))
+
+		return
+	}
+
+	q.serve(log, msg, func(ctx context.Context, token model.Token) (model.User, error) {
+		return q.uc.GetByID(ctx, token, req.ID)
+	})
+}
+
+func (q *UserQuery) getByEmail(msg *natsgo.Msg) {
+	const op = "nats.UserQuery.getByEmail"
+
+	log := q.log.With(slog.String("op", op))
+
+	var req query.GetByEmailRequest
+	if err := proto.Unmarshal(msg.Data, &req); err != nil {
+		q.reply(log, msg, dto.FromErrorToResponse(dto.ErrMalformedRequest))
+
+		return
+	}
+
+	q.serve(log, msg, func(ctx context.Context, token model.Token) (model.User, error) {
+		return q.uc.GetByEmail(ctx, token, req.Email, req.OrganizationID)
+	})
+}
+
+// serve runs the lookup as the service principal and replies with the user
+// or the error.
+func (q *UserQuery) serve(
+	log *slog.Logger,
+	msg *natsgo.Msg,
+	lookup func(ctx context.Context, token model.Token) (model.User, error),
+) {
+	ctx, cancel := context.WithTimeout(context.Background(), q.cfg.Timeout)
+	defer cancel()
+
+	accessToken, err := q.tokens.GenerateAccessToken(q.cfg.Principal, principalRole)
+	if err != nil {
+		log.Error("generating principal token", logger.Err(err))
+		q.reply(log, msg, dto.FromErrorToResponse(err))
+
+		return
+	}
+
+	user, err := lookup(ctx, model.Token{AccessToken: accessToken})
+	if err != nil {
+		q.reply(log, msg, dto.FromErrorToResponse(err))
+
+		return
+	}
+
+	q.reply(log, msg, dto.FromUserToResponse(user))
+}
+
+func (q *UserQuery) reply(log *slog.Logger, msg *natsgo.Msg, res *query.UserResponse) {
+	data, err := proto.Marshal(res)
+	if err != nil {
+		log.Error("encoding reply", logger.Err(err))
+
+		return
+	}
+
+	if err = msg.Respond(data); err != nil {
+		log.Warn("sending reply", logger.Err(err), slog.String("subject", msg.Subject))
+	}
+}
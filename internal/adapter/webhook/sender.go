@@ -0,0 +1,90 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/sorawaslocked/ap2final_user_service/internal/model"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Receivers check HeaderSignature against the hex HMAC-SHA256 of the
+// timestamp, a dot and the body, keyed with the webhook secret, and reject
+// old timestamps to stop replays.
+const (
+	HeaderID        = "Webhook-ID"
+	HeaderEvent     = "Webhook-Event"
+	HeaderTimestamp = "Webhook-Timestamp"
+	HeaderSignature = "Webhook-Signature"
+
+	signaturePrefix = "sha256="
+	contentType     = "application/cloudevents+json"
+
+	// maxResponseBody is how much of a response is read before the
+	// connection is reused.
+	maxResponseBody = 64 << 10
+)
+
+// Sender posts deliveries to webhook URLs.
+type Sender struct {
+	client *http.Client
+}
+
+func NewSender(timeout time.Duration) *Sender {
+	return &Sender{
+		client: &http.Client{
+			Timeout: timeout,
+			// A redirect counts as a failed delivery rather than sending the
+			// payload somewhere the admin did not register.
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// Send posts the delivery and returns the response status code, which is
+// zero when no response arrived. Any status outside 2xx is an error.
+func (s *Sender) Send(ctx context.Context, webhook model.Webhook, delivery model.WebhookDelivery) (int, error) {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set(HeaderID, delivery.ID)
+	req.Header.Set(HeaderEvent, delivery.EventType)
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, signaturePrefix+Sign(webhook.Secret, timestamp, delivery.Payload))
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+
+	_, _ = io.Copy(io.Discard, io.LimitReader(res.Body, maxResponseBody))
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return res.StatusCode, fmt.Errorf("webhook responded with status %d", res.StatusCode)
+	}
+
+	return res.StatusCode, nil
+}
+
+// Sign returns the hex signature of a delivery body sent at timestamp.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
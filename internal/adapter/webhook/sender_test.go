@@ -0,0 +1,155 @@
+package webhook
+
+import (
+	"context"
+	"github.com/sorawaslocked/ap2final_user_service/internal/model"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestSenderSignsDelivery(t *testing.T) {
+	webhook := model.Webhook{Secret: "secret"}
+	delivery := model.WebhookDelivery{
+		ID:        "delivery-1",
+		EventType: model.WebhookEventUserUpdated,
+		Payload:   []byte(`{"id":"user-1"}`),
+	}
+
+	var got *http.Request
+	var body []byte
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	webhook.URL = server.URL
+
+	before := time.Now().Unix()
+
+	statusCode, err := NewSender(time.Second).Send(context.Background(), webhook, delivery)
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if statusCode != http.StatusNoContent {
+		t.Errorf("Send() status = %d, want %d", statusCode, http.StatusNoContent)
+	}
+
+	if got.Method != http.MethodPost {
+		t.Errorf("method = %s, want POST", got.Method)
+	}
+	if string(body) != string(delivery.Payload) {
+		t.Errorf("body = %s, want %s", body, delivery.Payload)
+	}
+	if v := got.Header.Get("Content-Type"); v != contentType {
+		t.Errorf("Content-Type = %q, want %q", v, contentType)
+	}
+	if v := got.Header.Get(HeaderID); v != delivery.ID {
+		t.Errorf("%s = %q, want %q", HeaderID, v, delivery.ID)
+	}
+	if v := got.Header.Get(HeaderEvent); v != delivery.EventType {
+		t.Errorf("%s = %q, want %q", HeaderEvent, v, delivery.EventType)
+	}
+
+	timestamp := got.Header.Get(HeaderTimestamp)
+
+	sentAt, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		t.Fatalf("%s = %q is not a unix time: %v", HeaderTimestamp, timestamp, err)
+	}
+	if sentAt < before || sentAt > time.Now().Unix() {
+		t.Errorf("%s = %d, want between %d and now", HeaderTimestamp, sentAt, before)
+	}
+
+	want := signaturePrefix + Sign(webhook.Secret, timestamp, delivery.Payload)
+	if v := got.Header.Get(HeaderSignature); v != want {
+		t.Errorf("%s = %q, want %q", HeaderSignature, v, want)
+	}
+}
+
+func TestSenderRejectsNon2xx(t *testing.T) {
+	tests := []struct {
+		name       string
+		statusCode int
+		wantErr    bool
+	}{
+		{"ok", http.StatusOK, false},
+		{"accepted", http.StatusAccepted, false},
+		{"bad request", http.StatusBadRequest, true},
+		{"server error", http.StatusInternalServerError, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(tt.statusCode)
+			}))
+			defer server.Close()
+
+			statusCode, err := NewSender(time.Second).Send(
+				context.Background(),
+				model.Webhook{URL: server.URL, Secret: "secret"},
+				model.WebhookDelivery{ID: "delivery-1"},
+			)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Send() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if statusCode != tt.statusCode {
+				t.Errorf("Send() status = %d, want %d", statusCode, tt.statusCode)
+			}
+		})
+	}
+}
+
+func TestSenderDoesNotFollowRedirects(t *testing.T) {
+	followed := false
+
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		followed = true
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer target.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, target.URL, http.StatusTemporaryRedirect)
+	}))
+	defer server.Close()
+
+	statusCode, err := NewSender(time.Second).Send(
+		context.Background(),
+		model.Webhook{URL: server.URL, Secret: "secret"},
+		model.WebhookDelivery{ID: "delivery-1", Payload: []byte("{}")},
+	)
+	if err == nil {
+		t.Error("Send() error = nil, want an error for a redirect")
+	}
+	if statusCode != http.StatusTemporaryRedirect {
+		t.Errorf("Send() status = %d, want %d", statusCode, http.StatusTemporaryRedirect)
+	}
+	if followed {
+		t.Error("redirect was followed")
+	}
+}
+
+func TestSenderNoResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	server.Close()
+
+	statusCode, err := NewSender(time.Second).Send(
+		context.Background(),
+		model.Webhook{URL: server.URL, Secret: "secret"},
+		model.WebhookDelivery{ID: "delivery-1"},
+	)
+	if err == nil {
+		t.Error("Send() error = nil, want an error")
+	}
+	if statusCode != 0 {
+		t.Errorf("Send() status = %d, want 0", statusCode)
+	}
+}
@@ -2,6 +2,8 @@ package app
 
 import (
 	"context"
+	"encoding/base64"
+	"fmt"
 	"github.com/sorawaslocked/ap2final_base/pkg/logger"
 	mongocfg "github.com/sorawaslocked/ap2final_base/pkg/mongo"
 	natscfg "github.com/sorawaslocked/ap2final_base/pkg/nats"
@@ -9,8 +11,12 @@ import (
 	grpcserver "github.com/sorawaslocked/ap2final_user_service/internal/adapter/grpc"
 	mongorepo "github.com/sorawaslocked/ap2final_user_service/internal/adapter/mongo"
 	"github.com/sorawaslocked/ap2final_user_service/internal/adapter/nats/producer"
+	"github.com/sorawaslocked/ap2final_user_service/internal/adapter/nats/subscriber"
+	"github.com/sorawaslocked/ap2final_user_service/internal/adapter/webhook"
 	"github.com/sorawaslocked/ap2final_user_service/internal/config"
+	"github.com/sorawaslocked/ap2final_user_service/internal/model"
 	"github.com/sorawaslocked/ap2final_user_service/internal/usecase"
+	"github.com/sorawaslocked/ap2final_user_service/internal/worker"
 	"log/slog"
 	"os"
 	"os/signal"
@@ -21,8 +27,15 @@ import (
 const serviceName = "user service"
 
 type App struct {
-	grpcServer *grpcserver.Server
-	log        *slog.Logger
+	grpcServer       *grpcserver.Server
+	userQuery        *subscriber.UserQuery
+	userCommands     *subscriber.UserCommands
+	purgeWorker      *worker.Purge
+	suspensionWorker *worker.SuspensionExpiry
+	outboxRelay      *worker.OutboxRelay
+	webhookDispatch  *worker.WebhookDispatcher
+	userCDC          *worker.UserCDC
+	log              *slog.Logger
 }
 
 func New(
@@ -54,7 +67,56 @@ func New(
 	}
 	newLog.Info("connected to nats", slog.String("connection status", natsClient.Conn.Status().String()))
 
-	userProducer := producer.NewUserProducer(natsClient, cfg.Nats.NatsSubjects.UserEventSubject)
+	publisher, err := newPublisher(ctx, cfg, natsClient)
+	if err != nil {
+		newLog.Error("creating event publisher", logger.Err(err), slog.String("publisher", cfg.Nats.Publisher))
+
+		return nil, err
+	}
+
+	// Events go to the outbox and reach NATS through the relay.
+	outboxRepo := mongorepo.NewOutbox(db.Connection, cfg.Outbox.Retention)
+	if err = outboxRepo.EnsureIndexes(ctx); err != nil {
+		newLog.Error("creating outbox indexes", logger.Err(err))
+
+		return nil, err
+	}
+
+	// Webhook deliveries are queued in the same transaction as the outbox.
+	webhookRepo := mongorepo.NewWebhook(db.Connection, cfg.Webhooks.Retention)
+	if err = webhookRepo.EnsureIndexes(ctx); err != nil {
+		newLog.Error("creating webhook indexes", logger.Err(err))
+
+		return nil, err
+	}
+
+	sealer, err := newSealer(cfg)
+	if err != nil {
+		newLog.Error("loading event secret key", logger.Err(err))
+
+		return nil, err
+	}
+
+	userProducer := producer.NewUserProducer(outboxRepo, webhookRepo, sealer, producer.Subjects{
+		Register:          cfg.Nats.NatsSubjects.UserEventSubject,
+		Updated:           cfg.Nats.NatsSubjects.UserUpdatedSubject,
+		Deleted:           cfg.Nats.NatsSubjects.UserDeletedSubject,
+		RoleChanged:       cfg.Nats.NatsSubjects.RoleChangedSubject,
+		PasswordChanged:   cfg.Nats.NatsSubjects.PasswordChangedSubject,
+		LoggedIn:          cfg.Nats.NatsSubjects.LoggedInSubject,
+		SessionRevoked:    cfg.Nats.NatsSubjects.SessionRevokedSubject,
+		Purged:            cfg.Nats.NatsSubjects.UserPurgedEventSubject,
+		Erased:            cfg.Nats.NatsSubjects.UserErasedEventSubject,
+		PhoneVerification: cfg.Nats.NatsSubjects.PhoneVerificationSubject,
+		EmailChange:       cfg.Nats.NatsSubjects.EmailChangeSubject,
+		EmailChanged:      cfg.Nats.NatsSubjects.EmailChangedSubject,
+		Invitation:        cfg.Nats.NatsSubjects.InvitationSubject,
+
+		GroupMembersAdded:   cfg.Nats.NatsSubjects.GroupMembersAddedSubject,
+		GroupMembersRemoved: cfg.Nats.NatsSubjects.GroupMembersRemovedSubject,
+		GroupDeleted:        cfg.Nats.NatsSubjects.GroupDeletedSubject,
+		CommandResult:       cfg.Nats.NatsSubjects.CommandResultSubject,
+	}, envelopeConfig(cfg))
 
 	jwtProvider := security.NewJWTProvider(
 		"secretKey",
@@ -62,25 +124,307 @@ func New(
 		time.Hour*24,
 	)
 
-	userRepo := mongorepo.NewUser(db.Connection)
+	userRepo := mongorepo.NewUser(db.Connection, cfg.Tenancy.EmailUniqueness)
+	if err = userRepo.EnsureIndexes(ctx); err != nil {
+		newLog.Error("creating user indexes", logger.Err(err))
+
+		return nil, err
+	}
+
 	tokenRepo := mongorepo.NewSession(db.Connection)
+	auditRepo := mongorepo.NewAudit(db.Connection)
+	verificationRepo := mongorepo.NewVerification(db.Connection)
+	if err = verificationRepo.EnsureIndexes(ctx); err != nil {
+		newLog.Error("creating verification indexes", logger.Err(err))
 
-	userUseCase := usecase.NewUser(log, userRepo, tokenRepo, userProducer, jwtProvider)
+		return nil, err
+	}
+
+	avatarRepo, err := mongorepo.NewAvatar(db.Connection)
+	if err != nil {
+		newLog.Error("opening avatar bucket", logger.Err(err))
+
+		return nil, err
+	}
+	if err = avatarRepo.EnsureIndexes(ctx); err != nil {
+		newLog.Error("creating avatar indexes", logger.Err(err))
+
+		return nil, err
+	}
+
+	orgRepo := mongorepo.NewOrganization(db.Connection)
+	if err = orgRepo.EnsureIndexes(ctx); err != nil {
+		newLog.Error("creating organization indexes", logger.Err(err))
 
-	grpcServer := grpcserver.New(cfg.Server.GRPC, log, userUseCase, jwtProvider)
+		return nil, err
+	}
+
+	invitationRepo := mongorepo.NewInvitation(db.Connection)
+	if err = invitationRepo.EnsureIndexes(ctx); err != nil {
+		newLog.Error("creating invitation indexes", logger.Err(err))
+
+		return nil, err
+	}
+
+	groupRepo := mongorepo.NewGroup(db.Connection)
+	if err = groupRepo.EnsureIndexes(ctx); err != nil {
+		newLog.Error("creating group indexes", logger.Err(err))
+
+		return nil, err
+	}
+
+	attributes, err := attributeRegistry(cfg.Attributes)
+	if err != nil {
+		newLog.Error("loading attribute registry", logger.Err(err))
+
+		return nil, err
+	}
+
+	transactor := mongorepo.NewTransactor(db.Connection)
+
+	userUseCase := usecase.NewUser(
+		log,
+		userRepo,
+		tokenRepo,
+		auditRepo,
+		verificationRepo,
+		avatarRepo,
+		groupRepo,
+		invitationRepo,
+		transactor,
+		userProducer,
+		jwtProvider,
+		usecase.UserConfig{
+			DefaultPhoneRegion:   cfg.Registration.DefaultPhoneRegion,
+			PhoneVerificationTTL: cfg.Verification.PhoneCodeTTL,
+			EmailChangeTTL:       cfg.Verification.EmailChangeTTL,
+			EmailRevertTTL:       cfg.Verification.EmailRevertTTL,
+			Attributes:           attributes,
+			AvatarBaseURL:        cfg.Avatar.BaseURL,
+			AvatarMaxUploadSize:  cfg.Avatar.MaxUploadSize,
+			AvatarMaxDimension:   cfg.Avatar.MaxDimension,
+			EmailUniquePerTenant: cfg.Tenancy.EmailUniqueness == mongorepo.EmailUniqueTenant,
+			InviteOnly:           cfg.Registration.InviteOnly,
+			GroupsInTokens:       cfg.Groups.InTokens,
+			GroupTokenLimit:      cfg.Groups.TokenLimit,
+		},
+	)
+	groupUseCase := usecase.NewGroup(log, userRepo, groupRepo, auditRepo, transactor, userProducer, jwtProvider)
+	orgUseCase := usecase.NewOrganization(log, orgRepo, userUseCase, groupUseCase, auditRepo, jwtProvider)
+	invitationUseCase := usecase.NewInvitation(
+		log,
+		invitationRepo,
+		userUseCase,
+		auditRepo,
+		transactor,
+		userProducer,
+		jwtProvider,
+		usecase.InvitationConfig{
+			TTL:                  cfg.Registration.InvitationTTL,
+			EmailUniquePerTenant: cfg.Tenancy.EmailUniqueness == mongorepo.EmailUniqueTenant,
+		},
+	)
+	webhookUseCase := usecase.NewWebhook(log, webhookRepo, auditRepo, jwtProvider)
+	replayUseCase := usecase.NewReplay(
+		log,
+		userRepo,
+		mongorepo.NewReplayCheckpoint(db.Connection),
+		auditRepo,
+		newReplayProducer(cfg, publisher),
+		jwtProvider,
+	)
+
+	grpcServer := grpcserver.New(cfg.Server.GRPC, log, grpcserver.UseCases{
+		User:         userUseCase,
+		Organization: orgUseCase,
+		Group:        groupUseCase,
+		Invitation:   invitationUseCase,
+		Webhook:      webhookUseCase,
+		Replay:       replayUseCase,
+	}, jwtProvider)
+	userQuery := subscriber.NewUserQuery(log, natsClient, userUseCase, jwtProvider, subscriber.QueryConfig{
+		GetByIDSubject:    cfg.Nats.Query.GetByIDSubject,
+		GetByEmailSubject: cfg.Nats.Query.GetByEmailSubject,
+		QueueGroup:        cfg.Nats.Query.QueueGroup,
+		Principal:         cfg.Nats.Query.Principal,
+		Timeout:           cfg.Nats.Query.Timeout,
+	})
+	userCommands := subscriber.NewUserCommands(log, natsClient, userUseCase, userProducer, jwtProvider, subscriber.CommandConfig{
+		Stream:            cfg.Nats.Commands.Stream,
+		Consumer:          cfg.Nats.Commands.Consumer,
+		DeactivateSubject: cfg.Nats.Commands.DeactivateSubject,
+		SetRoleSubject:    cfg.Nats.Commands.SetRoleSubject,
+		DeadLetterSubject: cfg.Nats.Commands.DeadLetterSubject,
+		Keys:              cfg.Nats.Commands.Keys,
+		Allowed:           cfg.Nats.Commands.Allowed,
+		MaxAge:            cfg.Nats.Commands.MaxAge,
+		MaxAttempts:       cfg.Nats.Commands.MaxAttempts,
+		RetryDelay:        cfg.Nats.Commands.RetryDelay,
+		AckWait:           cfg.Nats.Commands.AckWait,
+	})
+
+	purgeWorker := worker.NewPurge(log, userUseCase, cfg.Purge.Interval, cfg.Purge.Retention)
+	suspensionWorker := worker.NewSuspensionExpiry(log, userUseCase, cfg.Suspension.ExpiryInterval)
+
+	outboxRelay := worker.NewOutboxRelay(log, outboxRepo, publisher, worker.OutboxRelayConfig{
+		Interval:    cfg.Outbox.RelayInterval,
+		BatchSize:   cfg.Outbox.BatchSize,
+		Lease:       cfg.Outbox.Lease,
+		MinBackoff:  cfg.Outbox.MinBackoff,
+		MaxBackoff:  cfg.Outbox.MaxBackoff,
+		MaxAttempts: cfg.Outbox.MaxAttempts,
+	})
+
+	webhookDispatch := worker.NewWebhookDispatcher(
+		log,
+		webhookRepo,
+		webhook.NewSender(cfg.Webhooks.Timeout),
+		worker.WebhookDispatcherConfig{
+			Interval:     cfg.Webhooks.DispatchInterval,
+			BatchSize:    cfg.Webhooks.BatchSize,
+			Lease:        cfg.Webhooks.Lease,
+			MaxAttempts:  cfg.Webhooks.MaxAttempts,
+			MinBackoff:   cfg.Webhooks.MinBackoff,
+			MaxBackoff:   cfg.Webhooks.MaxBackoff,
+			DisableAfter: cfg.Webhooks.DisableAfter,
+		},
+	)
+
+	// userCDC stays nil unless change data capture is enabled.
+	var userCDC *worker.UserCDC
+	if cfg.CDC.Enabled {
+		userCDC = worker.NewUserCDC(
+			log,
+			mongorepo.NewUserChanges(db.Connection),
+			newChangeProducer(cfg, publisher),
+			leaseOwner(),
+			cfg.CDC.LeaseTTL,
+			cfg.CDC.RetryDelay,
+		)
+	}
 
 	return &App{
-		grpcServer: grpcServer,
-		log:        log,
+		grpcServer:       grpcServer,
+		userQuery:        userQuery,
+		userCommands:     userCommands,
+		purgeWorker:      purgeWorker,
+		suspensionWorker: suspensionWorker,
+		outboxRelay:      outboxRelay,
+		webhookDispatch:  webhookDispatch,
+		userCDC:          userCDC,
+		log:              log,
 	}, nil
 }
 
+func newPublisher(ctx context.Context, cfg *config.Config, natsClient *natscfg.Client) (worker.Publisher, error) {
+	switch cfg.Nats.Publisher {
+	case producer.PublisherCore:
+		return producer.NewNatsPublisher(natsClient), nil
+	case producer.PublisherJetStream:
+		return producer.NewJetStreamPublisher(ctx, natsClient, producer.JetStreamConfig{
+			Stream:          cfg.Nats.JetStream.Stream,
+			Subjects:        cfg.Nats.JetStream.Subjects,
+			DuplicateWindow: cfg.Nats.JetStream.DuplicateWindow,
+			MaxAge:          cfg.Nats.JetStream.MaxAge,
+			AckTimeout:      cfg.Nats.JetStream.AckTimeout,
+			RetryAttempts:   cfg.Nats.JetStream.RetryAttempts,
+			RetryWait:       cfg.Nats.JetStream.RetryWait,
+		})
+	default:
+		return nil, fmt.Errorf("unknown nats publisher %q", cfg.Nats.Publisher)
+	}
+}
+
+// newSealer decodes the key that seals the secrets of mail and SMS events.
+func newSealer(cfg *config.Config) (*producer.Sealer, error) {
+	key, err := base64.StdEncoding.DecodeString(cfg.Nats.Events.SecretKey)
+	if err != nil {
+		return nil, fmt.Errorf("decoding event secret key: %w", err)
+	}
+
+	return producer.NewSealer(key)
+}
+
+func envelopeConfig(cfg *config.Config) producer.EnvelopeConfig {
+	return producer.EnvelopeConfig{
+		Source:      cfg.Nats.Events.Source,
+		SchemaBase:  cfg.Nats.Events.SchemaBase,
+		ContentType: cfg.Nats.Events.ContentType,
+	}
+}
+
+// newReplayProducer publishes replayed events straight to NATS.
+func newReplayProducer(cfg *config.Config, publisher worker.Publisher) *producer.ReplayProducer {
+	return producer.NewReplayProducer(publisher, producer.ReplaySubjects{
+		Register: cfg.Nats.NatsSubjects.UserEventSubject,
+		Snapshot: cfg.Nats.NatsSubjects.UserSnapshotSubject,
+	}, envelopeConfig(cfg))
+}
+
+// newChangeProducer publishes captured changes straight to NATS, with the
+// CDC source so consumers can tell them from the service's own events.
+func newChangeProducer(cfg *config.Config, publisher worker.Publisher) *producer.ChangeProducer {
+	envelopeCfg := envelopeConfig(cfg)
+	envelopeCfg.Source = cfg.CDC.Source
+
+	return producer.NewChangeProducer(publisher, producer.ChangeSubjects{
+		Register: cfg.CDC.RegisterSubject,
+		Updated:  cfg.CDC.UpdatedSubject,
+		Deleted:  cfg.CDC.DeletedSubject,
+		Purged:   cfg.CDC.PurgedSubject,
+	}, envelopeCfg)
+}
+
+// leaseOwner names this replica when it takes a lease.
+func leaseOwner() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+
+	return fmt.Sprintf("%s-%d", hostname, os.Getpid())
+}
+
+func attributeRegistry(attributes []config.Attribute) (model.AttributeRegistry, error) {
+	definitions := make([]model.AttributeDefinition, len(attributes))
+
+	for i, attribute := range attributes {
+		definitions[i] = model.AttributeDefinition{
+			Key:        attribute.Key,
+			Type:       attribute.Type,
+			MaxSize:    attribute.MaxSize,
+			ReadRoles:  attribute.ReadRoles,
+			WriteRoles: attribute.WriteRoles,
+		}
+	}
+
+	return model.NewAttributeRegistry(definitions)
+}
+
 func (a *App) stop() {
 	a.grpcServer.Stop()
+	a.userQuery.Stop()
+	a.userCommands.Stop()
+	a.purgeWorker.Stop()
+	a.suspensionWorker.Stop()
+	a.outboxRelay.Stop()
+	a.webhookDispatch.Stop()
+	if a.userCDC != nil {
+		a.userCDC.Stop()
+	}
 }
 
 func (a *App) Run() {
 	a.grpcServer.MustRun()
+	a.userQuery.MustStart()
+	a.userCommands.MustStart(context.Background())
+	a.purgeWorker.Start(context.Background())
+	a.suspensionWorker.Start(context.Background())
+	a.outboxRelay.Start(context.Background())
+	a.webhookDispatch.Start(context.Background())
+	if a.userCDC != nil {
+		a.userCDC.Start(context.Background())
+	}
 
 	shutdownCh := make(chan os.Signal, 1)
 	signal.Notify(shutdownCh, syscall.SIGINT, syscall.SIGTERM)
@@ -0,0 +1,53 @@
+package app
+
+import (
+	"context"
+	"github.com/sorawaslocked/ap2final_base/pkg/logger"
+	mongocfg "github.com/sorawaslocked/ap2final_base/pkg/mongo"
+	natscfg "github.com/sorawaslocked/ap2final_base/pkg/nats"
+	mongorepo "github.com/sorawaslocked/ap2final_user_service/internal/adapter/mongo"
+	"github.com/sorawaslocked/ap2final_user_service/internal/config"
+	"github.com/sorawaslocked/ap2final_user_service/internal/usecase"
+	"log/slog"
+)
+
+// NewReplay connects to Mongo and NATS and returns the replay for the
+// replay command. It needs no running service.
+func NewReplay(ctx context.Context, cfg *config.Config, log *slog.Logger) (*usecase.Replay, error) {
+	const op = "App.NewReplay"
+
+	newLog := log.With(slog.String("op", op))
+
+	newLog.Info("connecting to mongo database", slog.String("uri", cfg.Mongo.URI))
+
+	db, err := mongocfg.NewDB(ctx, cfg.Mongo)
+	if err != nil {
+		newLog.Error("error connecting to mongo database", logger.Err(err))
+
+		return nil, err
+	}
+
+	newLog.Info("connecting to nats", slog.Any("hosts", cfg.Nats.Hosts))
+	natsClient, err := natscfg.NewClient(ctx, cfg.Nats.Hosts, cfg.Nats.Nkey, cfg.Nats.IsTest)
+	if err != nil {
+		newLog.Error("connecting to nats", logger.Err(err))
+
+		return nil, err
+	}
+
+	publisher, err := newPublisher(ctx, cfg, natsClient)
+	if err != nil {
+		newLog.Error("creating event publisher", logger.Err(err), slog.String("publisher", cfg.Nats.Publisher))
+
+		return nil, err
+	}
+
+	return usecase.NewReplay(
+		log,
+		mongorepo.NewUser(db.Connection, cfg.Tenancy.EmailUniqueness),
+		mongorepo.NewReplayCheckpoint(db.Connection),
+		mongorepo.NewAudit(db.Connection),
+		newReplayProducer(cfg, publisher),
+		nil,
+	), nil
+}
@@ -0,0 +1,96 @@
+// Package avatar turns uploaded pictures into the avatar renditions the
+// service stores and draws placeholders for users without one.
+package avatar
+
+import (
+	"bytes"
+	"github.com/sorawaslocked/ap2final_user_service/internal/model"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"net/http"
+)
+
+// ContentType is the type of every processed rendition.
+const ContentType = "image/jpeg"
+
+const jpegQuality = 85
+
+var sizes = map[string]int{
+	model.AvatarSizeLarge:  512,
+	model.AvatarSizeMedium: 128,
+	model.AvatarSizeSmall:  48,
+}
+
+var allowedContentTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// Pixels returns the edge length of the named size.
+func Pixels(size string) (int, bool) {
+	px, ok := sizes[size]
+
+	return px, ok
+}
+
+// Process decodes the upload, crops it to a centred square and re-encodes it
+// at every size. Re-encoding from pixels drops EXIF and any other metadata.
+// The declared content type must match the sniffed one.
+func Process(data []byte, contentType string, maxDimension int) ([]model.AvatarImage, error) {
+	if !allowedContentTypes[contentType] || http.DetectContentType(data) != contentType {
+		return nil, model.ErrUnsupportedImage
+	}
+
+	// Check the dimensions before decoding so a small file cannot expand into
+	// a huge bitmap.
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, model.ErrUnsupportedImage
+	}
+
+	if config.Width > maxDimension || config.Height > maxDimension {
+		return nil, model.ErrImageTooLarge
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, model.ErrUnsupportedImage
+	}
+
+	square := centredSquare(src.Bounds())
+	images := make([]model.AvatarImage, 0, len(sizes))
+
+	for size, px := range sizes {
+		dst := image.NewRGBA(image.Rect(0, 0, px, px))
+		// JPEG has no alpha channel, so transparent areas become white.
+		draw.Draw(dst, dst.Bounds(), image.White, image.Point{}, draw.Src)
+		draw.CatmullRom.Scale(dst, dst.Bounds(), src, square, draw.Over, nil)
+
+		var buf bytes.Buffer
+		if err = jpeg.Encode(&buf, dst, &jpeg.Options{Quality: jpegQuality}); err != nil {
+			return nil, err
+		}
+
+		images = append(images, model.AvatarImage{
+			Size:        size,
+			ContentType: ContentType,
+			Data:        buf.Bytes(),
+		})
+	}
+
+	return images, nil
+}
+
+func centredSquare(bounds image.Rectangle) image.Rectangle {
+	side := min(bounds.Dx(), bounds.Dy())
+	x := bounds.Min.X + (bounds.Dx()-side)/2
+	y := bounds.Min.Y + (bounds.Dy()-side)/2
+
+	return image.Rect(x, y, x+side, y+side)
+}
@@ -0,0 +1,116 @@
+package avatar
+
+import (
+	"bytes"
+	"errors"
+	"github.com/sorawaslocked/ap2final_user_service/internal/model"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func encodedImage(t *testing.T, format string, width, height int) []byte {
+	t.Helper()
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for x := range width {
+		for y := range height {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+
+	var buf bytes.Buffer
+	var err error
+
+	switch format {
+	case "png":
+		err = png.Encode(&buf, img)
+	case "jpeg":
+		err = jpeg.Encode(&buf, img, nil)
+	case "gif":
+		err = gif.Encode(&buf, img, nil)
+	}
+	if err != nil {
+		t.Fatalf("encoding %s: %v", format, err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestProcess(t *testing.T) {
+	png := encodedImage(t, "png", 200, 100)
+
+	tests := []struct {
+		name         string
+		data         []byte
+		contentType  string
+		maxDimension int
+		wantErr      error
+	}{
+		{"png", png, "image/png", 1024, nil},
+		{"jpeg", encodedImage(t, "jpeg", 64, 64), "image/jpeg", 1024, nil},
+		{"gif", encodedImage(t, "gif", 10, 30), "image/gif", 1024, nil},
+		{"at max dimension", png, "image/png", 200, nil},
+		{"too wide", png, "image/png", 199, model.ErrImageTooLarge},
+		{"mismatched content type", png, "image/jpeg", 1024, model.ErrUnsupportedImage},
+		{"unsupported content type", png, "image/bmp", 1024, model.ErrUnsupportedImage},
+		{"not an image", []byte("hello, world"), "text/plain; charset=utf-8", 1024, model.ErrUnsupportedImage},
+		{"truncated", png[:len(png)/2], "image/png", 1024, model.ErrUnsupportedImage},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			images, err := Process(tt.data, tt.contentType, tt.maxDimension)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Process() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+
+			if len(images) != len(sizes) {
+				t.Fatalf("Process() returned %d images, want %d", len(images), len(sizes))
+			}
+
+			for _, img := range images {
+				if img.ContentType != ContentType {
+					t.Errorf("%s: content type = %q, want %q", img.Size, img.ContentType, ContentType)
+				}
+
+				decoded, err := jpeg.Decode(bytes.NewReader(img.Data))
+				if err != nil {
+					t.Fatalf("%s: decoding rendition: %v", img.Size, err)
+				}
+
+				px, _ := Pixels(img.Size)
+				if b := decoded.Bounds(); b.Dx() != px || b.Dy() != px {
+					t.Errorf("%s: rendition is %dx%d, want %dx%d", img.Size, b.Dx(), b.Dy(), px, px)
+				}
+			}
+		})
+	}
+}
+
+func TestCentredSquare(t *testing.T) {
+	tests := []struct {
+		name   string
+		bounds image.Rectangle
+		want   image.Rectangle
+	}{
+		{"square", image.Rect(0, 0, 10, 10), image.Rect(0, 0, 10, 10)},
+		{"landscape", image.Rect(0, 0, 200, 100), image.Rect(50, 0, 150, 100)},
+		{"portrait", image.Rect(0, 0, 100, 300), image.Rect(0, 100, 100, 200)},
+		{"offset origin", image.Rect(10, 20, 40, 30), image.Rect(20, 20, 30, 30)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := centredSquare(tt.bounds); got != tt.want {
+				t.Errorf("centredSquare(%v) = %v, want %v", tt.bounds, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package avatar
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"image"
+	"image/color"
+	"image/png"
+)
+
+// IdenticonContentType is the type of generated placeholders.
+const IdenticonContentType = "image/png"
+
+const identiconGrid = 5
+
+// Identicon draws a horizontally symmetric 5x5 pattern derived from the seed,
+// so the same user always gets the same placeholder.
+func Identicon(seed string, px int) ([]byte, error) {
+	sum := sha256.Sum256([]byte(seed))
+
+	background := color.RGBA{R: 0xf0, G: 0xf0, B: 0xf0, A: 0xff}
+	foreground := color.RGBA{R: sum[0], G: sum[1], B: sum[2], A: 0xff}
+
+	img := image.NewPaletted(image.Rect(0, 0, px, px), color.Palette{background, foreground})
+
+	// A margin of half a cell on each side keeps the pattern off the edges.
+	cell := px / (identiconGrid + 1)
+	margin := (px - cell*identiconGrid) / 2
+
+	for row := 0; row < identiconGrid; row++ {
+		for col := 0; col <= identiconGrid/2; col++ {
+			// Bits after the colour bytes decide which cells are filled.
+			bit := row*(identiconGrid/2+1) + col
+			if sum[3+bit/8]&(1<<(bit%8)) == 0 {
+				continue
+			}
+
+			fillCell(img, margin, cell, row, col)
+			fillCell(img, margin, cell, row, identiconGrid-1-col)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func fillCell(img *image.Paletted, margin, cell, row, col int) {
+	for y := margin + row*cell; y < margin+(row+1)*cell; y++ {
+		for x := margin + col*cell; x < margin+(col+1)*cell; x++ {
+			img.SetColorIndex(x, y, 1)
+		}
+	}
+}
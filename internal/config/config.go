@@ -5,8 +5,8 @@ import (
 	"github.com/ilyakaznacheev/cleanenv"
 	"github.com/sorawaslocked/ap2final_base/pkg/grpc"
 	"github.com/sorawaslocked/ap2final_base/pkg/mongo"
-	"github.com/sorawaslocked/ap2final_base/pkg/nats"
 	"os"
+	"time"
 )
 
 type (
@@ -14,12 +14,213 @@ type (
 		Env    string       `yaml:"env" env-required:"true"`
 		Mongo  mongo.Config `yaml:"mongo" env-required:"true"`
 		Server Server       `yaml:"server" env-required:"true"`
-		Nats   nats.Config  `yaml:"nats" env-required:"true"`
+		Nats   Nats         `yaml:"nats" env-required:"true"`
+		Purge  Purge        `yaml:"purge"`
+
+		Suspension   Suspension   `yaml:"suspension"`
+		Registration Registration `yaml:"registration"`
+		Verification Verification `yaml:"verification"`
+		Attributes   []Attribute  `yaml:"attributes"`
+		Avatar       Avatar       `yaml:"avatar"`
+		Tenancy      Tenancy      `yaml:"tenancy"`
+		Groups       Groups       `yaml:"groups"`
+		Outbox       Outbox       `yaml:"outbox"`
+		Webhooks     Webhooks     `yaml:"webhooks"`
+		CDC          CDC          `yaml:"cdc"`
 	}
 
 	Server struct {
 		GRPC grpc.Config `yaml:"grpc" env-required:"true"`
 	}
+
+	Nats struct {
+		Hosts        []string     `yaml:"hosts" env-required:"true"`
+		Nkey         string       `yaml:"nkey" env-required:"true"`
+		IsTest       bool         `yaml:"isTest"`
+		NatsSubjects NatsSubjects `yaml:"natsSubjects" env-required:"true"`
+		// Publisher is "jetstream" or "core". Core NATS does not store
+		// events, so it is only meant for tests.
+		Publisher string    `yaml:"publisher" env-default:"jetstream"`
+		JetStream JetStream `yaml:"jetStream"`
+		Events    Events    `yaml:"events"`
+		Query     Query     `yaml:"query"`
+		Commands  Commands  `yaml:"commands"`
+	}
+
+	// Commands lets other services act on users over JetStream. Keys maps
+	// each allowed service to the key it signs commands with, and Allowed
+	// to the commands it may send. Keys are only read from
+	// NATS_COMMAND_KEYS, as comma-separated service:key pairs, so they can
+	// come from a secret store.
+	Commands struct {
+		Stream            string              `yaml:"stream" env-default:"USER_SVC_CMD"`
+		Consumer          string              `yaml:"consumer" env-default:"user_svc"`
+		DeactivateSubject string              `yaml:"deactivateSubject" env-default:"user_svc.cmd.deactivate"`
+		SetRoleSubject    string              `yaml:"setRoleSubject" env-default:"user_svc.cmd.set_role"`
+		DeadLetterSubject string              `yaml:"deadLetterSubject" env-default:"user_svc.dlq.cmd"`
+		Keys              map[string]string   `yaml:"-" env:"NATS_COMMAND_KEYS"`
+		Allowed           map[string][]string `yaml:"allowed"`
+		MaxAge            time.Duration       `yaml:"maxAge" env-default:"5m"`
+		MaxAttempts       int                 `yaml:"maxAttempts" env-default:"5"`
+		RetryDelay        time.Duration       `yaml:"retryDelay" env-default:"10s"`
+		AckWait           time.Duration       `yaml:"ackWait" env-default:"30s"`
+	}
+
+	// Query serves user lookups over NATS request/reply. Lookups run as the
+	// Principal service account, with admin rights.
+	Query struct {
+		GetByIDSubject    string        `yaml:"getByIDSubject" env-default:"user_svc.query.get_by_id"`
+		GetByEmailSubject string        `yaml:"getByEmailSubject" env-default:"user_svc.query.get_by_email"`
+		QueueGroup        string        `yaml:"queueGroup" env-default:"user_svc"`
+		Principal         string        `yaml:"principal" env-default:"user_svc"`
+		Timeout           time.Duration `yaml:"timeout" env-default:"5s"`
+	}
+
+	// Events controls the CloudEvents envelope of published events.
+	// ContentType is "protobuf" or "json". SecretKey is the base64 AES-256
+	// key that seals the secrets in mail and SMS events. It is only read
+	// from the environment.
+	Events struct {
+		Source      string `yaml:"source" env-default:"/user_svc"`
+		SchemaBase  string `yaml:"schemaBase" env-default:"urn:ap2final:schema"`
+		ContentType string `yaml:"contentType" env-default:"protobuf"`
+		SecretKey   string `yaml:"-" env:"EVENT_SECRET_KEY" env-required:"true"`
+	}
+
+	// JetStream.Subjects must not cover the query subjects: the stream would
+	// answer lookup requests with its own acks. MaxAge is how long events,
+	// including those about erased users, stay in the stream.
+	JetStream struct {
+		Stream          string        `yaml:"stream" env-default:"USER_SVC"`
+		Subjects        []string      `yaml:"subjects" env-default:"user_svc.event.>,user_svc.mail.>,user_svc.sms.>,user_svc.cdc.>"`
+		DuplicateWindow time.Duration `yaml:"duplicateWindow" env-default:"2m"`
+		MaxAge          time.Duration `yaml:"maxAge" env-default:"720h"`
+		AckTimeout      time.Duration `yaml:"ackTimeout" env-default:"5s"`
+		RetryAttempts   int           `yaml:"retryAttempts" env-default:"3"`
+		RetryWait       time.Duration `yaml:"retryWait" env-default:"250ms"`
+	}
+
+	NatsSubjects struct {
+		UserEventSubject       string `yaml:"userEventSubject" env-required:"true"`
+		UserUpdatedSubject     string `yaml:"userUpdatedSubject" env-default:"user_svc.event.updated"`
+		UserDeletedSubject     string `yaml:"userDeletedSubject" env-default:"user_svc.event.deleted"`
+		RoleChangedSubject     string `yaml:"roleChangedSubject" env-default:"user_svc.event.role_changed"`
+		PasswordChangedSubject string `yaml:"passwordChangedSubject" env-default:"user_svc.event.password_changed"`
+		LoggedInSubject        string `yaml:"loggedInSubject" env-default:"user_svc.event.logged_in"`
+		SessionRevokedSubject  string `yaml:"sessionRevokedSubject" env-default:"user_svc.event.session_revoked"`
+		UserPurgedEventSubject string `yaml:"userPurgedEventSubject" env-default:"user_svc.event.purged"`
+		UserErasedEventSubject string `yaml:"userErasedEventSubject" env-default:"user_svc.event.erased"`
+		UserSnapshotSubject    string `yaml:"userSnapshotSubject" env-default:"user_svc.event.snapshot"`
+
+		PhoneVerificationSubject string `yaml:"phoneVerificationSubject" env-default:"user_svc.sms.phone_verification"`
+		EmailChangeSubject       string `yaml:"emailChangeSubject" env-default:"user_svc.mail.email_change"`
+		EmailChangedSubject      string `yaml:"emailChangedSubject" env-default:"user_svc.mail.email_changed"`
+		InvitationSubject        string `yaml:"invitationSubject" env-default:"user_svc.mail.invitation"`
+
+		GroupMembersAddedSubject   string `yaml:"groupMembersAddedSubject" env-default:"user_svc.event.group_members_added"`
+		GroupMembersRemovedSubject string `yaml:"groupMembersRemovedSubject" env-default:"user_svc.event.group_members_removed"`
+		GroupDeletedSubject        string `yaml:"groupDeletedSubject" env-default:"user_svc.event.group_deleted"`
+
+		CommandResultSubject string `yaml:"commandResultSubject" env-default:"user_svc.event.command_result"`
+	}
+
+	// Purge controls the background removal of soft-deleted users.
+	Purge struct {
+		Interval  time.Duration `yaml:"interval" env-default:"1h"`
+		Retention time.Duration `yaml:"retention" env-default:"720h"`
+	}
+
+	// Suspension controls how often expired suspensions are lifted.
+	Suspension struct {
+		ExpiryInterval time.Duration `yaml:"expiryInterval" env-default:"5m"`
+	}
+
+	Registration struct {
+		DefaultPhoneRegion string `yaml:"defaultPhoneRegion" env-default:"KZ"`
+		// InviteOnly turns off open registration; users join by invitation.
+		InviteOnly    bool          `yaml:"inviteOnly" env-default:"false"`
+		InvitationTTL time.Duration `yaml:"invitationTTL" env-default:"168h"`
+	}
+
+	Verification struct {
+		PhoneCodeTTL   time.Duration `yaml:"phoneCodeTTL" env-default:"10m"`
+		EmailChangeTTL time.Duration `yaml:"emailChangeTTL" env-default:"24h"`
+		EmailRevertTTL time.Duration `yaml:"emailRevertTTL" env-default:"72h"`
+	}
+
+	Avatar struct {
+		BaseURL       string `yaml:"baseURL" env-default:"/avatars"`
+		MaxUploadSize int64  `yaml:"maxUploadSize" env-default:"5242880"`
+		MaxDimension  int    `yaml:"maxDimension" env-default:"4096"`
+	}
+
+	// Tenancy controls whether an email address is unique across the whole
+	// service ("global") or only within an organisation ("tenant").
+	Tenancy struct {
+		EmailUniqueness string `yaml:"emailUniqueness" env-default:"global"`
+	}
+
+	// Groups controls group ids in access tokens. Users in more than
+	// TokenLimit groups get the ids of their oldest memberships; services
+	// that need all of them call ListGroupsForUser.
+	Groups struct {
+		InTokens   bool `yaml:"inTokens" env-default:"false"`
+		TokenLimit int  `yaml:"tokenLimit" env-default:"20"`
+	}
+
+	// Outbox controls the relay that publishes queued events to NATS. A
+	// message is tried MaxAttempts times before it is marked dead. Sent
+	// messages are kept for Retention; dead ones until removed by hand.
+	Outbox struct {
+		RelayInterval time.Duration `yaml:"relayInterval" env-default:"1s"`
+		BatchSize     int           `yaml:"batchSize" env-default:"100"`
+		Lease         time.Duration `yaml:"lease" env-default:"30s"`
+		MinBackoff    time.Duration `yaml:"minBackoff" env-default:"1s"`
+		MaxBackoff    time.Duration `yaml:"maxBackoff" env-default:"5m"`
+		MaxAttempts   int           `yaml:"maxAttempts" env-default:"20"`
+		Retention     time.Duration `yaml:"retention" env-default:"168h"`
+	}
+
+	// Webhooks controls delivery of events to partner endpoints. A delivery
+	// is tried MaxAttempts times; a webhook is disabled after DisableAfter
+	// failed attempts in a row. Deliveries are kept for Retention.
+	Webhooks struct {
+		DispatchInterval time.Duration `yaml:"dispatchInterval" env-default:"1s"`
+		BatchSize        int           `yaml:"batchSize" env-default:"50"`
+		Lease            time.Duration `yaml:"lease" env-default:"1m"`
+		Timeout          time.Duration `yaml:"timeout" env-default:"10s"`
+		MaxAttempts      int           `yaml:"maxAttempts" env-default:"8"`
+		MinBackoff       time.Duration `yaml:"minBackoff" env-default:"10s"`
+		MaxBackoff       time.Duration `yaml:"maxBackoff" env-default:"1h"`
+		DisableAfter     int           `yaml:"disableAfter" env-default:"20"`
+		Retention        time.Duration `yaml:"retention" env-default:"720h"`
+	}
+
+	// CDC publishes every write to the users collection, including those
+	// made outside the service. The events have subjects of their own: the
+	// service already announces its own changes, and sharing the subjects
+	// would announce them twice. It needs Mongo to run as a replica set.
+	// One replica at a time holds the lease and tails the stream.
+	CDC struct {
+		Enabled         bool          `yaml:"enabled" env-default:"false"`
+		Source          string        `yaml:"source" env-default:"/user_svc/cdc"`
+		RetryDelay      time.Duration `yaml:"retryDelay" env-default:"5s"`
+		LeaseTTL        time.Duration `yaml:"leaseTTL" env-default:"30s"`
+		RegisterSubject string        `yaml:"registerSubject" env-default:"user_svc.cdc.register"`
+		UpdatedSubject  string        `yaml:"updatedSubject" env-default:"user_svc.cdc.updated"`
+		DeletedSubject  string        `yaml:"deletedSubject" env-default:"user_svc.cdc.deleted"`
+		PurgedSubject   string        `yaml:"purgedSubject" env-default:"user_svc.cdc.purged"`
+	}
+
+	// Attribute registers a custom user attribute. Roles may include "self"
+	// for the user the attribute belongs to.
+	Attribute struct {
+		Key        string   `yaml:"key"`
+		Type       string   `yaml:"type"`
+		MaxSize    int      `yaml:"maxSize"`
+		ReadRoles  []string `yaml:"readRoles"`
+		WriteRoles []string `yaml:"writeRoles"`
+	}
 )
 
 func MustLoad() *Config {
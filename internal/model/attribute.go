@@ -0,0 +1,95 @@
+package model
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+)
+
+const (
+	AttributeTypeString = "string"
+	AttributeTypeNumber = "number"
+	AttributeTypeBool   = "bool"
+
+	// AttributeRoleSelf grants access to the user the attribute belongs to.
+	AttributeRoleSelf = "self"
+)
+
+var attributeKeyPattern = regexp.MustCompile(`^[a-z][a-zA-Z0-9_]{0,63}$`)
+
+type AttributeDefinition struct {
+	Key  string
+	Type string
+	// MaxSize limits string values, in bytes.
+	MaxSize    int
+	ReadRoles  []string
+	WriteRoles []string
+}
+
+// AttributeRegistry lists the custom attributes users may carry, keyed by name.
+type AttributeRegistry map[string]AttributeDefinition
+
+func NewAttributeRegistry(definitions []AttributeDefinition) (AttributeRegistry, error) {
+	registry := make(AttributeRegistry, len(definitions))
+
+	for _, definition := range definitions {
+		if !attributeKeyPattern.MatchString(definition.Key) {
+			return nil, fmt.Errorf("attribute %q: invalid key", definition.Key)
+		}
+
+		switch definition.Type {
+		case AttributeTypeString, AttributeTypeNumber, AttributeTypeBool:
+		default:
+			return nil, fmt.Errorf("attribute %q: unknown type %q", definition.Key, definition.Type)
+		}
+
+		if _, ok := registry[definition.Key]; ok {
+			return nil, fmt.Errorf("attribute %q: defined twice", definition.Key)
+		}
+
+		registry[definition.Key] = definition
+	}
+
+	return registry, nil
+}
+
+// Validate checks that the key is registered and the value has its type and size.
+func (r AttributeRegistry) Validate(key string, value any) error {
+	definition, ok := r[key]
+	if !ok {
+		return fmt.Errorf("unknown attribute")
+	}
+
+	switch definition.Type {
+	case AttributeTypeString:
+		s, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("must be a string")
+		}
+		if definition.MaxSize > 0 && len(s) > definition.MaxSize {
+			return fmt.Errorf("must be at most %d bytes", definition.MaxSize)
+		}
+	case AttributeTypeNumber:
+		if _, ok := value.(float64); !ok {
+			return fmt.Errorf("must be a number")
+		}
+	case AttributeTypeBool:
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("must be a boolean")
+		}
+	}
+
+	return nil
+}
+
+func (r AttributeRegistry) CanRead(key, callerRole string, isSelf bool) bool {
+	return allowed(r[key].ReadRoles, callerRole, isSelf)
+}
+
+func (r AttributeRegistry) CanWrite(key, callerRole string, isSelf bool) bool {
+	return allowed(r[key].WriteRoles, callerRole, isSelf)
+}
+
+func allowed(roles []string, callerRole string, isSelf bool) bool {
+	return slices.Contains(roles, callerRole) || (isSelf && slices.Contains(roles, AttributeRoleSelf))
+}
@@ -0,0 +1,57 @@
+package model
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAttributeRegistryValidate(t *testing.T) {
+	registry, err := NewAttributeRegistry([]AttributeDefinition{
+		{Key: "department", Type: AttributeTypeString, MaxSize: 8},
+		{Key: "nickname", Type: AttributeTypeString},
+		{Key: "employeeNumber", Type: AttributeTypeNumber},
+		{Key: "contractor", Type: AttributeTypeBool},
+	})
+	if err != nil {
+		t.Fatalf("NewAttributeRegistry() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		key     string
+		value   any
+		wantErr string
+	}{
+		{"string", "department", "sales", ""},
+		{"string at max size", "department", "12345678", ""},
+		{"string over max size", "department", "123456789", "at most 8 bytes"},
+		{"string size counts bytes", "department", "éééé€", "at most 8 bytes"},
+		{"string without max size", "nickname", strings.Repeat("a", 1000), ""},
+		{"string wrong type", "department", 1.0, "must be a string"},
+		{"number", "employeeNumber", 42.0, ""},
+		{"number as int", "employeeNumber", 42, "must be a number"},
+		{"number as string", "employeeNumber", "42", "must be a number"},
+		{"bool", "contractor", false, ""},
+		{"bool as string", "contractor", "true", "must be a boolean"},
+		{"nil value", "contractor", nil, "must be a boolean"},
+		{"unknown key", "salary", 1.0, "unknown attribute"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := registry.Validate(tt.key, tt.value)
+
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate(%q, %v) error = %v, want nil", tt.key, tt.value, err)
+				}
+
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate(%q, %v) error = %v, want %q", tt.key, tt.value, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package model
+
+import "time"
+
+const (
+	AuditActionDataExported = "user.data_exported"
+	AuditActionErased       = "user.erased"
+	AuditActionSuspended    = "user.suspended"
+	AuditActionUnsuspended  = "user.unsuspended"
+	AuditActionEmailChanged = "user.email_changed"
+	AuditActionEmailRevert  = "user.email_reverted"
+
+	AuditActionInvitationCreated  = "invitation.created"
+	AuditActionInvitationRevoked  = "invitation.revoked"
+	AuditActionInvitationAccepted = "invitation.accepted"
+
+	AuditActionGroupCreated = "group.created"
+	AuditActionGroupDeleted = "group.deleted"
+
+	AuditActionWebhookCreated = "webhook.created"
+	AuditActionWebhookUpdated = "webhook.updated"
+	AuditActionWebhookDeleted = "webhook.deleted"
+
+	AuditActionReplayStarted = "replay.started"
+
+	AuditActionOrgCreated           = "org.created"
+	AuditActionOrgMemberAdded       = "org.member_added"
+	AuditActionOrgMemberRemoved     = "org.member_removed"
+	AuditActionOrgMemberRoleChanged = "org.member_role_changed"
+)
+
+type AuditEntry struct {
+	ActorID   string
+	Action    string
+	TargetID  string
+	Details   map[string]string
+	CreatedAt time.Time
+}
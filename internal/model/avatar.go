@@ -0,0 +1,16 @@
+package model
+
+const (
+	AvatarSizeLarge  = "large"
+	AvatarSizeMedium = "medium"
+	AvatarSizeSmall  = "small"
+)
+
+// AvatarImage is one stored rendition of a user's avatar.
+type AvatarImage struct {
+	UserID      string
+	Size        string
+	Version     int64
+	ContentType string
+	Data        []byte
+}
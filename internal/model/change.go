@@ -0,0 +1,38 @@
+package model
+
+import (
+	"errors"
+	"time"
+)
+
+const (
+	UserChangeInsert  = "insert"
+	UserChangeUpdate  = "update"
+	UserChangeReplace = "replace"
+	UserChangeDelete  = "delete"
+)
+
+// ErrChangeHistoryLost is returned when a change stream cannot resume
+// because the changes after its resume token are gone from the oplog.
+var ErrChangeHistoryLost = errors.New("change stream history lost")
+
+// ErrLeaseHeld is returned when another replica holds the lease on the
+// change stream, or took it over.
+var ErrLeaseHeld = errors.New("change stream lease held by another owner")
+
+// UserChange is a write to the users collection read from a change stream.
+type UserChange struct {
+	// ID identifies the change. It is the same every time the stream reads
+	// the change, so it can be used to drop duplicates.
+	ID string
+	// ResumeToken lets the stream continue after this change.
+	ResumeToken []byte
+	Operation   string
+	// User is the document after the change. Deletes and documents removed
+	// before they could be read only carry the ID.
+	User User
+	// ChangedFields names the top-level fields an update set or removed.
+	// Replacements do not say which fields changed and leave it empty.
+	ChangedFields []string
+	ChangedAt     time.Time
+}
@@ -0,0 +1,21 @@
+package model
+
+import "time"
+
+// Commands other services send to act on users.
+const (
+	CommandDeactivate = "deactivate"
+	CommandSetRole    = "set_role"
+)
+
+// CommandResult reports how a command ended. Code is a gRPC status code and
+// is zero when the command succeeded.
+type CommandResult struct {
+	CommandID   string
+	Command     string
+	Source      string
+	UserID      string
+	Code        uint32
+	Error       string
+	ProcessedAt time.Time
+}
@@ -1,12 +1,54 @@
 package model
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
 
 var (
 	ErrNotFound            = errors.New("not found")
+	ErrAlreadyExists       = errors.New("already exists")
+	ErrVersionConflict     = errors.New("version conflict")
 	ErrRefreshTokenExpired = errors.New("refresh token expired")
 	ErrPasswordsDoNotMatch = errors.New("passwords do not match")
 	ErrUnauthorized        = errors.New("unauthorized")
 	ErrEmptyClaims         = errors.New("empty claims")
 	ErrInvalidToken        = errors.New("invalid token")
+	ErrInvalidSearchQuery  = errors.New("invalid search query")
+	ErrAccountSuspended    = errors.New("account suspended")
+	ErrNotSuspended        = errors.New("account is not suspended")
+	ErrInvalidSuspension   = errors.New("invalid suspension")
+	ErrValidation          = errors.New("invalid argument")
+
+	ErrPhoneNotSet             = errors.New("phone number is not set")
+	ErrAlreadyVerified         = errors.New("already verified")
+	ErrInvalidVerificationCode = errors.New("invalid verification code")
+	ErrVerificationExpired     = errors.New("verification expired")
+	ErrTooManyAttempts         = errors.New("too many attempts")
+
+	ErrEmailChangeRequiresVerification = errors.New("email can only be changed with verification")
+
+	ErrRegistrationClosed = errors.New("registration is by invitation only")
+	ErrInvitationExpired  = errors.New("invitation expired")
+
+	ErrAlreadyMember = errors.New("user already belongs to an organization")
+	ErrNotMember     = errors.New("user is not a member of the organization")
+	ErrLastOwner     = errors.New("organization must keep at least one owner")
+
+	ErrUnsupportedImage = errors.New("unsupported image")
+	ErrImageTooLarge    = errors.New("image too large")
 )
+
+// VersionConflictError is returned when an update expected a version the
+// document no longer has. It matches ErrVersionConflict with errors.Is.
+type VersionConflictError struct {
+	CurrentVersion int64
+}
+
+func (e *VersionConflictError) Error() string {
+	return fmt.Sprintf("%s: current version is %d", ErrVersionConflict, e.CurrentVersion)
+}
+
+func (e *VersionConflictError) Unwrap() error {
+	return ErrVersionConflict
+}
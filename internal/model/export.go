@@ -0,0 +1,10 @@
+package model
+
+import "time"
+
+// UserDataExport is everything the service stores about a single user.
+type UserDataExport struct {
+	User        User
+	Sessions    []Session
+	GeneratedAt time.Time
+}
@@ -0,0 +1,25 @@
+package model
+
+import "time"
+
+// Group collects users for access decisions in other services. Groups of an
+// organisation carry its TenantID.
+type Group struct {
+	ID        string
+	Name      string
+	TenantID  string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+type GroupFilter struct {
+	ID  *string
+	IDs []string
+	// TenantID set to "" matches groups outside any organisation.
+	TenantID *string
+}
+
+type GroupUpdateData struct {
+	Name      *string
+	UpdatedAt time.Time
+}
@@ -0,0 +1,49 @@
+package model
+
+import "time"
+
+const (
+	InvitationStatusPending  = "pending"
+	InvitationStatusAccepted = "accepted"
+	InvitationStatusRevoked  = "revoked"
+)
+
+// Invitation lets someone join with a role chosen by the inviter. Only a hash
+// of the invitation token is stored.
+type Invitation struct {
+	ID    string
+	Email string
+	Role  string
+	// TenantID and OrgRole are set when the invitation is into an organisation.
+	TenantID  string
+	OrgRole   string
+	TokenHash string
+	Status    string
+	InvitedBy string
+	// AcceptedBy is the id of the user created from the invitation.
+	AcceptedBy string
+	ExpiresAt  time.Time
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+type InvitationFilter struct {
+	ID        *string
+	Email     *string
+	TenantID  *string
+	TokenHash *string
+	Status    *string
+}
+
+type InvitationUpdateData struct {
+	Status     *string
+	AcceptedBy *string
+	UpdatedAt  time.Time
+}
+
+// InvitationListing selects a page of invitations. An empty Status lists all.
+type InvitationListing struct {
+	Status string
+	Limit  int64
+	Offset int64
+}
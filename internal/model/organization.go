@@ -0,0 +1,43 @@
+package model
+
+import "time"
+
+const (
+	OrgRoleOwner  = "owner"
+	OrgRoleAdmin  = "admin"
+	OrgRoleMember = "member"
+)
+
+// Organization is a tenant. Its members are the users whose TenantID is the
+// organisation's id.
+type Organization struct {
+	ID        string
+	Name      string
+	Slug      string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+type OrganizationFilter struct {
+	ID   *string
+	Slug *string
+}
+
+type OrganizationUpdateData struct {
+	Name      *string
+	UpdatedAt time.Time
+}
+
+func IsValidOrgRole(role string) bool {
+	switch role {
+	case OrgRoleOwner, OrgRoleAdmin, OrgRoleMember:
+		return true
+	default:
+		return false
+	}
+}
+
+// CanManageMembers reports whether the organisation role may change members.
+func CanManageMembers(orgRole string) bool {
+	return orgRole == OrgRoleOwner || orgRole == OrgRoleAdmin
+}
@@ -0,0 +1,41 @@
+package model
+
+import (
+	"errors"
+	"time"
+)
+
+const (
+	OutboxStatusPending = "pending"
+	OutboxStatusSent    = "sent"
+	// OutboxStatusDead marks a message the relay gave up on. It is kept until
+	// removed by hand and no longer holds back later messages.
+	OutboxStatusDead = "dead"
+)
+
+// ErrNoStream means no stream stores the subject, so publishing it again
+// cannot succeed.
+var ErrNoStream = errors.New("no stream for subject")
+
+// OutboxMessage is an encoded event waiting to be published. It is written
+// in the same transaction as the change it describes.
+type OutboxMessage struct {
+	ID      string
+	Subject string
+	// AggregateID is the id of the user, group or invitation the event is
+	// about. Messages of one aggregate are published in the order queued.
+	AggregateID string
+	// Headers carry the CloudEvents attributes of the event.
+	Headers map[string]string
+	Payload []byte
+	// Sensitive messages carry a secret. They are deleted once sent instead
+	// of being kept for the retention period.
+	Sensitive bool
+	Status    string
+	Attempts  int
+	// LastError is the reason the last publish attempt failed.
+	LastError     string
+	NextAttemptAt time.Time
+	CreatedAt     time.Time
+	SentAt        time.Time
+}
@@ -0,0 +1,31 @@
+package model
+
+const (
+	ThemeLight  = "light"
+	ThemeDark   = "dark"
+	ThemeSystem = "system"
+)
+
+// UserPreferences are the typed per-user settings. The locale preference is
+// kept on User.Locale, where registration stores it.
+type UserPreferences struct {
+	Timezone       string
+	Theme          string
+	MarketingOptIn bool
+}
+
+type UserPreferencesUpdate struct {
+	Locale         *string
+	Timezone       *string
+	Theme          *string
+	MarketingOptIn *bool
+}
+
+func IsValidTheme(theme string) bool {
+	switch theme {
+	case ThemeLight, ThemeDark, ThemeSystem:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,51 @@
+package model
+
+import "time"
+
+// Events a replay can publish for every user.
+const (
+	ReplayEventSnapshot = "snapshot"
+	ReplayEventRegister = "register"
+)
+
+// ReplayOptions selects the users to re-announce and how fast.
+type ReplayOptions struct {
+	// Checkpoint names the run. A named run saves its position after every
+	// batch and continues from it when started again; an empty name starts
+	// from the first user every time.
+	Checkpoint string
+	// Event is ReplayEventSnapshot or ReplayEventRegister.
+	Event string
+	// CreatedAfter and Role filter the users; zero values match everyone.
+	CreatedAfter time.Time
+	Role         string
+	BatchSize    int64
+	// RatePerSecond caps how many events are published per second.
+	RatePerSecond int
+	// DryRun walks the users without publishing or saving the checkpoint.
+	DryRun bool
+}
+
+// ReplayCheckpoint is the saved position of a named replay. The filters are
+// kept so a resumed run cannot silently change them.
+type ReplayCheckpoint struct {
+	ID           string
+	Event        string
+	CreatedAfter time.Time
+	Role         string
+	// LastUserID is the id of the last user published; users are replayed
+	// in id order.
+	LastUserID  string
+	Replayed    int64
+	StartedAt   time.Time
+	UpdatedAt   time.Time
+	CompletedAt time.Time
+}
+
+type ReplayProgress struct {
+	Checkpoint string
+	LastUserID string
+	Replayed   int64
+	Done       bool
+	DryRun     bool
+}
@@ -0,0 +1,24 @@
+package model
+
+type UserSearch struct {
+	Query  string
+	Limit  int64
+	Offset int64
+	// TenantID restricts the search to one organisation when set.
+	TenantID *string
+}
+
+type UserSearchResult struct {
+	User       User
+	Score      float64
+	Highlights map[string]string
+}
+
+// UserListing selects a page of users by exact field and attribute values.
+type UserListing struct {
+	Role       *string
+	IsActive   *bool
+	Attributes map[string]any
+	Limit      int64
+	Offset     int64
+}
@@ -8,3 +8,13 @@ type Session struct {
 	ExpiresAt    time.Time
 	CreatedAt    time.Time
 }
+
+// Reasons for revoking all sessions of a user.
+const (
+	SessionRevokeReasonDeleted           = "deleted"
+	SessionRevokeReasonPurged            = "purged"
+	SessionRevokeReasonErased            = "erased"
+	SessionRevokeReasonSuspended         = "suspended"
+	SessionRevokeReasonEmailReverted     = "email_reverted"
+	SessionRevokeReasonMembershipChanged = "membership_changed"
+)
@@ -0,0 +1,42 @@
+package model
+
+import "time"
+
+const (
+	SuspensionReasonFraudReview = "fraud_review"
+	SuspensionReasonAbuse       = "abuse"
+	SuspensionReasonNonPayment  = "non_payment"
+	SuspensionReasonOther       = "other"
+
+	// SuspensionLiftedBySystem marks suspensions lifted because their period elapsed.
+	SuspensionLiftedBySystem = "system"
+)
+
+type Suspension struct {
+	ReasonCode  string
+	Note        string
+	SuspendedBy string
+	SuspendedAt time.Time
+	// Until is zero for suspensions without an end date.
+	Until time.Time
+
+	LiftedBy string
+	LiftedAt time.Time
+}
+
+func IsValidSuspensionReason(reasonCode string) bool {
+	switch reasonCode {
+	case SuspensionReasonFraudReview,
+		SuspensionReasonAbuse,
+		SuspensionReasonNonPayment,
+		SuspensionReasonOther:
+		return true
+	default:
+		return false
+	}
+}
+
+// IsExpired reports whether a time-limited suspension has run out at now.
+func (s Suspension) IsExpired(now time.Time) bool {
+	return !s.Until.IsZero() && !s.Until.After(now)
+}
@@ -4,3 +4,11 @@ type Token struct {
 	AccessToken  string
 	RefreshToken string
 }
+
+// Claims are the verified contents of an access token.
+type Claims struct {
+	UserID string
+	Role   string
+	// TenantID is the caller's organisation, empty for users outside one.
+	TenantID string
+}
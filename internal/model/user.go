@@ -2,33 +2,89 @@ package model
 
 import "time"
 
+const (
+	ErasureStatusInProgress = "in_progress"
+	ErasureStatusCompleted  = "completed"
+)
+
+// Fields that an update may clear.
+const (
+	UserFieldFirstName   = "firstName"
+	UserFieldLastName    = "lastName"
+	UserFieldPhoneNumber = "phoneNumber"
+)
+
 type User struct {
 	ID           string
 	FirstName    string
 	LastName     string
 	Email        string
+	PendingEmail string
 	PhoneNumber  string
+	Locale       string
 	Password     string
 	PasswordHash string
 	Role         string
 	CreatedAt    time.Time
 	UpdatedAt    time.Time
+	DeletedAt    time.Time
+	// TenantID is the organisation the user belongs to, empty if none.
+	TenantID string
+	OrgRole  string
+	// Version is incremented on every update.
+	Version int64
+
+	// ErasureStatus tracks an anonymisation request so it can be resumed.
+	ErasureStatus string
+	ErasedAt      time.Time
+
+	// AvatarVersion is 0 until the user uploads an avatar.
+	AvatarVersion int64
+	AvatarURL     string
+
+	Preferences UserPreferences
+	// Attributes holds custom values for keys from the AttributeRegistry.
+	Attributes map[string]any
 
-	IsDeleted bool
-	IsActive  bool
+	// Suspension is the active suspension, nil when the account is not suspended.
+	Suspension        *Suspension
+	SuspensionHistory []Suspension
+
+	IsDeleted     bool
+	IsActive      bool
+	PhoneVerified bool
 }
 
 type UserFilter struct {
 	ID           *string
+	IDs          []string
 	FirstName    *string
 	LastName     *string
 	Email        *string
 	PhoneNumber  *string
 	PasswordHash *string
 	Role         *string
+	// TenantID set to "" matches users outside any organisation.
+	TenantID *string
+	OrgRole  *string
+	Version  *int64
+
+	// IDAfter matches users with a greater id, for paging in id order.
+	IDAfter *string
+	// CreatedAfter matches users created after the given time.
+	CreatedAfter *time.Time
+	// DeletedBefore matches users soft-deleted before the given time.
+	DeletedBefore *time.Time
+	// IncludeDeleted matches users regardless of soft deletion when IsDeleted is nil.
+	IncludeDeleted bool
+	// SuspendedUntilBefore matches users whose suspension ends before the given time.
+	SuspendedUntilBefore *time.Time
+	// Attributes matches users whose custom attributes equal all given values.
+	Attributes map[string]any
 
-	IsDeleted *bool
-	IsActive  *bool
+	IsDeleted     *bool
+	IsActive      *bool
+	PhoneVerified *bool
 }
 
 type UserCredentialUpdateData struct {
@@ -40,11 +96,44 @@ type UserUpdateData struct {
 	FirstName    *string
 	LastName     *string
 	Email        *string
+	PendingEmail *string
 	PhoneNumber  *string
 	PasswordHash *string
 	Role         *string
 	UpdatedAt    time.Time
+	// TenantID and OrgRole set to "" take the user out of its organisation.
+	TenantID *string
+	OrgRole  *string
+
+	Locale         *string
+	Timezone       *string
+	Theme          *string
+	MarketingOptIn *bool
+	// SetAttributes and UnsetAttributes change single custom attributes.
+	SetAttributes   map[string]any
+	UnsetAttributes []string
+
+	AvatarVersion *int64
+	AvatarURL     *string
+
+	// Clear lists the UserField* fields to remove from the user.
+	Clear []string
+	// ExpectedVersion makes the update fail with a VersionConflictError when
+	// the stored version differs. It is a precondition and is not written.
+	ExpectedVersion *int64
+	// DeletedAt set to the zero time removes the deletion timestamp.
+	DeletedAt     *time.Time
+	ErasureStatus *string
+	ErasedAt      *time.Time
+	// Suspension replaces the active suspension.
+	Suspension *Suspension
+	// ArchivedSuspension is appended to the suspension history. Without a new
+	// Suspension the active one is removed.
+	ArchivedSuspension *Suspension
+	// SuspensionHistory, if not nil, replaces the suspension history.
+	SuspensionHistory []Suspension
 
-	IsDeleted *bool
-	IsActive  *bool
+	IsDeleted     *bool
+	IsActive      *bool
+	PhoneVerified *bool
 }
@@ -0,0 +1,40 @@
+package model
+
+import "strings"
+
+type FieldViolation struct {
+	Field       string
+	Description string
+}
+
+// ValidationError lists every invalid field of a request. It matches
+// ErrValidation with errors.Is.
+type ValidationError struct {
+	Violations []FieldViolation
+}
+
+func (e *ValidationError) Add(field, description string) {
+	e.Violations = append(e.Violations, FieldViolation{Field: field, Description: description})
+}
+
+// Err returns nil when no violation was added.
+func (e *ValidationError) Err() error {
+	if len(e.Violations) == 0 {
+		return nil
+	}
+
+	return e
+}
+
+func (e *ValidationError) Error() string {
+	fields := make([]string, len(e.Violations))
+	for i, violation := range e.Violations {
+		fields[i] = violation.Field
+	}
+
+	return ErrValidation.Error() + ": " + strings.Join(fields, ", ")
+}
+
+func (e *ValidationError) Unwrap() error {
+	return ErrValidation
+}
@@ -0,0 +1,30 @@
+package model
+
+import "time"
+
+const (
+	VerificationPurposePhone       = "phone"
+	VerificationPurposeEmailChange = "email_change"
+	VerificationPurposeEmailRevert = "email_revert"
+)
+
+// Verification is a pending one-time code or token sent to the user. Only a
+// hash of the secret is stored.
+type Verification struct {
+	UserID  string
+	Purpose string
+	// Key tells apart verifications of one user and purpose that are
+	// pending together. Only email reverts set it, one for each change.
+	Key       string
+	CodeHash  string
+	Payload   string
+	Attempts  int
+	ExpiresAt time.Time
+	CreatedAt time.Time
+}
+
+type VerificationFilter struct {
+	UserID   *string
+	Purpose  *string
+	CodeHash *string
+}
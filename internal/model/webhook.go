@@ -0,0 +1,119 @@
+package model
+
+import (
+	"slices"
+	"time"
+)
+
+// Event types webhooks can subscribe to. Events that carry secrets, such as
+// verification codes, are never sent to webhooks.
+const (
+	WebhookEventUserRegistered  = "user.registered"
+	WebhookEventUserUpdated     = "user.updated"
+	WebhookEventUserDeleted     = "user.deleted"
+	WebhookEventUserRoleChanged = "user.role_changed"
+	WebhookEventUserPurged      = "user.purged"
+	WebhookEventUserErased      = "user.erased"
+)
+
+var webhookEvents = []string{
+	WebhookEventUserRegistered,
+	WebhookEventUserUpdated,
+	WebhookEventUserDeleted,
+	WebhookEventUserRoleChanged,
+	WebhookEventUserPurged,
+	WebhookEventUserErased,
+}
+
+func IsValidWebhookEvent(eventType string) bool {
+	return slices.Contains(webhookEvents, eventType)
+}
+
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliveryDelivered = "delivered"
+	WebhookDeliveryFailed    = "failed"
+)
+
+// Webhook is a partner endpoint that receives events over HTTP.
+type Webhook struct {
+	ID  string
+	URL string
+	// Secret signs the deliveries. It is only returned when the webhook is created.
+	Secret string
+	// EventTypes lists the events sent to the webhook; empty means all of them.
+	EventTypes  []string
+	Description string
+	Enabled     bool
+	// ConsecutiveFailures counts failed attempts since the last delivered one.
+	// The webhook is disabled when it reaches the configured limit.
+	ConsecutiveFailures int
+	DisabledAt          time.Time
+	CreatedBy           string
+	CreatedAt           time.Time
+	UpdatedAt           time.Time
+}
+
+type WebhookFilter struct {
+	ID      *string
+	Enabled *bool
+	// EventType matches webhooks subscribed to the event, including those
+	// subscribed to all events.
+	EventType *string
+}
+
+type WebhookUpdateData struct {
+	URL *string
+	// EventTypes set to an empty slice subscribes the webhook to all events.
+	EventTypes          *[]string
+	Description         *string
+	Enabled             *bool
+	ConsecutiveFailures *int
+	DisabledAt          *time.Time
+	UpdatedAt           time.Time
+}
+
+type WebhookListing struct {
+	Limit  int64
+	Offset int64
+}
+
+// WebhookDelivery is one event queued for one webhook.
+type WebhookDelivery struct {
+	ID        string
+	WebhookID string
+	EventID   string
+	EventType string
+	// UserID is the user the event is about.
+	UserID string
+	// Payload is the event as a structured CloudEvents JSON document.
+	Payload       []byte
+	Status        string
+	Attempts      int
+	NextAttemptAt time.Time
+	CreatedAt     time.Time
+	// Log holds every attempt, oldest first.
+	Log []WebhookAttempt
+}
+
+type WebhookAttempt struct {
+	AttemptedAt time.Time
+	// StatusCode is zero when no response arrived.
+	StatusCode int
+	Error      string
+	Duration   time.Duration
+}
+
+type WebhookDeliveryFilter struct {
+	WebhookID *string
+	Status    *string
+}
+
+// WebhookDeliveryListing selects a page of deliveries, newest first. Empty
+// fields match everything.
+type WebhookDeliveryListing struct {
+	WebhookID string
+	Status    string
+	Limit     int64
+	Offset    int64
+}
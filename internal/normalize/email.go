@@ -0,0 +1,43 @@
+package normalize
+
+import (
+	"errors"
+	"golang.org/x/text/unicode/norm"
+	"net/mail"
+	"strings"
+)
+
+const emailMaxLength = 254
+
+var ErrInvalidEmail = errors.New("invalid email")
+
+// Email validates an address and returns it trimmed, in Unicode NFC form and
+// with a lowercase domain. The local part keeps its case.
+func Email(raw string) (string, error) {
+	email := norm.NFC.String(strings.TrimSpace(raw))
+
+	if email == "" || len(email) > emailMaxLength {
+		return "", ErrInvalidEmail
+	}
+
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Name != "" || addr.Address != email {
+		return "", ErrInvalidEmail
+	}
+
+	at := strings.LastIndex(email, "@")
+	local, domain := email[:at], strings.ToLower(email[at+1:])
+
+	labels := strings.Split(domain, ".")
+	if len(labels) < 2 {
+		return "", ErrInvalidEmail
+	}
+
+	for _, label := range labels {
+		if label == "" || strings.HasPrefix(label, "-") || strings.HasSuffix(label, "-") {
+			return "", ErrInvalidEmail
+		}
+	}
+
+	return local + "@" + domain, nil
+}
@@ -0,0 +1,43 @@
+package normalize
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestEmail(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		want    string
+		wantErr error
+	}{
+		{"plain", "user@example.com", "user@example.com", nil},
+		{"trims spaces", "  user@example.com\t", "user@example.com", nil},
+		{"lowercases domain only", "John.Doe@Example.COM", "John.Doe@example.com", nil},
+		{"keeps plus tag", "user+tag@example.com", "user+tag@example.com", nil},
+		{"subdomain", "user@mail.example.co.uk", "user@mail.example.co.uk", nil},
+		{"empty", "", "", ErrInvalidEmail},
+		{"spaces only", "   ", "", ErrInvalidEmail},
+		{"no at", "user.example.com", "", ErrInvalidEmail},
+		{"single label domain", "user@localhost", "", ErrInvalidEmail},
+		{"display name", "John <john@example.com>", "", ErrInvalidEmail},
+		{"leading hyphen label", "user@-example.com", "", ErrInvalidEmail},
+		{"trailing hyphen label", "user@example-.com", "", ErrInvalidEmail},
+		{"empty label", "user@example..com", "", ErrInvalidEmail},
+		{"too long", strings.Repeat("a", 250) + "@example.com", "", ErrInvalidEmail},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Email(tt.raw)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Email(%q) error = %v, want %v", tt.raw, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Email(%q) = %q, want %q", tt.raw, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package normalize
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+)
+
+var (
+	ErrInvalidPhoneNumber = errors.New("invalid phone number")
+	ErrUnknownPhoneRegion = errors.New("unknown phone region")
+)
+
+//go:embed phone_metadata.json
+var phoneMetadataJSON []byte
+
+type phoneRegion struct {
+	CallingCode    string `json:"callingCode"`
+	NationalPrefix string `json:"nationalPrefix"`
+	Lengths        []int  `json:"lengths"`
+}
+
+// phoneRegions is the bundled numbering plan, keyed by ISO 3166-1 region code.
+var phoneRegions = loadPhoneRegions()
+
+func loadPhoneRegions() map[string]phoneRegion {
+	var metadata struct {
+		Regions map[string]phoneRegion `json:"regions"`
+	}
+
+	if err := json.Unmarshal(phoneMetadataJSON, &metadata); err != nil {
+		panic("normalize: invalid phone metadata: " + err.Error())
+	}
+
+	return metadata.Regions
+}
+
+// Phone converts a phone number to E.164. Numbers without an international
+// prefix are read as national numbers of defaultRegion.
+func Phone(raw, defaultRegion string) (string, error) {
+	raw = strings.TrimSpace(raw)
+
+	international := strings.HasPrefix(raw, "+")
+
+	digits := strings.Map(func(r rune) rune {
+		switch {
+		case r >= '0' && r <= '9':
+			return r
+		case strings.ContainsRune(" -().+", r):
+			return -1
+		default:
+			return 'x'
+		}
+	}, raw)
+	if digits == "" || strings.ContainsRune(digits, 'x') {
+		return "", ErrInvalidPhoneNumber
+	}
+
+	if !international && strings.HasPrefix(digits, "00") {
+		international = true
+		digits = digits[2:]
+	}
+
+	if international {
+		for _, region := range phoneRegions {
+			national, ok := strings.CutPrefix(digits, region.CallingCode)
+			if ok && slices.Contains(region.Lengths, len(national)) {
+				return "+" + digits, nil
+			}
+		}
+
+		return "", ErrInvalidPhoneNumber
+	}
+
+	region, ok := phoneRegions[strings.ToUpper(defaultRegion)]
+	if !ok {
+		return "", ErrUnknownPhoneRegion
+	}
+
+	if !slices.Contains(region.Lengths, len(digits)) && region.NationalPrefix != "" {
+		digits = strings.TrimPrefix(digits, region.NationalPrefix)
+	}
+
+	if !slices.Contains(region.Lengths, len(digits)) {
+		return "", ErrInvalidPhoneNumber
+	}
+
+	return "+" + region.CallingCode + digits, nil
+}
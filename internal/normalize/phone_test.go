@@ -0,0 +1,41 @@
+package normalize
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPhone(t *testing.T) {
+	tests := []struct {
+		name          string
+		raw           string
+		defaultRegion string
+		want          string
+		wantErr       error
+	}{
+		{"international with punctuation", "+1 (202) 555-0143", "", "+12025550143", nil},
+		{"international ignores region", "+44 20 7946 0958", "US", "+442079460958", nil},
+		{"double zero prefix", "00 44 20 7946 0958", "", "+442079460958", nil},
+		{"national with prefix", "8 701 123 45 67", "KZ", "+77011234567", nil},
+		{"national without prefix", "701 123 45 67", "KZ", "+77011234567", nil},
+		{"lowercase region", "020 7946 0958", "gb", "+442079460958", nil},
+		{"national number kept when length fits", "2025550143", "US", "+12025550143", nil},
+		{"empty", "", "US", "", ErrInvalidPhoneNumber},
+		{"letters", "+1 202 555 01ab", "", "", ErrInvalidPhoneNumber},
+		{"international wrong length", "+1 202 555", "", "", ErrInvalidPhoneNumber},
+		{"national wrong length", "12345", "US", "", ErrInvalidPhoneNumber},
+		{"unknown region", "5550143", "XX", "", ErrUnknownPhoneRegion},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Phone(tt.raw, tt.defaultRegion)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Phone(%q, %q) error = %v, want %v", tt.raw, tt.defaultRegion, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Phone(%q, %q) = %q, want %q", tt.raw, tt.defaultRegion, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package normalize
+
+import (
+	"errors"
+	"golang.org/x/text/language"
+	"golang.org/x/text/unicode/norm"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const nameMaxLength = 100
+
+var (
+	ErrInvalidName   = errors.New("invalid name")
+	ErrInvalidLocale = errors.New("invalid locale")
+)
+
+// Name trims a personal name, converts it to NFC and rejects control characters.
+func Name(raw string) (string, error) {
+	name := norm.NFC.String(strings.TrimSpace(raw))
+
+	if utf8.RuneCountInString(name) > nameMaxLength {
+		return "", ErrInvalidName
+	}
+
+	for _, r := range name {
+		if unicode.IsControl(r) {
+			return "", ErrInvalidName
+		}
+	}
+
+	return name, nil
+}
+
+// Locale parses a BCP 47 language tag and returns its canonical form along
+// with its region, if the tag names one explicitly.
+func Locale(raw string) (string, string, error) {
+	tag, err := language.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return "", "", ErrInvalidLocale
+	}
+
+	region, confidence := tag.Region()
+	if confidence != language.Exact {
+		return tag.String(), "", nil
+	}
+
+	return tag.String(), region.String(), nil
+}
@@ -0,0 +1,28 @@
+package normalize
+
+import (
+	"errors"
+	"strings"
+	"time"
+	// The zone database is embedded so validation does not depend on the host.
+	_ "time/tzdata"
+)
+
+var ErrInvalidTimezone = errors.New("invalid timezone")
+
+// Timezone checks that raw names an IANA time zone, such as "Asia/Almaty".
+func Timezone(raw string) (string, error) {
+	name := strings.TrimSpace(raw)
+
+	// LoadLocation also accepts "Local", which means nothing to other hosts.
+	if name == "" || name == "Local" {
+		return "", ErrInvalidTimezone
+	}
+
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return "", ErrInvalidTimezone
+	}
+
+	return location.String(), nil
+}
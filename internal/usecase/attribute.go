@@ -0,0 +1,124 @@
+package usecase
+
+import (
+	"context"
+	"github.com/sorawaslocked/ap2final_base/pkg/logger"
+	"github.com/sorawaslocked/ap2final_user_service/internal/model"
+	"log/slog"
+	"maps"
+	"slices"
+	"time"
+)
+
+// GetAttributes returns the user's custom attributes that the caller may read.
+func (uc *User) GetAttributes(ctx context.Context, token model.Token, id string) (map[string]any, error) {
+	const op = "usecase.User.GetAttributes"
+
+	log := uc.log.With(slog.String("op", op))
+
+	claims, err := uc.verifyClaims(log, token)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := uc.usersFor(claims).FindOne(ctx, model.UserFilter{ID: &id})
+	if err != nil {
+		log.Warn("finding user", logger.Err(err), slog.String("id", id))
+
+		return nil, err
+	}
+
+	return uc.readableAttributes(user, claims.Role, claims.UserID == id), nil
+}
+
+// SetAttributes writes and removes custom attributes. The whole request is
+// rejected if the caller may not write one of the keys.
+func (uc *User) SetAttributes(
+	ctx context.Context,
+	token model.Token,
+	id string,
+	set map[string]any,
+	remove []string,
+) (map[string]any, error) {
+	const op = "usecase.User.SetAttributes"
+
+	log := uc.log.With(slog.String("op", op))
+
+	claims, err := uc.verifyClaims(log, token)
+	if err != nil {
+		return nil, err
+	}
+
+	isSelf := claims.UserID == id
+	verr := &model.ValidationError{}
+
+	for key, value := range set {
+		if err := uc.cfg.Attributes.Validate(key, value); err != nil {
+			verr.Add("attributes."+key, err.Error())
+		}
+	}
+
+	for _, key := range remove {
+		if _, ok := uc.cfg.Attributes[key]; !ok {
+			verr.Add("attributes."+key, "unknown attribute")
+		}
+	}
+
+	if err := verr.Err(); err != nil {
+		log.Warn("validating attributes", logger.Err(err), slog.String("id", id))
+
+		return nil, err
+	}
+
+	for _, key := range append(slices.Collect(maps.Keys(set)), remove...) {
+		if !uc.cfg.Attributes.CanWrite(key, claims.Role, isSelf) {
+			err := model.ErrUnauthorized
+			log.Warn(
+				"checking attribute permissions",
+				logger.Err(err),
+				slog.String("key", key),
+				slog.String("claimsUserID", claims.UserID),
+				slog.String("claimsRole", claims.Role),
+			)
+
+			return nil, err
+		}
+	}
+
+	user, err := uc.updateUser(ctx, uc.usersFor(claims), model.UserFilter{ID: &id}, model.User{}, model.UserUpdateData{
+		SetAttributes:   set,
+		UnsetAttributes: remove,
+		UpdatedAt:       time.Now().UTC(),
+	})
+	if err != nil {
+		log.Warn("updating attributes", logger.Err(err), slog.String("id", id))
+
+		return nil, err
+	}
+
+	return uc.readableAttributes(user, claims.Role, isSelf), nil
+}
+
+func (uc *User) readableAttributes(user model.User, role string, isSelf bool) map[string]any {
+	attributes := make(map[string]any)
+
+	for key, value := range user.Attributes {
+		if uc.cfg.Attributes.CanRead(key, role, isSelf) {
+			attributes[key] = value
+		}
+	}
+
+	return attributes
+}
+
+// validateAttributeFilter checks that every filtered attribute is registered and
+// the caller may read it, so listings cannot probe hidden values.
+func (uc *User) validateAttributeFilter(verr *model.ValidationError, filter map[string]any, role string) {
+	for key, value := range filter {
+		if err := uc.cfg.Attributes.Validate(key, value); err != nil {
+			verr.Add("attributes."+key, err.Error())
+		} else if !uc.cfg.Attributes.CanRead(key, role, false) {
+			verr.Add("attributes."+key, "attribute is not readable")
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package usecase
+
+import (
+	"context"
+	"github.com/sorawaslocked/ap2final_base/pkg/logger"
+	"github.com/sorawaslocked/ap2final_user_service/internal/model"
+	"log/slog"
+)
+
+// auditor writes the audit log for the use cases that embed it.
+type auditor struct {
+	auditRepo AuditRepository
+}
+
+// audit records an entry in the audit log. Failures are logged but do not fail
+// the audited operation.
+func (uc auditor) audit(ctx context.Context, log *slog.Logger, entry model.AuditEntry) {
+	if err := uc.auditRepo.InsertOne(ctx, entry); err != nil {
+		log.Error(
+			"writing audit entry",
+			logger.Err(err),
+			slog.String("action", entry.Action),
+			slog.String("targetID", entry.TargetID),
+		)
+	}
+}
@@ -0,0 +1,195 @@
+package usecase
+
+import (
+	"context"
+	"github.com/sorawaslocked/ap2final_base/pkg/logger"
+	"github.com/sorawaslocked/ap2final_base/pkg/security"
+	"github.com/sorawaslocked/ap2final_user_service/internal/model"
+	"log/slog"
+)
+
+// authenticator checks access tokens. The use cases embed it so every one of
+// them authorises callers the same way.
+type authenticator struct {
+	jwtProvider *security.JWTProvider
+}
+
+// access adds the caller's view of the users to the token checks, for use
+// cases that authorise by organisation role.
+type access struct {
+	authenticator
+	repo UserRepository
+}
+
+// verifyClaims parses the access token and returns the caller's claims.
+func (uc authenticator) verifyClaims(log *slog.Logger, token model.Token) (model.Claims, error) {
+	claims, err := uc.jwtProvider.VerifyAndParseClaims(token.AccessToken)
+	if err != nil {
+		err := model.ErrInvalidToken
+		log.Warn(
+			"verifying token and parsing claims",
+			logger.Err(err),
+			slog.String("accessToken", token.AccessToken),
+		)
+
+		return model.Claims{}, err
+	}
+
+	if claims.UserID == nil || claims.Role == nil {
+		err := model.ErrEmptyClaims
+		log.Warn(
+			"checking claims",
+			logger.Err(err),
+			slog.String("accessToken", token.AccessToken),
+		)
+
+		return model.Claims{}, err
+	}
+
+	res := model.Claims{
+		UserID: *claims.UserID,
+		Role:   *claims.Role,
+	}
+
+	// Tokens issued before organisations existed carry no tenant.
+	if claims.TenantID != nil {
+		res.TenantID = *claims.TenantID
+	}
+
+	return res, nil
+}
+
+// requireAdmin verifies the access token and rejects callers without the admin role.
+func (uc authenticator) requireAdmin(log *slog.Logger, token model.Token) (model.Claims, error) {
+	claims, err := uc.verifyClaims(log, token)
+	if err != nil {
+		return model.Claims{}, err
+	}
+
+	if claims.Role != "admin" {
+		err := model.ErrUnauthorized
+		log.Warn(
+			"checking claims",
+			logger.Err(err),
+			slog.String("claimsUserID", claims.UserID),
+			slog.String("claimsRole", claims.Role),
+		)
+
+		return model.Claims{}, err
+	}
+
+	return claims, nil
+}
+
+// requireSelfOrAdmin verifies the access token and rejects callers that are
+// neither the user with the given id nor an admin.
+func (uc authenticator) requireSelfOrAdmin(log *slog.Logger, token model.Token, id string) (model.Claims, error) {
+	claims, err := uc.verifyClaims(log, token)
+	if err != nil {
+		return model.Claims{}, err
+	}
+
+	if claims.UserID != id && claims.Role != "admin" {
+		err := model.ErrUnauthorized
+		log.Warn(
+			"checking claims",
+			logger.Err(err),
+			slog.String("claimsUserID", claims.UserID),
+			slog.String("claimsRole", claims.Role),
+		)
+
+		return model.Claims{}, err
+	}
+
+	return claims, nil
+}
+
+// requirePlatformAdmin rejects callers who are not admins outside any organisation.
+func (uc authenticator) requirePlatformAdmin(log *slog.Logger, token model.Token) (model.Claims, error) {
+	claims, err := uc.requireAdmin(log, token)
+	if err != nil {
+		return model.Claims{}, err
+	}
+
+	if !isPlatformAdmin(claims) {
+		err := model.ErrUnauthorized
+		log.Warn(
+			"checking claims",
+			logger.Err(err),
+			slog.String("claimsUserID", claims.UserID),
+			slog.String("claimsTenantID", claims.TenantID),
+		)
+
+		return model.Claims{}, err
+	}
+
+	return claims, nil
+}
+
+func isPlatformAdmin(claims model.Claims) bool {
+	return claims.Role == "admin" && claims.TenantID == ""
+}
+
+// requireOrgManager returns the caller's role in the organisation and rejects
+// callers who may not manage it. Platform admins act as owners.
+func (uc access) requireOrgManager(ctx context.Context, log *slog.Logger, claims model.Claims, orgID string) (string, error) {
+	if isPlatformAdmin(claims) {
+		return model.OrgRoleOwner, nil
+	}
+
+	if claims.TenantID == orgID {
+		caller, err := uc.usersFor(claims).FindOne(ctx, model.UserFilter{ID: &claims.UserID})
+		if err != nil {
+			log.Warn("finding caller", logger.Err(err), slog.String("id", claims.UserID))
+
+			return "", err
+		}
+
+		if model.CanManageMembers(caller.OrgRole) {
+			return caller.OrgRole, nil
+		}
+	}
+
+	err := model.ErrUnauthorized
+	log.Warn(
+		"checking organization role",
+		logger.Err(err),
+		slog.String("claimsUserID", claims.UserID),
+		slog.String("orgID", orgID),
+	)
+
+	return "", err
+}
+
+// requireManager rejects callers who may not manage the users of their
+// organisation, or of the platform when outside one, and returns their
+// organisation role, empty for platform admins.
+func (uc access) requireManager(ctx context.Context, log *slog.Logger, claims model.Claims) (string, error) {
+	if claims.TenantID != "" {
+		return uc.requireOrgManager(ctx, log, claims, claims.TenantID)
+	}
+
+	if claims.Role != "admin" {
+		err := model.ErrUnauthorized
+		log.Warn(
+			"checking claims",
+			logger.Err(err),
+			slog.String("claimsUserID", claims.UserID),
+			slog.String("claimsRole", claims.Role),
+		)
+
+		return "", err
+	}
+
+	return "", nil
+}
+
+// tenantScope returns the tenant filter for records the caller manages.
+// Platform admins are not limited to a tenant.
+func tenantScope(claims model.Claims) *string {
+	if claims.TenantID == "" {
+		return nil
+	}
+
+	return &claims.TenantID
+}
@@ -0,0 +1,171 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/sorawaslocked/ap2final_base/pkg/logger"
+	"github.com/sorawaslocked/ap2final_user_service/internal/avatar"
+	"github.com/sorawaslocked/ap2final_user_service/internal/model"
+	"io"
+	"log/slog"
+	"time"
+)
+
+// UploadAvatar replaces the caller's avatar with the image read from r. The
+// renditions are stored under a new avatar version before the user points to
+// it, so readers never see a half-written avatar.
+func (uc *User) UploadAvatar(ctx context.Context, token model.Token, contentType string, r io.Reader) (model.User, error) {
+	const op = "usecase.User.UploadAvatar"
+
+	log := uc.log.With(slog.String("op", op))
+
+	claims, err := uc.verifyClaims(log, token)
+	if err != nil {
+		return model.User{}, err
+	}
+
+	userID := claims.UserID
+
+	data, err := io.ReadAll(io.LimitReader(r, uc.cfg.AvatarMaxUploadSize+1))
+	if err != nil {
+		log.Warn("reading avatar", logger.Err(err), slog.String("id", userID))
+
+		return model.User{}, err
+	}
+
+	if int64(len(data)) > uc.cfg.AvatarMaxUploadSize {
+		err := model.ErrImageTooLarge
+		log.Warn("reading avatar", logger.Err(err), slog.String("id", userID))
+
+		return model.User{}, err
+	}
+
+	images, err := avatar.Process(data, contentType, uc.cfg.AvatarMaxDimension)
+	if err != nil {
+		log.Warn("processing avatar", logger.Err(err), slog.String("id", userID))
+
+		return model.User{}, err
+	}
+
+	user, err := uc.repo.FindOne(ctx, model.UserFilter{ID: &userID})
+	if err != nil {
+		log.Warn("finding user", logger.Err(err), slog.String("id", userID))
+
+		return model.User{}, err
+	}
+
+	// Versions come from the clock rather than a counter, so concurrent
+	// uploads never store renditions under the same version.
+	version := max(user.AvatarVersion+1, time.Now().UnixNano())
+	for i := range images {
+		images[i].UserID = userID
+		images[i].Version = version
+	}
+
+	err = uc.avatarRepo.Save(ctx, images)
+	if err != nil {
+		log.Error("saving avatar", logger.Err(err), slog.String("id", userID))
+		uc.deleteUnusedAvatar(ctx, log, userID, version)
+
+		return model.User{}, err
+	}
+
+	avatarURL := fmt.Sprintf("%s/%s?v=%d", uc.cfg.AvatarBaseURL, userID, version)
+
+	// A concurrent upload may have changed the avatar in the meantime.
+	updatedUser, err := uc.updateUser(
+		ctx,
+		uc.repo,
+		model.UserFilter{ID: &userID, Version: &user.Version},
+		user,
+		model.UserUpdateData{
+			AvatarVersion: &version,
+			AvatarURL:     &avatarURL,
+			UpdatedAt:     time.Now().UTC(),
+		},
+	)
+	if err != nil {
+		log.Warn("updating avatar version", logger.Err(err), slog.String("id", userID))
+		uc.deleteUnusedAvatar(ctx, log, userID, version)
+
+		return model.User{}, err
+	}
+
+	err = uc.avatarRepo.DeleteStale(ctx, userID, version)
+	if err != nil {
+		log.Error("deleting previous avatar", logger.Err(err), slog.String("id", userID))
+	}
+
+	return updatedUser, nil
+}
+
+// deleteUnusedAvatar removes the renditions of an upload the user does not
+// point to.
+func (uc *User) deleteUnusedAvatar(ctx context.Context, log *slog.Logger, userID string, version int64) {
+	if err := uc.avatarRepo.DeleteVersion(ctx, userID, version); err != nil {
+		log.Error("deleting unused avatar", logger.Err(err), slog.String("id", userID))
+	}
+}
+
+// GetAvatar returns the user's avatar in the given size. Users without one get
+// a generated identicon, which is always the same for the same user.
+func (uc *User) GetAvatar(ctx context.Context, token model.Token, id, size string) (model.AvatarImage, error) {
+	const op = "usecase.User.GetAvatar"
+
+	log := uc.log.With(slog.String("op", op))
+
+	claims, err := uc.verifyClaims(log, token)
+	if err != nil {
+		return model.AvatarImage{}, err
+	}
+
+	if size == "" {
+		size = model.AvatarSizeMedium
+	}
+
+	px, ok := avatar.Pixels(size)
+	if !ok {
+		verr := &model.ValidationError{}
+		verr.Add("size", "size must be one of large, medium or small")
+		log.Warn("validating avatar size", logger.Err(verr), slog.String("size", size))
+
+		return model.AvatarImage{}, verr
+	}
+
+	user, err := uc.usersFor(claims).FindOne(ctx, model.UserFilter{ID: &id})
+	if err != nil {
+		log.Warn("finding user", logger.Err(err), slog.String("id", id))
+
+		return model.AvatarImage{}, err
+	}
+
+	if user.AvatarVersion > 0 {
+		image, err := uc.avatarRepo.FindOne(ctx, id, size, user.AvatarVersion)
+		if err == nil {
+			return image, nil
+		}
+
+		if !errors.Is(err, model.ErrNotFound) {
+			log.Error("finding avatar", logger.Err(err), slog.String("id", id))
+
+			return model.AvatarImage{}, err
+		}
+
+		log.Warn("avatar files missing, using identicon", slog.String("id", id))
+	}
+
+	data, err := avatar.Identicon(id, px)
+	if err != nil {
+		log.Error("generating identicon", logger.Err(err), slog.String("id", id))
+
+		return model.AvatarImage{}, err
+	}
+
+	return model.AvatarImage{
+		UserID:      id,
+		Size:        size,
+		ContentType: avatar.IdenticonContentType,
+		Data:        data,
+	}, nil
+}
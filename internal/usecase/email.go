@@ -0,0 +1,295 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"github.com/sorawaslocked/ap2final_base/pkg/logger"
+	"github.com/sorawaslocked/ap2final_base/pkg/security"
+	"github.com/sorawaslocked/ap2final_user_service/internal/model"
+	"github.com/sorawaslocked/ap2final_user_service/internal/normalize"
+	"log/slog"
+	"time"
+)
+
+// RequestEmailChange stores newEmail as the caller's pending address and sends
+// a confirmation token to it. The current email stays in use until confirmed.
+func (uc *User) RequestEmailChange(ctx context.Context, token model.Token, newEmail, currentPassword string) error {
+	const op = "usecase.User.RequestEmailChange"
+
+	log := uc.log.With(slog.String("op", op))
+
+	claims, err := uc.verifyClaims(log, token)
+	if err != nil {
+		return err
+	}
+
+	userID := claims.UserID
+
+	user, err := uc.repo.FindOne(ctx, model.UserFilter{ID: &userID})
+	if err != nil {
+		log.Warn("finding user", logger.Err(err), slog.String("id", userID))
+
+		return err
+	}
+
+	err = security.CheckPassword(currentPassword, user.PasswordHash)
+	if err != nil {
+		err := model.ErrPasswordsDoNotMatch
+		log.Warn("checking password", logger.Err(err))
+
+		return err
+	}
+
+	newEmail, err = normalize.Email(newEmail)
+	if err != nil {
+		verr := &model.ValidationError{}
+		verr.Add("new_email", err.Error())
+		log.Warn("normalizing email", logger.Err(verr))
+
+		return verr
+	}
+
+	emailFilter := model.UserFilter{Email: &newEmail, IncludeDeleted: true}
+	if uc.cfg.EmailUniquePerTenant {
+		emailFilter.TenantID = &user.TenantID
+	}
+
+	_, err = uc.repo.FindOne(ctx, emailFilter)
+	if err == nil {
+		err := model.ErrAlreadyExists
+		log.Warn("checking email", logger.Err(err))
+
+		return err
+	}
+	if !errors.Is(err, model.ErrNotFound) {
+		log.Error("checking email", logger.Err(err))
+
+		return err
+	}
+
+	confirmToken, err := newVerificationToken()
+	if err != nil {
+		log.Error("generating token", logger.Err(err))
+
+		return err
+	}
+
+	now := time.Now().UTC()
+
+	// The token, the pending email and the mail to the new address go
+	// together, so a request never leaves a token nobody was sent.
+	err = uc.tx.WithTransaction(ctx, func(ctx context.Context) error {
+		err := uc.verificationRepo.Save(ctx, model.Verification{
+			UserID:    userID,
+			Purpose:   model.VerificationPurposeEmailChange,
+			CodeHash:  hashSecret(confirmToken),
+			Payload:   newEmail,
+			ExpiresAt: now.Add(uc.cfg.EmailChangeTTL),
+			CreatedAt: now,
+		})
+		if err != nil {
+			return err
+		}
+
+		_, err = uc.updateUser(ctx, uc.repo, model.UserFilter{ID: &userID}, user, model.UserUpdateData{
+			PendingEmail: &newEmail,
+			UpdatedAt:    now,
+		})
+		if err != nil {
+			return err
+		}
+
+		return uc.producer.PushEmailChangeRequested(ctx, user, newEmail, confirmToken)
+	})
+	if err != nil {
+		log.Warn("requesting email change", logger.Err(err), slog.String("id", userID))
+
+		return err
+	}
+
+	return nil
+}
+
+// ConfirmEmailChange swaps in the pending email and notifies the old address
+// with a token that can revert the change for a few days.
+func (uc *User) ConfirmEmailChange(ctx context.Context, confirmToken string) (model.User, error) {
+	const op = "usecase.User.ConfirmEmailChange"
+
+	log := uc.log.With(slog.String("op", op))
+
+	verification, err := uc.findVerificationByToken(ctx, model.VerificationPurposeEmailChange, confirmToken)
+	if err != nil {
+		log.Warn("finding verification", logger.Err(err))
+
+		return model.User{}, err
+	}
+
+	user, err := uc.repo.FindOne(ctx, model.UserFilter{ID: &verification.UserID})
+	if err != nil {
+		log.Warn("finding user", logger.Err(err), slog.String("id", verification.UserID))
+
+		return model.User{}, err
+	}
+
+	// A newer request replaces the pending email, invalidating older tokens.
+	if user.PendingEmail != verification.Payload {
+		err := model.ErrInvalidToken
+		log.Warn("checking pending email", logger.Err(err), slog.String("id", user.ID))
+
+		return model.User{}, err
+	}
+
+	revertToken, err := newVerificationToken()
+	if err != nil {
+		log.Error("generating token", logger.Err(err))
+
+		return model.User{}, err
+	}
+
+	// Every change gets a revert token of its own, so a second change does
+	// not take away the first address's way back.
+	revertHash := hashSecret(revertToken)
+
+	pendingEmail := ""
+	now := time.Now().UTC()
+
+	var updatedUser model.User
+
+	// The swap, the revert token and the mail to the old address go together,
+	// so the email never changes without its way back.
+	err = uc.tx.WithTransaction(ctx, func(ctx context.Context) error {
+		var err error
+
+		updatedUser, err = uc.updateUser(ctx, uc.repo, model.UserFilter{ID: &user.ID}, user, model.UserUpdateData{
+			Email:        &verification.Payload,
+			PendingEmail: &pendingEmail,
+			UpdatedAt:    now,
+		})
+		if err != nil {
+			return err
+		}
+
+		err = uc.verificationRepo.DeleteOne(ctx, user.ID, model.VerificationPurposeEmailChange)
+		if err != nil {
+			return err
+		}
+
+		err = uc.verificationRepo.Save(ctx, model.Verification{
+			UserID:    user.ID,
+			Purpose:   model.VerificationPurposeEmailRevert,
+			Key:       revertHash,
+			CodeHash:  revertHash,
+			Payload:   user.Email,
+			ExpiresAt: now.Add(uc.cfg.EmailRevertTTL),
+			CreatedAt: now,
+		})
+		if err != nil {
+			return err
+		}
+
+		return uc.producer.PushEmailChanged(ctx, user, updatedUser.Email, revertToken)
+	})
+	if err != nil {
+		log.Warn("changing email", logger.Err(err), slog.String("id", user.ID))
+
+		return model.User{}, err
+	}
+
+	uc.audit(ctx, log, model.AuditEntry{
+		ActorID:   user.ID,
+		Action:    model.AuditActionEmailChanged,
+		TargetID:  user.ID,
+		CreatedAt: now,
+	})
+
+	return updatedUser, nil
+}
+
+// RevertEmailChange restores the previous email using the token sent to it and
+// signs the user out everywhere, since the change may not have been theirs.
+func (uc *User) RevertEmailChange(ctx context.Context, revertToken string) (model.User, error) {
+	const op = "usecase.User.RevertEmailChange"
+
+	log := uc.log.With(slog.String("op", op))
+
+	verification, err := uc.findVerificationByToken(ctx, model.VerificationPurposeEmailRevert, revertToken)
+	if err != nil {
+		log.Warn("finding verification", logger.Err(err))
+
+		return model.User{}, err
+	}
+
+	pendingEmail := ""
+	now := time.Now().UTC()
+
+	var revertedUser model.User
+
+	err = uc.tx.WithTransaction(ctx, func(ctx context.Context) error {
+		var err error
+
+		revertedUser, err = uc.updateUser(
+			ctx,
+			uc.repo,
+			model.UserFilter{ID: &verification.UserID},
+			model.User{},
+			model.UserUpdateData{
+				Email:        &verification.Payload,
+				PendingEmail: &pendingEmail,
+				UpdatedAt:    now,
+			},
+		)
+		if err != nil {
+			return err
+		}
+
+		err = uc.revokeSessions(ctx, verification.UserID, model.SessionRevokeReasonEmailReverted)
+		if err != nil {
+			return err
+		}
+
+		// The reverted-to address is trusted again; tokens of later changes
+		// would undo its revert.
+		revertPurpose := model.VerificationPurposeEmailRevert
+
+		return uc.verificationRepo.DeleteMany(ctx, model.VerificationFilter{
+			UserID:  &verification.UserID,
+			Purpose: &revertPurpose,
+		})
+	})
+	if err != nil {
+		log.Warn("reverting email", logger.Err(err), slog.String("id", verification.UserID))
+
+		return model.User{}, err
+	}
+
+	uc.audit(ctx, log, model.AuditEntry{
+		ActorID:   verification.UserID,
+		Action:    model.AuditActionEmailRevert,
+		TargetID:  verification.UserID,
+		CreatedAt: now,
+	})
+
+	return revertedUser, nil
+}
+
+func (uc *User) findVerificationByToken(ctx context.Context, purpose, token string) (model.Verification, error) {
+	codeHash := hashSecret(token)
+
+	verification, err := uc.verificationRepo.FindOne(ctx, model.VerificationFilter{
+		Purpose:  &purpose,
+		CodeHash: &codeHash,
+	})
+	if err != nil {
+		if errors.Is(err, model.ErrNotFound) {
+			return model.Verification{}, model.ErrInvalidToken
+		}
+
+		return model.Verification{}, err
+	}
+
+	if err = checkVerification(verification, token, time.Now().UTC()); err != nil {
+		return model.Verification{}, err
+	}
+
+	return verification, nil
+}
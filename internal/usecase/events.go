@@ -0,0 +1,116 @@
+package usecase
+
+import (
+	"context"
+	"github.com/sorawaslocked/ap2final_user_service/internal/model"
+	"slices"
+)
+
+// revokeSessions signs the user out everywhere and announces it.
+func (uc *User) revokeSessions(ctx context.Context, userID, reason string) error {
+	return uc.tx.WithTransaction(ctx, func(ctx context.Context) error {
+		if err := uc.tokenRepo.DeleteByUserID(ctx, userID); err != nil {
+			return err
+		}
+
+		return uc.producer.PushSessionRevoked(ctx, userID, reason)
+	})
+}
+
+// updateUser applies the update and announces it in one transaction, so a
+// user is never changed without the matching events. before is the user as
+// read by the caller and is only needed when the update changes the role.
+func (uc *User) updateUser(
+	ctx context.Context,
+	users UserRepository,
+	filter model.UserFilter,
+	before model.User,
+	update model.UserUpdateData,
+) (model.User, error) {
+	var after model.User
+
+	err := uc.tx.WithTransaction(ctx, func(ctx context.Context) error {
+		var err error
+
+		after, err = users.UpdateOne(ctx, filter, update)
+		if err != nil {
+			return err
+		}
+
+		return uc.pushUpdateEvents(ctx, before, after, update)
+	})
+	if err != nil {
+		return model.User{}, err
+	}
+
+	return after, nil
+}
+
+// pushUpdateEvents announces what an update changed. before is only needed
+// when the update changes the role.
+func (uc *User) pushUpdateEvents(ctx context.Context, before, after model.User, update model.UserUpdateData) error {
+	if fields := changedFields(update); len(fields) > 0 {
+		if err := uc.producer.PushUpdated(ctx, after, fields); err != nil {
+			return err
+		}
+	}
+
+	if update.Role != nil && before.Role != after.Role {
+		if err := uc.producer.PushRoleChanged(ctx, after, before.Role); err != nil {
+			return err
+		}
+	}
+
+	if update.PasswordHash != nil {
+		if err := uc.producer.PushPasswordChanged(ctx, after); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// changedFields names the fields an update sets or clears. Password and role
+// changes have events of their own but role is listed too. Pending emails
+// and the version are internal and never listed.
+func changedFields(update model.UserUpdateData) []string {
+	var fields []string
+
+	set := []struct {
+		name  string
+		isSet bool
+	}{
+		{model.UserFieldFirstName, update.FirstName != nil},
+		{model.UserFieldLastName, update.LastName != nil},
+		{"email", update.Email != nil},
+		{model.UserFieldPhoneNumber, update.PhoneNumber != nil},
+		{"role", update.Role != nil},
+		{"tenantID", update.TenantID != nil},
+		{"orgRole", update.OrgRole != nil},
+		{"locale", update.Locale != nil},
+		{"timezone", update.Timezone != nil},
+		{"theme", update.Theme != nil},
+		{"marketingOptIn", update.MarketingOptIn != nil},
+		{"attributes", len(update.SetAttributes) > 0 || len(update.UnsetAttributes) > 0},
+		{"avatarURL", update.AvatarURL != nil},
+		{"suspension", update.Suspension != nil || update.ArchivedSuspension != nil},
+		{"erasureStatus", update.ErasureStatus != nil},
+		{"phoneVerified", update.PhoneVerified != nil},
+		{"isActive", update.IsActive != nil},
+		{"isDeleted", update.IsDeleted != nil},
+	}
+
+	for _, field := range set {
+		if field.isSet {
+			fields = append(fields, field.name)
+		}
+	}
+
+	for _, field := range update.Clear {
+		if !slices.Contains(fields, field) {
+			fields = append(fields, field)
+		}
+	}
+
+	return fields
+}
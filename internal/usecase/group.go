@@ -0,0 +1,396 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"github.com/sorawaslocked/ap2final_base/pkg/logger"
+	"github.com/sorawaslocked/ap2final_base/pkg/security"
+	"github.com/sorawaslocked/ap2final_user_service/internal/model"
+	"github.com/sorawaslocked/ap2final_user_service/internal/normalize"
+	"log/slog"
+	"slices"
+	"time"
+)
+
+// groupMembersMaxBatch caps how many users one call may add or remove.
+const groupMembersMaxBatch = 100
+
+type Group struct {
+	access
+	auditor
+	log       *slog.Logger
+	groupRepo GroupRepository
+	tx        Transactor
+	producer  GroupEventStorage
+}
+
+func NewGroup(
+	log *slog.Logger,
+	repo UserRepository,
+	groupRepo GroupRepository,
+	auditRepo AuditRepository,
+	tx Transactor,
+	producer GroupEventStorage,
+	jwtProvider *security.JWTProvider,
+) *Group {
+	return &Group{
+		access:    access{authenticator: authenticator{jwtProvider: jwtProvider}, repo: repo},
+		auditor:   auditor{auditRepo: auditRepo},
+		log:       log,
+		groupRepo: groupRepo,
+		tx:        tx,
+		producer:  producer,
+	}
+}
+
+// CreateGroup creates a group in the caller's organisation, or outside any
+// organisation for platform admins.
+func (uc *Group) CreateGroup(ctx context.Context, token model.Token, name string) (model.Group, error) {
+	const op = "usecase.Group.CreateGroup"
+
+	log := uc.log.With(slog.String("op", op))
+
+	claims, err := uc.verifyClaims(log, token)
+	if err != nil {
+		return model.Group{}, err
+	}
+
+	if _, err = uc.requireManager(ctx, log, claims); err != nil {
+		return model.Group{}, err
+	}
+
+	name, err = normalizeGroupName(log, name)
+	if err != nil {
+		return model.Group{}, err
+	}
+
+	now := time.Now().UTC()
+
+	createdGroup, err := uc.groupRepo.InsertOne(ctx, model.Group{
+		Name:      name,
+		TenantID:  claims.TenantID,
+		CreatedAt: now,
+		UpdatedAt: now,
+	})
+	if err != nil {
+		log.Warn("creating group", logger.Err(err), slog.String("name", name))
+
+		return model.Group{}, err
+	}
+
+	uc.audit(ctx, log, model.AuditEntry{
+		ActorID:   claims.UserID,
+		Action:    model.AuditActionGroupCreated,
+		TargetID:  createdGroup.ID,
+		CreatedAt: now,
+	})
+
+	return createdGroup, nil
+}
+
+func (uc *Group) RenameGroup(ctx context.Context, token model.Token, id, name string) (model.Group, error) {
+	const op = "usecase.Group.RenameGroup"
+
+	log := uc.log.With(slog.String("op", op))
+
+	claims, err := uc.verifyClaims(log, token)
+	if err != nil {
+		return model.Group{}, err
+	}
+
+	if _, err = uc.requireManager(ctx, log, claims); err != nil {
+		return model.Group{}, err
+	}
+
+	name, err = normalizeGroupName(log, name)
+	if err != nil {
+		return model.Group{}, err
+	}
+
+	renamedGroup, err := uc.groupRepo.UpdateOne(
+		ctx,
+		model.GroupFilter{ID: &id, TenantID: tenantScope(claims)},
+		model.GroupUpdateData{Name: &name, UpdatedAt: time.Now().UTC()},
+	)
+	if err != nil {
+		log.Warn("renaming group", logger.Err(err), slog.String("groupID", id))
+
+		return model.Group{}, err
+	}
+
+	return renamedGroup, nil
+}
+
+// DeleteGroup removes the group and all its memberships.
+func (uc *Group) DeleteGroup(ctx context.Context, token model.Token, id string) (model.Group, error) {
+	const op = "usecase.Group.DeleteGroup"
+
+	log := uc.log.With(slog.String("op", op))
+
+	claims, err := uc.verifyClaims(log, token)
+	if err != nil {
+		return model.Group{}, err
+	}
+
+	if _, err = uc.requireManager(ctx, log, claims); err != nil {
+		return model.Group{}, err
+	}
+
+	var deletedGroup model.Group
+
+	err = uc.tx.WithTransaction(ctx, func(ctx context.Context) error {
+		var err error
+
+		deletedGroup, err = uc.groupRepo.DeleteOne(ctx, model.GroupFilter{ID: &id, TenantID: tenantScope(claims)})
+		if err != nil {
+			return err
+		}
+
+		return uc.producer.PushGroupDeleted(ctx, deletedGroup)
+	})
+	if err != nil {
+		log.Warn("deleting group", logger.Err(err), slog.String("groupID", id))
+
+		return model.Group{}, err
+	}
+
+	uc.audit(ctx, log, model.AuditEntry{
+		ActorID:   claims.UserID,
+		Action:    model.AuditActionGroupDeleted,
+		TargetID:  id,
+		Details:   map[string]string{"name": deletedGroup.Name},
+		CreatedAt: time.Now().UTC(),
+	})
+
+	return deletedGroup, nil
+}
+
+// AddGroupMembers adds users of the group's organisation to the group and
+// returns the ids of those who were not members yet.
+func (uc *Group) AddGroupMembers(ctx context.Context, token model.Token, id string, userIDs []string) ([]string, error) {
+	const op = "usecase.Group.AddGroupMembers"
+
+	log := uc.log.With(slog.String("op", op))
+
+	group, userIDs, err := uc.groupForMembershipChange(ctx, log, token, id, userIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only users of the group's own organisation may join it.
+	users, err := uc.usersFor(model.Claims{TenantID: group.TenantID}).Find(ctx, model.UserFilter{IDs: userIDs})
+	if err != nil {
+		log.Warn("finding users", logger.Err(err))
+
+		return nil, err
+	}
+
+	if len(users) != len(userIDs) {
+		verr := &model.ValidationError{}
+
+		for _, userID := range userIDs {
+			if !slices.ContainsFunc(users, func(user model.User) bool { return user.ID == userID }) {
+				verr.Add("user_ids", fmt.Sprintf("user %s not found", userID))
+			}
+		}
+
+		log.Warn("validating members", logger.Err(verr), slog.String("groupID", id))
+
+		return nil, verr
+	}
+
+	var added []string
+
+	err = uc.tx.WithTransaction(ctx, func(ctx context.Context) error {
+		var err error
+
+		added, err = uc.groupRepo.AddMembers(ctx, id, userIDs)
+		if err != nil || len(added) == 0 {
+			return err
+		}
+
+		return uc.producer.PushGroupMembersAdded(ctx, group, added)
+	})
+	if err != nil {
+		log.Error("adding members", logger.Err(err), slog.String("groupID", id))
+
+		return nil, err
+	}
+
+	return added, nil
+}
+
+// RemoveGroupMembers removes users from the group and returns the ids of
+// those who were members.
+func (uc *Group) RemoveGroupMembers(
+	ctx context.Context,
+	token model.Token,
+	id string,
+	userIDs []string,
+) ([]string, error) {
+	const op = "usecase.Group.RemoveGroupMembers"
+
+	log := uc.log.With(slog.String("op", op))
+
+	group, userIDs, err := uc.groupForMembershipChange(ctx, log, token, id, userIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	var removed []string
+
+	err = uc.tx.WithTransaction(ctx, func(ctx context.Context) error {
+		var err error
+
+		removed, err = uc.groupRepo.RemoveMembers(ctx, id, userIDs)
+		if err != nil || len(removed) == 0 {
+			return err
+		}
+
+		return uc.producer.PushGroupMembersRemoved(ctx, group, removed)
+	})
+	if err != nil {
+		log.Error("removing members", logger.Err(err), slog.String("groupID", id))
+
+		return nil, err
+	}
+
+	return removed, nil
+}
+
+func (uc *Group) ListGroupsForUser(ctx context.Context, token model.Token, userID string) ([]model.Group, error) {
+	const op = "usecase.Group.ListGroupsForUser"
+
+	log := uc.log.With(slog.String("op", op))
+
+	claims, err := uc.requireSelfOrAdmin(log, token, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := uc.usersFor(claims).FindOne(ctx, model.UserFilter{ID: &userID})
+	if err != nil {
+		log.Warn("finding user", logger.Err(err), slog.String("id", userID))
+
+		return nil, err
+	}
+
+	groupIDs, err := uc.groupRepo.GroupIDsForUser(ctx, userID, user.TenantID, 0)
+	if err != nil {
+		log.Error("finding group ids", logger.Err(err), slog.String("id", userID))
+
+		return nil, err
+	}
+
+	if len(groupIDs) == 0 {
+		return []model.Group{}, nil
+	}
+
+	groups, err := uc.groupRepo.Find(ctx, model.GroupFilter{IDs: groupIDs})
+	if err != nil {
+		log.Error("finding groups", logger.Err(err), slog.String("id", userID))
+
+		return nil, err
+	}
+
+	return groups, nil
+}
+
+// leaveTenantGroups removes the user from every group of tenantID. It runs
+// when the user leaves an organisation so old memberships do not follow them.
+func (uc *Group) leaveTenantGroups(ctx context.Context, log *slog.Logger, userID, tenantID string) error {
+	groupIDs, err := uc.groupRepo.GroupIDsForUser(ctx, userID, tenantID, 0)
+	if err != nil {
+		log.Error("finding group ids", logger.Err(err), slog.String("id", userID))
+
+		return err
+	}
+
+	if len(groupIDs) == 0 {
+		return nil
+	}
+
+	groups, err := uc.groupRepo.Find(ctx, model.GroupFilter{IDs: groupIDs})
+	if err != nil {
+		log.Error("finding groups", logger.Err(err), slog.String("id", userID))
+
+		return err
+	}
+
+	err = uc.tx.WithTransaction(ctx, func(ctx context.Context) error {
+		for _, group := range groups {
+			removed, err := uc.groupRepo.RemoveMembers(ctx, group.ID, []string{userID})
+			if err != nil {
+				return err
+			}
+
+			if len(removed) == 0 {
+				continue
+			}
+
+			if err = uc.producer.PushGroupMembersRemoved(ctx, group, removed); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		log.Error("leaving groups", logger.Err(err), slog.String("id", userID))
+
+		return err
+	}
+
+	return nil
+}
+
+// groupForMembershipChange checks the caller may manage the group and returns
+// it with the deduplicated user ids.
+func (uc *Group) groupForMembershipChange(
+	ctx context.Context,
+	log *slog.Logger,
+	token model.Token,
+	id string,
+	userIDs []string,
+) (model.Group, []string, error) {
+	claims, err := uc.verifyClaims(log, token)
+	if err != nil {
+		return model.Group{}, nil, err
+	}
+
+	if _, err = uc.requireManager(ctx, log, claims); err != nil {
+		return model.Group{}, nil, err
+	}
+
+	userIDs = slices.Compact(slices.Sorted(slices.Values(userIDs)))
+
+	if len(userIDs) == 0 || len(userIDs) > groupMembersMaxBatch {
+		verr := &model.ValidationError{}
+		verr.Add("user_ids", fmt.Sprintf("between 1 and %d user ids are required", groupMembersMaxBatch))
+		log.Warn("validating members", logger.Err(verr), slog.String("groupID", id))
+
+		return model.Group{}, nil, verr
+	}
+
+	group, err := uc.groupRepo.FindOne(ctx, model.GroupFilter{ID: &id, TenantID: tenantScope(claims)})
+	if err != nil {
+		log.Warn("finding group", logger.Err(err), slog.String("groupID", id))
+
+		return model.Group{}, nil, err
+	}
+
+	return group, userIDs, nil
+}
+
+func normalizeGroupName(log *slog.Logger, name string) (string, error) {
+	name, err := normalize.Name(name)
+	if err != nil || name == "" {
+		verr := &model.ValidationError{}
+		verr.Add("name", "name must be between 1 and 100 characters")
+		log.Warn("validating group", logger.Err(verr))
+
+		return "", verr
+	}
+
+	return name, nil
+}
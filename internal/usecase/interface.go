@@ -9,16 +9,138 @@ type UserRepository interface {
 	InsertOne(ctx context.Context, user model.User) (model.User, error)
 	FindOne(ctx context.Context, filter model.UserFilter) (model.User, error)
 	Find(ctx context.Context, filter model.UserFilter) ([]model.User, error)
+	List(ctx context.Context, filter model.UserFilter, limit, offset int64) ([]model.User, error)
 	UpdateOne(ctx context.Context, filter model.UserFilter, update model.UserUpdateData) (model.User, error)
 	DeleteOne(ctx context.Context, filter model.UserFilter) (model.User, error)
+	Search(ctx context.Context, search model.UserSearch) ([]model.UserSearchResult, error)
 }
 
 type TokenRepository interface {
 	InsertOne(ctx context.Context, session model.Session) error
 	FindOneByToken(ctx context.Context, token string) (model.Session, error)
+	FindByUserID(ctx context.Context, userID string) ([]model.Session, error)
 	DeleteByToken(ctx context.Context, token string) error
+	DeleteByUserID(ctx context.Context, userID string) error
 }
 
+// UserEventStorage publishes one typed event per kind of change.
 type UserEventStorage interface {
 	Push(ctx context.Context, user model.User) error
+	PushUpdated(ctx context.Context, user model.User, changedFields []string) error
+	PushDeleted(ctx context.Context, user model.User) error
+	PushRoleChanged(ctx context.Context, user model.User, oldRole string) error
+	PushPasswordChanged(ctx context.Context, user model.User) error
+	PushLoggedIn(ctx context.Context, user model.User, session model.Session) error
+	PushSessionRevoked(ctx context.Context, userID, reason string) error
+	PushPurged(ctx context.Context, user model.User) error
+	PushErased(ctx context.Context, user model.User) error
+	PushPhoneVerification(ctx context.Context, user model.User, code string) error
+	PushEmailChangeRequested(ctx context.Context, user model.User, newEmail, token string) error
+	PushEmailChanged(ctx context.Context, user model.User, newEmail, revertToken string) error
+	EraseUserEvents(ctx context.Context, userID string) error
+}
+
+// GroupEventStorage publishes membership changes and deleted groups.
+type GroupEventStorage interface {
+	PushGroupMembersAdded(ctx context.Context, group model.Group, userIDs []string) error
+	PushGroupMembersRemoved(ctx context.Context, group model.Group, userIDs []string) error
+	PushGroupDeleted(ctx context.Context, group model.Group) error
+}
+
+// InvitationEventStorage publishes invitations so they can be mailed.
+type InvitationEventStorage interface {
+	PushInvitationSent(ctx context.Context, invitation model.Invitation, token string) error
+}
+
+type AuditRepository interface {
+	InsertOne(ctx context.Context, entry model.AuditEntry) error
+}
+
+type VerificationRepository interface {
+	Save(ctx context.Context, verification model.Verification) error
+	FindOne(ctx context.Context, filter model.VerificationFilter) (model.Verification, error)
+	ClaimAttempt(ctx context.Context, userID, purpose string, maxAttempts int) (model.Verification, error)
+	DeleteOne(ctx context.Context, userID, purpose string) error
+	DeleteMany(ctx context.Context, filter model.VerificationFilter) error
+}
+
+type AvatarRepository interface {
+	Save(ctx context.Context, avatars []model.AvatarImage) error
+	FindOne(ctx context.Context, userID, size string, version int64) (model.AvatarImage, error)
+	DeleteStale(ctx context.Context, userID string, keepVersion int64) error
+	DeleteVersion(ctx context.Context, userID string, version int64) error
+	DeleteByUserID(ctx context.Context, userID string) error
+}
+
+type OrganizationRepository interface {
+	InsertOne(ctx context.Context, org model.Organization) (model.Organization, error)
+	FindOne(ctx context.Context, filter model.OrganizationFilter) (model.Organization, error)
+	UpdateOne(
+		ctx context.Context,
+		filter model.OrganizationFilter,
+		update model.OrganizationUpdateData,
+	) (model.Organization, error)
+}
+
+type InvitationRepository interface {
+	InsertOne(ctx context.Context, invitation model.Invitation) (model.Invitation, error)
+	FindOne(ctx context.Context, filter model.InvitationFilter) (model.Invitation, error)
+	List(ctx context.Context, filter model.InvitationFilter, limit, offset int64) ([]model.Invitation, error)
+	UpdateOne(
+		ctx context.Context,
+		filter model.InvitationFilter,
+		update model.InvitationUpdateData,
+	) (model.Invitation, error)
+	InvitationEraser
+}
+
+// InvitationEraser removes the invitations of an erased user.
+type InvitationEraser interface {
+	DeleteMany(ctx context.Context, filter model.InvitationFilter) error
+}
+
+type GroupRepository interface {
+	InsertOne(ctx context.Context, group model.Group) (model.Group, error)
+	FindOne(ctx context.Context, filter model.GroupFilter) (model.Group, error)
+	Find(ctx context.Context, filter model.GroupFilter) ([]model.Group, error)
+	UpdateOne(ctx context.Context, filter model.GroupFilter, update model.GroupUpdateData) (model.Group, error)
+	DeleteOne(ctx context.Context, filter model.GroupFilter) (model.Group, error)
+	AddMembers(ctx context.Context, groupID string, userIDs []string) ([]string, error)
+	RemoveMembers(ctx context.Context, groupID string, userIDs []string) ([]string, error)
+	GroupMembershipRepository
+}
+
+// GroupMembershipRepository looks up and drops the memberships of one user.
+type GroupMembershipRepository interface {
+	GroupIDsForUser(ctx context.Context, userID, tenantID string, limit int64) ([]string, error)
+	DeleteMembershipsByUserID(ctx context.Context, userID string) error
+}
+
+type WebhookRepository interface {
+	InsertOne(ctx context.Context, webhook model.Webhook) (model.Webhook, error)
+	List(ctx context.Context, filter model.WebhookFilter, limit, offset int64) ([]model.Webhook, error)
+	UpdateOne(ctx context.Context, filter model.WebhookFilter, update model.WebhookUpdateData) (model.Webhook, error)
+	DeleteOne(ctx context.Context, filter model.WebhookFilter) (model.Webhook, error)
+	ListDeliveries(
+		ctx context.Context,
+		filter model.WebhookDeliveryFilter,
+		limit, offset int64,
+	) ([]model.WebhookDelivery, error)
+}
+
+type ReplayCheckpointRepository interface {
+	FindOne(ctx context.Context, id string) (model.ReplayCheckpoint, error)
+	Save(ctx context.Context, checkpoint model.ReplayCheckpoint) error
+}
+
+// ReplayEventStorage publishes the events of a replay.
+type ReplayEventStorage interface {
+	PushRegister(ctx context.Context, user model.User) error
+	PushSnapshot(ctx context.Context, user model.User) error
+}
+
+// Transactor runs fn in a transaction. Repositories called with the context
+// passed to fn take part in it.
+type Transactor interface {
+	WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error
 }
@@ -0,0 +1,388 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"github.com/sorawaslocked/ap2final_base/pkg/logger"
+	"github.com/sorawaslocked/ap2final_base/pkg/security"
+	"github.com/sorawaslocked/ap2final_user_service/internal/model"
+	"github.com/sorawaslocked/ap2final_user_service/internal/normalize"
+	"log/slog"
+	"time"
+)
+
+type InvitationConfig struct {
+	TTL time.Duration
+	// EmailUniquePerTenant allows the same email in different organisations.
+	EmailUniquePerTenant bool
+}
+
+type Invitation struct {
+	access
+	auditor
+	cfg            InvitationConfig
+	log            *slog.Logger
+	invitationRepo InvitationRepository
+	users          *User
+	tx             Transactor
+	producer       InvitationEventStorage
+}
+
+// NewInvitation returns the invitation use case. Accepted invitations are
+// registered through users.
+func NewInvitation(
+	log *slog.Logger,
+	invitationRepo InvitationRepository,
+	users *User,
+	auditRepo AuditRepository,
+	tx Transactor,
+	producer InvitationEventStorage,
+	jwtProvider *security.JWTProvider,
+	cfg InvitationConfig,
+) *Invitation {
+	return &Invitation{
+		access:         access{authenticator: authenticator{jwtProvider: jwtProvider}, repo: users.repo},
+		auditor:        auditor{auditRepo: auditRepo},
+		cfg:            cfg,
+		log:            log,
+		invitationRepo: invitationRepo,
+		users:          users,
+		tx:             tx,
+		producer:       producer,
+	}
+}
+
+// CreateInvitation invites an email address and mails it a one-time token.
+// Platform admins invite with a platform role ("user" or "admin"). Owners and
+// admins of an organisation invite into it, and the role is the organisation
+// role the invitee will get.
+func (uc *Invitation) CreateInvitation(
+	ctx context.Context,
+	token model.Token,
+	invitation model.Invitation,
+) (model.Invitation, error) {
+	const op = "usecase.Invitation.CreateInvitation"
+
+	log := uc.log.With(slog.String("op", op))
+
+	claims, err := uc.verifyClaims(log, token)
+	if err != nil {
+		return model.Invitation{}, err
+	}
+
+	callerRole, err := uc.requireManager(ctx, log, claims)
+	if err != nil {
+		return model.Invitation{}, err
+	}
+
+	now := time.Now().UTC()
+	verr := &model.ValidationError{}
+
+	email, err := normalize.Email(invitation.Email)
+	if err != nil {
+		verr.Add("email", err.Error())
+	}
+	invitation.Email = email
+
+	if claims.TenantID == "" {
+		if invitation.Role != "user" && invitation.Role != "admin" {
+			verr.Add("role", "role must be user or admin")
+		}
+	} else {
+		if !model.IsValidOrgRole(invitation.Role) {
+			verr.Add("role", "role must be one of owner, admin or member")
+		}
+
+		invitation.TenantID = claims.TenantID
+		invitation.OrgRole = invitation.Role
+		invitation.Role = "user"
+	}
+
+	if invitation.ExpiresAt.IsZero() {
+		invitation.ExpiresAt = now.Add(uc.cfg.TTL)
+	} else if !invitation.ExpiresAt.After(now) {
+		verr.Add("expires_at", "expiry must be in the future")
+	}
+
+	if err = verr.Err(); err != nil {
+		log.Warn("validating invitation", logger.Err(err))
+
+		return model.Invitation{}, err
+	}
+
+	if invitation.OrgRole == model.OrgRoleOwner && callerRole != model.OrgRoleOwner {
+		err := model.ErrUnauthorized
+		log.Warn("inviting owner", logger.Err(err), slog.String("claimsUserID", claims.UserID))
+
+		return model.Invitation{}, err
+	}
+
+	emailFilter := model.UserFilter{Email: &invitation.Email, IncludeDeleted: true}
+	if uc.cfg.EmailUniquePerTenant {
+		emailFilter.TenantID = &invitation.TenantID
+	}
+
+	_, err = uc.repo.FindOne(ctx, emailFilter)
+	if err == nil {
+		err := model.ErrAlreadyExists
+		log.Warn("checking email", logger.Err(err))
+
+		return model.Invitation{}, err
+	}
+	if !errors.Is(err, model.ErrNotFound) {
+		log.Error("checking email", logger.Err(err))
+
+		return model.Invitation{}, err
+	}
+
+	secret, err := newVerificationToken()
+	if err != nil {
+		log.Error("generating invitation token", logger.Err(err))
+
+		return model.Invitation{}, err
+	}
+
+	invitation.TokenHash = hashSecret(secret)
+	invitation.Status = model.InvitationStatusPending
+	invitation.InvitedBy = claims.UserID
+	invitation.CreatedAt = now
+	invitation.UpdatedAt = now
+
+	var createdInvitation model.Invitation
+
+	err = uc.tx.WithTransaction(ctx, func(ctx context.Context) error {
+		var err error
+
+		createdInvitation, err = uc.invitationRepo.InsertOne(ctx, invitation)
+		if err != nil {
+			return err
+		}
+
+		return uc.producer.PushInvitationSent(ctx, createdInvitation, secret)
+	})
+	if err != nil {
+		log.Error("creating invitation", logger.Err(err))
+
+		return model.Invitation{}, err
+	}
+
+	uc.audit(ctx, log, model.AuditEntry{
+		ActorID:   claims.UserID,
+		Action:    model.AuditActionInvitationCreated,
+		TargetID:  createdInvitation.ID,
+		Details:   map[string]string{"role": invitation.Role, "orgID": invitation.TenantID, "orgRole": invitation.OrgRole},
+		CreatedAt: now,
+	})
+
+	return createdInvitation, nil
+}
+
+// ListInvitations returns the invitations the caller manages, newest first.
+func (uc *Invitation) ListInvitations(
+	ctx context.Context,
+	token model.Token,
+	listing model.InvitationListing,
+) ([]model.Invitation, error) {
+	const op = "usecase.Invitation.ListInvitations"
+
+	log := uc.log.With(slog.String("op", op))
+
+	claims, err := uc.verifyClaims(log, token)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = uc.requireManager(ctx, log, claims); err != nil {
+		return nil, err
+	}
+
+	verr := &model.ValidationError{}
+
+	switch listing.Status {
+	case "", model.InvitationStatusPending, model.InvitationStatusAccepted, model.InvitationStatusRevoked:
+	default:
+		verr.Add("status", "status must be one of pending, accepted or revoked")
+	}
+
+	if listing.Offset < 0 {
+		verr.Add("offset", "offset must not be negative")
+	}
+
+	if err = verr.Err(); err != nil {
+		log.Warn("validating listing", logger.Err(err))
+
+		return nil, err
+	}
+
+	if listing.Limit <= 0 {
+		listing.Limit = searchDefaultLimit
+	}
+	if listing.Limit > searchMaxLimit {
+		listing.Limit = searchMaxLimit
+	}
+
+	filter := model.InvitationFilter{TenantID: tenantScope(claims)}
+	if listing.Status != "" {
+		filter.Status = &listing.Status
+	}
+
+	invitations, err := uc.invitationRepo.List(ctx, filter, listing.Limit, listing.Offset)
+	if err != nil {
+		log.Error("listing invitations", logger.Err(err))
+
+		return nil, err
+	}
+
+	return invitations, nil
+}
+
+// RevokeInvitation cancels a pending invitation so its token can no longer be used.
+func (uc *Invitation) RevokeInvitation(ctx context.Context, token model.Token, id string) (model.Invitation, error) {
+	const op = "usecase.Invitation.RevokeInvitation"
+
+	log := uc.log.With(slog.String("op", op))
+
+	claims, err := uc.verifyClaims(log, token)
+	if err != nil {
+		return model.Invitation{}, err
+	}
+
+	if _, err = uc.requireManager(ctx, log, claims); err != nil {
+		return model.Invitation{}, err
+	}
+
+	filter := model.InvitationFilter{ID: &id, TenantID: tenantScope(claims)}
+	pending := model.InvitationStatusPending
+	filter.Status = &pending
+
+	revoked := model.InvitationStatusRevoked
+	now := time.Now().UTC()
+
+	revokedInvitation, err := uc.invitationRepo.UpdateOne(ctx, filter, model.InvitationUpdateData{
+		Status:    &revoked,
+		UpdatedAt: now,
+	})
+	if err != nil {
+		log.Warn("revoking invitation", logger.Err(err), slog.String("invitationID", id))
+
+		return model.Invitation{}, err
+	}
+
+	uc.audit(ctx, log, model.AuditEntry{
+		ActorID:   claims.UserID,
+		Action:    model.AuditActionInvitationRevoked,
+		TargetID:  id,
+		CreatedAt: now,
+	})
+
+	return revokedInvitation, nil
+}
+
+// AcceptInvitation creates the invited user. The email and roles come from
+// the invitation; the profile supplies the rest of the registration.
+func (uc *Invitation) AcceptInvitation(
+	ctx context.Context,
+	invitationToken, password string,
+	profile model.User,
+) (model.User, error) {
+	const op = "usecase.Invitation.AcceptInvitation"
+
+	log := uc.log.With(slog.String("op", op))
+
+	tokenHash := hashSecret(invitationToken)
+	pending := model.InvitationStatusPending
+
+	invitation, err := uc.invitationRepo.FindOne(ctx, model.InvitationFilter{TokenHash: &tokenHash, Status: &pending})
+	if err != nil {
+		log.Warn("finding invitation", logger.Err(err))
+
+		return model.User{}, err
+	}
+
+	now := time.Now().UTC()
+
+	if !invitation.ExpiresAt.After(now) {
+		err := model.ErrInvitationExpired
+		log.Warn("checking invitation", logger.Err(err), slog.String("invitationID", invitation.ID))
+
+		return model.User{}, err
+	}
+
+	user := model.User{
+		FirstName:   profile.FirstName,
+		LastName:    profile.LastName,
+		Email:       invitation.Email,
+		PhoneNumber: profile.PhoneNumber,
+		Locale:      profile.Locale,
+		Password:    password,
+	}
+
+	user, err = uc.users.normalizeRegistration(user)
+	if err != nil {
+		log.Warn("validating registration", logger.Err(err))
+
+		return model.User{}, err
+	}
+
+	passwordHash, err := security.HashPassword(user.Password)
+	if err != nil {
+		log.Error("hashing password", logger.Err(err))
+
+		return model.User{}, err
+	}
+
+	user.PasswordHash = passwordHash
+	user.Role = invitation.Role
+	user.TenantID = invitation.TenantID
+	user.OrgRole = invitation.OrgRole
+	user.CreatedAt = now
+	user.UpdatedAt = now
+
+	accepted := model.InvitationStatusAccepted
+
+	var createdUser model.User
+
+	// Claiming the invitation and creating the user commit together, so a
+	// token is used at most once and a failed registration keeps it usable.
+	err = uc.tx.WithTransaction(ctx, func(ctx context.Context) error {
+		_, err := uc.invitationRepo.UpdateOne(
+			ctx,
+			model.InvitationFilter{ID: &invitation.ID, Status: &pending},
+			model.InvitationUpdateData{Status: &accepted, UpdatedAt: now},
+		)
+		if err != nil {
+			return err
+		}
+
+		createdUser, err = uc.users.insertUser(ctx, user)
+		if err != nil {
+			return err
+		}
+
+		_, err = uc.invitationRepo.UpdateOne(
+			ctx,
+			model.InvitationFilter{ID: &invitation.ID},
+			model.InvitationUpdateData{AcceptedBy: &createdUser.ID, UpdatedAt: now},
+		)
+
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, model.ErrAlreadyExists) || errors.Is(err, model.ErrNotFound) {
+			log.Warn("accepting invitation", logger.Err(err), slog.String("invitationID", invitation.ID))
+		} else {
+			log.Error("accepting invitation", logger.Err(err), slog.String("invitationID", invitation.ID))
+		}
+
+		return model.User{}, err
+	}
+
+	uc.audit(ctx, log, model.AuditEntry{
+		ActorID:   createdUser.ID,
+		Action:    model.AuditActionInvitationAccepted,
+		TargetID:  invitation.ID,
+		CreatedAt: now,
+	})
+
+	return createdUser, nil
+}
@@ -0,0 +1,136 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"github.com/sorawaslocked/ap2final_user_service/internal/model"
+	"io"
+	"log/slog"
+	"slices"
+	"testing"
+	"time"
+)
+
+type fakeInvitations struct {
+	InvitationRepository
+	journal    *journal
+	invitation model.Invitation
+}
+
+func (r fakeInvitations) FindOne(_ context.Context, filter model.InvitationFilter) (model.Invitation, error) {
+	if filter.TokenHash == nil || *filter.TokenHash != r.invitation.TokenHash {
+		return model.Invitation{}, model.ErrNotFound
+	}
+
+	return r.invitation, nil
+}
+
+func (r fakeInvitations) UpdateOne(
+	ctx context.Context,
+	filter model.InvitationFilter,
+	update model.InvitationUpdateData,
+) (model.Invitation, error) {
+	if update.AcceptedBy != nil {
+		return r.invitation, r.journal.write(ctx, "invitation %s accepted by %s", *filter.ID, *update.AcceptedBy)
+	}
+
+	return r.invitation, r.journal.write(ctx, "claim invitation %s", *filter.ID)
+}
+
+type fakeAudit struct {
+	journal *journal
+}
+
+func (r fakeAudit) InsertOne(ctx context.Context, entry model.AuditEntry) error {
+	return r.journal.write(ctx, "audit %s %s", entry.Action, entry.TargetID)
+}
+
+func newTestInvitation(j *journal, expiresAt time.Time) *Invitation {
+	invitations := fakeInvitations{
+		journal: j,
+		invitation: model.Invitation{
+			ID:        "invitation-1",
+			Email:     "ada@example.com",
+			Role:      "user",
+			TenantID:  "org-1",
+			OrgRole:   "member",
+			TokenHash: hashSecret("invitation-token"),
+			Status:    model.InvitationStatusPending,
+			ExpiresAt: expiresAt,
+		},
+	}
+
+	return NewInvitation(
+		slog.New(slog.NewTextHandler(io.Discard, nil)),
+		invitations,
+		newTestUser(j, nil, UserConfig{}),
+		fakeAudit{journal: j},
+		fakeTransactor{journal: j},
+		nil,
+		nil,
+		InvitationConfig{},
+	)
+}
+
+func TestInvitationAccept(t *testing.T) {
+	j := &journal{}
+	profile := model.User{FirstName: "Ada", LastName: "Lovelace"}
+
+	user, err := newTestInvitation(j, time.Now().Add(time.Hour)).
+		AcceptInvitation(context.Background(), "invitation-token", "correct horse", profile)
+	if err != nil {
+		t.Fatalf("AcceptInvitation() error = %v", err)
+	}
+
+	if user.Email != "ada@example.com" || user.TenantID != "org-1" || user.OrgRole != "member" {
+		t.Errorf("AcceptInvitation() = %+v, want the invited user in org-1", user)
+	}
+
+	want := []string{
+		"claim invitation invitation-1",
+		"insert user user-1",
+		"push registered user-1",
+		"invitation invitation-1 accepted by user-1",
+		"audit " + model.AuditActionInvitationAccepted + " invitation-1 (outside transaction)",
+	}
+	if !slices.Equal(j.entries, want) {
+		t.Errorf("entries = %q, want %q", j.entries, want)
+	}
+}
+
+func TestInvitationAcceptFailures(t *testing.T) {
+	tests := []struct {
+		name      string
+		token     string
+		expiresAt time.Time
+		fail      map[string]error
+		wantErr   error
+	}{
+		{"unknown token", "other-token", time.Now().Add(time.Hour), nil, model.ErrNotFound},
+		{"expired", "invitation-token", time.Now().Add(-time.Second), nil, model.ErrInvitationExpired},
+		// The claim is rolled back, so the invitation stays usable.
+		{
+			"email taken",
+			"invitation-token",
+			time.Now().Add(time.Hour),
+			map[string]error{"insert user user-1": model.ErrAlreadyExists},
+			model.ErrAlreadyExists,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			j := &journal{fail: tt.fail}
+			profile := model.User{FirstName: "Ada", LastName: "Lovelace"}
+
+			_, err := newTestInvitation(j, tt.expiresAt).
+				AcceptInvitation(context.Background(), tt.token, "correct horse", profile)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("AcceptInvitation() error = %v, want %v", err, tt.wantErr)
+			}
+			if len(j.entries) != 0 {
+				t.Errorf("entries = %q, want none", j.entries)
+			}
+		})
+	}
+}
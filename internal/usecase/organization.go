@@ -0,0 +1,497 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"github.com/sorawaslocked/ap2final_base/pkg/logger"
+	"github.com/sorawaslocked/ap2final_base/pkg/security"
+	"github.com/sorawaslocked/ap2final_user_service/internal/model"
+	"github.com/sorawaslocked/ap2final_user_service/internal/normalize"
+	"log/slog"
+	"regexp"
+	"time"
+)
+
+var orgSlugPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{1,62}$`)
+
+type Organization struct {
+	access
+	auditor
+	log     *slog.Logger
+	orgRepo OrganizationRepository
+	users   *User
+	groups  *Group
+}
+
+// NewOrganization returns the organisation use case. Membership changes are
+// written through users and groups so they publish the same events.
+func NewOrganization(
+	log *slog.Logger,
+	orgRepo OrganizationRepository,
+	users *User,
+	groups *Group,
+	auditRepo AuditRepository,
+	jwtProvider *security.JWTProvider,
+) *Organization {
+	return &Organization{
+		access:  access{authenticator: authenticator{jwtProvider: jwtProvider}, repo: users.repo},
+		auditor: auditor{auditRepo: auditRepo},
+		log:     log,
+		orgRepo: orgRepo,
+		users:   users,
+		groups:  groups,
+	}
+}
+
+// CreateOrganization creates an organisation and, if ownerID is set, makes
+// that user its first owner. Only platform admins may create organisations.
+func (uc *Organization) CreateOrganization(
+	ctx context.Context,
+	token model.Token,
+	org model.Organization,
+	ownerID string,
+) (model.Organization, error) {
+	const op = "usecase.Organization.CreateOrganization"
+
+	log := uc.log.With(slog.String("op", op))
+
+	claims, err := uc.requirePlatformAdmin(log, token)
+	if err != nil {
+		return model.Organization{}, err
+	}
+
+	verr := &model.ValidationError{}
+
+	org.Name, err = normalize.Name(org.Name)
+	if err != nil || org.Name == "" {
+		verr.Add("name", "name must be between 1 and 100 characters")
+	}
+
+	if !orgSlugPattern.MatchString(org.Slug) {
+		verr.Add("slug", "slug must be 2-63 lowercase letters, digits or dashes")
+	}
+
+	if err = verr.Err(); err != nil {
+		log.Warn("validating organization", logger.Err(err))
+
+		return model.Organization{}, err
+	}
+
+	org.CreatedAt = time.Now().UTC()
+	org.UpdatedAt = org.CreatedAt
+
+	createdOrg, err := uc.orgRepo.InsertOne(ctx, org)
+	if err != nil {
+		log.Warn("creating organization", logger.Err(err), slog.String("slug", org.Slug))
+
+		return model.Organization{}, err
+	}
+
+	uc.audit(ctx, log, model.AuditEntry{
+		ActorID:   claims.UserID,
+		Action:    model.AuditActionOrgCreated,
+		TargetID:  createdOrg.ID,
+		CreatedAt: createdOrg.CreatedAt,
+	})
+
+	if ownerID != "" {
+		_, err = uc.joinOrganization(ctx, log, claims, createdOrg.ID, ownerID, model.OrgRoleOwner)
+		if err != nil {
+			return model.Organization{}, err
+		}
+	}
+
+	return createdOrg, nil
+}
+
+func (uc *Organization) GetOrganization(ctx context.Context, token model.Token, id string) (model.Organization, error) {
+	const op = "usecase.Organization.GetOrganization"
+
+	log := uc.log.With(slog.String("op", op))
+
+	claims, err := uc.verifyClaims(log, token)
+	if err != nil {
+		return model.Organization{}, err
+	}
+
+	if claims.TenantID != id && !isPlatformAdmin(claims) {
+		err := model.ErrUnauthorized
+		log.Warn(
+			"checking claims",
+			logger.Err(err),
+			slog.String("claimsUserID", claims.UserID),
+			slog.String("orgID", id),
+		)
+
+		return model.Organization{}, err
+	}
+
+	org, err := uc.orgRepo.FindOne(ctx, model.OrganizationFilter{ID: &id})
+	if err != nil {
+		log.Warn("finding organization", logger.Err(err), slog.String("orgID", id))
+
+		return model.Organization{}, err
+	}
+
+	return org, nil
+}
+
+func (uc *Organization) UpdateOrganization(
+	ctx context.Context,
+	token model.Token,
+	id string,
+	update model.OrganizationUpdateData,
+) (model.Organization, error) {
+	const op = "usecase.Organization.UpdateOrganization"
+
+	log := uc.log.With(slog.String("op", op))
+
+	claims, err := uc.verifyClaims(log, token)
+	if err != nil {
+		return model.Organization{}, err
+	}
+
+	if _, err = uc.requireOrgManager(ctx, log, claims, id); err != nil {
+		return model.Organization{}, err
+	}
+
+	if update.Name != nil {
+		name, err := normalize.Name(*update.Name)
+		if err != nil || name == "" {
+			verr := &model.ValidationError{}
+			verr.Add("name", "name must be between 1 and 100 characters")
+			log.Warn("validating organization", logger.Err(verr))
+
+			return model.Organization{}, verr
+		}
+		update.Name = &name
+	}
+
+	update.UpdatedAt = time.Now().UTC()
+
+	org, err := uc.orgRepo.UpdateOne(ctx, model.OrganizationFilter{ID: &id}, update)
+	if err != nil {
+		log.Warn("updating organization", logger.Err(err), slog.String("orgID", id))
+
+		return model.Organization{}, err
+	}
+
+	return org, nil
+}
+
+func (uc *Organization) ListOrganizationMembers(
+	ctx context.Context,
+	token model.Token,
+	id string,
+	limit, offset int64,
+) ([]model.User, error) {
+	const op = "usecase.Organization.ListOrganizationMembers"
+
+	log := uc.log.With(slog.String("op", op))
+
+	claims, err := uc.verifyClaims(log, token)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = uc.requireOrgManager(ctx, log, claims, id); err != nil {
+		return nil, err
+	}
+
+	if offset < 0 {
+		verr := &model.ValidationError{}
+		verr.Add("offset", "offset must not be negative")
+		log.Warn("validating listing", logger.Err(verr))
+
+		return nil, verr
+	}
+
+	if limit <= 0 {
+		limit = searchDefaultLimit
+	}
+	if limit > searchMaxLimit {
+		limit = searchMaxLimit
+	}
+
+	members, err := uc.usersFor(model.Claims{TenantID: id}).List(ctx, model.UserFilter{}, limit, offset)
+	if err != nil {
+		log.Warn("listing members", logger.Err(err), slog.String("orgID", id))
+
+		return nil, err
+	}
+
+	return members, nil
+}
+
+// AddOrganizationMember moves a user who belongs to no organisation into this
+// one. Only platform admins may do this; organisation admins invite users.
+func (uc *Organization) AddOrganizationMember(
+	ctx context.Context,
+	token model.Token,
+	orgID, userID, role string,
+) (model.User, error) {
+	const op = "usecase.Organization.AddOrganizationMember"
+
+	log := uc.log.With(slog.String("op", op))
+
+	claims, err := uc.requirePlatformAdmin(log, token)
+	if err != nil {
+		return model.User{}, err
+	}
+
+	if !model.IsValidOrgRole(role) {
+		return model.User{}, invalidOrgRole(log, role)
+	}
+
+	if _, err = uc.orgRepo.FindOne(ctx, model.OrganizationFilter{ID: &orgID}); err != nil {
+		log.Warn("finding organization", logger.Err(err), slog.String("orgID", orgID))
+
+		return model.User{}, err
+	}
+
+	return uc.joinOrganization(ctx, log, claims, orgID, userID, role)
+}
+
+func (uc *Organization) UpdateOrganizationMember(
+	ctx context.Context,
+	token model.Token,
+	orgID, userID, role string,
+) (model.User, error) {
+	const op = "usecase.Organization.UpdateOrganizationMember"
+
+	log := uc.log.With(slog.String("op", op))
+
+	claims, err := uc.verifyClaims(log, token)
+	if err != nil {
+		return model.User{}, err
+	}
+
+	callerRole, err := uc.requireOrgManager(ctx, log, claims, orgID)
+	if err != nil {
+		return model.User{}, err
+	}
+
+	if !model.IsValidOrgRole(role) {
+		return model.User{}, invalidOrgRole(log, role)
+	}
+
+	members := uc.usersFor(model.Claims{TenantID: orgID})
+
+	member, err := members.FindOne(ctx, model.UserFilter{ID: &userID})
+	if err != nil {
+		log.Warn("finding member", logger.Err(err), slog.String("id", userID))
+
+		return model.User{}, memberNotFound(err)
+	}
+
+	if err = uc.checkOwnerChange(ctx, log, orgID, callerRole, member, role); err != nil {
+		return model.User{}, err
+	}
+
+	updatedMember, err := uc.users.updateUser(ctx, members, model.UserFilter{ID: &userID}, member, model.UserUpdateData{
+		OrgRole:   &role,
+		UpdatedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		log.Warn("updating member", logger.Err(err), slog.String("id", userID))
+
+		return model.User{}, err
+	}
+
+	uc.audit(ctx, log, model.AuditEntry{
+		ActorID:   claims.UserID,
+		Action:    model.AuditActionOrgMemberRoleChanged,
+		TargetID:  userID,
+		Details:   map[string]string{"orgID": orgID, "from": member.OrgRole, "to": role},
+		CreatedAt: time.Now().UTC(),
+	})
+
+	return updatedMember, nil
+}
+
+// RemoveOrganizationMember takes the user out of the organisation and its
+// groups. Their sessions are revoked so new tokens no longer carry the tenant.
+func (uc *Organization) RemoveOrganizationMember(ctx context.Context, token model.Token, orgID, userID string) (model.User, error) {
+	const op = "usecase.Organization.RemoveOrganizationMember"
+
+	log := uc.log.With(slog.String("op", op))
+
+	claims, err := uc.verifyClaims(log, token)
+	if err != nil {
+		return model.User{}, err
+	}
+
+	callerRole, err := uc.requireOrgManager(ctx, log, claims, orgID)
+	if err != nil {
+		return model.User{}, err
+	}
+
+	member, err := uc.usersFor(model.Claims{TenantID: orgID}).FindOne(ctx, model.UserFilter{ID: &userID})
+	if err != nil {
+		log.Warn("finding member", logger.Err(err), slog.String("id", userID))
+
+		return model.User{}, memberNotFound(err)
+	}
+
+	if err = uc.checkOwnerChange(ctx, log, orgID, callerRole, member, ""); err != nil {
+		return model.User{}, err
+	}
+
+	noTenant := ""
+
+	removedMember, err := uc.users.updateUser(
+		ctx,
+		uc.repo,
+		model.UserFilter{ID: &userID, TenantID: &orgID},
+		member,
+		model.UserUpdateData{
+			TenantID:  &noTenant,
+			OrgRole:   &noTenant,
+			UpdatedAt: time.Now().UTC(),
+		},
+	)
+	if err != nil {
+		log.Warn("removing member", logger.Err(err), slog.String("id", userID))
+
+		return model.User{}, err
+	}
+
+	if err = uc.groups.leaveTenantGroups(ctx, log, userID, orgID); err != nil {
+		return model.User{}, err
+	}
+
+	err = uc.users.revokeSessions(ctx, userID, model.SessionRevokeReasonMembershipChanged)
+	if err != nil {
+		log.Error("revoking sessions", logger.Err(err), slog.String("id", userID))
+
+		return model.User{}, err
+	}
+
+	uc.audit(ctx, log, model.AuditEntry{
+		ActorID:   claims.UserID,
+		Action:    model.AuditActionOrgMemberRemoved,
+		TargetID:  userID,
+		Details:   map[string]string{"orgID": orgID},
+		CreatedAt: time.Now().UTC(),
+	})
+
+	return removedMember, nil
+}
+
+// joinOrganization moves a user outside any organisation into orgID, drops
+// their memberships of groups outside any organisation and revokes their
+// sessions so new tokens carry the tenant.
+func (uc *Organization) joinOrganization(
+	ctx context.Context,
+	log *slog.Logger,
+	claims model.Claims,
+	orgID, userID, role string,
+) (model.User, error) {
+	noTenant := ""
+
+	member, err := uc.users.updateUser(
+		ctx,
+		uc.repo,
+		model.UserFilter{ID: &userID, TenantID: &noTenant},
+		model.User{},
+		model.UserUpdateData{
+			TenantID:  &orgID,
+			OrgRole:   &role,
+			UpdatedAt: time.Now().UTC(),
+		},
+	)
+	if errors.Is(err, model.ErrNotFound) {
+		// Tell an unknown user apart from one who already has an organisation.
+		if _, findErr := uc.repo.FindOne(ctx, model.UserFilter{ID: &userID}); findErr == nil {
+			err = model.ErrAlreadyMember
+		}
+	}
+	if err != nil {
+		log.Warn("adding member", logger.Err(err), slog.String("id", userID), slog.String("orgID", orgID))
+
+		return model.User{}, err
+	}
+
+	if err = uc.groups.leaveTenantGroups(ctx, log, userID, noTenant); err != nil {
+		return model.User{}, err
+	}
+
+	err = uc.users.revokeSessions(ctx, userID, model.SessionRevokeReasonMembershipChanged)
+	if err != nil {
+		log.Error("revoking sessions", logger.Err(err), slog.String("id", userID))
+
+		return model.User{}, err
+	}
+
+	uc.audit(ctx, log, model.AuditEntry{
+		ActorID:   claims.UserID,
+		Action:    model.AuditActionOrgMemberAdded,
+		TargetID:  userID,
+		Details:   map[string]string{"orgID": orgID, "role": role},
+		CreatedAt: time.Now().UTC(),
+	})
+
+	return member, nil
+}
+
+// checkOwnerChange allows only owners to grant or take away the owner role
+// and keeps at least one owner in the organisation. newRole is empty when the
+// member is being removed.
+func (uc *Organization) checkOwnerChange(
+	ctx context.Context,
+	log *slog.Logger,
+	orgID, callerRole string,
+	member model.User,
+	newRole string,
+) error {
+	touchesOwner := member.OrgRole == model.OrgRoleOwner || newRole == model.OrgRoleOwner
+	if !touchesOwner {
+		return nil
+	}
+
+	if callerRole != model.OrgRoleOwner {
+		err := model.ErrUnauthorized
+		log.Warn("changing owner", logger.Err(err), slog.String("id", member.ID))
+
+		return err
+	}
+
+	if member.OrgRole != model.OrgRoleOwner || newRole == model.OrgRoleOwner {
+		return nil
+	}
+
+	owner := model.OrgRoleOwner
+
+	owners, err := uc.usersFor(model.Claims{TenantID: orgID}).Find(ctx, model.UserFilter{OrgRole: &owner})
+	if err != nil {
+		log.Error("finding owners", logger.Err(err), slog.String("orgID", orgID))
+
+		return err
+	}
+
+	if len(owners) <= 1 {
+		err := model.ErrLastOwner
+		log.Warn("changing owner", logger.Err(err), slog.String("id", member.ID))
+
+		return err
+	}
+
+	return nil
+}
+
+func invalidOrgRole(log *slog.Logger, role string) error {
+	verr := &model.ValidationError{}
+	verr.Add("role", "role must be one of owner, admin or member")
+	log.Warn("validating role", logger.Err(verr), slog.String("role", role))
+
+	return verr
+}
+
+// memberNotFound reports users outside the organisation as non-members.
+func memberNotFound(err error) error {
+	if errors.Is(err, model.ErrNotFound) {
+		return model.ErrNotMember
+	}
+
+	return err
+}
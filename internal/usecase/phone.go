@@ -0,0 +1,159 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"github.com/sorawaslocked/ap2final_base/pkg/logger"
+	"github.com/sorawaslocked/ap2final_user_service/internal/model"
+	"log/slog"
+	"time"
+)
+
+// RequestPhoneVerification sends a one-time code to the caller's phone number.
+func (uc *User) RequestPhoneVerification(ctx context.Context, token model.Token) error {
+	const op = "usecase.User.RequestPhoneVerification"
+
+	log := uc.log.With(slog.String("op", op))
+
+	claims, err := uc.verifyClaims(log, token)
+	if err != nil {
+		return err
+	}
+
+	userID := claims.UserID
+
+	user, err := uc.repo.FindOne(ctx, model.UserFilter{ID: &userID})
+	if err != nil {
+		log.Warn("finding user", logger.Err(err), slog.String("id", userID))
+
+		return err
+	}
+
+	if user.PhoneNumber == "" {
+		err := model.ErrPhoneNotSet
+		log.Warn("requesting phone verification", logger.Err(err), slog.String("id", userID))
+
+		return err
+	}
+
+	if user.PhoneVerified {
+		err := model.ErrAlreadyVerified
+		log.Warn("requesting phone verification", logger.Err(err), slog.String("id", userID))
+
+		return err
+	}
+
+	now := time.Now().UTC()
+	purpose := model.VerificationPurposePhone
+
+	// A new code keeps the attempts made on the pending one, so asking for
+	// codes again does not allow more guesses. They are forgotten once the
+	// pending code expires.
+	attempts := 0
+
+	pending, err := uc.verificationRepo.FindOne(ctx, model.VerificationFilter{UserID: &userID, Purpose: &purpose})
+	if err != nil && !errors.Is(err, model.ErrNotFound) {
+		log.Error("finding verification", logger.Err(err), slog.String("id", userID))
+
+		return err
+	}
+	if err == nil && pending.ExpiresAt.After(now) {
+		attempts = pending.Attempts
+	}
+
+	if attempts >= verificationMaxAttempts {
+		err := model.ErrTooManyAttempts
+		log.Warn("requesting phone verification", logger.Err(err), slog.String("id", userID))
+
+		return err
+	}
+
+	code, err := newVerificationCode()
+	if err != nil {
+		log.Error("generating verification code", logger.Err(err))
+
+		return err
+	}
+
+	err = uc.verificationRepo.Save(ctx, model.Verification{
+		UserID:    userID,
+		Purpose:   purpose,
+		CodeHash:  hashSecret(code),
+		Payload:   user.PhoneNumber,
+		Attempts:  attempts,
+		ExpiresAt: now.Add(uc.cfg.PhoneVerificationTTL),
+		CreatedAt: now,
+	})
+	if err != nil {
+		log.Error("saving verification", logger.Err(err), slog.String("id", userID))
+
+		return err
+	}
+
+	err = uc.producer.PushPhoneVerification(ctx, user, code)
+	if err != nil {
+		log.Error("pushing event", logger.Err(err), slog.String("id", userID))
+
+		return err
+	}
+
+	return nil
+}
+
+// VerifyPhone checks the code sent by RequestPhoneVerification and marks the
+// caller's phone number as verified.
+func (uc *User) VerifyPhone(ctx context.Context, token model.Token, code string) (model.User, error) {
+	const op = "usecase.User.VerifyPhone"
+
+	log := uc.log.With(slog.String("op", op))
+
+	claims, err := uc.verifyClaims(log, token)
+	if err != nil {
+		return model.User{}, err
+	}
+
+	userID := claims.UserID
+
+	purpose := model.VerificationPurposePhone
+
+	// Every attempt is counted before the code is compared.
+	verification, err := uc.verificationRepo.ClaimAttempt(ctx, userID, purpose, verificationMaxAttempts)
+	if err != nil {
+		log.Warn("claiming verification attempt", logger.Err(err), slog.String("id", userID))
+
+		return model.User{}, err
+	}
+
+	err = checkVerification(verification, code, time.Now().UTC())
+	if err != nil {
+		log.Warn("checking verification code", logger.Err(err), slog.String("id", userID))
+
+		return model.User{}, err
+	}
+
+	// The code only verifies the number it was sent to.
+	phoneVerified := true
+
+	verifiedUser, err := uc.updateUser(
+		ctx,
+		uc.repo,
+		model.UserFilter{ID: &userID, PhoneNumber: &verification.Payload},
+		model.User{},
+		model.UserUpdateData{
+			PhoneVerified: &phoneVerified,
+			UpdatedAt:     time.Now().UTC(),
+		},
+	)
+	if err != nil {
+		log.Warn("verifying phone", logger.Err(err), slog.String("id", userID))
+
+		return model.User{}, err
+	}
+
+	err = uc.verificationRepo.DeleteOne(ctx, userID, purpose)
+	if err != nil {
+		log.Error("deleting verification", logger.Err(err), slog.String("id", userID))
+	}
+
+	return verifiedUser, nil
+}
@@ -0,0 +1,97 @@
+package usecase
+
+import (
+	"context"
+	"github.com/sorawaslocked/ap2final_base/pkg/logger"
+	"github.com/sorawaslocked/ap2final_user_service/internal/model"
+	"github.com/sorawaslocked/ap2final_user_service/internal/normalize"
+	"log/slog"
+	"time"
+)
+
+// GetPreferences returns the user; its Locale and Preferences hold the settings.
+func (uc *User) GetPreferences(ctx context.Context, token model.Token, id string) (model.User, error) {
+	const op = "usecase.User.GetPreferences"
+
+	log := uc.log.With(slog.String("op", op))
+
+	claims, err := uc.requireSelfOrAdmin(log, token, id)
+	if err != nil {
+		return model.User{}, err
+	}
+
+	user, err := uc.usersFor(claims).FindOne(ctx, model.UserFilter{ID: &id})
+	if err != nil {
+		log.Warn("finding user", logger.Err(err), slog.String("id", id))
+
+		return model.User{}, err
+	}
+
+	return user, nil
+}
+
+// UpdatePreferences changes the given preferences. An empty locale, timezone
+// or theme resets it to the default.
+func (uc *User) UpdatePreferences(
+	ctx context.Context,
+	token model.Token,
+	id string,
+	preferences model.UserPreferencesUpdate,
+) (model.User, error) {
+	const op = "usecase.User.UpdatePreferences"
+
+	log := uc.log.With(slog.String("op", op))
+
+	claims, err := uc.requireSelfOrAdmin(log, token, id)
+	if err != nil {
+		return model.User{}, err
+	}
+
+	preferences, err = normalizePreferences(preferences)
+	if err != nil {
+		log.Warn("validating preferences", logger.Err(err), slog.String("id", id))
+
+		return model.User{}, err
+	}
+
+	user, err := uc.updateUser(ctx, uc.usersFor(claims), model.UserFilter{ID: &id}, model.User{}, model.UserUpdateData{
+		Locale:         preferences.Locale,
+		Timezone:       preferences.Timezone,
+		Theme:          preferences.Theme,
+		MarketingOptIn: preferences.MarketingOptIn,
+		UpdatedAt:      time.Now().UTC(),
+	})
+	if err != nil {
+		log.Warn("updating preferences", logger.Err(err), slog.String("id", id))
+
+		return model.User{}, err
+	}
+
+	return user, nil
+}
+
+func normalizePreferences(preferences model.UserPreferencesUpdate) (model.UserPreferencesUpdate, error) {
+	verr := &model.ValidationError{}
+
+	if preferences.Locale != nil && *preferences.Locale != "" {
+		locale, _, err := normalize.Locale(*preferences.Locale)
+		if err != nil {
+			verr.Add("locale", err.Error())
+		}
+		preferences.Locale = &locale
+	}
+
+	if preferences.Timezone != nil && *preferences.Timezone != "" {
+		timezone, err := normalize.Timezone(*preferences.Timezone)
+		if err != nil {
+			verr.Add("timezone", err.Error())
+		}
+		preferences.Timezone = &timezone
+	}
+
+	if preferences.Theme != nil && *preferences.Theme != "" && !model.IsValidTheme(*preferences.Theme) {
+		verr.Add("theme", "theme must be one of light, dark or system")
+	}
+
+	return preferences, verr.Err()
+}
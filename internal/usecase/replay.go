@@ -0,0 +1,307 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"github.com/sorawaslocked/ap2final_base/pkg/logger"
+	"github.com/sorawaslocked/ap2final_base/pkg/security"
+	"github.com/sorawaslocked/ap2final_user_service/internal/model"
+	"log/slog"
+	"strconv"
+	"time"
+)
+
+const (
+	replayDefaultBatchSize = 500
+	replayMaxBatchSize     = 5000
+	replayDefaultRate      = 200
+	replayMaxRate          = 10000
+)
+
+// Replay re-announces stored users so a downstream service that lost its
+// data can rebuild its projection. Soft-deleted users are skipped. Events
+// published after the last saved checkpoint are sent again on resume, so
+// consumers must treat them as upserts.
+type Replay struct {
+	authenticator
+	auditor
+	log         *slog.Logger
+	repo        UserRepository
+	checkpoints ReplayCheckpointRepository
+	producer    ReplayEventStorage
+}
+
+// NewReplay returns the replay. jwtProvider is only needed by ReplayUsers and
+// may be nil for callers that use Run directly, such as the replay command.
+func NewReplay(
+	log *slog.Logger,
+	repo UserRepository,
+	checkpoints ReplayCheckpointRepository,
+	auditRepo AuditRepository,
+	producer ReplayEventStorage,
+	jwtProvider *security.JWTProvider,
+) *Replay {
+	return &Replay{
+		authenticator: authenticator{jwtProvider: jwtProvider},
+		auditor:       auditor{auditRepo: auditRepo},
+		log:           log,
+		repo:          repo,
+		checkpoints:   checkpoints,
+		producer:      producer,
+	}
+}
+
+// Run publishes an event for every matching user in id order. progress, if
+// set, is called after every batch; an error from it stops the run.
+func (r *Replay) Run(
+	ctx context.Context,
+	opts model.ReplayOptions,
+	progress func(model.ReplayProgress) error,
+) (model.ReplayProgress, error) {
+	const op = "usecase.Replay.Run"
+
+	log := r.log.With(
+		slog.String("op", op),
+		slog.String("checkpoint", opts.Checkpoint),
+		slog.Bool("dryRun", opts.DryRun),
+	)
+
+	opts, err := normalizeReplayOptions(opts)
+	if err != nil {
+		log.Warn("validating replay", logger.Err(err))
+
+		return model.ReplayProgress{}, err
+	}
+
+	checkpoint, err := r.loadCheckpoint(ctx, log, opts)
+	if err != nil {
+		return model.ReplayProgress{}, err
+	}
+
+	state := model.ReplayProgress{
+		Checkpoint: opts.Checkpoint,
+		LastUserID: checkpoint.LastUserID,
+		Replayed:   checkpoint.Replayed,
+		Done:       !checkpoint.CompletedAt.IsZero(),
+		DryRun:     opts.DryRun,
+	}
+
+	if state.Done {
+		log.Info("replay already completed", slog.Int64("replayed", state.Replayed))
+
+		return state, nil
+	}
+
+	var filter model.UserFilter
+	if opts.Role != "" {
+		filter.Role = &opts.Role
+	}
+	if !opts.CreatedAfter.IsZero() {
+		filter.CreatedAfter = &opts.CreatedAfter
+	}
+
+	ticker := time.NewTicker(time.Second / time.Duration(opts.RatePerSecond))
+	defer ticker.Stop()
+
+	for !state.Done {
+		if state.LastUserID != "" {
+			lastUserID := state.LastUserID
+			filter.IDAfter = &lastUserID
+		}
+
+		users, err := r.repo.List(ctx, filter, opts.BatchSize, 0)
+		if err != nil {
+			log.Error("listing users", logger.Err(err), slog.String("lastUserID", state.LastUserID))
+
+			return state, err
+		}
+
+		for _, user := range users {
+			if !opts.DryRun {
+				select {
+				case <-ctx.Done():
+					return state, ctx.Err()
+				case <-ticker.C:
+				}
+
+				if err = r.push(ctx, opts.Event, user); err != nil {
+					log.Error("pushing event", logger.Err(err), slog.String("userID", user.ID))
+
+					return state, err
+				}
+			}
+
+			state.LastUserID = user.ID
+			state.Replayed++
+		}
+
+		state.Done = int64(len(users)) < opts.BatchSize
+
+		if !opts.DryRun && opts.Checkpoint != "" {
+			now := time.Now().UTC()
+
+			checkpoint.LastUserID = state.LastUserID
+			checkpoint.Replayed = state.Replayed
+			checkpoint.UpdatedAt = now
+			if state.Done {
+				checkpoint.CompletedAt = now
+			}
+
+			if err = r.checkpoints.Save(ctx, checkpoint); err != nil {
+				log.Error("saving checkpoint", logger.Err(err), slog.String("lastUserID", state.LastUserID))
+
+				return state, err
+			}
+		}
+
+		if progress != nil {
+			if err = progress(state); err != nil {
+				return state, err
+			}
+		}
+	}
+
+	log.Info("replay completed", slog.Int64("replayed", state.Replayed))
+
+	return state, nil
+}
+
+func (r *Replay) push(ctx context.Context, event string, user model.User) error {
+	if event == model.ReplayEventRegister {
+		return r.producer.PushRegister(ctx, user)
+	}
+
+	return r.producer.PushSnapshot(ctx, user)
+}
+
+// loadCheckpoint returns the saved position of a named run, or a new one.
+// A run cannot be resumed with other filters than it started with.
+func (r *Replay) loadCheckpoint(
+	ctx context.Context,
+	log *slog.Logger,
+	opts model.ReplayOptions,
+) (model.ReplayCheckpoint, error) {
+	if opts.Checkpoint == "" {
+		return model.ReplayCheckpoint{}, nil
+	}
+
+	checkpoint, err := r.checkpoints.FindOne(ctx, opts.Checkpoint)
+	if errors.Is(err, model.ErrNotFound) {
+		return model.ReplayCheckpoint{
+			ID:           opts.Checkpoint,
+			Event:        opts.Event,
+			CreatedAfter: opts.CreatedAfter,
+			Role:         opts.Role,
+			StartedAt:    time.Now().UTC(),
+		}, nil
+	}
+	if err != nil {
+		log.Error("finding checkpoint", logger.Err(err))
+
+		return model.ReplayCheckpoint{}, err
+	}
+
+	if checkpoint.Event != opts.Event ||
+		checkpoint.Role != opts.Role ||
+		!checkpoint.CreatedAfter.Equal(opts.CreatedAfter) {
+		verr := &model.ValidationError{}
+		verr.Add("checkpoint", "checkpoint was started with other filters")
+
+		err = verr.Err()
+		log.Warn("resuming checkpoint", logger.Err(err))
+
+		return model.ReplayCheckpoint{}, err
+	}
+
+	log.Info(
+		"resuming replay",
+		slog.String("lastUserID", checkpoint.LastUserID),
+		slog.Int64("replayed", checkpoint.Replayed),
+	)
+
+	return checkpoint, nil
+}
+
+func normalizeReplayOptions(opts model.ReplayOptions) (model.ReplayOptions, error) {
+	verr := &model.ValidationError{}
+
+	switch opts.Event {
+	case "":
+		opts.Event = model.ReplayEventSnapshot
+	case model.ReplayEventSnapshot, model.ReplayEventRegister:
+	default:
+		verr.Add("event", "event must be snapshot or register")
+	}
+
+	if opts.Role != "" && opts.Role != "user" && opts.Role != "admin" {
+		verr.Add("role", "role must be user or admin")
+	}
+
+	if opts.BatchSize < 0 {
+		verr.Add("batch_size", "batch size must not be negative")
+	}
+
+	if opts.RatePerSecond < 0 {
+		verr.Add("rate", "rate must not be negative")
+	}
+
+	if err := verr.Err(); err != nil {
+		return model.ReplayOptions{}, err
+	}
+
+	if opts.BatchSize == 0 {
+		opts.BatchSize = replayDefaultBatchSize
+	}
+	if opts.BatchSize > replayMaxBatchSize {
+		opts.BatchSize = replayMaxBatchSize
+	}
+
+	if opts.RatePerSecond == 0 {
+		opts.RatePerSecond = replayDefaultRate
+	}
+	if opts.RatePerSecond > replayMaxRate {
+		opts.RatePerSecond = replayMaxRate
+	}
+
+	// Mongo keeps milliseconds, so a finer time would never match the
+	// checkpoint it was saved in.
+	opts.CreatedAfter = opts.CreatedAfter.UTC().Truncate(time.Millisecond)
+
+	return opts, nil
+}
+
+// ReplayUsers runs a replay for a platform admin. See Run.
+func (r *Replay) ReplayUsers(
+	ctx context.Context,
+	token model.Token,
+	opts model.ReplayOptions,
+	progress func(model.ReplayProgress) error,
+) (model.ReplayProgress, error) {
+	const op = "usecase.Replay.ReplayUsers"
+
+	log := r.log.With(slog.String("op", op))
+
+	claims, err := r.requirePlatformAdmin(log, token)
+	if err != nil {
+		return model.ReplayProgress{}, err
+	}
+
+	details := map[string]string{
+		"event":  opts.Event,
+		"role":   opts.Role,
+		"dryRun": strconv.FormatBool(opts.DryRun),
+	}
+	if !opts.CreatedAfter.IsZero() {
+		details["createdAfter"] = opts.CreatedAfter.Format(time.RFC3339)
+	}
+
+	r.audit(ctx, log, model.AuditEntry{
+		ActorID:   claims.UserID,
+		Action:    model.AuditActionReplayStarted,
+		TargetID:  opts.Checkpoint,
+		Details:   details,
+		CreatedAt: time.Now().UTC(),
+	})
+
+	return r.Run(ctx, opts, progress)
+}
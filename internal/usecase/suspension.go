@@ -0,0 +1,268 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"github.com/sorawaslocked/ap2final_base/pkg/logger"
+	"github.com/sorawaslocked/ap2final_user_service/internal/model"
+	"log/slog"
+	"time"
+)
+
+func (uc *User) SuspendUser(ctx context.Context, token model.Token, id string, suspension model.Suspension) (model.User, error) {
+	const op = "usecase.User.SuspendUser"
+
+	log := uc.log.With(slog.String("op", op))
+
+	claims, err := uc.requireAdmin(log, token)
+	if err != nil {
+		return model.User{}, err
+	}
+
+	now := time.Now().UTC()
+
+	if !model.IsValidSuspensionReason(suspension.ReasonCode) || suspension.IsExpired(now) {
+		err := model.ErrInvalidSuspension
+		log.Warn(
+			"validating suspension",
+			logger.Err(err),
+			slog.String("reasonCode", suspension.ReasonCode),
+		)
+
+		return model.User{}, err
+	}
+
+	user, err := uc.usersFor(claims).FindOne(ctx, model.UserFilter{ID: &id})
+	if err != nil {
+		log.Warn(
+			"finding user",
+			logger.Err(err),
+			slog.String("id", id),
+		)
+
+		return model.User{}, err
+	}
+
+	suspension.SuspendedBy = claims.UserID
+	suspension.SuspendedAt = now
+
+	update := model.UserUpdateData{
+		Suspension: &suspension,
+		UpdatedAt:  now,
+	}
+
+	// A new suspension supersedes the active one, which moves to the history.
+	if user.Suspension != nil {
+		previous := *user.Suspension
+		previous.LiftedBy = claims.UserID
+		previous.LiftedAt = now
+		update.ArchivedSuspension = &previous
+	}
+
+	// The version read above keeps a suspension set meanwhile from being
+	// replaced without reaching the history.
+	suspendedUser, err := uc.updateUser(
+		ctx,
+		uc.usersFor(claims),
+		model.UserFilter{ID: &id, Version: &user.Version},
+		user,
+		update,
+	)
+	if err != nil {
+		log.Warn(
+			"suspending user",
+			logger.Err(err),
+			slog.String("id", id),
+		)
+
+		return model.User{}, err
+	}
+
+	err = uc.revokeSessions(ctx, id, model.SessionRevokeReasonSuspended)
+	if err != nil {
+		log.Warn(
+			"revoking sessions",
+			logger.Err(err),
+			slog.String("id", id),
+		)
+
+		return model.User{}, err
+	}
+
+	details := map[string]string{"reasonCode": suspension.ReasonCode}
+	if !suspension.Until.IsZero() {
+		details["until"] = suspension.Until.Format(time.RFC3339)
+	}
+
+	uc.audit(ctx, log, model.AuditEntry{
+		ActorID:   claims.UserID,
+		Action:    model.AuditActionSuspended,
+		TargetID:  id,
+		Details:   details,
+		CreatedAt: now,
+	})
+
+	return suspendedUser, nil
+}
+
+func (uc *User) UnsuspendUser(ctx context.Context, token model.Token, id string) (model.User, error) {
+	const op = "usecase.User.UnsuspendUser"
+
+	log := uc.log.With(slog.String("op", op))
+
+	claims, err := uc.requireAdmin(log, token)
+	if err != nil {
+		return model.User{}, err
+	}
+
+	user, err := uc.usersFor(claims).FindOne(ctx, model.UserFilter{ID: &id})
+	if err != nil {
+		log.Warn(
+			"finding user",
+			logger.Err(err),
+			slog.String("id", id),
+		)
+
+		return model.User{}, err
+	}
+
+	if user.Suspension == nil {
+		err := model.ErrNotSuspended
+		log.Warn(
+			"lifting suspension",
+			logger.Err(err),
+			slog.String("id", id),
+		)
+
+		return model.User{}, err
+	}
+
+	unsuspendedUser, err := uc.liftSuspension(ctx, user, claims.UserID)
+	if err != nil {
+		log.Warn(
+			"lifting suspension",
+			logger.Err(err),
+			slog.String("id", id),
+		)
+
+		return model.User{}, err
+	}
+
+	uc.audit(ctx, log, model.AuditEntry{
+		ActorID:   claims.UserID,
+		Action:    model.AuditActionUnsuspended,
+		TargetID:  id,
+		CreatedAt: time.Now().UTC(),
+	})
+
+	return unsuspendedUser, nil
+}
+
+func (uc *User) GetSuspensions(ctx context.Context, token model.Token, id string) (model.User, error) {
+	const op = "usecase.User.GetSuspensions"
+
+	log := uc.log.With(slog.String("op", op))
+
+	claims, err := uc.requireAdmin(log, token)
+	if err != nil {
+		return model.User{}, err
+	}
+
+	user, err := uc.usersFor(claims).FindOne(ctx, model.UserFilter{ID: &id, IncludeDeleted: true})
+	if err != nil {
+		log.Warn(
+			"finding user",
+			logger.Err(err),
+			slog.String("id", id),
+		)
+
+		return model.User{}, err
+	}
+
+	return user, nil
+}
+
+// LiftExpiredSuspensions lifts every suspension whose period ended before now
+// and returns how many were lifted.
+func (uc *User) LiftExpiredSuspensions(ctx context.Context, now time.Time) (int, error) {
+	const op = "usecase.User.LiftExpiredSuspensions"
+
+	log := uc.log.With(slog.String("op", op))
+
+	users, err := uc.repo.Find(ctx, model.UserFilter{SuspendedUntilBefore: &now})
+	if err != nil {
+		log.Error("finding expired suspensions", logger.Err(err))
+
+		return 0, err
+	}
+
+	lifted := 0
+
+	for _, user := range users {
+		_, err = uc.liftSuspension(ctx, user, model.SuspensionLiftedBySystem)
+		if err != nil {
+			log.Error("lifting suspension", logger.Err(err), slog.String("id", user.ID))
+
+			continue
+		}
+
+		lifted++
+	}
+
+	return lifted, nil
+}
+
+// checkSuspension rejects suspended users, lifting the suspension instead if
+// its period has already elapsed.
+func (uc *User) checkSuspension(ctx context.Context, log *slog.Logger, user model.User) error {
+	if user.Suspension == nil {
+		return nil
+	}
+
+	if user.Suspension.IsExpired(time.Now().UTC()) {
+		_, err := uc.liftSuspension(ctx, user, model.SuspensionLiftedBySystem)
+		if err != nil {
+			log.Warn("lifting expired suspension", logger.Err(err), slog.String("id", user.ID))
+
+			return err
+		}
+
+		return nil
+	}
+
+	err := model.ErrAccountSuspended
+	if !user.Suspension.Until.IsZero() {
+		err = fmt.Errorf("%w until %s", err, user.Suspension.Until.Format(time.RFC3339))
+	}
+
+	log.Warn(
+		"checking suspension",
+		logger.Err(err),
+		slog.String("id", user.ID),
+		slog.String("reasonCode", user.Suspension.ReasonCode),
+	)
+
+	return err
+}
+
+// liftSuspension archives the suspension user was read with. It fails with a
+// version conflict if the user changed since, so a suspension set in the
+// meantime is never lifted unseen.
+func (uc *User) liftSuspension(ctx context.Context, user model.User, liftedBy string) (model.User, error) {
+	now := time.Now().UTC()
+
+	archived := *user.Suspension
+	archived.LiftedBy = liftedBy
+	archived.LiftedAt = now
+
+	return uc.updateUser(
+		ctx,
+		uc.repo,
+		model.UserFilter{ID: &user.ID, Version: &user.Version},
+		user,
+		model.UserUpdateData{
+			ArchivedSuspension: &archived,
+			UpdatedAt:          now,
+		},
+	)
+}
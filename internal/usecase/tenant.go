@@ -0,0 +1,68 @@
+package usecase
+
+import (
+	"context"
+	"github.com/sorawaslocked/ap2final_user_service/internal/model"
+)
+
+// tenantUsers is a UserRepository confined to one organisation. Every filter,
+// search and insert is pinned to its tenant, so callers cannot reach users of
+// another organisation by forgetting a condition.
+type tenantUsers struct {
+	repo     UserRepository
+	tenantID string
+}
+
+// usersFor returns the user repository as seen by the caller. Members of an
+// organisation only see its users; callers outside any organisation see all.
+func (uc access) usersFor(claims model.Claims) UserRepository {
+	if claims.TenantID == "" {
+		return uc.repo
+	}
+
+	return tenantUsers{repo: uc.repo, tenantID: claims.TenantID}
+}
+
+func (t tenantUsers) InsertOne(ctx context.Context, user model.User) (model.User, error) {
+	user.TenantID = t.tenantID
+
+	return t.repo.InsertOne(ctx, user)
+}
+
+func (t tenantUsers) FindOne(ctx context.Context, filter model.UserFilter) (model.User, error) {
+	filter.TenantID = &t.tenantID
+
+	return t.repo.FindOne(ctx, filter)
+}
+
+func (t tenantUsers) Find(ctx context.Context, filter model.UserFilter) ([]model.User, error) {
+	filter.TenantID = &t.tenantID
+
+	return t.repo.Find(ctx, filter)
+}
+
+func (t tenantUsers) List(ctx context.Context, filter model.UserFilter, limit, offset int64) ([]model.User, error) {
+	filter.TenantID = &t.tenantID
+
+	return t.repo.List(ctx, filter, limit, offset)
+}
+
+func (t tenantUsers) UpdateOne(ctx context.Context, filter model.UserFilter, update model.UserUpdateData) (model.User, error) {
+	filter.TenantID = &t.tenantID
+	// Moving users between organisations goes through the unscoped repository.
+	update.TenantID = nil
+
+	return t.repo.UpdateOne(ctx, filter, update)
+}
+
+func (t tenantUsers) DeleteOne(ctx context.Context, filter model.UserFilter) (model.User, error) {
+	filter.TenantID = &t.tenantID
+
+	return t.repo.DeleteOne(ctx, filter)
+}
+
+func (t tenantUsers) Search(ctx context.Context, search model.UserSearch) ([]model.UserSearchResult, error) {
+	search.TenantID = &t.tenantID
+
+	return t.repo.Search(ctx, search)
+}
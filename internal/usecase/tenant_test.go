@@ -0,0 +1,118 @@
+package usecase
+
+import (
+	"context"
+	"github.com/sorawaslocked/ap2final_user_service/internal/model"
+	"testing"
+)
+
+// tenantRecorder remembers the tenant each call was scoped to.
+type tenantRecorder struct {
+	tenantID *string
+	// updateTenantID is the tenant an update would move the user to.
+	updateTenantID *string
+}
+
+func (r *tenantRecorder) InsertOne(_ context.Context, user model.User) (model.User, error) {
+	r.tenantID = &user.TenantID
+
+	return user, nil
+}
+
+func (r *tenantRecorder) FindOne(_ context.Context, filter model.UserFilter) (model.User, error) {
+	r.tenantID = filter.TenantID
+
+	return model.User{}, nil
+}
+
+func (r *tenantRecorder) Find(_ context.Context, filter model.UserFilter) ([]model.User, error) {
+	r.tenantID = filter.TenantID
+
+	return nil, nil
+}
+
+func (r *tenantRecorder) List(_ context.Context, filter model.UserFilter, _, _ int64) ([]model.User, error) {
+	r.tenantID = filter.TenantID
+
+	return nil, nil
+}
+
+func (r *tenantRecorder) UpdateOne(
+	_ context.Context,
+	filter model.UserFilter,
+	update model.UserUpdateData,
+) (model.User, error) {
+	r.tenantID = filter.TenantID
+	r.updateTenantID = update.TenantID
+
+	return model.User{}, nil
+}
+
+func (r *tenantRecorder) DeleteOne(_ context.Context, filter model.UserFilter) (model.User, error) {
+	r.tenantID = filter.TenantID
+
+	return model.User{}, nil
+}
+
+func (r *tenantRecorder) Search(_ context.Context, search model.UserSearch) ([]model.UserSearchResult, error) {
+	r.tenantID = search.TenantID
+
+	return nil, nil
+}
+
+func TestUsersForWithoutTenant(t *testing.T) {
+	recorder := &tenantRecorder{}
+	uc := access{repo: recorder}
+
+	if got := uc.usersFor(model.Claims{UserID: "admin-1", Role: "admin"}); got != UserRepository(recorder) {
+		t.Errorf("usersFor() = %T, want the unscoped repository", got)
+	}
+}
+
+func TestUsersForPinsTenant(t *testing.T) {
+	other := "org-2"
+	ctx := context.Background()
+
+	tests := []struct {
+		name string
+		call func(users UserRepository)
+	}{
+		{"InsertOne", func(users UserRepository) {
+			_, _ = users.InsertOne(ctx, model.User{TenantID: other})
+		}},
+		{"FindOne", func(users UserRepository) {
+			_, _ = users.FindOne(ctx, model.UserFilter{TenantID: &other})
+		}},
+		{"Find", func(users UserRepository) {
+			_, _ = users.Find(ctx, model.UserFilter{TenantID: &other})
+		}},
+		{"List", func(users UserRepository) {
+			_, _ = users.List(ctx, model.UserFilter{TenantID: &other}, 10, 0)
+		}},
+		{"UpdateOne", func(users UserRepository) {
+			_, _ = users.UpdateOne(ctx, model.UserFilter{TenantID: &other}, model.UserUpdateData{TenantID: &other})
+		}},
+		{"DeleteOne", func(users UserRepository) {
+			_, _ = users.DeleteOne(ctx, model.UserFilter{TenantID: &other})
+		}},
+		{"Search", func(users UserRepository) {
+			_, _ = users.Search(ctx, model.UserSearch{Query: "ada", TenantID: &other})
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := &tenantRecorder{}
+			uc := access{repo: recorder}
+
+			tt.call(uc.usersFor(model.Claims{UserID: "manager-1", Role: "user", TenantID: "org-1"}))
+
+			if recorder.tenantID == nil || *recorder.tenantID != "org-1" {
+				t.Errorf("%s scoped to %v, want org-1", tt.name, recorder.tenantID)
+			}
+			if recorder.updateTenantID != nil {
+				t.Errorf("%s moves the user to %q, want no move", tt.name, *recorder.updateTenantID)
+			}
+		})
+	}
+}
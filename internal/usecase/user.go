@@ -2,34 +2,82 @@ package usecase
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"github.com/sorawaslocked/ap2final_base/pkg/logger"
 	"github.com/sorawaslocked/ap2final_base/pkg/security"
 	"github.com/sorawaslocked/ap2final_user_service/internal/model"
+	"github.com/sorawaslocked/ap2final_user_service/internal/normalize"
 	"log/slog"
+	"maps"
+	"slices"
+	"strings"
 	"time"
+	"unicode/utf8"
 )
 
+type UserConfig struct {
+	// DefaultPhoneRegion is used for national phone numbers when the locale
+	// does not name a region.
+	DefaultPhoneRegion   string
+	PhoneVerificationTTL time.Duration
+	EmailChangeTTL       time.Duration
+	EmailRevertTTL       time.Duration
+	// Attributes lists the custom attributes users may carry.
+	Attributes model.AttributeRegistry
+	// AvatarBaseURL is the prefix of the avatar URLs handed to clients.
+	AvatarBaseURL       string
+	AvatarMaxUploadSize int64
+	AvatarMaxDimension  int
+	// EmailUniquePerTenant allows the same email in different organisations.
+	EmailUniquePerTenant bool
+	// InviteOnly disables Register so users can only join by invitation.
+	InviteOnly bool
+	// GroupsInTokens adds group ids to access tokens, at most GroupTokenLimit.
+	GroupsInTokens  bool
+	GroupTokenLimit int
+}
+
 type User struct {
-	log         *slog.Logger
-	repo        UserRepository
-	tokenRepo   TokenRepository
-	producer    UserEventStorage
-	jwtProvider *security.JWTProvider
+	access
+	auditor
+	cfg              UserConfig
+	log              *slog.Logger
+	tokenRepo        TokenRepository
+	verificationRepo VerificationRepository
+	avatarRepo       AvatarRepository
+	membershipRepo   GroupMembershipRepository
+	invitationRepo   InvitationEraser
+	tx               Transactor
+	producer         UserEventStorage
 }
 
 func NewUser(
 	log *slog.Logger,
 	repo UserRepository,
 	tokenRepo TokenRepository,
+	auditRepo AuditRepository,
+	verificationRepo VerificationRepository,
+	avatarRepo AvatarRepository,
+	membershipRepo GroupMembershipRepository,
+	invitationRepo InvitationEraser,
+	tx Transactor,
 	producer UserEventStorage,
 	jwtProvider *security.JWTProvider,
+	cfg UserConfig,
 ) *User {
 	return &User{
-		log:         log,
-		repo:        repo,
-		tokenRepo:   tokenRepo,
-		producer:    producer,
-		jwtProvider: jwtProvider,
+		access:           access{authenticator: authenticator{jwtProvider: jwtProvider}, repo: repo},
+		auditor:          auditor{auditRepo: auditRepo},
+		cfg:              cfg,
+		log:              log,
+		tokenRepo:        tokenRepo,
+		verificationRepo: verificationRepo,
+		avatarRepo:       avatarRepo,
+		membershipRepo:   membershipRepo,
+		invitationRepo:   invitationRepo,
+		tx:               tx,
+		producer:         producer,
 	}
 }
 
@@ -38,6 +86,20 @@ func (uc *User) Register(ctx context.Context, user model.User) (model.User, erro
 
 	log := uc.log.With(slog.String("op", op))
 
+	if uc.cfg.InviteOnly {
+		err := model.ErrRegistrationClosed
+		log.Warn("registering user", logger.Err(err))
+
+		return model.User{}, err
+	}
+
+	user, err := uc.normalizeRegistration(user)
+	if err != nil {
+		log.Warn("validating registration", logger.Err(err))
+
+		return model.User{}, err
+	}
+
 	user.CreatedAt = time.Now().UTC()
 	user.UpdatedAt = time.Now().UTC()
 	user.Role = "user"
@@ -51,21 +113,40 @@ func (uc *User) Register(ctx context.Context, user model.User) (model.User, erro
 
 	user.PasswordHash = hashedPassword
 
-	createdUser, err := uc.repo.InsertOne(ctx, user)
+	createdUser, err := uc.insertUser(ctx, user)
 	if err != nil {
-		log.Error("creating user", logger.Err(err))
+		if errors.Is(err, model.ErrAlreadyExists) {
+			log.Warn("creating user", logger.Err(err))
+		} else {
+			log.Error("creating user", logger.Err(err))
+		}
 
 		return model.User{}, err
 	}
 
-	err = uc.producer.Push(ctx, createdUser)
-	if err != nil {
-		log.Error("pushing event", logger.Err(err))
+	return createdUser, nil
+}
+
+// insertUser creates the user and queues the registration event in the same
+// transaction, so neither exists without the other.
+func (uc *User) insertUser(ctx context.Context, user model.User) (model.User, error) {
+	var createdUser model.User
+
+	err := uc.tx.WithTransaction(ctx, func(ctx context.Context) error {
+		var err error
 
+		createdUser, err = uc.repo.InsertOne(ctx, user)
+		if err != nil {
+			return err
+		}
+
+		return uc.producer.Push(ctx, createdUser)
+	})
+	if err != nil {
 		return model.User{}, err
 	}
 
-	return createdUser, err
+	return createdUser, nil
 }
 
 func (uc *User) Login(ctx context.Context, user model.User) (model.Token, error) {
@@ -73,7 +154,31 @@ func (uc *User) Login(ctx context.Context, user model.User) (model.Token, error)
 
 	log := uc.log.With(slog.String("op", op))
 
-	userFromDb, err := uc.repo.FindOne(ctx, model.UserFilter{Email: &user.Email})
+	filter := model.UserFilter{}
+
+	if user.Email != "" {
+		if email, err := normalize.Email(user.Email); err == nil {
+			user.Email = email
+		}
+		filter.Email = &user.Email
+	} else {
+		if phoneNumber, err := normalize.Phone(user.PhoneNumber, uc.cfg.DefaultPhoneRegion); err == nil {
+			user.PhoneNumber = phoneNumber
+		}
+
+		// Only a verified phone number can be used to sign in.
+		phoneVerified := true
+		filter.PhoneNumber = &user.PhoneNumber
+		filter.PhoneVerified = &phoneVerified
+	}
+
+	// With per-organisation emails the same address may exist in several
+	// organisations, so the caller has to name theirs.
+	if uc.cfg.EmailUniquePerTenant || user.TenantID != "" {
+		filter.TenantID = &user.TenantID
+	}
+
+	userFromDb, err := uc.repo.FindOne(ctx, filter)
 	if err != nil {
 		log.Warn("finding user", logger.Err(err))
 
@@ -88,7 +193,11 @@ func (uc *User) Login(ctx context.Context, user model.User) (model.Token, error)
 		return model.Token{}, err
 	}
 
-	accessToken, err := uc.jwtProvider.GenerateAccessToken(userFromDb.ID, userFromDb.Role)
+	if err = uc.checkSuspension(ctx, log, userFromDb); err != nil {
+		return model.Token{}, err
+	}
+
+	accessToken, err := uc.accessToken(ctx, log, userFromDb)
 	if err != nil {
 		log.Warn("generating access token", logger.Err(err))
 
@@ -109,7 +218,13 @@ func (uc *User) Login(ctx context.Context, user model.User) (model.Token, error)
 		CreatedAt:    time.Now().UTC(),
 	}
 
-	err = uc.tokenRepo.InsertOne(ctx, session)
+	err = uc.tx.WithTransaction(ctx, func(ctx context.Context) error {
+		if err := uc.tokenRepo.InsertOne(ctx, session); err != nil {
+			return err
+		}
+
+		return uc.producer.PushLoggedIn(ctx, userFromDb, session)
+	})
 	if err != nil {
 		log.Warn("inserting new token session", logger.Err(err))
 
@@ -149,7 +264,11 @@ func (uc *User) RefreshToken(ctx context.Context, refreshToken string) (model.To
 		return model.Token{}, err
 	}
 
-	accessToken, err := uc.jwtProvider.GenerateAccessToken(user.ID, user.Role)
+	if err = uc.checkSuspension(ctx, log, user); err != nil {
+		return model.Token{}, err
+	}
+
+	accessToken, err := uc.accessToken(ctx, log, user)
 	if err != nil {
 		log.Warn("generating access token", logger.Err(err))
 
@@ -195,48 +314,56 @@ func (uc *User) GetByID(ctx context.Context, token model.Token, id string) (mode
 
 	log := uc.log.With(slog.String("op", op))
 
-	claims, err := uc.jwtProvider.VerifyAndParseClaims(token.AccessToken)
+	claims, err := uc.requireSelfOrAdmin(log, token, id)
 	if err != nil {
-		err := model.ErrInvalidToken
-		log.Warn(
-			"verifying token and parsing claims",
-			logger.Err(err),
-			slog.String("accessToken", token.AccessToken),
-		)
-
 		return model.User{}, err
 	}
 
-	if claims.UserID == nil || claims.Role == nil {
-		err := model.ErrEmptyClaims
+	users := uc.usersFor(claims)
+
+	user, err := users.FindOne(ctx, model.UserFilter{ID: &id})
+	if err != nil {
 		log.Warn(
-			"checking claims",
+			"finding user",
 			logger.Err(err),
-			slog.String("accessToken", token.AccessToken),
+			slog.String("id", id),
 		)
 
 		return model.User{}, err
 	}
 
-	if *claims.UserID != id && *claims.Role != "admin" {
-		err := model.ErrUnauthorized
-		log.Warn(
-			"checking claims",
-			logger.Err(err),
-			slog.String("claimsUserID", *claims.UserID),
-			slog.String("claimsRole", *claims.Role),
-		)
+	return user, nil
+}
+
+// GetByEmail finds a user by email. With per-organisation emails the caller
+// has to name the organisation, as on login.
+func (uc *User) GetByEmail(ctx context.Context, token model.Token, email, tenantID string) (model.User, error) {
+	const op = "usecase.User.GetByEmail"
+
+	log := uc.log.With(slog.String("op", op))
 
+	claims, err := uc.requireAdmin(log, token)
+	if err != nil {
 		return model.User{}, err
 	}
 
-	user, err := uc.repo.FindOne(ctx, model.UserFilter{ID: &id})
+	email, err = normalize.Email(email)
 	if err != nil {
-		log.Warn(
-			"finding user",
-			logger.Err(err),
-			slog.String("id", id),
-		)
+		verr := &model.ValidationError{}
+		verr.Add("email", err.Error())
+		log.Warn("normalizing email", logger.Err(verr))
+
+		return model.User{}, verr
+	}
+
+	filter := model.UserFilter{Email: &email}
+	if uc.cfg.EmailUniquePerTenant || tenantID != "" {
+		filter.TenantID = &tenantID
+	}
+
+	user, err := uc.usersFor(claims).FindOne(ctx, filter)
+	if err != nil {
+		log.Warn("finding user", logger.Err(err), slog.String("email", email))
 
 		return model.User{}, err
 	}
@@ -257,43 +384,69 @@ func (uc *User) UpdateByID(
 
 	update.UpdatedAt = time.Now().UTC()
 
-	claims, err := uc.jwtProvider.VerifyAndParseClaims(token.AccessToken)
+	claims, err := uc.requireSelfOrAdmin(log, token, id)
 	if err != nil {
-		err := model.ErrInvalidToken
-		log.Warn(
-			"verifying token and parsing claims",
-			logger.Err(err),
-			slog.String("accessToken", token.AccessToken),
-		)
-
 		return model.User{}, err
 	}
 
-	if claims.UserID == nil || claims.Role == nil {
-		err := model.ErrEmptyClaims
-		log.Warn(
-			"checking claims",
-			logger.Err(err),
-			slog.String("accessToken", token.AccessToken),
-		)
+	users := uc.usersFor(claims)
+
+	// Users change their own email through RequestEmailChange.
+	if update.Email != nil && claims.Role != "admin" {
+		err := model.ErrEmailChangeRequiresVerification
+		log.Warn("updating email", logger.Err(err), slog.String("id", id))
 
 		return model.User{}, err
 	}
 
-	if *claims.UserID != id && *claims.Role != "admin" {
+	// Only admins grant roles and activate, deactivate or delete accounts.
+	if (update.Role != nil || update.IsActive != nil || update.IsDeleted != nil) && claims.Role != "admin" {
 		err := model.ErrUnauthorized
-		log.Warn(
-			"checking claims",
-			logger.Err(err),
-			slog.String("claimsUserID", *claims.UserID),
-			slog.String("claimsRole", *claims.Role),
-		)
+		log.Warn("updating privileged fields", logger.Err(err), slog.String("id", id))
 
 		return model.User{}, err
 	}
 
-	if credentialsUpdate.CurrentPassword != "" && credentialsUpdate.NewPassword != "" {
-		userFromDb, err := uc.repo.FindOne(ctx, model.UserFilter{ID: &id})
+	if update.Email != nil {
+		email, err := normalize.Email(*update.Email)
+		if err != nil {
+			verr := &model.ValidationError{}
+			verr.Add("email", err.Error())
+			log.Warn("normalizing email", logger.Err(verr))
+
+			return model.User{}, verr
+		}
+		update.Email = &email
+	}
+
+	if update.PhoneNumber != nil {
+		phoneNumber := *update.PhoneNumber
+		if phoneNumber != "" {
+			phoneNumber, err = normalize.Phone(phoneNumber, uc.cfg.DefaultPhoneRegion)
+			if err != nil {
+				verr := &model.ValidationError{}
+				verr.Add("phone_number", err.Error())
+				log.Warn("normalizing phone number", logger.Err(verr))
+
+				return model.User{}, verr
+			}
+		}
+
+		update.PhoneNumber = &phoneNumber
+	}
+
+	// A changed or removed number has to be verified again.
+	if update.PhoneNumber != nil || slices.Contains(update.Clear, model.UserFieldPhoneNumber) {
+		phoneVerified := false
+		update.PhoneVerified = &phoneVerified
+	}
+
+	changePassword := credentialsUpdate.CurrentPassword != "" && credentialsUpdate.NewPassword != ""
+
+	// The current user is needed to check the password and to report the old role.
+	var userFromDb model.User
+	if changePassword || update.Role != nil {
+		userFromDb, err = users.FindOne(ctx, model.UserFilter{ID: &id})
 		if err != nil {
 			log.Warn(
 				"finding user",
@@ -303,7 +456,9 @@ func (uc *User) UpdateByID(
 
 			return model.User{}, err
 		}
+	}
 
+	if changePassword {
 		err = security.CheckPassword(credentialsUpdate.CurrentPassword, userFromDb.PasswordHash)
 		if err != nil {
 			err := model.ErrPasswordsDoNotMatch
@@ -321,9 +476,11 @@ func (uc *User) UpdateByID(
 		update.PasswordHash = &hashedPassword
 	}
 
-	updatedUser, err := uc.repo.UpdateOne(
+	updatedUser, err := uc.updateUser(
 		ctx,
-		model.UserFilter{ID: &id},
+		users,
+		model.UserFilter{ID: &id, Version: update.ExpectedVersion},
+		userFromDb,
 		update,
 	)
 	if err != nil {
@@ -344,45 +501,342 @@ func (uc *User) DeleteByID(ctx context.Context, token model.Token, id string) (m
 
 	log := uc.log.With(slog.String("op", op))
 
-	claims, err := uc.jwtProvider.VerifyAndParseClaims(token.AccessToken)
+	claims, err := uc.requireSelfOrAdmin(log, token, id)
+	if err != nil {
+		return model.User{}, err
+	}
+
+	users := uc.usersFor(claims)
+
+	now := time.Now().UTC()
+	isDeleted := true
+
+	var deletedUser model.User
+
+	err = uc.tx.WithTransaction(ctx, func(ctx context.Context) error {
+		var err error
+
+		deletedUser, err = users.UpdateOne(
+			ctx,
+			model.UserFilter{ID: &id},
+			model.UserUpdateData{
+				UpdatedAt: now,
+				DeletedAt: &now,
+				IsDeleted: &isDeleted,
+			},
+		)
+		if err != nil {
+			return err
+		}
+
+		return uc.producer.PushDeleted(ctx, deletedUser)
+	})
 	if err != nil {
-		err := model.ErrInvalidToken
 		log.Warn(
-			"verifying token and parsing claims",
+			"deleting user",
 			logger.Err(err),
-			slog.String("accessToken", token.AccessToken),
+			slog.String("id", id),
 		)
 
 		return model.User{}, err
 	}
 
-	if claims.UserID == nil || claims.Role == nil {
-		err := model.ErrEmptyClaims
+	err = uc.revokeSessions(ctx, id, model.SessionRevokeReasonDeleted)
+	if err != nil {
 		log.Warn(
-			"checking claims",
+			"revoking sessions",
 			logger.Err(err),
-			slog.String("accessToken", token.AccessToken),
+			slog.String("id", id),
 		)
 
 		return model.User{}, err
 	}
 
-	if *claims.UserID != id && *claims.Role != "admin" {
-		err := model.ErrUnauthorized
+	return deletedUser, nil
+}
+
+func (uc *User) RestoreUser(ctx context.Context, token model.Token, id string) (model.User, error) {
+	const op = "usecase.User.RestoreUser"
+
+	log := uc.log.With(slog.String("op", op))
+
+	claims, err := uc.requireAdmin(log, token)
+	if err != nil {
+		return model.User{}, err
+	}
+
+	isDeleted := true
+	isNotDeleted := false
+
+	restoredUser, err := uc.updateUser(
+		ctx,
+		uc.usersFor(claims),
+		model.UserFilter{ID: &id, IsDeleted: &isDeleted},
+		model.User{},
+		model.UserUpdateData{
+			UpdatedAt: time.Now().UTC(),
+			DeletedAt: &time.Time{},
+			IsDeleted: &isNotDeleted,
+		},
+	)
+	if err != nil {
+		log.Warn(
+			"restoring user",
+			logger.Err(err),
+			slog.String("id", id),
+		)
+
+		return model.User{}, err
+	}
+
+	return restoredUser, nil
+}
+
+// PurgeDeleted permanently removes users that were soft-deleted before the
+// given time and returns how many were purged. It stops at the first user it
+// cannot purge so the next run retries them.
+func (uc *User) PurgeDeleted(ctx context.Context, before time.Time) (int, error) {
+	const op = "usecase.User.PurgeDeleted"
+
+	log := uc.log.With(slog.String("op", op))
+
+	isDeleted := true
+
+	users, err := uc.repo.Find(ctx, model.UserFilter{IsDeleted: &isDeleted, DeletedBefore: &before})
+	if err != nil {
+		log.Error("finding deleted users", logger.Err(err))
+
+		return 0, err
+	}
+
+	purged := 0
+
+	for _, user := range users {
+		// Avatars live outside the transaction. Removing them first is safe to
+		// repeat if the purge below fails and runs again.
+		err = uc.avatarRepo.DeleteByUserID(ctx, user.ID)
+		if err != nil {
+			log.Error("deleting avatar", logger.Err(err), slog.String("id", user.ID))
+
+			return purged, err
+		}
+
+		err = uc.tx.WithTransaction(ctx, func(ctx context.Context) error {
+			_, err := uc.repo.DeleteOne(ctx, model.UserFilter{ID: &user.ID, IsDeleted: &isDeleted})
+			if err != nil {
+				return err
+			}
+
+			err = uc.revokeSessions(ctx, user.ID, model.SessionRevokeReasonPurged)
+			if err != nil {
+				return err
+			}
+
+			err = uc.membershipRepo.DeleteMembershipsByUserID(ctx, user.ID)
+			if err != nil {
+				return err
+			}
+
+			return uc.producer.PushPurged(ctx, user)
+		})
+		if err != nil {
+			log.Error("purging user", logger.Err(err), slog.String("id", user.ID))
+
+			return purged, err
+		}
+
+		purged++
+	}
+
+	return purged, nil
+}
+
+const (
+	searchQueryMaxLength = 64
+	searchDefaultLimit   = 20
+	searchMaxLimit       = 100
+)
+
+func (uc *User) SearchUsers(ctx context.Context, token model.Token, search model.UserSearch) ([]model.UserSearchResult, error) {
+	const op = "usecase.User.SearchUsers"
+
+	log := uc.log.With(slog.String("op", op))
+
+	claims, err := uc.requireAdmin(log, token)
+	if err != nil {
+		return nil, err
+	}
+
+	search.Query = strings.TrimSpace(search.Query)
+	if search.Query == "" || utf8.RuneCountInString(search.Query) > searchQueryMaxLength || search.Offset < 0 {
+		err := model.ErrInvalidSearchQuery
+		log.Warn("validating search query", logger.Err(err), slog.String("query", search.Query))
+
+		return nil, err
+	}
+
+	if search.Limit <= 0 {
+		search.Limit = searchDefaultLimit
+	}
+	if search.Limit > searchMaxLimit {
+		search.Limit = searchMaxLimit
+	}
+
+	results, err := uc.usersFor(claims).Search(ctx, search)
+	if err != nil {
+		log.Warn("searching users", logger.Err(err), slog.String("query", search.Query))
+
+		return nil, err
+	}
+
+	return results, nil
+}
+
+func (uc *User) ListUsers(ctx context.Context, token model.Token, listing model.UserListing) ([]model.User, error) {
+	const op = "usecase.User.ListUsers"
+
+	log := uc.log.With(slog.String("op", op))
+
+	claims, err := uc.requireAdmin(log, token)
+	if err != nil {
+		return nil, err
+	}
+
+	verr := &model.ValidationError{}
+	if listing.Offset < 0 {
+		verr.Add("offset", "offset must not be negative")
+	}
+	uc.validateAttributeFilter(verr, listing.Attributes, "admin")
+
+	if err := verr.Err(); err != nil {
+		log.Warn("validating listing", logger.Err(err))
+
+		return nil, err
+	}
+
+	if listing.Limit <= 0 {
+		listing.Limit = searchDefaultLimit
+	}
+	if listing.Limit > searchMaxLimit {
+		listing.Limit = searchMaxLimit
+	}
+
+	filter := model.UserFilter{
+		Role:       listing.Role,
+		IsActive:   listing.IsActive,
+		Attributes: listing.Attributes,
+	}
+
+	users, err := uc.usersFor(claims).List(ctx, filter, listing.Limit, listing.Offset)
+	if err != nil {
+		log.Warn("listing users", logger.Err(err))
+
+		return nil, err
+	}
+
+	return users, nil
+}
+
+func (uc *User) ExportMyData(ctx context.Context, token model.Token) (model.UserDataExport, error) {
+	const op = "usecase.User.ExportMyData"
+
+	log := uc.log.With(slog.String("op", op))
+
+	claims, err := uc.verifyClaims(log, token)
+	if err != nil {
+		return model.UserDataExport{}, err
+	}
+
+	return uc.exportUserData(ctx, log, uc.repo, claims.UserID, claims.UserID)
+}
+
+func (uc *User) ExportUserData(ctx context.Context, token model.Token, id string) (model.UserDataExport, error) {
+	const op = "usecase.User.ExportUserData"
+
+	log := uc.log.With(slog.String("op", op))
+
+	claims, err := uc.requireAdmin(log, token)
+	if err != nil {
+		return model.UserDataExport{}, err
+	}
+
+	return uc.exportUserData(ctx, log, uc.usersFor(claims), claims.UserID, id)
+}
+
+func (uc *User) exportUserData(
+	ctx context.Context,
+	log *slog.Logger,
+	users UserRepository,
+	actorID, id string,
+) (model.UserDataExport, error) {
+	user, err := users.FindOne(ctx, model.UserFilter{ID: &id})
+	if err != nil {
 		log.Warn(
-			"checking claims",
+			"finding user",
+			logger.Err(err),
+			slog.String("id", id),
+		)
+
+		return model.UserDataExport{}, err
+	}
+
+	sessions, err := uc.tokenRepo.FindByUserID(ctx, id)
+	if err != nil {
+		log.Warn(
+			"finding sessions",
+			logger.Err(err),
+			slog.String("id", id),
+		)
+
+		return model.UserDataExport{}, err
+	}
+
+	err = uc.auditRepo.InsertOne(ctx, model.AuditEntry{
+		ActorID:   actorID,
+		Action:    model.AuditActionDataExported,
+		TargetID:  id,
+		CreatedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		log.Error(
+			"auditing data export",
 			logger.Err(err),
-			slog.String("claimsUserID", *claims.UserID),
-			slog.String("claimsRole", *claims.Role),
+			slog.String("id", id),
 		)
 
+		return model.UserDataExport{}, err
+	}
+
+	return model.UserDataExport{
+		User:        user,
+		Sessions:    sessions,
+		GeneratedAt: time.Now().UTC(),
+	}, nil
+}
+
+// AnonymizeUser irreversibly replaces the user's personal data with placeholders,
+// removes their sessions and credentials, drops the stored events and webhook
+// deliveries about them and announces the erasure. Events already published
+// stay in the stream until its maximum age; consumers must act on the erased
+// event. Every step is idempotent, so calling it again after a failure resumes
+// the erasure.
+func (uc *User) AnonymizeUser(ctx context.Context, token model.Token, id string) (model.User, error) {
+	const op = "usecase.User.AnonymizeUser"
+
+	log := uc.log.With(slog.String("op", op))
+
+	claims, err := uc.requireAdmin(log, token)
+	if err != nil {
 		return model.User{}, err
 	}
 
-	deletedUser, err := uc.repo.DeleteOne(ctx, model.UserFilter{ID: &id})
+	users := uc.usersFor(claims)
+	filter := model.UserFilter{ID: &id, IncludeDeleted: true}
+
+	user, err := users.FindOne(ctx, filter)
 	if err != nil {
 		log.Warn(
-			"deleting user",
+			"finding user",
 			logger.Err(err),
 			slog.String("id", id),
 		)
@@ -390,5 +844,197 @@ func (uc *User) DeleteByID(ctx context.Context, token model.Token, id string) (m
 		return model.User{}, err
 	}
 
-	return deletedUser, nil
+	if user.ErasureStatus == model.ErasureStatusCompleted {
+		return user, nil
+	}
+
+	firstName := "Erased"
+	lastName := "User"
+	email := fmt.Sprintf("erased-%s@erased.invalid", id)
+	pendingEmail := ""
+	phoneNumber := ""
+	passwordHash := ""
+	status := model.ErasureStatusInProgress
+	isActive := false
+	avatarVersion := int64(0)
+	avatarURL := ""
+	locale := ""
+	timezone := ""
+	theme := ""
+	marketingOptIn := false
+
+	// Suspension notes are free text written about the user.
+	var suspension *model.Suspension
+	if user.Suspension != nil {
+		scrubbed := *user.Suspension
+		scrubbed.Note = ""
+		suspension = &scrubbed
+	}
+
+	suspensionHistory := make([]model.Suspension, len(user.SuspensionHistory))
+	for i, past := range user.SuspensionHistory {
+		past.Note = ""
+		suspensionHistory[i] = past
+	}
+
+	// Pending verifications carry old and new email addresses and phone
+	// numbers.
+	err = uc.verificationRepo.DeleteMany(ctx, model.VerificationFilter{UserID: &id})
+	if err != nil {
+		log.Error(
+			"deleting verifications",
+			logger.Err(err),
+			slog.String("id", id),
+		)
+
+		return model.User{}, err
+	}
+
+	// Invitations are found by address, so they go before the address does.
+	err = uc.invitationRepo.DeleteMany(ctx, model.InvitationFilter{Email: &user.Email})
+	if err != nil {
+		log.Error(
+			"deleting invitations",
+			logger.Err(err),
+			slog.String("id", id),
+		)
+
+		return model.User{}, err
+	}
+
+	// The version keeps a suspension written meanwhile from being replaced.
+	scrubFilter := filter
+	scrubFilter.Version = &user.Version
+
+	user, err = users.UpdateOne(ctx, scrubFilter, model.UserUpdateData{
+		FirstName:         &firstName,
+		LastName:          &lastName,
+		Email:             &email,
+		PendingEmail:      &pendingEmail,
+		PhoneNumber:       &phoneNumber,
+		PasswordHash:      &passwordHash,
+		ErasureStatus:     &status,
+		UpdatedAt:         time.Now().UTC(),
+		Locale:            &locale,
+		Timezone:          &timezone,
+		Theme:             &theme,
+		MarketingOptIn:    &marketingOptIn,
+		Suspension:        suspension,
+		SuspensionHistory: suspensionHistory,
+		IsActive:          &isActive,
+		AvatarVersion:     &avatarVersion,
+		AvatarURL:         &avatarURL,
+		// Custom attributes may hold personal data as well.
+		UnsetAttributes: slices.Collect(maps.Keys(user.Attributes)),
+	})
+	if err != nil {
+		log.Error(
+			"scrubbing personal data",
+			logger.Err(err),
+			slog.String("id", id),
+		)
+
+		return model.User{}, err
+	}
+
+	// Queued and sent events carry the old data. The sessions revoked below
+	// are announced afresh.
+	err = uc.producer.EraseUserEvents(ctx, id)
+	if err != nil {
+		log.Error(
+			"erasing stored events",
+			logger.Err(err),
+			slog.String("id", id),
+		)
+
+		return model.User{}, err
+	}
+
+	err = uc.revokeSessions(ctx, id, model.SessionRevokeReasonErased)
+	if err != nil {
+		log.Error(
+			"revoking sessions",
+			logger.Err(err),
+			slog.String("id", id),
+		)
+
+		return model.User{}, err
+	}
+
+	err = uc.avatarRepo.DeleteByUserID(ctx, id)
+	if err != nil {
+		log.Error(
+			"deleting avatar",
+			logger.Err(err),
+			slog.String("id", id),
+		)
+
+		return model.User{}, err
+	}
+
+	err = uc.membershipRepo.DeleteMembershipsByUserID(ctx, id)
+	if err != nil {
+		log.Error(
+			"deleting group memberships",
+			logger.Err(err),
+			slog.String("id", id),
+		)
+
+		return model.User{}, err
+	}
+
+	err = uc.producer.PushErased(ctx, user)
+	if err != nil {
+		log.Error(
+			"pushing event",
+			logger.Err(err),
+			slog.String("id", id),
+		)
+
+		return model.User{}, err
+	}
+
+	now := time.Now().UTC()
+	status = model.ErasureStatusCompleted
+
+	user, err = users.UpdateOne(ctx, filter, model.UserUpdateData{
+		ErasureStatus: &status,
+		ErasedAt:      &now,
+		UpdatedAt:     now,
+	})
+	if err != nil {
+		log.Error(
+			"completing erasure",
+			logger.Err(err),
+			slog.String("id", id),
+		)
+
+		return model.User{}, err
+	}
+
+	uc.audit(ctx, log, model.AuditEntry{
+		ActorID:   claims.UserID,
+		Action:    model.AuditActionErased,
+		TargetID:  id,
+		CreatedAt: now,
+	})
+
+	return user, nil
+}
+
+// accessToken issues an access token for the user. When configured it
+// carries the ids of the user's oldest groups, up to GroupTokenLimit.
+func (uc *User) accessToken(ctx context.Context, log *slog.Logger, user model.User) (string, error) {
+	if !uc.cfg.GroupsInTokens {
+		return uc.jwtProvider.GenerateAccessTokenWithTenant(user.ID, user.Role, user.TenantID)
+	}
+
+	groupIDs, err := uc.membershipRepo.GroupIDsForUser(ctx, user.ID, user.TenantID, int64(uc.cfg.GroupTokenLimit))
+	if err != nil {
+		log.Error("finding group ids", logger.Err(err), slog.String("id", user.ID))
+
+		return "", err
+	}
+
+	return uc.jwtProvider.GenerateAccessTokenWithGroups(user.ID, user.Role, user.TenantID, groupIDs)
 }
@@ -0,0 +1,249 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/sorawaslocked/ap2final_user_service/internal/model"
+	"io"
+	"log/slog"
+	"slices"
+	"testing"
+	"time"
+)
+
+var testTime = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+// journal records the writes and events of a test in order. Writes made
+// outside a transaction are marked, and a failed transaction drops its
+// entries like Mongo rolls back its writes.
+type journal struct {
+	entries []string
+	// fail makes the write recorded as the key fail with the error instead.
+	fail map[string]error
+}
+
+type txKey struct{}
+
+func (j *journal) write(ctx context.Context, format string, args ...any) error {
+	entry := fmt.Sprintf(format, args...)
+
+	if err := j.fail[entry]; err != nil {
+		return err
+	}
+
+	if ctx.Value(txKey{}) == nil {
+		entry += " (outside transaction)"
+	}
+
+	j.entries = append(j.entries, entry)
+
+	return nil
+}
+
+// fakeTransactor joins a transaction already carried by ctx, like the mongo
+// Transactor.
+type fakeTransactor struct {
+	journal *journal
+}
+
+func (tx fakeTransactor) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if ctx.Value(txKey{}) != nil {
+		return fn(ctx)
+	}
+
+	start := len(tx.journal.entries)
+
+	if err := fn(context.WithValue(ctx, txKey{}, true)); err != nil {
+		tx.journal.entries = tx.journal.entries[:start]
+
+		return err
+	}
+
+	return nil
+}
+
+type fakeUsers struct {
+	UserRepository
+	journal *journal
+	found   []model.User
+}
+
+func (r fakeUsers) InsertOne(ctx context.Context, user model.User) (model.User, error) {
+	user.ID = "user-1"
+
+	return user, r.journal.write(ctx, "insert user %s", user.ID)
+}
+
+func (r fakeUsers) Find(context.Context, model.UserFilter) ([]model.User, error) {
+	return r.found, nil
+}
+
+func (r fakeUsers) DeleteOne(ctx context.Context, filter model.UserFilter) (model.User, error) {
+	return model.User{ID: *filter.ID}, r.journal.write(ctx, "delete user %s", *filter.ID)
+}
+
+type fakeTokens struct {
+	TokenRepository
+	journal *journal
+}
+
+func (r fakeTokens) DeleteByUserID(ctx context.Context, userID string) error {
+	return r.journal.write(ctx, "delete sessions %s", userID)
+}
+
+type fakeAvatars struct {
+	AvatarRepository
+	journal *journal
+}
+
+func (r fakeAvatars) DeleteByUserID(ctx context.Context, userID string) error {
+	return r.journal.write(ctx, "delete avatars %s", userID)
+}
+
+type fakeMemberships struct {
+	GroupMembershipRepository
+	journal *journal
+}
+
+func (r fakeMemberships) DeleteMembershipsByUserID(ctx context.Context, userID string) error {
+	return r.journal.write(ctx, "delete memberships %s", userID)
+}
+
+type fakeUserEvents struct {
+	UserEventStorage
+	journal *journal
+}
+
+func (p fakeUserEvents) Push(ctx context.Context, user model.User) error {
+	return p.journal.write(ctx, "push registered %s", user.ID)
+}
+
+func (p fakeUserEvents) PushSessionRevoked(ctx context.Context, userID, reason string) error {
+	return p.journal.write(ctx, "push sessions revoked %s %s", userID, reason)
+}
+
+func (p fakeUserEvents) PushPurged(ctx context.Context, user model.User) error {
+	return p.journal.write(ctx, "push purged %s", user.ID)
+}
+
+func newTestUser(j *journal, found []model.User, cfg UserConfig) *User {
+	return NewUser(
+		slog.New(slog.NewTextHandler(io.Discard, nil)),
+		fakeUsers{journal: j, found: found},
+		fakeTokens{journal: j},
+		nil,
+		nil,
+		fakeAvatars{journal: j},
+		fakeMemberships{journal: j},
+		nil,
+		fakeTransactor{journal: j},
+		fakeUserEvents{journal: j},
+		nil,
+		cfg,
+	)
+}
+
+func purgeEntries(id string) []string {
+	return []string{
+		"delete avatars " + id + " (outside transaction)",
+		"delete user " + id,
+		"delete sessions " + id,
+		"push sessions revoked " + id + " " + model.SessionRevokeReasonPurged,
+		"delete memberships " + id,
+		"push purged " + id,
+	}
+}
+
+func TestUserRegister(t *testing.T) {
+	j := &journal{}
+
+	user, err := newTestUser(j, nil, UserConfig{}).Register(context.Background(), model.User{
+		Email:     " Ada@Example.com ",
+		Password:  "correct horse",
+		FirstName: "Ada",
+		LastName:  "Lovelace",
+	})
+	if err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+
+	if user.Email != "Ada@example.com" || user.Role != "user" {
+		t.Errorf("Register() = %+v, want a normalised user with the user role", user)
+	}
+	if want := []string{"insert user user-1", "push registered user-1"}; !slices.Equal(j.entries, want) {
+		t.Errorf("entries = %q, want %q", j.entries, want)
+	}
+}
+
+func TestUserRegisterFailures(t *testing.T) {
+	errPush := errors.New("outbox unavailable")
+
+	tests := []struct {
+		name    string
+		cfg     UserConfig
+		fail    map[string]error
+		wantErr error
+	}{
+		{"invite only", UserConfig{InviteOnly: true}, nil, model.ErrRegistrationClosed},
+		// The user must not exist without its registration event.
+		{"event fails", UserConfig{}, map[string]error{"push registered user-1": errPush}, errPush},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			j := &journal{fail: tt.fail}
+
+			_, err := newTestUser(j, nil, tt.cfg).Register(context.Background(), model.User{
+				Email:     "ada@example.com",
+				Password:  "correct horse",
+				FirstName: "Ada",
+				LastName:  "Lovelace",
+			})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Register() error = %v, want %v", err, tt.wantErr)
+			}
+			if len(j.entries) != 0 {
+				t.Errorf("entries = %q, want none", j.entries)
+			}
+		})
+	}
+}
+
+func TestUserPurgeDeleted(t *testing.T) {
+	j := &journal{}
+	found := []model.User{{ID: "user-1"}, {ID: "user-2"}}
+
+	purged, err := newTestUser(j, found, UserConfig{}).PurgeDeleted(context.Background(), testTime)
+	if err != nil {
+		t.Fatalf("PurgeDeleted() error = %v", err)
+	}
+
+	if purged != 2 {
+		t.Errorf("PurgeDeleted() = %d, want 2", purged)
+	}
+	if want := append(purgeEntries("user-1"), purgeEntries("user-2")...); !slices.Equal(j.entries, want) {
+		t.Errorf("entries = %q, want %q", j.entries, want)
+	}
+}
+
+func TestUserPurgeDeletedStopsAtFailure(t *testing.T) {
+	errPush := errors.New("outbox unavailable")
+	j := &journal{fail: map[string]error{"push purged user-2": errPush}}
+	found := []model.User{{ID: "user-1"}, {ID: "user-2"}, {ID: "user-3"}}
+
+	purged, err := newTestUser(j, found, UserConfig{}).PurgeDeleted(context.Background(), testTime)
+	if !errors.Is(err, errPush) {
+		t.Fatalf("PurgeDeleted() error = %v, want %v", err, errPush)
+	}
+
+	if purged != 1 {
+		t.Errorf("PurgeDeleted() = %d, want 1", purged)
+	}
+	// The second user keeps everything but the avatar, removed before the
+	// transaction, and the third is not touched.
+	want := append(purgeEntries("user-1"), "delete avatars user-2 (outside transaction)")
+	if !slices.Equal(j.entries, want) {
+		t.Errorf("entries = %q, want %q", j.entries, want)
+	}
+}
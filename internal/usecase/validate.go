@@ -0,0 +1,55 @@
+package usecase
+
+import (
+	"github.com/sorawaslocked/ap2final_user_service/internal/model"
+	"github.com/sorawaslocked/ap2final_user_service/internal/normalize"
+	"unicode/utf8"
+)
+
+const passwordMinLength = 8
+
+// normalizeRegistration validates the registration fields and returns the user
+// with email, names, phone number and locale in their canonical form.
+func (uc *User) normalizeRegistration(user model.User) (model.User, error) {
+	verr := &model.ValidationError{}
+
+	email, err := normalize.Email(user.Email)
+	if err != nil {
+		verr.Add("email", err.Error())
+	}
+	user.Email = email
+
+	if utf8.RuneCountInString(user.Password) < passwordMinLength {
+		verr.Add("password", "password must be at least 8 characters long")
+	}
+
+	if user.FirstName, err = normalize.Name(user.FirstName); err != nil {
+		verr.Add("first_name", err.Error())
+	}
+
+	if user.LastName, err = normalize.Name(user.LastName); err != nil {
+		verr.Add("last_name", err.Error())
+	}
+
+	phoneRegion := uc.cfg.DefaultPhoneRegion
+
+	if user.Locale != "" {
+		locale, region, err := normalize.Locale(user.Locale)
+		if err != nil {
+			verr.Add("locale", err.Error())
+		}
+		user.Locale = locale
+
+		if region != "" {
+			phoneRegion = region
+		}
+	}
+
+	if user.PhoneNumber != "" {
+		if user.PhoneNumber, err = normalize.Phone(user.PhoneNumber, phoneRegion); err != nil {
+			verr.Add("phone_number", err.Error())
+		}
+	}
+
+	return user, verr.Err()
+}
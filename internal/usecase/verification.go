@@ -0,0 +1,64 @@
+package usecase
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"github.com/sorawaslocked/ap2final_user_service/internal/model"
+	"math/big"
+	"time"
+)
+
+const (
+	verificationCodeDigits  = 6
+	verificationMaxAttempts = 5
+)
+
+// newVerificationCode returns a random numeric code for SMS delivery.
+func newVerificationCode() (string, error) {
+	max := new(big.Int).Exp(big.NewInt(10), big.NewInt(verificationCodeDigits), nil)
+
+	n, err := rand.Int(rand.Reader, max)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%0*d", verificationCodeDigits, n), nil
+}
+
+// newVerificationToken returns a random URL-safe token for links sent by email.
+func newVerificationToken() (string, error) {
+	b := make([]byte, 32)
+
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// checkVerification validates a submitted code against a pending verification.
+func checkVerification(verification model.Verification, code string, now time.Time) error {
+	if !verification.ExpiresAt.After(now) {
+		return model.ErrVerificationExpired
+	}
+
+	if verification.Attempts >= verificationMaxAttempts {
+		return model.ErrTooManyAttempts
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hashSecret(code)), []byte(verification.CodeHash)) != 1 {
+		return model.ErrInvalidVerificationCode
+	}
+
+	return nil
+}
@@ -0,0 +1,351 @@
+package usecase
+
+import (
+	"context"
+	"github.com/sorawaslocked/ap2final_base/pkg/logger"
+	"github.com/sorawaslocked/ap2final_base/pkg/security"
+	"github.com/sorawaslocked/ap2final_user_service/internal/model"
+	"log/slog"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const webhookDescriptionMaxLen = 256
+
+type Webhook struct {
+	authenticator
+	auditor
+	log         *slog.Logger
+	webhookRepo WebhookRepository
+}
+
+func NewWebhook(
+	log *slog.Logger,
+	webhookRepo WebhookRepository,
+	auditRepo AuditRepository,
+	jwtProvider *security.JWTProvider,
+) *Webhook {
+	return &Webhook{
+		authenticator: authenticator{jwtProvider: jwtProvider},
+		auditor:       auditor{auditRepo: auditRepo},
+		log:           log,
+		webhookRepo:   webhookRepo,
+	}
+}
+
+// CreateWebhook registers a partner endpoint. The returned webhook carries
+// the signing secret, which is not returned again.
+func (uc *Webhook) CreateWebhook(ctx context.Context, token model.Token, webhook model.Webhook) (model.Webhook, error) {
+	const op = "usecase.Webhook.CreateWebhook"
+
+	log := uc.log.With(slog.String("op", op))
+
+	claims, err := uc.requirePlatformAdmin(log, token)
+	if err != nil {
+		return model.Webhook{}, err
+	}
+
+	verr := &model.ValidationError{}
+
+	webhook.URL = strings.TrimSpace(webhook.URL)
+	validateWebhookURL(verr, webhook.URL)
+	validateWebhookEvents(verr, webhook.EventTypes)
+
+	webhook.Description = strings.TrimSpace(webhook.Description)
+	validateWebhookDescription(verr, webhook.Description)
+
+	if err = verr.Err(); err != nil {
+		log.Warn("validating webhook", logger.Err(err))
+
+		return model.Webhook{}, err
+	}
+
+	secret, err := newVerificationToken()
+	if err != nil {
+		log.Error("generating webhook secret", logger.Err(err))
+
+		return model.Webhook{}, err
+	}
+
+	now := time.Now().UTC()
+
+	webhook.Secret = secret
+	webhook.Enabled = true
+	webhook.ConsecutiveFailures = 0
+	webhook.DisabledAt = time.Time{}
+	webhook.CreatedBy = claims.UserID
+	webhook.CreatedAt = now
+	webhook.UpdatedAt = now
+
+	createdWebhook, err := uc.webhookRepo.InsertOne(ctx, webhook)
+	if err != nil {
+		log.Error("creating webhook", logger.Err(err))
+
+		return model.Webhook{}, err
+	}
+
+	uc.audit(ctx, log, model.AuditEntry{
+		ActorID:   claims.UserID,
+		Action:    model.AuditActionWebhookCreated,
+		TargetID:  createdWebhook.ID,
+		Details:   map[string]string{"url": createdWebhook.URL},
+		CreatedAt: now,
+	})
+
+	createdWebhook.Secret = secret
+
+	return createdWebhook, nil
+}
+
+// ListWebhooks returns the registered webhooks, newest first.
+func (uc *Webhook) ListWebhooks(
+	ctx context.Context,
+	token model.Token,
+	listing model.WebhookListing,
+) ([]model.Webhook, error) {
+	const op = "usecase.Webhook.ListWebhooks"
+
+	log := uc.log.With(slog.String("op", op))
+
+	if _, err := uc.requirePlatformAdmin(log, token); err != nil {
+		return nil, err
+	}
+
+	verr := &model.ValidationError{}
+
+	if listing.Offset < 0 {
+		verr.Add("offset", "offset must not be negative")
+	}
+
+	if err := verr.Err(); err != nil {
+		log.Warn("validating listing", logger.Err(err))
+
+		return nil, err
+	}
+
+	if listing.Limit <= 0 {
+		listing.Limit = searchDefaultLimit
+	}
+	if listing.Limit > searchMaxLimit {
+		listing.Limit = searchMaxLimit
+	}
+
+	webhooks, err := uc.webhookRepo.List(ctx, model.WebhookFilter{}, listing.Limit, listing.Offset)
+	if err != nil {
+		log.Error("listing webhooks", logger.Err(err))
+
+		return nil, err
+	}
+
+	for i := range webhooks {
+		webhooks[i].Secret = ""
+	}
+
+	return webhooks, nil
+}
+
+// UpdateWebhook changes the webhook. Enabling it again clears the failure
+// count, so a webhook disabled for failing gets a fresh start.
+func (uc *Webhook) UpdateWebhook(
+	ctx context.Context,
+	token model.Token,
+	id string,
+	update model.WebhookUpdateData,
+) (model.Webhook, error) {
+	const op = "usecase.Webhook.UpdateWebhook"
+
+	log := uc.log.With(slog.String("op", op))
+
+	claims, err := uc.requirePlatformAdmin(log, token)
+	if err != nil {
+		return model.Webhook{}, err
+	}
+
+	verr := &model.ValidationError{}
+
+	if update.URL != nil {
+		trimmed := strings.TrimSpace(*update.URL)
+		update.URL = &trimmed
+		validateWebhookURL(verr, trimmed)
+	}
+
+	if update.EventTypes != nil {
+		validateWebhookEvents(verr, *update.EventTypes)
+	}
+
+	if update.Description != nil {
+		trimmed := strings.TrimSpace(*update.Description)
+		update.Description = &trimmed
+		validateWebhookDescription(verr, trimmed)
+	}
+
+	if err = verr.Err(); err != nil {
+		log.Warn("validating webhook", logger.Err(err))
+
+		return model.Webhook{}, err
+	}
+
+	now := time.Now().UTC()
+
+	update.ConsecutiveFailures = nil
+	update.DisabledAt = nil
+	update.UpdatedAt = now
+
+	if update.Enabled != nil {
+		if *update.Enabled {
+			failures := 0
+			update.ConsecutiveFailures = &failures
+			update.DisabledAt = &time.Time{}
+		} else {
+			update.DisabledAt = &now
+		}
+	}
+
+	updatedWebhook, err := uc.webhookRepo.UpdateOne(ctx, model.WebhookFilter{ID: &id}, update)
+	if err != nil {
+		log.Warn("updating webhook", logger.Err(err), slog.String("webhookID", id))
+
+		return model.Webhook{}, err
+	}
+
+	uc.audit(ctx, log, model.AuditEntry{
+		ActorID:   claims.UserID,
+		Action:    model.AuditActionWebhookUpdated,
+		TargetID:  id,
+		Details:   webhookUpdateDetails(update),
+		CreatedAt: now,
+	})
+
+	updatedWebhook.Secret = ""
+
+	return updatedWebhook, nil
+}
+
+// DeleteWebhook removes the webhook and drops its queued deliveries.
+func (uc *Webhook) DeleteWebhook(ctx context.Context, token model.Token, id string) (model.Webhook, error) {
+	const op = "usecase.Webhook.DeleteWebhook"
+
+	log := uc.log.With(slog.String("op", op))
+
+	claims, err := uc.requirePlatformAdmin(log, token)
+	if err != nil {
+		return model.Webhook{}, err
+	}
+
+	deletedWebhook, err := uc.webhookRepo.DeleteOne(ctx, model.WebhookFilter{ID: &id})
+	if err != nil {
+		log.Warn("deleting webhook", logger.Err(err), slog.String("webhookID", id))
+
+		return model.Webhook{}, err
+	}
+
+	uc.audit(ctx, log, model.AuditEntry{
+		ActorID:   claims.UserID,
+		Action:    model.AuditActionWebhookDeleted,
+		TargetID:  id,
+		Details:   map[string]string{"url": deletedWebhook.URL},
+		CreatedAt: time.Now().UTC(),
+	})
+
+	deletedWebhook.Secret = ""
+
+	return deletedWebhook, nil
+}
+
+// ListWebhookDeliveries returns deliveries with their attempt log, newest
+// first, so admins can see why a partner is not receiving events.
+func (uc *Webhook) ListWebhookDeliveries(
+	ctx context.Context,
+	token model.Token,
+	listing model.WebhookDeliveryListing,
+) ([]model.WebhookDelivery, error) {
+	const op = "usecase.Webhook.ListWebhookDeliveries"
+
+	log := uc.log.With(slog.String("op", op))
+
+	if _, err := uc.requirePlatformAdmin(log, token); err != nil {
+		return nil, err
+	}
+
+	verr := &model.ValidationError{}
+
+	switch listing.Status {
+	case "", model.WebhookDeliveryPending, model.WebhookDeliveryDelivered, model.WebhookDeliveryFailed:
+	default:
+		verr.Add("status", "status must be one of pending, delivered or failed")
+	}
+
+	if listing.Offset < 0 {
+		verr.Add("offset", "offset must not be negative")
+	}
+
+	if err := verr.Err(); err != nil {
+		log.Warn("validating listing", logger.Err(err))
+
+		return nil, err
+	}
+
+	if listing.Limit <= 0 {
+		listing.Limit = searchDefaultLimit
+	}
+	if listing.Limit > searchMaxLimit {
+		listing.Limit = searchMaxLimit
+	}
+
+	var filter model.WebhookDeliveryFilter
+	if listing.WebhookID != "" {
+		filter.WebhookID = &listing.WebhookID
+	}
+	if listing.Status != "" {
+		filter.Status = &listing.Status
+	}
+
+	deliveries, err := uc.webhookRepo.ListDeliveries(ctx, filter, listing.Limit, listing.Offset)
+	if err != nil {
+		log.Error("listing webhook deliveries", logger.Err(err))
+
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+// validateWebhookURL accepts absolute http and https URLs.
+func validateWebhookURL(verr *model.ValidationError, rawURL string) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		verr.Add("url", "url must be an absolute http or https URL")
+	}
+}
+
+func validateWebhookEvents(verr *model.ValidationError, eventTypes []string) {
+	for _, eventType := range eventTypes {
+		if !model.IsValidWebhookEvent(eventType) {
+			verr.Add("event_types", "unknown event type "+eventType)
+		}
+	}
+}
+
+func validateWebhookDescription(verr *model.ValidationError, description string) {
+	if len(description) > webhookDescriptionMaxLen {
+		verr.Add("description", "description must be at most 256 characters")
+	}
+}
+
+func webhookUpdateDetails(update model.WebhookUpdateData) map[string]string {
+	details := map[string]string{}
+
+	if update.URL != nil {
+		details["url"] = *update.URL
+	}
+	if update.EventTypes != nil {
+		details["eventTypes"] = strings.Join(*update.EventTypes, ",")
+	}
+	if update.Enabled != nil {
+		details["enabled"] = strconv.FormatBool(*update.Enabled)
+	}
+
+	return details
+}
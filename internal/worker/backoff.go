@@ -0,0 +1,17 @@
+package worker
+
+import "time"
+
+// backoff doubles minDelay with every failed attempt, up to maxDelay.
+func backoff(minDelay, maxDelay time.Duration, attempts int) time.Duration {
+	delay := minDelay
+
+	for range attempts {
+		delay *= 2
+		if delay >= maxDelay {
+			return maxDelay
+		}
+	}
+
+	return delay
+}
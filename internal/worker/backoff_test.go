@@ -0,0 +1,29 @@
+package worker
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		name     string
+		attempts int
+		want     time.Duration
+	}{
+		{"first retry", 0, time.Second},
+		{"second retry", 1, 2 * time.Second},
+		{"third retry", 2, 4 * time.Second},
+		{"just below cap", 5, 32 * time.Second},
+		{"reaches cap", 6, time.Minute},
+		{"far past cap", 100, time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := backoff(time.Second, time.Minute, tt.attempts); got != tt.want {
+				t.Errorf("backoff(1s, 1m, %d) = %s, want %s", tt.attempts, got, tt.want)
+			}
+		})
+	}
+}
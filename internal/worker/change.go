@@ -0,0 +1,183 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"github.com/sorawaslocked/ap2final_base/pkg/logger"
+	"github.com/sorawaslocked/ap2final_user_service/internal/model"
+	"log/slog"
+	"time"
+)
+
+// releaseTimeout bounds giving up the lease on the way out, when the run's
+// context is usually already done.
+const releaseTimeout = 5 * time.Second
+
+// UserCDC publishes every write to the users collection, including those
+// made outside the service, such as edits in the Mongo shell. The resume
+// token is saved after each published change, so a restart continues where
+// it stopped and at most the last change is published twice.
+//
+// Only the replica holding the lease tails the stream; the others retry
+// after the retry delay and take over once the lease runs out.
+type UserCDC struct {
+	periodic
+
+	log        *slog.Logger
+	stream     UserChangeStream
+	publisher  ChangePublisher
+	owner      string
+	leaseTTL   time.Duration
+	retryDelay time.Duration
+}
+
+func NewUserCDC(
+	log *slog.Logger,
+	stream UserChangeStream,
+	publisher ChangePublisher,
+	owner string,
+	leaseTTL time.Duration,
+	retryDelay time.Duration,
+) *UserCDC {
+	return &UserCDC{
+		log:        log,
+		stream:     stream,
+		publisher:  publisher,
+		owner:      owner,
+		leaseTTL:   leaseTTL,
+		retryDelay: retryDelay,
+	}
+}
+
+// Start tails the collection until stopped. A broken stream is opened again
+// after the retry delay.
+func (w *UserCDC) Start(ctx context.Context) {
+	w.start(ctx, w.retryDelay, w.run)
+}
+
+func (w *UserCDC) Stop() {
+	w.log.Info("stopping change data capture")
+
+	w.stop()
+}
+
+func (w *UserCDC) run(ctx context.Context) {
+	const op = "worker.UserCDC.run"
+
+	log := w.log.With(slog.String("op", op), slog.String("owner", w.owner))
+
+	err := w.stream.AcquireLease(ctx, w.owner, time.Now().UTC(), w.leaseTTL)
+	if errors.Is(err, model.ErrLeaseHeld) {
+		log.Debug("another replica holds the change stream lease")
+
+		return
+	}
+	if err != nil {
+		log.Error("acquiring lease", logger.Err(err))
+
+		return
+	}
+
+	// The watch stops as soon as the lease cannot be renewed, so two
+	// replicas never publish side by side for longer than a renewal.
+	watchCtx, cancel := context.WithCancel(ctx)
+	renewed := make(chan struct{})
+
+	go func() {
+		defer close(renewed)
+
+		w.renewLease(watchCtx, cancel, log)
+	}()
+
+	defer func() {
+		cancel()
+		<-renewed
+
+		releaseCtx, cancelRelease := context.WithTimeout(context.Background(), releaseTimeout)
+		defer cancelRelease()
+
+		if err := w.stream.ReleaseLease(releaseCtx, w.owner); err != nil {
+			log.Warn("releasing lease", logger.Err(err))
+		}
+	}()
+
+	w.watch(watchCtx, log)
+
+	if ctx.Err() == nil && watchCtx.Err() != nil {
+		log.Warn("lost the change stream lease")
+	}
+}
+
+func (w *UserCDC) watch(ctx context.Context, log *slog.Logger) {
+	resumeToken, err := w.stream.LoadResumeToken(ctx)
+	if err != nil && !errors.Is(err, model.ErrNotFound) {
+		log.Error("loading resume token", logger.Err(err))
+
+		return
+	}
+
+	err = w.stream.Watch(ctx, resumeToken, func(ctx context.Context, change model.UserChange) error {
+		if err := w.publisher.PushChange(ctx, change); err != nil {
+			log.Error(
+				"publishing change",
+				logger.Err(err),
+				slog.String("operation", change.Operation),
+				slog.String("userID", change.User.ID),
+			)
+
+			return err
+		}
+
+		return w.stream.SaveResumeToken(ctx, w.owner, change.ResumeToken)
+	})
+	if ctx.Err() != nil {
+		return
+	}
+
+	if errors.Is(err, model.ErrLeaseHeld) {
+		log.Warn("another replica took the change stream lease")
+
+		return
+	}
+
+	// The changes since the token are gone. Start over from the next change;
+	// running the replay command brings consumers back in line.
+	if errors.Is(err, model.ErrChangeHistoryLost) {
+		log.Error("resuming change stream, changes were missed", logger.Err(err))
+
+		if err = w.stream.SaveResumeToken(ctx, w.owner, nil); err != nil {
+			log.Error("clearing resume token", logger.Err(err))
+		}
+
+		return
+	}
+
+	if err != nil {
+		log.Error("watching users", logger.Err(err))
+	}
+}
+
+// renewLease extends the lease every third of its duration and cancels the
+// watch if that fails.
+func (w *UserCDC) renewLease(ctx context.Context, cancel context.CancelFunc, log *slog.Logger) {
+	ticker := time.NewTicker(w.leaseTTL / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		err := w.stream.AcquireLease(ctx, w.owner, time.Now().UTC(), w.leaseTTL)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Warn("renewing lease", logger.Err(err))
+				cancel()
+			}
+
+			return
+		}
+	}
+}
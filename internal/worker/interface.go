@@ -0,0 +1,61 @@
+package worker
+
+import (
+	"context"
+	"github.com/sorawaslocked/ap2final_user_service/internal/model"
+	"time"
+)
+
+type UserPurger interface {
+	PurgeDeleted(ctx context.Context, before time.Time) (int, error)
+}
+
+type SuspensionLifter interface {
+	LiftExpiredSuspensions(ctx context.Context, now time.Time) (int, error)
+}
+
+type OutboxStore interface {
+	Claim(ctx context.Context, now time.Time, lease time.Duration) (model.OutboxMessage, error)
+	MarkSent(ctx context.Context, id string, sentAt time.Time) error
+	Delete(ctx context.Context, id string) error
+	MarkFailed(ctx context.Context, id string, nextAttemptAt time.Time, reason string) error
+	MarkDead(ctx context.Context, id string, reason string) error
+}
+
+// Publisher sends an outbox message. The message id stays the same across
+// retries so the broker can drop duplicates.
+type Publisher interface {
+	Publish(ctx context.Context, message model.OutboxMessage) error
+}
+
+type WebhookStore interface {
+	FindOne(ctx context.Context, filter model.WebhookFilter) (model.Webhook, error)
+	ClaimDelivery(ctx context.Context, now time.Time, lease time.Duration) (model.WebhookDelivery, error)
+	RecordAttempt(
+		ctx context.Context,
+		id string,
+		attempt model.WebhookAttempt,
+		status string,
+		nextAttemptAt time.Time,
+	) error
+	RecordSuccess(ctx context.Context, id string) error
+	RecordFailure(ctx context.Context, id string, disableAfter int, now time.Time) (bool, error)
+}
+
+type WebhookSender interface {
+	Send(ctx context.Context, webhook model.Webhook, delivery model.WebhookDelivery) (int, error)
+}
+
+// UserChangeStream reads the writes to the users collection and keeps the
+// position to resume from. Only the holder of the lease may save it.
+type UserChangeStream interface {
+	Watch(ctx context.Context, resumeToken []byte, handle func(ctx context.Context, change model.UserChange) error) error
+	LoadResumeToken(ctx context.Context) ([]byte, error)
+	SaveResumeToken(ctx context.Context, owner string, token []byte) error
+	AcquireLease(ctx context.Context, owner string, now time.Time, ttl time.Duration) error
+	ReleaseLease(ctx context.Context, owner string) error
+}
+
+type ChangePublisher interface {
+	PushChange(ctx context.Context, change model.UserChange) error
+}
@@ -0,0 +1,143 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"github.com/sorawaslocked/ap2final_base/pkg/logger"
+	"github.com/sorawaslocked/ap2final_user_service/internal/model"
+	"log/slog"
+	"time"
+)
+
+type OutboxRelayConfig struct {
+	Interval time.Duration
+	// BatchSize caps how many messages one run publishes.
+	BatchSize int
+	// Lease is how long a claimed message stays hidden from other relays.
+	Lease      time.Duration
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// MaxAttempts is how many times a message is tried before it is marked
+	// dead.
+	MaxAttempts int
+}
+
+// OutboxRelay publishes pending outbox messages. Messages of one aggregate go
+// out in the order they were queued; a message that fails holds back the
+// later messages of its aggregate until it is published or marked dead, but
+// not those of other aggregates. A message is marked sent only after the
+// publisher accepted it, so delivery is at least once.
+//
+// A message is marked dead, and left for an operator, after MaxAttempts
+// failures or at once when no stream stores its subject.
+type OutboxRelay struct {
+	periodic
+
+	log       *slog.Logger
+	outbox    OutboxStore
+	publisher Publisher
+	cfg       OutboxRelayConfig
+}
+
+func NewOutboxRelay(log *slog.Logger, outbox OutboxStore, publisher Publisher, cfg OutboxRelayConfig) *OutboxRelay {
+	return &OutboxRelay{
+		log:       log,
+		outbox:    outbox,
+		publisher: publisher,
+		cfg:       cfg,
+	}
+}
+
+func (w *OutboxRelay) Start(ctx context.Context) {
+	w.start(ctx, w.cfg.Interval, w.run)
+}
+
+func (w *OutboxRelay) Stop() {
+	w.log.Info("stopping outbox relay")
+
+	w.stop()
+}
+
+func (w *OutboxRelay) run(ctx context.Context) {
+	const op = "worker.OutboxRelay.run"
+
+	log := w.log.With(slog.String("op", op))
+
+	sent := 0
+
+	for range w.cfg.BatchSize {
+		if ctx.Err() != nil {
+			return
+		}
+
+		now := time.Now().UTC()
+
+		message, err := w.outbox.Claim(ctx, now, w.cfg.Lease)
+		if errors.Is(err, model.ErrNotFound) {
+			break
+		}
+		if err != nil {
+			log.Error("claiming outbox message", logger.Err(err))
+
+			return
+		}
+
+		err = w.publisher.Publish(ctx, message)
+		if err != nil {
+			w.fail(ctx, log, message, now, err)
+
+			// Only this aggregate waits for the retry.
+			continue
+		}
+
+		// If this fails the message is published again after the lease.
+		if err = w.markSent(ctx, message); err != nil {
+			log.Error("marking outbox message sent", logger.Err(err), slog.String("id", message.ID))
+
+			break
+		}
+
+		sent++
+	}
+
+	if sent > 0 {
+		log.Debug("relayed outbox messages", slog.Int("count", sent))
+	}
+}
+
+func (w *OutboxRelay) fail(ctx context.Context, log *slog.Logger, message model.OutboxMessage, now time.Time, err error) {
+	attempts := message.Attempts + 1
+	log = log.With(
+		slog.String("id", message.ID),
+		slog.String("subject", message.Subject),
+		slog.String("aggregateID", message.AggregateID),
+		slog.Int("attempts", attempts),
+	)
+
+	if errors.Is(err, model.ErrNoStream) || attempts >= w.cfg.MaxAttempts {
+		log.Error("giving up on outbox message", logger.Err(err))
+
+		if markErr := w.outbox.MarkDead(ctx, message.ID, err.Error()); markErr != nil {
+			log.Error("marking outbox message dead", logger.Err(markErr))
+		}
+
+		return
+	}
+
+	retryAt := now.Add(backoff(w.cfg.MinBackoff, w.cfg.MaxBackoff, message.Attempts))
+	log.Warn("publishing outbox message", logger.Err(err), slog.Time("retryAt", retryAt))
+
+	if markErr := w.outbox.MarkFailed(ctx, message.ID, retryAt, err.Error()); markErr != nil {
+		log.Error("marking outbox message failed", logger.Err(markErr))
+	}
+}
+
+// markSent keeps a sent message for the retention period, except sensitive
+// ones, which are deleted right away.
+func (w *OutboxRelay) markSent(ctx context.Context, message model.OutboxMessage) error {
+	if message.Sensitive {
+		return w.outbox.Delete(ctx, message.ID)
+	}
+
+	return w.outbox.MarkSent(ctx, message.ID, time.Now().UTC())
+}
@@ -0,0 +1,203 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/sorawaslocked/ap2final_user_service/internal/model"
+	"io"
+	"log/slog"
+	"slices"
+	"testing"
+	"time"
+)
+
+// fakeOutboxStore keeps messages in queue order. Like the mongo store it only
+// claims the oldest pending message of each aggregate, once it is due.
+type fakeOutboxStore struct {
+	messages []model.OutboxMessage
+	deleted  []string
+}
+
+func (s *fakeOutboxStore) Claim(_ context.Context, now time.Time, lease time.Duration) (model.OutboxMessage, error) {
+	seen := map[string]bool{}
+
+	for i, message := range s.messages {
+		if message.Status != model.OutboxStatusPending || seen[message.AggregateID] {
+			continue
+		}
+		seen[message.AggregateID] = true
+
+		if message.NextAttemptAt.After(now) {
+			continue
+		}
+
+		s.messages[i].NextAttemptAt = now.Add(lease)
+
+		return s.messages[i], nil
+	}
+
+	return model.OutboxMessage{}, model.ErrNotFound
+}
+
+func (s *fakeOutboxStore) find(id string) *model.OutboxMessage {
+	for i := range s.messages {
+		if s.messages[i].ID == id {
+			return &s.messages[i]
+		}
+	}
+
+	return nil
+}
+
+func (s *fakeOutboxStore) MarkSent(_ context.Context, id string, _ time.Time) error {
+	s.find(id).Status = model.OutboxStatusSent
+
+	return nil
+}
+
+func (s *fakeOutboxStore) Delete(_ context.Context, id string) error {
+	s.messages = slices.DeleteFunc(s.messages, func(message model.OutboxMessage) bool {
+		return message.ID == id
+	})
+	s.deleted = append(s.deleted, id)
+
+	return nil
+}
+
+func (s *fakeOutboxStore) MarkFailed(_ context.Context, id string, nextAttemptAt time.Time, reason string) error {
+	message := s.find(id)
+	message.Attempts++
+	message.NextAttemptAt = nextAttemptAt
+	message.LastError = reason
+
+	return nil
+}
+
+func (s *fakeOutboxStore) MarkDead(_ context.Context, id string, reason string) error {
+	message := s.find(id)
+	message.Attempts++
+	message.Status = model.OutboxStatusDead
+	message.LastError = reason
+
+	return nil
+}
+
+// fakePublisher fails the messages listed in errs and records the rest.
+type fakePublisher struct {
+	errs      map[string]error
+	published []string
+}
+
+func (p *fakePublisher) Publish(_ context.Context, message model.OutboxMessage) error {
+	if err := p.errs[message.ID]; err != nil {
+		return err
+	}
+
+	p.published = append(p.published, message.ID)
+
+	return nil
+}
+
+func newTestRelay(store *fakeOutboxStore, publisher *fakePublisher) *OutboxRelay {
+	return NewOutboxRelay(
+		slog.New(slog.NewTextHandler(io.Discard, nil)),
+		store,
+		publisher,
+		OutboxRelayConfig{
+			BatchSize:   10,
+			Lease:       time.Minute,
+			MinBackoff:  time.Second,
+			MaxBackoff:  time.Minute,
+			MaxAttempts: 3,
+		},
+	)
+}
+
+func pendingMessage(id, aggregateID string) model.OutboxMessage {
+	return model.OutboxMessage{ID: id, AggregateID: aggregateID, Status: model.OutboxStatusPending}
+}
+
+func TestOutboxRelayPublishesInOrder(t *testing.T) {
+	secret := pendingMessage("m3", "user-1")
+	secret.Sensitive = true
+
+	store := &fakeOutboxStore{messages: []model.OutboxMessage{
+		pendingMessage("m1", "user-1"),
+		pendingMessage("m2", "user-2"),
+		secret,
+	}}
+	publisher := &fakePublisher{}
+
+	newTestRelay(store, publisher).run(context.Background())
+
+	if want := []string{"m1", "m2", "m3"}; !slices.Equal(publisher.published, want) {
+		t.Errorf("published %v, want %v", publisher.published, want)
+	}
+	for _, id := range []string{"m1", "m2"} {
+		if got := store.find(id).Status; got != model.OutboxStatusSent {
+			t.Errorf("%s status = %q, want %q", id, got, model.OutboxStatusSent)
+		}
+	}
+	if !slices.Equal(store.deleted, []string{"m3"}) || store.find("m3") != nil {
+		t.Errorf("deleted %v, want the sensitive message m3", store.deleted)
+	}
+}
+
+func TestOutboxRelayFailureHoldsBackOnlyItsAggregate(t *testing.T) {
+	store := &fakeOutboxStore{messages: []model.OutboxMessage{
+		pendingMessage("m1", "user-1"),
+		pendingMessage("m2", "user-1"),
+		pendingMessage("m3", "user-2"),
+	}}
+	publisher := &fakePublisher{errs: map[string]error{"m1": errors.New("timeout")}}
+
+	before := time.Now()
+	newTestRelay(store, publisher).run(context.Background())
+
+	if want := []string{"m3"}; !slices.Equal(publisher.published, want) {
+		t.Errorf("published %v, want %v", publisher.published, want)
+	}
+
+	failed := store.find("m1")
+	if failed.Status != model.OutboxStatusPending || failed.Attempts != 1 || failed.LastError != "timeout" {
+		t.Errorf("m1 = %+v, want pending after one failed attempt", *failed)
+	}
+	if failed.NextAttemptAt.Before(before.Add(time.Second)) {
+		t.Errorf("m1 retry at %v, want at least a second after %v", failed.NextAttemptAt, before)
+	}
+	if held := store.find("m2"); held.Status != model.OutboxStatusPending || held.Attempts != 0 {
+		t.Errorf("m2 = %+v, want untouched behind m1", *held)
+	}
+}
+
+func TestOutboxRelayMarksDead(t *testing.T) {
+	exhausted := pendingMessage("m1", "user-1")
+	exhausted.Attempts = 2
+
+	tests := []struct {
+		name    string
+		message model.OutboxMessage
+		err     error
+	}{
+		{"max attempts", exhausted, errors.New("timeout")},
+		{"no stream", pendingMessage("m1", "user-1"), fmt.Errorf("%w: user.unknown", model.ErrNoStream)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &fakeOutboxStore{messages: []model.OutboxMessage{tt.message, pendingMessage("m2", "user-1")}}
+			publisher := &fakePublisher{errs: map[string]error{"m1": tt.err}}
+
+			newTestRelay(store, publisher).run(context.Background())
+
+			if dead := store.find("m1"); dead.Status != model.OutboxStatusDead || dead.LastError != tt.err.Error() {
+				t.Errorf("m1 = %+v, want dead with the publish error", *dead)
+			}
+			// A dead message no longer holds back its aggregate.
+			if want := []string{"m2"}; !slices.Equal(publisher.published, want) {
+				t.Errorf("published %v, want %v", publisher.published, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package worker
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// periodic runs a job immediately and then on every tick until stopped.
+type periodic struct {
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func (p *periodic) start(ctx context.Context, interval time.Duration, job func(ctx context.Context)) {
+	ctx, p.cancel = context.WithCancel(ctx)
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			job(ctx)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (p *periodic) stop() {
+	if p.cancel != nil {
+		p.cancel()
+	}
+	p.wg.Wait()
+}
@@ -0,0 +1,55 @@
+package worker
+
+import (
+	"context"
+	"github.com/sorawaslocked/ap2final_base/pkg/logger"
+	"log/slog"
+	"time"
+)
+
+// Purge periodically hard-deletes users whose soft deletion is older than the
+// retention period.
+type Purge struct {
+	periodic
+
+	log       *slog.Logger
+	uc        UserPurger
+	interval  time.Duration
+	retention time.Duration
+}
+
+func NewPurge(log *slog.Logger, uc UserPurger, interval, retention time.Duration) *Purge {
+	return &Purge{
+		log:       log,
+		uc:        uc,
+		interval:  interval,
+		retention: retention,
+	}
+}
+
+func (w *Purge) Start(ctx context.Context) {
+	w.start(ctx, w.interval, w.run)
+}
+
+func (w *Purge) Stop() {
+	w.log.Info("stopping purge worker")
+
+	w.stop()
+}
+
+func (w *Purge) run(ctx context.Context) {
+	const op = "worker.Purge.run"
+
+	log := w.log.With(slog.String("op", op))
+
+	purged, err := w.uc.PurgeDeleted(ctx, time.Now().UTC().Add(-w.retention))
+	if err != nil {
+		log.Error("purging deleted users", logger.Err(err))
+
+		return
+	}
+
+	if purged > 0 {
+		log.Info("purged deleted users", slog.Int("count", purged))
+	}
+}
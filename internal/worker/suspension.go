@@ -0,0 +1,52 @@
+package worker
+
+import (
+	"context"
+	"github.com/sorawaslocked/ap2final_base/pkg/logger"
+	"log/slog"
+	"time"
+)
+
+// SuspensionExpiry periodically lifts suspensions whose period has elapsed.
+type SuspensionExpiry struct {
+	periodic
+
+	log      *slog.Logger
+	uc       SuspensionLifter
+	interval time.Duration
+}
+
+func NewSuspensionExpiry(log *slog.Logger, uc SuspensionLifter, interval time.Duration) *SuspensionExpiry {
+	return &SuspensionExpiry{
+		log:      log,
+		uc:       uc,
+		interval: interval,
+	}
+}
+
+func (w *SuspensionExpiry) Start(ctx context.Context) {
+	w.start(ctx, w.interval, w.run)
+}
+
+func (w *SuspensionExpiry) Stop() {
+	w.log.Info("stopping suspension expiry worker")
+
+	w.stop()
+}
+
+func (w *SuspensionExpiry) run(ctx context.Context) {
+	const op = "worker.SuspensionExpiry.run"
+
+	log := w.log.With(slog.String("op", op))
+
+	lifted, err := w.uc.LiftExpiredSuspensions(ctx, time.Now().UTC())
+	if err != nil {
+		log.Error("lifting expired suspensions", logger.Err(err))
+
+		return
+	}
+
+	if lifted > 0 {
+		log.Info("lifted expired suspensions", slog.Int("count", lifted))
+	}
+}
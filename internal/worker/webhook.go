@@ -0,0 +1,171 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"github.com/sorawaslocked/ap2final_base/pkg/logger"
+	"github.com/sorawaslocked/ap2final_user_service/internal/model"
+	"log/slog"
+	"time"
+)
+
+type WebhookDispatcherConfig struct {
+	Interval  time.Duration
+	BatchSize int
+	// Lease is how long a claimed delivery stays hidden from other dispatchers.
+	// It has to be longer than a request may take.
+	Lease time.Duration
+	// MaxAttempts is how often a delivery is tried before it is marked failed.
+	MaxAttempts int
+	MinBackoff  time.Duration
+	MaxBackoff  time.Duration
+	// DisableAfter disables a webhook after that many failed attempts in a row.
+	DisableAfter int
+}
+
+// WebhookDispatcher posts queued deliveries to their webhooks and logs every
+// attempt on the delivery.
+type WebhookDispatcher struct {
+	periodic
+
+	log    *slog.Logger
+	store  WebhookStore
+	sender WebhookSender
+	cfg    WebhookDispatcherConfig
+}
+
+func NewWebhookDispatcher(
+	log *slog.Logger,
+	store WebhookStore,
+	sender WebhookSender,
+	cfg WebhookDispatcherConfig,
+) *WebhookDispatcher {
+	return &WebhookDispatcher{
+		log:    log,
+		store:  store,
+		sender: sender,
+		cfg:    cfg,
+	}
+}
+
+func (w *WebhookDispatcher) Start(ctx context.Context) {
+	w.start(ctx, w.cfg.Interval, w.run)
+}
+
+func (w *WebhookDispatcher) Stop() {
+	w.log.Info("stopping webhook dispatcher")
+
+	w.stop()
+}
+
+func (w *WebhookDispatcher) run(ctx context.Context) {
+	const op = "worker.WebhookDispatcher.run"
+
+	log := w.log.With(slog.String("op", op))
+
+	for range w.cfg.BatchSize {
+		if ctx.Err() != nil {
+			return
+		}
+
+		delivery, err := w.store.ClaimDelivery(ctx, time.Now().UTC(), w.cfg.Lease)
+		if errors.Is(err, model.ErrNotFound) {
+			return
+		}
+		if err != nil {
+			log.Error("claiming webhook delivery", logger.Err(err))
+
+			return
+		}
+
+		w.deliver(ctx, log, delivery)
+	}
+}
+
+func (w *WebhookDispatcher) deliver(ctx context.Context, log *slog.Logger, delivery model.WebhookDelivery) {
+	log = log.With(
+		slog.String("deliveryID", delivery.ID),
+		slog.String("webhookID", delivery.WebhookID),
+		slog.String("eventType", delivery.EventType),
+	)
+
+	webhook, err := w.store.FindOne(ctx, model.WebhookFilter{ID: &delivery.WebhookID})
+	if err != nil && !errors.Is(err, model.ErrNotFound) {
+		log.Error("finding webhook", logger.Err(err))
+
+		return
+	}
+
+	now := time.Now().UTC()
+
+	// Deliveries of disabled webhooks fail without a request; re-enabling a
+	// webhook only affects new events.
+	if err != nil || !webhook.Enabled {
+		attempt := model.WebhookAttempt{AttemptedAt: now, Error: "webhook is disabled"}
+		w.record(ctx, log, delivery, attempt, model.WebhookDeliveryFailed, now)
+
+		return
+	}
+
+	statusCode, sendErr := w.sender.Send(ctx, webhook, delivery)
+
+	attempt := model.WebhookAttempt{
+		AttemptedAt: now,
+		StatusCode:  statusCode,
+		Duration:    time.Since(now),
+	}
+
+	if sendErr == nil {
+		w.record(ctx, log, delivery, attempt, model.WebhookDeliveryDelivered, now)
+
+		if err = w.store.RecordSuccess(ctx, webhook.ID); err != nil {
+			log.Error("resetting webhook failures", logger.Err(err))
+		}
+
+		return
+	}
+
+	attempt.Error = sendErr.Error()
+
+	status := model.WebhookDeliveryPending
+	retryAt := now.Add(backoff(w.cfg.MinBackoff, w.cfg.MaxBackoff, delivery.Attempts))
+
+	if delivery.Attempts+1 >= w.cfg.MaxAttempts {
+		status = model.WebhookDeliveryFailed
+	}
+
+	log.Warn(
+		"delivering webhook",
+		logger.Err(sendErr),
+		slog.Int("statusCode", statusCode),
+		slog.Int("attempts", delivery.Attempts+1),
+		slog.String("status", status),
+	)
+
+	w.record(ctx, log, delivery, attempt, status, retryAt)
+
+	disabled, err := w.store.RecordFailure(ctx, webhook.ID, w.cfg.DisableAfter, now)
+	if err != nil {
+		log.Error("counting webhook failure", logger.Err(err))
+
+		return
+	}
+
+	if disabled {
+		log.Warn("disabled failing webhook", slog.Int("failures", w.cfg.DisableAfter))
+	}
+}
+
+// record saves the attempt. If it fails the delivery is retried after the lease.
+func (w *WebhookDispatcher) record(
+	ctx context.Context,
+	log *slog.Logger,
+	delivery model.WebhookDelivery,
+	attempt model.WebhookAttempt,
+	status string,
+	nextAttemptAt time.Time,
+) {
+	if err := w.store.RecordAttempt(ctx, delivery.ID, attempt, status, nextAttemptAt); err != nil {
+		log.Error("recording webhook attempt", logger.Err(err))
+	}
+}
@@ -0,0 +1,219 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"github.com/sorawaslocked/ap2final_user_service/internal/model"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+// fakeWebhookStore keeps one webhook and a queue of deliveries. Like the
+// mongo store it disables the webhook once RecordFailure reaches the limit.
+type fakeWebhookStore struct {
+	webhook    model.Webhook
+	deliveries []model.WebhookDelivery
+	attempts   []recordedAttempt
+	successes  int
+}
+
+type recordedAttempt struct {
+	deliveryID    string
+	attempt       model.WebhookAttempt
+	status        string
+	nextAttemptAt time.Time
+}
+
+func (s *fakeWebhookStore) FindOne(_ context.Context, filter model.WebhookFilter) (model.Webhook, error) {
+	if filter.ID == nil || *filter.ID != s.webhook.ID {
+		return model.Webhook{}, model.ErrNotFound
+	}
+
+	return s.webhook, nil
+}
+
+func (s *fakeWebhookStore) ClaimDelivery(context.Context, time.Time, time.Duration) (model.WebhookDelivery, error) {
+	if len(s.deliveries) == 0 {
+		return model.WebhookDelivery{}, model.ErrNotFound
+	}
+
+	delivery := s.deliveries[0]
+	s.deliveries = s.deliveries[1:]
+
+	return delivery, nil
+}
+
+func (s *fakeWebhookStore) RecordAttempt(
+	_ context.Context,
+	id string,
+	attempt model.WebhookAttempt,
+	status string,
+	nextAttemptAt time.Time,
+) error {
+	s.attempts = append(s.attempts, recordedAttempt{id, attempt, status, nextAttemptAt})
+
+	return nil
+}
+
+func (s *fakeWebhookStore) RecordSuccess(context.Context, string) error {
+	s.successes++
+	s.webhook.ConsecutiveFailures = 0
+
+	return nil
+}
+
+func (s *fakeWebhookStore) RecordFailure(_ context.Context, _ string, disableAfter int, now time.Time) (bool, error) {
+	s.webhook.ConsecutiveFailures++
+
+	if s.webhook.Enabled && s.webhook.ConsecutiveFailures >= disableAfter {
+		s.webhook.Enabled = false
+		s.webhook.DisabledAt = now
+
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// fakeWebhookSender answers every delivery with statusCode and err.
+type fakeWebhookSender struct {
+	statusCode int
+	err        error
+	sent       int
+}
+
+func (s *fakeWebhookSender) Send(context.Context, model.Webhook, model.WebhookDelivery) (int, error) {
+	s.sent++
+
+	return s.statusCode, s.err
+}
+
+func newTestDispatcher(store *fakeWebhookStore, sender *fakeWebhookSender) *WebhookDispatcher {
+	return NewWebhookDispatcher(
+		slog.New(slog.NewTextHandler(io.Discard, nil)),
+		store,
+		sender,
+		WebhookDispatcherConfig{
+			BatchSize:    10,
+			Lease:        time.Minute,
+			MaxAttempts:  3,
+			MinBackoff:   time.Second,
+			MaxBackoff:   time.Minute,
+			DisableAfter: 2,
+		},
+	)
+}
+
+func TestWebhookDispatcherDelivers(t *testing.T) {
+	store := &fakeWebhookStore{
+		webhook:    model.Webhook{ID: "webhook-1", Enabled: true, ConsecutiveFailures: 1},
+		deliveries: []model.WebhookDelivery{{ID: "delivery-1", WebhookID: "webhook-1"}},
+	}
+	sender := &fakeWebhookSender{statusCode: 200}
+
+	newTestDispatcher(store, sender).run(context.Background())
+
+	if len(store.attempts) != 1 {
+		t.Fatalf("recorded %d attempts, want 1", len(store.attempts))
+	}
+	if got := store.attempts[0]; got.status != model.WebhookDeliveryDelivered || got.attempt.StatusCode != 200 {
+		t.Errorf("attempt = %+v, want delivered with status 200", got)
+	}
+	if store.successes != 1 || store.webhook.ConsecutiveFailures != 0 {
+		t.Errorf("successes = %d, failures = %d, want 1 and 0", store.successes, store.webhook.ConsecutiveFailures)
+	}
+}
+
+func TestWebhookDispatcherRetriesWithBackoff(t *testing.T) {
+	tests := []struct {
+		name       string
+		attempts   int
+		wantStatus string
+		wantDelay  time.Duration
+	}{
+		{"first failure", 0, model.WebhookDeliveryPending, time.Second},
+		{"second failure", 1, model.WebhookDeliveryPending, 2 * time.Second},
+		{"last attempt", 2, model.WebhookDeliveryFailed, 4 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &fakeWebhookStore{
+				webhook: model.Webhook{ID: "webhook-1", Enabled: true},
+				deliveries: []model.WebhookDelivery{
+					{ID: "delivery-1", WebhookID: "webhook-1", Attempts: tt.attempts},
+				},
+			}
+			sender := &fakeWebhookSender{statusCode: 500, err: errors.New("webhook responded with status 500")}
+
+			before := time.Now().UTC()
+
+			newTestDispatcher(store, sender).run(context.Background())
+
+			if len(store.attempts) != 1 {
+				t.Fatalf("recorded %d attempts, want 1", len(store.attempts))
+			}
+
+			got := store.attempts[0]
+			if got.status != tt.wantStatus {
+				t.Errorf("status = %s, want %s", got.status, tt.wantStatus)
+			}
+			if got.attempt.StatusCode != 500 || got.attempt.Error == "" {
+				t.Errorf("attempt = %+v, want status 500 with an error", got.attempt)
+			}
+
+			delay := got.nextAttemptAt.Sub(before)
+			if delay < tt.wantDelay || delay > tt.wantDelay+time.Second {
+				t.Errorf("next attempt in %s, want about %s", delay, tt.wantDelay)
+			}
+		})
+	}
+}
+
+func TestWebhookDispatcherDisablesFailingWebhook(t *testing.T) {
+	store := &fakeWebhookStore{
+		webhook: model.Webhook{ID: "webhook-1", Enabled: true},
+		deliveries: []model.WebhookDelivery{
+			{ID: "delivery-1", WebhookID: "webhook-1"},
+			{ID: "delivery-2", WebhookID: "webhook-1"},
+			{ID: "delivery-3", WebhookID: "webhook-1"},
+		},
+	}
+	sender := &fakeWebhookSender{err: errors.New("connection refused")}
+
+	newTestDispatcher(store, sender).run(context.Background())
+
+	if store.webhook.Enabled {
+		t.Fatal("webhook is still enabled after reaching DisableAfter failures")
+	}
+	if sender.sent != 2 {
+		t.Errorf("sent %d requests, want 2", sender.sent)
+	}
+	if len(store.attempts) != 3 {
+		t.Fatalf("recorded %d attempts, want 3", len(store.attempts))
+	}
+
+	// The delivery after the webhook was disabled fails without a request.
+	if got := store.attempts[2]; got.status != model.WebhookDeliveryFailed || got.attempt.Error != "webhook is disabled" {
+		t.Errorf("attempt after disabling = %+v, want failed as disabled", got)
+	}
+}
+
+func TestWebhookDispatcherFailsDeliveryOfDeletedWebhook(t *testing.T) {
+	store := &fakeWebhookStore{
+		webhook:    model.Webhook{ID: "webhook-1", Enabled: true},
+		deliveries: []model.WebhookDelivery{{ID: "delivery-1", WebhookID: "webhook-2"}},
+	}
+	sender := &fakeWebhookSender{statusCode: 200}
+
+	newTestDispatcher(store, sender).run(context.Background())
+
+	if sender.sent != 0 {
+		t.Errorf("sent %d requests, want 0", sender.sent)
+	}
+	if len(store.attempts) != 1 || store.attempts[0].status != model.WebhookDeliveryFailed {
+		t.Errorf("attempts = %+v, want one failed attempt", store.attempts)
+	}
+}